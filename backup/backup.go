@@ -0,0 +1,216 @@
+package backup
+
+import (
+	"atodo_go/table"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Version is the archive format written by Export. Import reads this version
+// only; bump it whenever a field changes meaning.
+const Version = 1
+
+var ErrTaskNotFound = errors.New("task not found")
+
+// Archive is a versioned snapshot of a task and everything below it. Task IDs
+// are those of the exporting database and only link entries of the archive.
+type Archive struct {
+	Version    int        `json:"version"`
+	ExportedAt int64      `json:"exported_at"`
+	Root       int        `json:"root"`
+	Tasks      []Task     `json:"tasks"`
+	Relations  []Relation `json:"relations"`
+}
+
+type Task struct {
+	ID                   int             `json:"id"`
+	ParentTask           int             `json:"parent_task"`
+	Name                 string          `json:"name"`
+	Goal                 string          `json:"goal"`
+	Deadline             int64           `json:"deadline"`
+	InWorkTime           bool            `json:"in_work_time"`
+	Status               string          `json:"status"`
+	PositionX            int             `json:"position_x"`
+	PositionY            int             `json:"position_y"`
+	DependencyConstraint string          `json:"dependency_constraint"`
+	SubtaskConstraint    string          `json:"subtask_constraint"`
+	AvailableFrom        int64           `json:"available_from"`
+	Tags                 []Tag           `json:"tags"`
+	Note                 string          `json:"note"`
+	Checklist            []ChecklistItem `json:"checklist"`
+	Triggers             []Typed         `json:"triggers"`
+	AfterEffects         []Typed         `json:"after_effects"`
+	Suspension           *Typed          `json:"suspension"`
+}
+
+type Tag struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type ChecklistItem struct {
+	Text    string `json:"text"`
+	Checked bool   `json:"checked"`
+}
+
+// Typed is a trigger, after-effect or suspension: its type name and the info
+// stored for it.
+type Typed struct {
+	Type string          `json:"type"`
+	Info json.RawMessage `json:"info"`
+}
+
+type Relation struct {
+	ParentTask int `json:"parent_task"`
+	Source     int `json:"source"`
+	Target     int `json:"target"`
+}
+
+func toMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func fromMillis(millis int64) time.Time {
+	if millis == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(millis)
+}
+
+// subtree returns the task and its descendants, parents before children.
+func subtree(ctx context.Context, rootID int) ([]table.Task, error) {
+	tasks, err := table.GetAllTasks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[int][]table.Task)
+	var root *table.Task
+	for i, task := range tasks {
+		if task.ID == rootID {
+			root = &tasks[i]
+			continue
+		}
+		children[task.ParentTask] = append(children[task.ParentTask], task)
+	}
+	if root == nil {
+		return nil, ErrTaskNotFound
+	}
+	ordered := []table.Task{*root}
+	for i := 0; i < len(ordered); i++ {
+		below := children[ordered[i].ID]
+		sort.Slice(below, func(a, b int) bool { return below[a].ID < below[b].ID })
+		ordered = append(ordered, below...)
+	}
+	return ordered, nil
+}
+
+func exportTask(ctx context.Context, task table.Task) (Task, error) {
+	status, _ := task.Status.String()
+	exported := Task{
+		ID:                   task.ID,
+		ParentTask:           task.ParentTask,
+		Name:                 task.Name,
+		Goal:                 task.Goal,
+		Deadline:             task.Deadline.UnixMilli(),
+		InWorkTime:           task.InWorkTime,
+		Status:               status,
+		PositionX:            task.PositionX,
+		PositionY:            task.PositionY,
+		DependencyConstraint: task.DependencyConstraint,
+		SubtaskConstraint:    task.SubtaskConstraint,
+		AvailableFrom:        toMillis(task.AvailableFrom),
+		Tags:                 make([]Tag, 0),
+		Checklist:            make([]ChecklistItem, 0),
+		Triggers:             make([]Typed, 0),
+		AfterEffects:         make([]Typed, 0),
+	}
+	tags, err := table.GetTagsByTaskID(ctx, task.ID)
+	if err != nil {
+		return Task{}, err
+	}
+	for _, tag := range tags {
+		exported.Tags = append(exported.Tags, Tag{Name: tag.Name, Color: tag.Color})
+	}
+	exported.Note, err = table.GetTaskNote(ctx, task.ID)
+	if err != nil {
+		return Task{}, err
+	}
+	items, err := table.GetChecklist(ctx, task.ID)
+	if err != nil {
+		return Task{}, err
+	}
+	for _, item := range items {
+		exported.Checklist = append(exported.Checklist, ChecklistItem{Text: item.Text, Checked: item.Checked})
+	}
+	triggers, err := table.GetTaskTriggersByID(ctx, task.ID)
+	if err != nil {
+		return Task{}, err
+	}
+	for _, trigger := range triggers {
+		name, err := trigger.Type.String()
+		if err != nil {
+			return Task{}, err
+		}
+		exported.Triggers = append(exported.Triggers, Typed{Type: name, Info: json.RawMessage(trigger.Info)})
+	}
+	afterEffects, err := table.GetTaskAfterEffectsByID(ctx, task.ID)
+	if err != nil {
+		return Task{}, err
+	}
+	for _, afterEffect := range afterEffects {
+		name, _ := afterEffect.Type.String()
+		exported.AfterEffects = append(exported.AfterEffects, Typed{Type: name, Info: json.RawMessage(afterEffect.Info)})
+	}
+	if table.IsTaskSuspended(ctx, task.ID) {
+		suspended, err := table.GetSuspendedTask(ctx, task.ID)
+		if err != nil {
+			return Task{}, err
+		}
+		name, err := suspended.Type.String()
+		if err != nil {
+			return Task{}, err
+		}
+		exported.Suspension = &Typed{Type: name, Info: json.RawMessage(suspended.Info)}
+	}
+	return exported, nil
+}
+
+// Export snapshots the task with the ID and all of its subtasks.
+func Export(ctx context.Context, rootID int, now time.Time) (*Archive, error) {
+	tasks, err := subtree(ctx, rootID)
+	if err != nil {
+		return nil, err
+	}
+	archive := &Archive{
+		Version:    Version,
+		ExportedAt: now.UnixMilli(),
+		Root:       rootID,
+		Tasks:      make([]Task, 0, len(tasks)),
+		Relations:  make([]Relation, 0),
+	}
+	for _, task := range tasks {
+		exported, err := exportTask(ctx, task)
+		if err != nil {
+			return nil, err
+		}
+		archive.Tasks = append(archive.Tasks, exported)
+		relations, err := table.GetRelationByParentTask(ctx, task.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, relation := range relations {
+			archive.Relations = append(archive.Relations, Relation{
+				ParentTask: relation.ParentTask,
+				Source:     relation.Source,
+				Target:     relation.Target,
+			})
+		}
+	}
+	return archive, nil
+}
@@ -0,0 +1,400 @@
+package backup
+
+import (
+	"atodo_go/table"
+	"atodo_go/task_show"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Result is what Import did, or would do on a dry run.
+type Result struct {
+	DryRun    bool        `json:"dry_run"`
+	Root      int         `json:"root"`
+	Tasks     int         `json:"tasks"`
+	Relations int         `json:"relations"`
+	IDs       map[int]int `json:"ids"`
+	Warnings  []string    `json:"warnings"`
+}
+
+func parseStatus(name string) (table.TaskStatus, bool) {
+	for status := table.Todo; status <= table.Done; status++ {
+		if statusName, _ := status.String(); statusName == name {
+			return status, true
+		}
+	}
+	return table.Todo, false
+}
+
+func parseTriggerType(name string) (table.TaskTriggerType, bool) {
+	for t := table.Dependency; t <= table.Event; t++ {
+		if typeName, _ := t.String(); typeName == name {
+			return t, true
+		}
+	}
+	return table.Dependency, false
+}
+
+func parseAfterEffectType(name string) (table.AfterEffectType, bool) {
+	for t := table.Periodic; t <= table.Periodic; t++ {
+		if typeName, _ := t.String(); typeName == name {
+			return t, true
+		}
+	}
+	return table.Periodic, false
+}
+
+func parseSuspensionType(name string) (table.SuspendedTaskType, bool) {
+	for t := table.Time; t <= table.Email; t++ {
+		if typeName, _ := t.String(); typeName == name {
+			return t, true
+		}
+	}
+	return table.Time, false
+}
+
+// decodeInfo checks that the info of an entry decodes into the struct of its
+// type.
+func decodeInfo(info json.RawMessage, into any) error {
+	if len(info) == 0 {
+		return fmt.Errorf("info is missing")
+	}
+	return json.Unmarshal(info, into)
+}
+
+// order returns the archive's tasks by ID, parents before children.
+func order(archive *Archive) []Task {
+	children := make(map[int][]Task)
+	var ordered []Task
+	for _, task := range archive.Tasks {
+		if task.ID == archive.Root {
+			ordered = append(ordered, task)
+		} else {
+			children[task.ParentTask] = append(children[task.ParentTask], task)
+		}
+	}
+	for i := 0; i < len(ordered); i++ {
+		ordered = append(ordered, children[ordered[i].ID]...)
+	}
+	return ordered
+}
+
+func validateTask(task Task, byID map[int]Task) ([]string, error) {
+	warnings := make([]string, 0)
+	if _, ok := parseStatus(task.Status); !ok {
+		return nil, fmt.Errorf("unknown status %q", task.Status)
+	}
+	for _, tag := range task.Tags {
+		err := table.ValidateTagName(tag.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, trigger := range task.Triggers {
+		t, ok := parseTriggerType(trigger.Type)
+		if !ok {
+			return nil, fmt.Errorf("unknown trigger type %q", trigger.Type)
+		}
+		switch t {
+		case table.Dependency:
+			var info table.DependencyInfo
+			err := decodeInfo(trigger.Info, &info)
+			if err != nil {
+				return nil, fmt.Errorf("dependency trigger: %w", err)
+			}
+			if _, ok := byID[info.Source]; !ok {
+				warnings = append(warnings, fmt.Sprintf("task %d: dependency on task %d outside the archive is dropped", task.ID, info.Source))
+			}
+		case table.Event:
+			var info table.EventInfo
+			err := decodeInfo(trigger.Info, &info)
+			if err != nil {
+				return nil, fmt.Errorf("event trigger: %w", err)
+			}
+		}
+	}
+	for _, afterEffect := range task.AfterEffects {
+		if _, ok := parseAfterEffectType(afterEffect.Type); !ok {
+			return nil, fmt.Errorf("unknown after-effect type %q", afterEffect.Type)
+		}
+		var info table.PeriodicT
+		err := decodeInfo(afterEffect.Info, &info)
+		if err == nil {
+			err = info.Validate()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("periodic after-effect: %w", err)
+		}
+	}
+	if task.Suspension != nil {
+		t, ok := parseSuspensionType(task.Suspension.Type)
+		if !ok {
+			return nil, fmt.Errorf("unknown suspension type %q", task.Suspension.Type)
+		}
+		var err error
+		if t == table.Time {
+			err = decodeInfo(task.Suspension.Info, &table.SuspendedTimeInfo{})
+		} else {
+			err = decodeInfo(task.Suspension.Info, &table.SuspendedEmailInfo{})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("suspension: %w", err)
+		}
+	}
+	return warnings, nil
+}
+
+// Validate checks that the archive can be imported as a whole and returns
+// warnings about what would be dropped.
+func Validate(archive *Archive) ([]string, error) {
+	if archive.Version != Version {
+		return nil, fmt.Errorf("unsupported archive version %d, expected %d", archive.Version, Version)
+	}
+	byID := make(map[int]Task, len(archive.Tasks))
+	for _, task := range archive.Tasks {
+		if _, ok := byID[task.ID]; ok {
+			return nil, fmt.Errorf("task %d appears twice", task.ID)
+		}
+		byID[task.ID] = task
+	}
+	if _, ok := byID[archive.Root]; !ok {
+		return nil, fmt.Errorf("root task %d is not in the archive", archive.Root)
+	}
+	if len(order(archive)) != len(archive.Tasks) {
+		return nil, fmt.Errorf("some tasks are not below the root task %d", archive.Root)
+	}
+	warnings := make([]string, 0)
+	for _, task := range archive.Tasks {
+		taskWarnings, err := validateTask(task, byID)
+		if err != nil {
+			return nil, fmt.Errorf("task %d: %w", task.ID, err)
+		}
+		warnings = append(warnings, taskWarnings...)
+	}
+	for _, relation := range archive.Relations {
+		source, sourceOK := byID[relation.Source]
+		target, targetOK := byID[relation.Target]
+		_, parentOK := byID[relation.ParentTask]
+		if !sourceOK || !targetOK || !parentOK {
+			return nil, fmt.Errorf("relation %d -> %d refers to tasks outside the archive", relation.Source, relation.Target)
+		}
+		if source.ParentTask != relation.ParentTask || target.ParentTask != relation.ParentTask || relation.Source == relation.Target {
+			return nil, fmt.Errorf("relation %d -> %d does not link two subtasks of task %d", relation.Source, relation.Target, relation.ParentTask)
+		}
+	}
+	return warnings, nil
+}
+
+func parentExists(ctx context.Context, parentID int) (bool, error) {
+	if parentID == -1 {
+		return true, nil
+	}
+	tasks, err := table.GetAllTasks(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, task := range tasks {
+		if task.ID == parentID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func importTags(ctx context.Context, taskID int, tags []Tag) error {
+	for _, tag := range tags {
+		existing, err := table.GetTagByName(ctx, tag.Name)
+		if err != nil {
+			return err
+		}
+		tagID := 0
+		if existing != nil {
+			tagID = existing.ID
+		} else {
+			tagID, err = table.CreateTag(ctx, tag.Name, tag.Color)
+			if err != nil {
+				return err
+			}
+		}
+		err = table.AddTagToTask(ctx, taskID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importDetails(ctx context.Context, task Task, ids map[int]int) error {
+	taskID := ids[task.ID]
+	err := importTags(ctx, taskID, task.Tags)
+	if err != nil {
+		return err
+	}
+	if task.Note != "" {
+		err = table.SetTaskNote(ctx, taskID, task.Note)
+		if err != nil {
+			return err
+		}
+	}
+	if len(task.Checklist) != 0 {
+		items := make([]table.ChecklistItemShow, 0, len(task.Checklist))
+		for _, item := range task.Checklist {
+			items = append(items, table.ChecklistItemShow{Text: item.Text, Checked: item.Checked})
+		}
+		err = table.SetChecklist(ctx, taskID, items)
+		if err != nil {
+			return err
+		}
+	}
+	for _, trigger := range task.Triggers {
+		t, _ := parseTriggerType(trigger.Type)
+		imported := table.TaskTrigger{ID: taskID, Type: t, Info: []byte(trigger.Info)}
+		if t == table.Dependency {
+			var info table.DependencyInfo
+			_ = json.Unmarshal(trigger.Info, &info)
+			source, ok := ids[info.Source]
+			if !ok {
+				continue
+			}
+			err = imported.SetDependencyInfo(source)
+			if err != nil {
+				return err
+			}
+		}
+		err = table.AddOrUpdateTaskTrigger(ctx, imported)
+		if err != nil {
+			return err
+		}
+	}
+	for _, afterEffect := range task.AfterEffects {
+		t, _ := parseAfterEffectType(afterEffect.Type)
+		err = table.AddOrUpdateTaskAfterEffect(ctx, table.TaskAfterEffect{ID: taskID, Type: t, Info: []byte(afterEffect.Info)})
+		if err != nil {
+			return err
+		}
+	}
+	if task.Suspension != nil {
+		t, _ := parseSuspensionType(task.Suspension.Type)
+		err = table.AddOrUpdateSuspendedTask(ctx, table.SuspendedTask{ID: taskID, Type: t, Info: []byte(task.Suspension.Info)})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import adds the archive's tasks below the parent, or as a new workspace for
+// -1, with new IDs. The archive is validated first and written in one
+// transaction, so nothing is kept of an archive that cannot be imported; a
+// dry run stops after validating.
+func Import(ctx context.Context, archive *Archive, parentID int, dryRun bool) (*Result, error) {
+	warnings, err := Validate(archive)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := parentExists(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTaskNotFound
+	}
+	result := &Result{
+		DryRun:    dryRun,
+		Root:      -1,
+		Tasks:     len(archive.Tasks),
+		Relations: len(archive.Relations),
+		IDs:       make(map[int]int, len(archive.Tasks)),
+		Warnings:  warnings,
+	}
+	if dryRun {
+		return result, nil
+	}
+	err = table.Transaction(ctx, func(ctx context.Context) error {
+		return importTasks(ctx, archive, parentID, result.IDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Root = result.IDs[archive.Root]
+	return result, nil
+}
+
+// importTasks writes the archive below the parent and fills ids with the new
+// ID of every archived task.
+func importTasks(ctx context.Context, archive *Archive, parentID int, ids map[int]int) error {
+	ordered := order(archive)
+	for _, task := range ordered {
+		status, _ := parseStatus(task.Status)
+		parent := parentID
+		if task.ID != archive.Root {
+			parent = ids[task.ParentTask]
+		}
+		id := table.AddTask(ctx, table.Task{
+			Name:                 task.Name,
+			Goal:                 task.Goal,
+			Deadline:             time.UnixMilli(task.Deadline),
+			InWorkTime:           task.InWorkTime,
+			Status:               status,
+			ParentTask:           parent,
+			PositionX:            task.PositionX,
+			PositionY:            task.PositionY,
+			DependencyConstraint: task.DependencyConstraint,
+			SubtaskConstraint:    task.SubtaskConstraint,
+			AvailableFrom:        fromMillis(task.AvailableFrom),
+		})
+		if id == -1 {
+			return fmt.Errorf("task %d: failed to add task", task.ID)
+		}
+		ids[task.ID] = id
+	}
+	for _, task := range ordered {
+		err := importDetails(ctx, task, ids)
+		if err != nil {
+			return fmt.Errorf("task %d: %w", task.ID, err)
+		}
+	}
+	for _, relation := range archive.Relations {
+		err := table.AddRelation(ctx, ids[relation.ParentTask], ids[relation.Source], ids[relation.Target])
+		if err != nil {
+			return err
+		}
+	}
+	err := layoutStacked(ctx, archive, ids)
+	if err != nil {
+		return err
+	}
+	return task_show.PlaceTask(ctx, ids[archive.Root])
+}
+
+// layoutStacked lays out the imported subtasks of every task where two of
+// them share a position, as in archives from other tools, which have none.
+func layoutStacked(ctx context.Context, archive *Archive, ids map[int]int) error {
+	positions := make(map[int]map[task_show.Position]bool)
+	stacked := make(map[int]bool)
+	for _, task := range archive.Tasks {
+		if task.ID == archive.Root {
+			continue
+		}
+		if positions[task.ParentTask] == nil {
+			positions[task.ParentTask] = make(map[task_show.Position]bool)
+		}
+		position := task_show.Position{X: task.PositionX, Y: task.PositionY}
+		if positions[task.ParentTask][position] {
+			stacked[task.ParentTask] = true
+		}
+		positions[task.ParentTask][position] = true
+	}
+	for _, task := range order(archive) {
+		if !stacked[task.ID] {
+			continue
+		}
+		_, err := task_show.AutoLayout(ctx, ids[task.ID])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
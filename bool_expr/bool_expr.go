@@ -0,0 +1,172 @@
+package bool_expr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Expr is a boolean expression over subjects of type T.
+type Expr[T any] interface {
+	Match(subject T) bool
+}
+
+type notExpr[T any] struct{ expr Expr[T] }
+type andExpr[T any] struct{ left, right Expr[T] }
+type orExpr[T any] struct{ left, right Expr[T] }
+
+func (e notExpr[T]) Match(subject T) bool { return !e.expr.Match(subject) }
+func (e andExpr[T]) Match(subject T) bool { return e.left.Match(subject) && e.right.Match(subject) }
+func (e orExpr[T]) Match(subject T) bool  { return e.left.Match(subject) || e.right.Match(subject) }
+
+// Syntax describes an expression language. The keywords are matched without
+// case, and the runes of Operators are tokens of their own when they start a
+// token, even without a space after them, so terms may still contain them.
+type Syntax struct {
+	// Name is how errors refer to the expression, e.g. "query".
+	Name      string
+	And       []string
+	Or        []string
+	Not       []string
+	Operators string
+}
+
+// Parse parses terms combined with the keywords of the syntax and
+// parentheses; adjacent terms are joined with and. Every other token is
+// turned into a term by term.
+func Parse[T any](syntax Syntax, text string, term func(token string) (Expr[T], error)) (Expr[T], error) {
+	p := &parser[T]{syntax: syntax, tokens: tokenize(text, syntax.Operators), term: term}
+	if len(p.tokens) == 0 {
+		return nil, errors.New("empty " + syntax.Name)
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in %s", p.peek(), syntax.Name)
+	}
+	return expr, nil
+}
+
+func tokenize(text string, operators string) []string {
+	tokens := make([]string, 0)
+	current := strings.Builder{}
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || (current.Len() == 0 && strings.ContainsRune(operators, r)):
+			flush()
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+func isKeyword(token string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.EqualFold(token, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+type parser[T any] struct {
+	syntax Syntax
+	tokens []string
+	pos    int
+	term   func(token string) (Expr[T], error)
+}
+
+func (p *parser[T]) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser[T]) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *parser[T]) parseOr() (Expr[T], error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for isKeyword(p.peek(), p.syntax.Or) {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr[T]{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser[T]) parseAnd() (Expr[T], error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		token := p.peek()
+		if token == "" || token == ")" || isKeyword(token, p.syntax.Or) {
+			return left, nil
+		}
+		if isKeyword(token, p.syntax.And) {
+			p.next()
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr[T]{left: left, right: right}
+	}
+}
+
+func (p *parser[T]) parseNot() (Expr[T], error) {
+	if isKeyword(p.peek(), p.syntax.Not) {
+		p.next()
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr[T]{expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser[T]) parsePrimary() (Expr[T], error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, errors.New("unexpected end of " + p.syntax.Name)
+	case token == "(":
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, errors.New("missing ) in " + p.syntax.Name)
+		}
+		return expr, nil
+	case token == ")" || isKeyword(token, p.syntax.And) || isKeyword(token, p.syntax.Or):
+		return nil, fmt.Errorf("unexpected %q in %s", token, p.syntax.Name)
+	}
+	return p.term(token)
+}
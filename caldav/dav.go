@@ -0,0 +1,375 @@
+package caldav
+
+import (
+	"atodo_go/table"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	Prefix         = "/caldav/"
+	PrincipalPath  = Prefix + "principal/"
+	CollectionPath = Prefix + "tasks/"
+)
+
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	nsCS     = "http://calendarserver.org/ns/"
+)
+
+const ContentType = "text/calendar; charset=utf-8; component=VTODO"
+
+var ErrUnsupportedReport = errors.New("unsupported report")
+
+var prefixes = map[string]string{nsDAV: "D", nsCalDAV: "C", nsCS: "CS"}
+
+// node is a generic XML element of a request body.
+type node struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content string     `xml:",chardata"`
+	Nodes   []node     `xml:",any"`
+}
+
+func (n node) child(space string, local string) *node {
+	for i := range n.Nodes {
+		if n.Nodes[i].XMLName.Space == space && n.Nodes[i].XMLName.Local == local {
+			return &n.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// find returns every element with the name below n.
+func (n node) find(space string, local string) []node {
+	found := make([]node, 0)
+	for _, child := range n.Nodes {
+		if child.XMLName.Space == space && child.XMLName.Local == local {
+			found = append(found, child)
+		}
+		found = append(found, child.find(space, local)...)
+	}
+	return found
+}
+
+func (n node) attr(local string) string {
+	for _, attr := range n.Attrs {
+		if attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func parseBody(body []byte) (*node, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil
+	}
+	var root node
+	err := xml.Unmarshal(body, &root)
+	if err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// requested returns the properties asked for in a prop element, or nil for
+// all of them.
+func requested(parent *node) []xml.Name {
+	if parent == nil {
+		return nil
+	}
+	prop := parent.child(nsDAV, "prop")
+	if prop == nil {
+		return nil
+	}
+	names := make([]xml.Name, 0, len(prop.Nodes))
+	for _, child := range prop.Nodes {
+		names = append(names, child.XMLName)
+	}
+	return names
+}
+
+func escape(text string) string {
+	var buffer bytes.Buffer
+	_ = xml.EscapeText(&buffer, []byte(text))
+	return buffer.String()
+}
+
+func element(name xml.Name, inner string) string {
+	prefix, ok := prefixes[name.Space]
+	open := prefix + ":" + name.Local
+	if !ok {
+		open = "X:" + name.Local + ` xmlns:X="` + escape(name.Space) + `"`
+		prefix = "X"
+	}
+	if inner == "" {
+		return "<" + open + "/>"
+	}
+	return "<" + open + ">" + inner + "</" + prefix + ":" + name.Local + ">"
+}
+
+func href(path string) string {
+	return element(xml.Name{Space: nsDAV, Local: "href"}, escape(path))
+}
+
+func statusLine(code int) string {
+	return "HTTP/1.1 " + strconv.Itoa(code) + " " + http.StatusText(code)
+}
+
+// properties maps property names to their values as inner XML.
+type properties map[xml.Name]string
+
+func dav(local string) xml.Name {
+	return xml.Name{Space: nsDAV, Local: local}
+}
+
+func cal(local string) xml.Name {
+	return xml.Name{Space: nsCalDAV, Local: local}
+}
+
+type multistatus struct {
+	builder strings.Builder
+}
+
+func newMultistatus() *multistatus {
+	status := &multistatus{}
+	status.builder.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	status.builder.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="` + nsCalDAV + `" xmlns:CS="` + nsCS + `">`)
+	return status
+}
+
+func propstat(props string, code int) string {
+	return element(dav("propstat"), element(dav("prop"), props)+element(dav("status"), statusLine(code)))
+}
+
+// addProps answers a request for the names, or for every property but
+// calendar-data when names is nil, like allprop.
+func (status *multistatus) addProps(path string, available properties, names []xml.Name) {
+	var found, missing strings.Builder
+	if names == nil {
+		for name, value := range available {
+			if name != cal("calendar-data") {
+				found.WriteString(element(name, value))
+			}
+		}
+	}
+	for _, name := range names {
+		value, ok := available[name]
+		if ok {
+			found.WriteString(element(name, value))
+		} else {
+			missing.WriteString(element(name, ""))
+		}
+	}
+	inner := href(path)
+	if found.Len() != 0 || missing.Len() == 0 {
+		inner += propstat(found.String(), http.StatusOK)
+	}
+	if missing.Len() != 0 {
+		inner += propstat(missing.String(), http.StatusNotFound)
+	}
+	status.builder.WriteString(element(dav("response"), inner))
+}
+
+func (status *multistatus) addStatus(path string, code int) {
+	status.builder.WriteString(element(dav("response"), href(path)+element(dav("status"), statusLine(code))))
+}
+
+func (status *multistatus) bytes(syncToken string) []byte {
+	if syncToken != "" {
+		status.builder.WriteString(element(dav("sync-token"), escape(syncToken)))
+	}
+	status.builder.WriteString("</D:multistatus>\n")
+	return []byte(status.builder.String())
+}
+
+func principalProps() properties {
+	return properties{
+		dav("current-user-principal"): href(PrincipalPath),
+		cal("calendar-home-set"):      href(Prefix),
+	}
+}
+
+func rootProps() properties {
+	props := principalProps()
+	props[dav("resourcetype")] = element(dav("collection"), "")
+	props[dav("displayname")] = "atodo"
+	return props
+}
+
+func userProps(ctx context.Context) properties {
+	name := "atodo"
+	user, err := table.GetUserByID(ctx, table.CurrentUserID(ctx))
+	if err == nil && user.Name != "" {
+		name = user.Name
+	}
+	props := principalProps()
+	props[dav("resourcetype")] = element(dav("collection"), "") + element(dav("principal"), "")
+	props[dav("displayname")] = escape(name)
+	props[dav("principal-URL")] = href(PrincipalPath)
+	return props
+}
+
+func collectionProps(token string) properties {
+	props := principalProps()
+	reports := ""
+	for _, report := range []xml.Name{cal("calendar-query"), cal("calendar-multiget"), dav("sync-collection")} {
+		reports += element(dav("supported-report"), element(dav("report"), element(report, "")))
+	}
+	privileges := ""
+	for _, privilege := range []string{"read", "write", "write-content", "bind", "unbind"} {
+		privileges += element(dav("privilege"), element(dav(privilege), ""))
+	}
+	props[dav("resourcetype")] = element(dav("collection"), "") + element(cal("calendar"), "")
+	props[dav("displayname")] = "atodo tasks"
+	props[cal("supported-calendar-component-set")] = `<C:comp name="VTODO"/>`
+	props[dav("supported-report-set")] = reports
+	props[dav("current-user-privilege-set")] = privileges
+	props[dav("sync-token")] = escape(token)
+	props[xml.Name{Space: nsCS, Local: "getctag"}] = escape(token)
+	return props
+}
+
+func (resource Resource) props() properties {
+	props := properties{
+		dav("resourcetype"):   "",
+		dav("getetag"):        escape(resource.ETag()),
+		dav("getcontenttype"): escape(ContentType),
+		cal("calendar-data"):  escape(resource.Data()),
+	}
+	if !resource.Version.ModifiedAt.IsZero() {
+		props[dav("getlastmodified")] = resource.Version.ModifiedAt.UTC().Format(http.TimeFormat)
+	}
+	return props
+}
+
+// Propfind answers a PROPFIND on the path. Depth 0 describes the path only,
+// any other depth its members too.
+func Propfind(ctx context.Context, path string, depth string, body []byte) ([]byte, error) {
+	request, err := parseBody(body)
+	if err != nil {
+		return nil, err
+	}
+	names := requested(request)
+	status := newMultistatus()
+	switch path {
+	case Prefix:
+		status.addProps(Prefix, rootProps(), names)
+		if depth != "0" {
+			token, err := SyncToken(ctx)
+			if err != nil {
+				return nil, err
+			}
+			status.addProps(CollectionPath, collectionProps(token), names)
+		}
+	case PrincipalPath:
+		status.addProps(PrincipalPath, userProps(ctx), names)
+	case CollectionPath:
+		token, err := SyncToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		status.addProps(CollectionPath, collectionProps(token), names)
+		if depth != "0" {
+			resources, err := Resources(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, resource := range resources {
+				status.addProps(CollectionPath+resource.Name, resource.props(), names)
+			}
+		}
+	default:
+		if !strings.HasPrefix(path, CollectionPath) {
+			return nil, ErrNotFound
+		}
+		resource, err := Lookup(ctx, strings.TrimPrefix(path, CollectionPath))
+		if err != nil {
+			return nil, err
+		}
+		status.addProps(path, resource.props(), names)
+	}
+	return status.bytes(""), nil
+}
+
+// wantsOnlyEvents reports whether a calendar-query filters for components
+// other than VTODO, which this collection does not hold.
+func wantsOnlyEvents(request node) bool {
+	filters := request.find(nsCalDAV, "comp-filter")
+	for _, filter := range filters {
+		name := strings.ToUpper(filter.attr("name"))
+		if name != "VCALENDAR" && name != "VTODO" {
+			return true
+		}
+	}
+	return false
+}
+
+// Report answers calendar-query, calendar-multiget and sync-collection
+// reports on the task collection.
+func Report(ctx context.Context, path string, body []byte) ([]byte, error) {
+	if path != CollectionPath {
+		return nil, ErrUnsupportedReport
+	}
+	request, err := parseBody(body)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, ErrUnsupportedReport
+	}
+	names := requested(request)
+	status := newMultistatus()
+	switch request.XMLName {
+	case cal("calendar-query"):
+		if wantsOnlyEvents(*request) {
+			return status.bytes(""), nil
+		}
+		resources, err := Resources(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range resources {
+			status.addProps(CollectionPath+resource.Name, resource.props(), names)
+		}
+		return status.bytes(""), nil
+	case cal("calendar-multiget"):
+		for _, requestedHref := range request.find(nsDAV, "href") {
+			target := strings.TrimSpace(requestedHref.Content)
+			resource, err := Lookup(ctx, strings.TrimPrefix(target, CollectionPath))
+			if errors.Is(err, ErrNotFound) || !strings.HasPrefix(target, CollectionPath) {
+				status.addStatus(target, http.StatusNotFound)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			status.addProps(target, resource.props(), names)
+		}
+		return status.bytes(""), nil
+	case dav("sync-collection"):
+		token := ""
+		if tokenNode := request.child(nsDAV, "sync-token"); tokenNode != nil {
+			token = strings.TrimSpace(tokenNode.Content)
+		}
+		changed, removed, current, err := Changes(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range changed {
+			status.addProps(CollectionPath+resource.Name, resource.props(), names)
+		}
+		for _, name := range removed {
+			status.addStatus(CollectionPath+name, http.StatusNotFound)
+		}
+		return status.bytes(current), nil
+	}
+	return nil, ErrUnsupportedReport
+}
@@ -0,0 +1,255 @@
+package caldav
+
+import (
+	"atodo_go/calendar"
+	"atodo_go/table"
+	"atodo_go/task_show"
+	"context"
+	"errors"
+	"strings"
+)
+
+// fields are the task fields a VTODO carries.
+type fields struct {
+	uid       string
+	name      string
+	hasName   bool
+	goal      string
+	deadline  int64
+	status    string
+	parentUID string
+}
+
+func readFields(data []byte) (*fields, error) {
+	object, err := calendar.Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if object.Name != "VCALENDAR" {
+		return nil, ErrUnsupported
+	}
+	todo := object.Find("VTODO")
+	if todo == nil {
+		return nil, ErrUnsupported
+	}
+	read := &fields{}
+	if property := todo.Get("UID"); property != nil {
+		read.uid = strings.TrimSpace(property.Value)
+	}
+	if read.uid == "" {
+		return nil, errors.New("VTODO has no UID")
+	}
+	if property := todo.Get("SUMMARY"); property != nil {
+		read.name = calendar.Unescape(property.Value)
+		read.hasName = true
+	}
+	if property := todo.Get("DESCRIPTION"); property != nil {
+		read.goal = calendar.Unescape(property.Value)
+	}
+	if property := todo.Get("DUE"); property != nil {
+		due, err := property.Time()
+		if err != nil {
+			return nil, err
+		}
+		read.deadline = due.UnixMilli()
+	}
+	if property := todo.Get("STATUS"); property != nil {
+		read.status = strings.ToUpper(property.Value)
+	}
+	for _, property := range todo.Properties {
+		relation := property.Params["RELTYPE"]
+		if property.Name == "RELATED-TO" && (relation == "" || strings.EqualFold(relation, "PARENT")) {
+			read.parentUID = strings.TrimSpace(property.Value)
+		}
+	}
+	return read, nil
+}
+
+func requireEditor(ctx context.Context, taskID int) error {
+	ok, err := table.HasWorkspaceRole(ctx, table.CurrentUserID(ctx), taskID, table.RoleEditor)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return table.ErrForbidden
+	}
+	return nil
+}
+
+// parentOf resolves RELATED-TO to a task the user may add to. Tasks without
+// a parent go below the task being viewed, like tasks added in the app.
+func parentOf(ctx context.Context, read *fields, index *index) (int, error) {
+	if read.parentUID == "" {
+		return table.GetNowViewingTask(ctx)
+	}
+	parent := index.lookupUID(read.parentUID)
+	if parent == -1 || !index.visible(ctx, parent) {
+		return -1, errors.New("unknown parent task: " + read.parentUID)
+	}
+	return parent, nil
+}
+
+// isAncestor reports whether ancestor is taskID or above it.
+func (index *index) isAncestor(ancestor int, taskID int) bool {
+	visited := make(map[int]bool)
+	for id := taskID; id != -1 && !visited[id]; id = index.tasks[id].ParentTask {
+		if id == ancestor {
+			return true
+		}
+		visited[id] = true
+		if _, ok := index.tasks[id]; !ok {
+			return false
+		}
+	}
+	return false
+}
+
+func applyStatus(ctx context.Context, task table.Task, status string) error {
+	switch {
+	case status == "COMPLETED" && task.Status != table.Done:
+		return table.CompleteTask(ctx, task.ID)
+	case status == "NEEDS-ACTION" && task.Status == table.Done:
+		return table.UpdateTaskStatus(ctx, task.ID, table.Todo)
+	}
+	return nil
+}
+
+func update(ctx context.Context, task table.Task, read *fields, index *index) error {
+	// clients that drop SUMMARY keep the task's name
+	if read.hasName {
+		err := table.UpdateTaskName(ctx, task.ID, read.name)
+		if err != nil {
+			return err
+		}
+	}
+	err := table.UpdateTaskGoal(ctx, task.ID, read.goal)
+	if err != nil {
+		return err
+	}
+	if read.deadline != task.Deadline.UnixMilli() {
+		err = table.UpdateTaskDeadline(ctx, task.ID, read.deadline)
+		if err != nil {
+			return err
+		}
+	}
+	// clients that drop RELATED-TO leave the task where it is
+	if read.parentUID != "" {
+		parent, err := parentOf(ctx, read, index)
+		if err != nil {
+			return err
+		}
+		if parent != task.ParentTask {
+			if index.isAncestor(task.ID, parent) {
+				return errors.New("a task cannot be moved below itself")
+			}
+			err = requireEditor(ctx, parent)
+			if err != nil {
+				return err
+			}
+			err = table.UpdateTaskParentTask(ctx, task.ID, parent)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return applyStatus(ctx, task, read.status)
+}
+
+func create(ctx context.Context, name string, read *fields, index *index) (int, error) {
+	if index.lookupUID(read.uid) != -1 {
+		return -1, ErrUIDConflict
+	}
+	parent, err := parentOf(ctx, read, index)
+	if err != nil {
+		return -1, err
+	}
+	if parent != -1 {
+		err = requireEditor(ctx, parent)
+		if err != nil {
+			return -1, err
+		}
+	}
+	taskID, err := table.CreateTaskUnder(ctx, parent, read.name, read.goal, read.deadline, false)
+	if err != nil {
+		return -1, err
+	}
+	err = task_show.PlaceTask(ctx, taskID)
+	if err != nil {
+		return -1, err
+	}
+	err = table.SaveCaldavResource(ctx, table.CaldavResource{TaskID: taskID, Name: name, UID: read.uid})
+	if err != nil {
+		return -1, err
+	}
+	task, err := table.GetTaskByID(ctx, taskID)
+	if err != nil {
+		return -1, err
+	}
+	return taskID, applyStatus(ctx, task, read.status)
+}
+
+// Put creates or updates the task served under the name from a VTODO and
+// returns the stored resource and whether it was created. ifMatch and
+// ifNoneMatch are the request's conditional headers.
+func Put(ctx context.Context, name string, data []byte, ifMatch string, ifNoneMatch string) (*Resource, bool, error) {
+	read, err := readFields(data)
+	if err != nil {
+		return nil, false, err
+	}
+	index, err := loadIndex(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	taskID := index.lookupName(name)
+	if _, ok := index.tasks[taskID]; !ok {
+		taskID = -1
+	}
+	created := taskID == -1
+	if created {
+		if ifMatch != "" {
+			return nil, false, ErrPreconditionFailed
+		}
+		taskID, err = create(ctx, name, read, index)
+		if err != nil {
+			return nil, false, err
+		}
+	} else {
+		if !index.visible(ctx, taskID) {
+			return nil, false, ErrNotFound
+		}
+		resource := index.resource(taskID)
+		if ifNoneMatch == "*" || (ifMatch != "" && !matchesETag(ifMatch, resource.ETag())) {
+			return nil, false, ErrPreconditionFailed
+		}
+		err = requireEditor(ctx, taskID)
+		if err != nil {
+			return nil, false, err
+		}
+		err = update(ctx, resource.Task, read, index)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	index, err = loadIndex(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	resource := index.resource(taskID)
+	return &resource, created, nil
+}
+
+// Delete eliminates the task served under the name, with its subtasks.
+func Delete(ctx context.Context, name string, ifMatch string) error {
+	resource, err := Lookup(ctx, name)
+	if err != nil {
+		return err
+	}
+	if ifMatch != "" && !matchesETag(ifMatch, resource.ETag()) {
+		return ErrPreconditionFailed
+	}
+	err = requireEditor(ctx, resource.Task.ID)
+	if err != nil {
+		return err
+	}
+	return table.EliminateTask(ctx, resource.Task.ID)
+}
@@ -0,0 +1,347 @@
+package caldav
+
+import (
+	"atodo_go/calendar"
+	"atodo_go/table"
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	uidPrefix   = "task-"
+	uidSuffix   = "@atodo"
+	nameSuffix  = ".ics"
+	tokenPrefix = "urn:atodo:sync:"
+	productID   = "-//atodo//atodo caldav//EN"
+)
+
+var (
+	ErrNotFound           = errors.New("resource not found")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrInvalidSyncToken   = errors.New("invalid sync token")
+	ErrUIDConflict        = errors.New("another resource already uses this UID")
+	ErrUnsupported        = errors.New("only VTODO resources are supported")
+)
+
+// Resource is a task served as a VTODO.
+type Resource struct {
+	Task      table.Task
+	Name      string
+	UID       string
+	ParentUID string
+	Version   table.TaskVersion
+}
+
+// index resolves task IDs to resource names and UIDs and back.
+type index struct {
+	resources map[int]table.CaldavResource
+	versions  map[int]table.TaskVersion
+	tasks     map[int]table.Task
+}
+
+func loadIndex(ctx context.Context) (*index, error) {
+	resources, err := table.GetCaldavResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := table.GetTaskVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := table.GetAllTasks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]table.Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+	return &index{resources: resources, versions: versions, tasks: byID}, nil
+}
+
+func (index *index) name(taskID int) string {
+	resource, ok := index.resources[taskID]
+	if ok {
+		return resource.Name
+	}
+	return strconv.Itoa(taskID) + nameSuffix
+}
+
+func (index *index) uid(taskID int) string {
+	resource, ok := index.resources[taskID]
+	if ok && !resource.Deleted {
+		return resource.UID
+	}
+	return uidPrefix + strconv.Itoa(taskID) + uidSuffix
+}
+
+// visible reports whether the task exists and the current user can view it.
+func (index *index) visible(ctx context.Context, taskID int) bool {
+	if _, ok := index.tasks[taskID]; !ok {
+		return false
+	}
+	ok, err := table.HasWorkspaceRole(ctx, table.CurrentUserID(ctx), taskID, table.RoleViewer)
+	return err == nil && ok
+}
+
+func (index *index) resource(taskID int) Resource {
+	task := index.tasks[taskID]
+	resource := Resource{
+		Task:    task,
+		Name:    index.name(taskID),
+		UID:     index.uid(taskID),
+		Version: index.versions[taskID],
+	}
+	if _, ok := index.tasks[task.ParentTask]; ok && task.ParentTask != -1 {
+		resource.ParentUID = index.uid(task.ParentTask)
+	}
+	return resource
+}
+
+// lookupName returns the task served under the resource name, or -1.
+func (index *index) lookupName(name string) int {
+	for taskID, resource := range index.resources {
+		if resource.Name == name && !resource.Deleted {
+			return taskID
+		}
+	}
+	if !strings.HasSuffix(name, nameSuffix) {
+		return -1
+	}
+	taskID, err := strconv.Atoi(strings.TrimSuffix(name, nameSuffix))
+	if err != nil {
+		return -1
+	}
+	if resource, ok := index.resources[taskID]; ok && !resource.Deleted {
+		// the task is served under the name its client chose
+		return -1
+	}
+	return taskID
+}
+
+// lookupUID returns the task with the UID, or -1.
+func (index *index) lookupUID(uid string) int {
+	for taskID, resource := range index.resources {
+		if resource.UID == uid && !resource.Deleted {
+			return taskID
+		}
+	}
+	if !strings.HasPrefix(uid, uidPrefix) || !strings.HasSuffix(uid, uidSuffix) {
+		return -1
+	}
+	taskID, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(uid, uidPrefix), uidSuffix))
+	if err != nil {
+		return -1
+	}
+	if _, ok := index.tasks[taskID]; !ok {
+		return -1
+	}
+	return taskID
+}
+
+// ETag changes whenever the task is audited.
+func (resource Resource) ETag() string {
+	return `"` + strconv.Itoa(resource.Task.ID) + "-" + strconv.Itoa(resource.Version.AuditID) + `"`
+}
+
+func status(task table.Task) string {
+	switch task.Status {
+	case table.Done:
+		return "COMPLETED"
+	case table.Suspended:
+		return "IN-PROCESS"
+	}
+	return "NEEDS-ACTION"
+}
+
+// Data renders the task as a VCALENDAR holding one VTODO.
+func (resource Resource) Data() string {
+	task := resource.Task
+	modified := resource.Version.ModifiedAt
+	if modified.IsZero() {
+		modified = time.Unix(0, 0)
+	}
+	todo := calendar.Component{Name: "VTODO"}
+	todo.Add("UID", resource.UID)
+	todo.Add("DTSTAMP", calendar.UTC(modified))
+	todo.Add("LAST-MODIFIED", calendar.UTC(modified))
+	todo.Add("SUMMARY", calendar.Text(task.Name))
+	if task.Goal != "" {
+		todo.Add("DESCRIPTION", calendar.Text(task.Goal))
+	}
+	if task.Deadline.UnixMilli() > 0 {
+		todo.Add("DUE", calendar.UTC(task.Deadline))
+	}
+	todo.Add("STATUS", status(task))
+	if resource.ParentUID != "" {
+		todo.Add("RELATED-TO", resource.ParentUID)
+	}
+	object := calendar.Component{Name: "VCALENDAR"}
+	object.Add("VERSION", "2.0")
+	object.Add("PRODID", productID)
+	object.Components = []calendar.Component{todo}
+	return object.String()
+}
+
+// reconcile records which tasks the current user can view now, stamping
+// every change with latest, and returns the visibilities by task ID. Sharing
+// and unsharing a workspace is audited, so access changes advance the sync
+// token like task changes do.
+func (index *index) reconcile(ctx context.Context, latest int) (map[int]table.CaldavVisibility, error) {
+	userID := table.CurrentUserID(ctx)
+	visibilities, err := table.GetCaldavVisibilities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	taskIDs := make([]int, 0, len(index.tasks)+len(visibilities))
+	for taskID := range index.tasks {
+		taskIDs = append(taskIDs, taskID)
+	}
+	for taskID := range visibilities {
+		if _, ok := index.tasks[taskID]; !ok {
+			taskIDs = append(taskIDs, taskID)
+		}
+	}
+	for _, taskID := range taskIDs {
+		visible := index.visible(ctx, taskID)
+		visibility, ok := visibilities[taskID]
+		if visibility.Visible == visible && (ok || !visible) {
+			continue
+		}
+		visibility = table.CaldavVisibility{UserID: userID, TaskID: taskID, Visible: visible, ChangedAt: latest}
+		err = table.SaveCaldavVisibility(ctx, visibility)
+		if err != nil {
+			return nil, err
+		}
+		visibilities[taskID] = visibility
+	}
+	return visibilities, nil
+}
+
+// snapshot loads the index and reconciles the current user's visibilities
+// with it in one transaction, so the latest audit log entry it returns covers
+// exactly the state it saw.
+func snapshot(ctx context.Context) (*index, map[int]table.CaldavVisibility, int, error) {
+	var loaded *index
+	var visibilities map[int]table.CaldavVisibility
+	latest := 0
+	err := table.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		latest, err = table.GetLatestAuditID(ctx)
+		if err != nil {
+			return err
+		}
+		loaded, err = loadIndex(ctx)
+		if err != nil {
+			return err
+		}
+		visibilities, err = loaded.reconcile(ctx, latest)
+		return err
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return loaded, visibilities, latest, nil
+}
+
+// visibleResources returns the visible resources ordered by task ID.
+func (index *index) visibleResources(visibilities map[int]table.CaldavVisibility, filter func(taskID int) bool) []Resource {
+	taskIDs := make([]int, 0, len(visibilities))
+	for taskID, visibility := range visibilities {
+		if visibility.Visible && filter(taskID) {
+			taskIDs = append(taskIDs, taskID)
+		}
+	}
+	sort.Ints(taskIDs)
+	resources := make([]Resource, 0, len(taskIDs))
+	for _, taskID := range taskIDs {
+		resources = append(resources, index.resource(taskID))
+	}
+	return resources
+}
+
+// Resources returns every task the current user can view.
+func Resources(ctx context.Context) ([]Resource, error) {
+	index, visibilities, _, err := snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return index.visibleResources(visibilities, func(int) bool { return true }), nil
+}
+
+// Lookup returns the resource with the name if the current user can view it.
+func Lookup(ctx context.Context, name string) (*Resource, error) {
+	index, err := loadIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	taskID := index.lookupName(name)
+	if taskID == -1 || !index.visible(ctx, taskID) {
+		return nil, ErrNotFound
+	}
+	resource := index.resource(taskID)
+	return &resource, nil
+}
+
+func matchesETag(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// SyncToken names the current state of the collection. The visibilities of
+// the current user are reconciled first, so access changes found later are
+// stamped after every token handed out.
+func SyncToken(ctx context.Context) (string, error) {
+	_, _, latest, err := snapshot(ctx)
+	if err != nil {
+		return "", err
+	}
+	return tokenPrefix + strconv.Itoa(latest), nil
+}
+
+// Changes returns the resources changed or made visible since the sync
+// token, the names of those the user could see at some point and that were
+// removed or are no longer visible since, and the current token. An empty
+// token returns every resource.
+func Changes(ctx context.Context, token string) ([]Resource, []string, string, error) {
+	index, visibilities, latest, err := snapshot(ctx)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	current := tokenPrefix + strconv.Itoa(latest)
+	if token == "" {
+		return index.visibleResources(visibilities, func(int) bool { return true }), nil, current, nil
+	}
+	since, err := strconv.Atoi(strings.TrimPrefix(token, tokenPrefix))
+	if err != nil || !strings.HasPrefix(token, tokenPrefix) || since < 0 || since > latest {
+		return nil, nil, "", ErrInvalidSyncToken
+	}
+	ids, err := table.GetTaskChangesSince(ctx, since)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	changedIDs := make(map[int]bool)
+	for _, taskID := range ids {
+		changedIDs[taskID] = true
+	}
+	removed := make([]string, 0)
+	for taskID, visibility := range visibilities {
+		if visibility.ChangedAt > since && !visibility.Visible {
+			removed = append(removed, index.name(taskID))
+		}
+	}
+	sort.Strings(removed)
+	changed := index.visibleResources(visibilities, func(taskID int) bool {
+		return changedIDs[taskID] || visibilities[taskID].ChangedAt > since
+	})
+	return changed, removed, current, nil
+}
@@ -0,0 +1,254 @@
+package calendar
+
+import (
+	"atodo_go/table"
+	"atodo_go/tag_filter"
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	VariantEvent = "vevent"
+	VariantTodo  = "vtodo"
+)
+
+const (
+	productID = "-//atodo//atodo calendar//EN"
+	uidDomain = "@atodo"
+)
+
+// Filter selects the tasks of a feed. WorkspaceID -1 selects every workspace.
+type Filter struct {
+	WorkspaceID int
+	Tags        tag_filter.TagFilter
+}
+
+// Entry is a task with a deadline or a time suspension, as it appears in the
+// calendar.
+type Entry struct {
+	Task     table.Task
+	Deadline *time.Time
+	Resume   *time.Time
+	// RRule repeats the deadline of periodic tasks, e.g. FREQ=DAILY;INTERVAL=1
+	RRule string
+	// Step is how far the deadline of a task with an RRule moves, in
+	// milliseconds
+	Step int64
+}
+
+func IsValidVariant(variant string) bool {
+	return variant == VariantEvent || variant == VariantTodo
+}
+
+// recurrence turns the deadline step of a periodic task into an RRULE, using
+// the largest unit that divides it.
+func recurrence(step int64) string {
+	if step <= 0 {
+		return ""
+	}
+	units := []struct {
+		freq   string
+		millis int64
+	}{
+		{"WEEKLY", 7 * 24 * time.Hour.Milliseconds()},
+		{"DAILY", 24 * time.Hour.Milliseconds()},
+		{"HOURLY", time.Hour.Milliseconds()},
+		{"MINUTELY", time.Minute.Milliseconds()},
+		{"SECONDLY", time.Second.Milliseconds()},
+	}
+	for _, unit := range units {
+		if step%unit.millis == 0 {
+			return "FREQ=" + unit.freq + ";INTERVAL=" + strconv.FormatInt(step/unit.millis, 10)
+		}
+	}
+	return ""
+}
+
+// periodicStep returns how far the deadline of a periodic task moves each
+// time it is completed, or 0 when the steps of its intervals differ and
+// cannot be written as one RRULE.
+func periodicStep(info *table.PeriodicT) int64 {
+	var step int64
+	for i := range info.Intervals {
+		current := table.PeriodicT{NowAt: i, Intervals: info.Intervals, Step: info.Step}
+		if i != 0 && current.DeadlineStep() != step {
+			return 0
+		}
+		step = current.DeadlineStep()
+	}
+	return step
+}
+
+func entry(ctx context.Context, task table.Task) (*Entry, error) {
+	result := &Entry{Task: task}
+	if task.Deadline.UnixMilli() > 0 {
+		deadline := task.Deadline
+		result.Deadline = &deadline
+		afterEffects, err := table.GetTaskAfterEffectsByID(ctx, task.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, afterEffect := range afterEffects {
+			if afterEffect.Type != table.Periodic {
+				continue
+			}
+			info, err := afterEffect.GetPeriodicInfo()
+			if err != nil {
+				return nil, err
+			}
+			step := periodicStep(info)
+			result.RRule = recurrence(step)
+			if result.RRule != "" {
+				result.Step = step
+			}
+		}
+	}
+	if task.Status == table.Suspended && table.IsTaskSuspended(ctx, task.ID) {
+		suspended, err := table.GetSuspendedTask(ctx, task.ID)
+		if err != nil {
+			return nil, err
+		}
+		if suspended.Type == table.Time {
+			info, err := suspended.GetTimeInfo()
+			if err != nil {
+				return nil, err
+			}
+			resume := time.UnixMilli(info.Timestamp)
+			result.Resume = &resume
+		}
+	}
+	if result.Deadline == nil && result.Resume == nil {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// Entries returns the unfinished tasks the current user can view that match
+// the filter and have a deadline or a time suspension.
+func Entries(ctx context.Context, filter Filter) ([]Entry, error) {
+	match, err := filter.Tags.Compile()
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := table.GetUnfinishedTasks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID := table.CurrentUserID(ctx)
+	entries := make([]Entry, 0)
+	for _, task := range tasks {
+		ok, err := table.HasWorkspaceRole(ctx, userID, task.ID, table.RoleViewer)
+		if err != nil || !ok {
+			continue
+		}
+		if filter.WorkspaceID != -1 {
+			workspaceID, err := table.GetWorkspaceID(ctx, task.ID)
+			if err != nil || workspaceID != filter.WorkspaceID {
+				continue
+			}
+		}
+		if !filter.Tags.IsEmpty() {
+			tags, err := table.GetEffectiveTagNames(ctx, task.ID)
+			if err != nil {
+				return nil, err
+			}
+			if !match(tags) {
+				continue
+			}
+		}
+		e, err := entry(ctx, task)
+		if err != nil {
+			return nil, err
+		}
+		if e != nil {
+			entries = append(entries, *e)
+		}
+	}
+	return entries, nil
+}
+
+func uid(taskID int, suffix string) string {
+	return "task-" + strconv.Itoa(taskID) + suffix + uidDomain
+}
+
+func newComponent(name string, uid string, summary string, task table.Task, stamp time.Time) Component {
+	component := Component{Name: name}
+	component.Add("UID", uid)
+	component.Add("DTSTAMP", UTC(stamp))
+	component.Add("SUMMARY", Text(summary))
+	if task.Goal != "" {
+		component.Add("DESCRIPTION", Text(task.Goal))
+	}
+	return component
+}
+
+// Components renders an entry as one VTODO, or as a VEVENT for the deadline
+// and one for the resume time. A VTODO starts when a suspended task resumes,
+// as long as that is before the deadline. Without such a start it repeats, and
+// every repetition starts one step before its due date, since an RRULE needs
+// a DTSTART.
+func (entry Entry) Components(variant string, stamp time.Time) []Component {
+	task := entry.Task
+	if variant == VariantTodo {
+		todo := newComponent("VTODO", uid(task.ID, ""), task.Name, task, stamp)
+		hasStart := entry.Resume != nil && (entry.Deadline == nil || entry.Resume.Before(*entry.Deadline))
+		repeats := entry.Deadline != nil && entry.RRule != "" && !hasStart
+		if hasStart {
+			todo.Add("DTSTART", UTC(*entry.Resume))
+		}
+		if repeats {
+			todo.Add("DTSTART", UTC(entry.Deadline.Add(-time.Duration(entry.Step)*time.Millisecond)))
+		}
+		if entry.Deadline != nil {
+			todo.Add("DUE", UTC(*entry.Deadline))
+		}
+		if repeats {
+			todo.Add("RRULE", entry.RRule)
+		}
+		status := "NEEDS-ACTION"
+		if task.Status == table.Suspended {
+			status = "IN-PROCESS"
+		}
+		todo.Add("STATUS", status)
+		return []Component{todo}
+	}
+	events := make([]Component, 0, 2)
+	if entry.Deadline != nil {
+		event := newComponent("VEVENT", uid(task.ID, "-deadline"), task.Name, task, stamp)
+		event.Add("DTSTART", UTC(*entry.Deadline))
+		if entry.RRule != "" {
+			event.Add("RRULE", entry.RRule)
+		}
+		event.Add("TRANSP", "TRANSPARENT")
+		events = append(events, event)
+	}
+	if entry.Resume != nil {
+		event := newComponent("VEVENT", uid(task.ID, "-resume"), "Resume: "+task.Name, task, stamp)
+		event.Add("DTSTART", UTC(*entry.Resume))
+		event.Add("TRANSP", "TRANSPARENT")
+		events = append(events, event)
+	}
+	return events
+}
+
+// Export renders the feed of the current user as an iCalendar object.
+func Export(ctx context.Context, filter Filter, variant string, now time.Time) (string, error) {
+	if !IsValidVariant(variant) {
+		return "", errors.New("unknown calendar variant: " + variant)
+	}
+	entries, err := Entries(ctx, filter)
+	if err != nil {
+		return "", err
+	}
+	calendar := Component{Name: "VCALENDAR"}
+	calendar.Add("VERSION", "2.0")
+	calendar.Add("PRODID", productID)
+	calendar.Add("CALSCALE", "GREGORIAN")
+	calendar.Add("X-WR-CALNAME", "atodo")
+	for _, entry := range entries {
+		calendar.Components = append(calendar.Components, entry.Components(variant, now)...)
+	}
+	return calendar.String(), nil
+}
@@ -0,0 +1,262 @@
+package calendar
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxLineOctets is the longest content line RFC 5545 allows before folding.
+const maxLineOctets = 75
+
+const utcLayout = "20060102T150405Z"
+
+// Property is one content line, e.g. DUE;TZID=Europe/Paris:20300102T093000.
+// Value is written as is; use Text for TEXT values.
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Component is a calendar component such as VEVENT, possibly with nested
+// components.
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []Component
+}
+
+func (component *Component) Add(name string, value string) {
+	component.Properties = append(component.Properties, Property{Name: name, Value: value})
+}
+
+// Get returns the first property with the name, or nil.
+func (component Component) Get(name string) *Property {
+	for i := range component.Properties {
+		if component.Properties[i].Name == name {
+			return &component.Properties[i]
+		}
+	}
+	return nil
+}
+
+// Find returns the first nested component with the name, or nil.
+func (component Component) Find(name string) *Component {
+	for i := range component.Components {
+		if component.Components[i].Name == name {
+			return &component.Components[i]
+		}
+	}
+	return nil
+}
+
+// Text escapes a TEXT value.
+func Text(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	return replacer.Replace(value)
+}
+
+// Unescape reverses Text.
+func Unescape(value string) string {
+	var builder strings.Builder
+	escaped := false
+	for _, r := range value {
+		if !escaped {
+			if r == '\\' {
+				escaped = true
+			} else {
+				builder.WriteRune(r)
+			}
+			continue
+		}
+		escaped = false
+		if r == 'n' || r == 'N' {
+			builder.WriteRune('\n')
+		} else {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// UTC formats a DATE-TIME value in UTC.
+func UTC(t time.Time) string {
+	return t.UTC().Format(utcLayout)
+}
+
+// fold splits a content line into lines of at most 75 octets, each
+// continuation starting with a space, without splitting UTF-8 sequences.
+func fold(line string) string {
+	if len(line) <= maxLineOctets {
+		return line + "\r\n"
+	}
+	var builder strings.Builder
+	limit := maxLineOctets
+	start := 0
+	for start < len(line) {
+		end := start + limit
+		if end >= len(line) {
+			end = len(line)
+		} else {
+			for end > start && !isRuneStart(line[end]) {
+				end--
+			}
+		}
+		if start > 0 {
+			builder.WriteString(" ")
+		}
+		builder.WriteString(line[start:end])
+		builder.WriteString("\r\n")
+		start = end
+		// the leading space counts towards the limit of continuation lines
+		limit = maxLineOctets - 1
+	}
+	return builder.String()
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+func (component Component) write(builder *strings.Builder) {
+	builder.WriteString(fold("BEGIN:" + component.Name))
+	for _, property := range component.Properties {
+		builder.WriteString(fold(property.line()))
+	}
+	for _, child := range component.Components {
+		child.write(builder)
+	}
+	builder.WriteString(fold("END:" + component.Name))
+}
+
+// String encodes the component with CRLF line endings.
+func (component Component) String() string {
+	var builder strings.Builder
+	component.write(&builder)
+	return builder.String()
+}
+
+func (property Property) line() string {
+	var builder strings.Builder
+	builder.WriteString(property.Name)
+	names := make([]string, 0, len(property.Params))
+	for name := range property.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := property.Params[name]
+		if strings.ContainsAny(value, ":;,") {
+			value = `"` + value + `"`
+		}
+		builder.WriteString(";" + name + "=" + value)
+	}
+	builder.WriteString(":" + property.Value)
+	return builder.String()
+}
+
+// unfold joins continuation lines and splits the data into content lines.
+func unfold(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\n ", "")
+	data = strings.ReplaceAll(data, "\n\t", "")
+	lines := make([]string, 0)
+	for _, line := range strings.Split(data, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseLine splits a content line into its name, parameters and value,
+// honouring quoted parameter values.
+func parseLine(line string) (Property, error) {
+	quoted := false
+	parts := make([]string, 0, 2)
+	start := 0
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ';' && !quoted:
+			parts = append(parts, line[start:i])
+			start = i + 1
+		case r == ':' && !quoted:
+			parts = append(parts, line[start:i])
+			property := Property{Name: strings.ToUpper(parts[0]), Value: line[i+1:]}
+			for _, param := range parts[1:] {
+				name, value, ok := strings.Cut(param, "=")
+				if !ok {
+					continue
+				}
+				if property.Params == nil {
+					property.Params = make(map[string]string)
+				}
+				property.Params[strings.ToUpper(name)] = strings.Trim(value, `"`)
+			}
+			return property, nil
+		}
+	}
+	return Property{}, errors.New("invalid content line: " + line)
+}
+
+// Parse reads an iCalendar object such as a VCALENDAR.
+func Parse(data string) (*Component, error) {
+	stack := make([]*Component, 0)
+	var root *Component
+	for _, line := range unfold(data) {
+		property, err := parseLine(line)
+		if err != nil {
+			return nil, err
+		}
+		switch property.Name {
+		case "BEGIN":
+			stack = append(stack, &Component{Name: strings.ToUpper(property.Value)})
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(property.Value) {
+				return nil, errors.New("unexpected END:" + property.Value)
+			}
+			component := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				root = component
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, *component)
+			}
+		default:
+			if len(stack) == 0 {
+				return nil, errors.New("property outside of a component: " + property.Name)
+			}
+			current := stack[len(stack)-1]
+			current.Properties = append(current.Properties, property)
+		}
+	}
+	if len(stack) != 0 || root == nil {
+		return nil, errors.New("incomplete calendar object")
+	}
+	return root, nil
+}
+
+// Time reads a DATE or DATE-TIME value. Times with a TZID are read in that
+// zone, floating times and dates in the local zone.
+func (property Property) Time() (time.Time, error) {
+	location := time.Local
+	if tzid, ok := property.Params["TZID"]; ok {
+		loaded, err := time.LoadLocation(tzid)
+		if err == nil {
+			location = loaded
+		}
+	}
+	value := property.Value
+	switch {
+	case property.Params["VALUE"] == "DATE" || len(value) == len("20060102"):
+		return time.ParseInLocation("20060102", value, location)
+	case strings.HasSuffix(value, "Z"):
+		return time.Parse(utcLayout, value)
+	}
+	return time.ParseInLocation("20060102T150405", value, location)
+}
@@ -0,0 +1,213 @@
+package digest
+
+import (
+	"atodo_go/notify"
+	"atodo_go/schedule"
+	"atodo_go/table"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+const interval = time.Minute
+
+type OverdueTaskShow struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Goal     string `json:"goal"`
+	Deadline int64  `json:"deadline"`
+}
+
+type CompletedTaskShow struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	CompletedAt int64  `json:"completed_at"`
+}
+
+// Digest summarizes the period starting today: what is ready, what resumes
+// from suspension, what is overdue and what waits for an event, and what was
+// completed in the period before.
+type Digest struct {
+	Period    string                          `json:"period"`
+	From      int64                           `json:"from"`
+	To        int64                           `json:"to"`
+	Ready     []schedule.TaskShow             `json:"ready"`
+	Resuming  []schedule.SuspendedTaskShow    `json:"resuming"`
+	Overdue   []OverdueTaskShow               `json:"overdue"`
+	Waiting   []schedule.EventTriggerTaskShow `json:"waiting"`
+	Completed []CompletedTaskShow             `json:"completed"`
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func periodDays(period string) (int, error) {
+	switch period {
+	case table.DigestDaily:
+		return 1, nil
+	case table.DigestWeekly:
+		return 7, nil
+	}
+	return 0, errors.New("unknown digest period: " + period)
+}
+
+// Generate builds the digest of the current user for the period starting on
+// the day of now.
+func Generate(ctx context.Context, period string, now time.Time) (*Digest, error) {
+	days, err := periodDays(period)
+	if err != nil {
+		return nil, err
+	}
+	from := startOfDay(now)
+	to := from.AddDate(0, 0, days)
+	// Schedule exits the process when the root task is missing
+	rootTask, err := table.GetRootTask(ctx)
+	if err != nil {
+		return nil, err
+	}
+	root, err := table.LookupTask(ctx, rootTask)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, errors.New("root task not found")
+	}
+	result, err := schedule.Schedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+	digest := &Digest{
+		Period:    period,
+		From:      from.UnixMilli(),
+		To:        to.UnixMilli(),
+		Ready:     result.Tasks,
+		Resuming:  make([]schedule.SuspendedTaskShow, 0),
+		Overdue:   make([]OverdueTaskShow, 0),
+		Waiting:   result.EventTriggerTask,
+		Completed: make([]CompletedTaskShow, 0),
+	}
+	for _, task := range result.SuspendedTasks {
+		info, ok := task.Info.(schedule.SuspendedTimeInfo)
+		if ok && info.Time < to.UnixMilli() {
+			digest.Resuming = append(digest.Resuming, task)
+		}
+	}
+
+	userID := table.CurrentUserID(ctx)
+	overdue, err := table.GetOverdueTasks(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range overdue {
+		ok, err := table.HasWorkspaceRole(ctx, userID, task.ID, table.RoleViewer)
+		if err != nil || !ok {
+			continue
+		}
+		digest.Overdue = append(digest.Overdue, OverdueTaskShow{
+			Id:       task.ID,
+			Name:     task.Name,
+			Goal:     task.Goal,
+			Deadline: task.Deadline.UnixMilli(),
+		})
+	}
+
+	completions, err := table.GetTaskCompletionsBetween(ctx, from.AddDate(0, 0, -days), from)
+	if err != nil {
+		return nil, err
+	}
+	for _, completion := range completions {
+		ok, err := table.HasWorkspaceRole(ctx, userID, completion.TaskID, table.RoleViewer)
+		if err != nil || !ok {
+			continue
+		}
+		task, err := table.LookupTask(ctx, completion.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		if task == nil {
+			continue
+		}
+		digest.Completed = append(digest.Completed, CompletedTaskShow{
+			Id:          task.ID,
+			Name:        task.Name,
+			CompletedAt: completion.CompletedAt.UnixMilli(),
+		})
+	}
+	return digest, nil
+}
+
+// Deliver sends the digest of every subscriber whose digest is due through
+// the subscriber's own notifier. Digests of subscribers without one wait
+// until they set one up.
+func Deliver(ctx context.Context, now time.Time) ([]int, error) {
+	subscriptions, err := table.GetDigestSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	delivered := make([]int, 0)
+	for _, subscription := range subscriptions {
+		if !subscription.IsDue(now) {
+			continue
+		}
+		notifier, err := notify.GetUserNotifier(ctx, subscription.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if notifier == nil {
+			continue
+		}
+		// a digest that fails to build is skipped until it is due again
+		err = table.MarkDigestSent(ctx, subscription.UserID, now)
+		if err != nil {
+			return nil, err
+		}
+		notification, err := build(ctx, subscription, now)
+		if err != nil {
+			log.Println("Failed to build digest: ", err)
+			continue
+		}
+		sent, err := notify.SendToUser(ctx, subscription.UserID, notification)
+		if err != nil {
+			log.Println("Failed to send digest: ", err)
+			continue
+		}
+		if sent {
+			delivered = append(delivered, subscription.UserID)
+		}
+	}
+	return delivered, nil
+}
+
+func build(ctx context.Context, subscription table.DigestSubscription, now time.Time) (notify.Notification, error) {
+	ctx = table.WithUser(ctx, subscription.UserID)
+	digest, err := Generate(ctx, subscription.Period, now)
+	if err != nil {
+		return notify.Notification{}, err
+	}
+	message, _, err := Render(digest, subscription.Format)
+	if err != nil {
+		return notify.Notification{}, err
+	}
+	return notify.Notification{
+		Event:   notify.EventDigest,
+		Title:   Title(digest),
+		Message: message,
+	}, nil
+}
+
+// Start delivers due digests every minute.
+func Start(ctx context.Context) {
+	ctx = table.WithSource(ctx, table.SourceScheduler)
+	go func() {
+		ticker := time.NewTicker(interval)
+		for now := range ticker.C {
+			_, err := Deliver(ctx, now)
+			if err != nil {
+				log.Println("Failed to deliver digests: ", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,179 @@
+package digest
+
+import (
+	"atodo_go/schedule"
+	"atodo_go/table"
+	"errors"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatText     = "text"
+)
+
+const (
+	dayLayout  = "Monday, 2 January 2006"
+	timeLayout = "2006-01-02 15:04"
+)
+
+type item struct {
+	Name   string
+	Detail string
+}
+
+type section struct {
+	Title string
+	Items []item
+}
+
+func IsValidFormat(format string) bool {
+	return format == FormatMarkdown || format == FormatHTML || format == FormatText
+}
+
+// Title names the digest, e.g. "Daily digest for Monday, 19 October 2026".
+func Title(digest *Digest) string {
+	prefix := "Daily digest for "
+	if digest.Period == table.DigestWeekly {
+		prefix = "Weekly digest from "
+	}
+	return prefix + time.UnixMilli(digest.From).Format(dayLayout)
+}
+
+func formatTime(millis int64) string {
+	return time.UnixMilli(millis).Format(timeLayout)
+}
+
+func deadline(millis int64) string {
+	if millis <= 0 {
+		return ""
+	}
+	return "due " + formatTime(millis)
+}
+
+func join(parts ...string) string {
+	kept := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, ", ")
+}
+
+func sections(digest *Digest) []section {
+	completedTitle := "Completed yesterday"
+	if digest.Period == table.DigestWeekly {
+		completedTitle = "Completed last week"
+	}
+	ready := section{Title: "Ready"}
+	for _, task := range digest.Ready {
+		ready.Items = append(ready.Items, item{task.Name, join(task.Goal, deadline(task.Deadline))})
+	}
+	resuming := section{Title: "Resuming"}
+	for _, task := range digest.Resuming {
+		detail := ""
+		if info, ok := task.Info.(schedule.SuspendedTimeInfo); ok {
+			detail = "at " + formatTime(info.Time)
+		}
+		resuming.Items = append(resuming.Items, item{task.Name, join(detail, deadline(task.Deadline))})
+	}
+	overdue := section{Title: "Overdue"}
+	for _, task := range digest.Overdue {
+		overdue.Items = append(overdue.Items, item{task.Name, "was " + deadline(task.Deadline)})
+	}
+	waiting := section{Title: "Waiting for events"}
+	for _, task := range digest.Waiting {
+		waiting.Items = append(waiting.Items, item{task.Name, join(task.EventName, task.EventDescription)})
+	}
+	completed := section{Title: completedTitle}
+	for _, task := range digest.Completed {
+		completed.Items = append(completed.Items, item{task.Name, formatTime(task.CompletedAt)})
+	}
+	return []section{ready, resuming, overdue, waiting, completed}
+}
+
+// Render returns the digest in the given format and its content type.
+// Markdown is used when no format is given.
+func Render(digest *Digest, format string) (string, string, error) {
+	switch format {
+	case FormatMarkdown, "":
+		return renderMarkdown(digest), "text/markdown; charset=utf-8", nil
+	case FormatText:
+		return renderText(digest), "text/plain; charset=utf-8", nil
+	case FormatHTML:
+		html, err := renderHTML(digest)
+		return html, "text/html; charset=utf-8", err
+	}
+	return "", "", errors.New("unknown digest format: " + format)
+}
+
+func renderMarkdown(digest *Digest) string {
+	var builder strings.Builder
+	builder.WriteString("# " + Title(digest) + "\n")
+	for _, section := range sections(digest) {
+		builder.WriteString("\n## " + section.Title + " (" + strconv.Itoa(len(section.Items)) + ")\n\n")
+		if len(section.Items) == 0 {
+			builder.WriteString("_Nothing._\n")
+		}
+		for _, item := range section.Items {
+			builder.WriteString("- **" + item.Name + "**")
+			if item.Detail != "" {
+				builder.WriteString(" — " + item.Detail)
+			}
+			builder.WriteString("\n")
+		}
+	}
+	return builder.String()
+}
+
+func renderText(digest *Digest) string {
+	var builder strings.Builder
+	title := Title(digest)
+	builder.WriteString(title + "\n" + strings.Repeat("=", len([]rune(title))) + "\n")
+	for _, section := range sections(digest) {
+		heading := section.Title + " (" + strconv.Itoa(len(section.Items)) + ")"
+		builder.WriteString("\n" + heading + "\n" + strings.Repeat("-", len([]rune(heading))) + "\n")
+		if len(section.Items) == 0 {
+			builder.WriteString("Nothing.\n")
+		}
+		for _, item := range section.Items {
+			builder.WriteString("* " + item.Name)
+			if item.Detail != "" {
+				builder.WriteString(": " + item.Detail)
+			}
+			builder.WriteString("\n")
+		}
+	}
+	return builder.String()
+}
+
+var htmlTemplate = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Sections}}<h2>{{.Title}} ({{len .Items}})</h2>
+{{if .Items}}<ul>
+{{range .Items}}<li><strong>{{.Name}}</strong>{{if .Detail}} — {{.Detail}}{{end}}</li>
+{{end}}</ul>
+{{else}}<p><em>Nothing.</em></p>
+{{end}}{{end}}</body>
+</html>
+`))
+
+func renderHTML(digest *Digest) (string, error) {
+	var builder strings.Builder
+	err := htmlTemplate.Execute(&builder, struct {
+		Title    string
+		Sections []section
+	}{Title(digest), sections(digest)})
+	if err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,76 @@
+package event_bus
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	TaskCreated     = "task_created"
+	TaskUpdated     = "task_updated"
+	TaskCompleted   = "task_completed"
+	TaskEliminated  = "task_eliminated"
+	TaskResumed     = "task_resumed"
+	TaskOverdue     = "task_overdue"
+	TriggerFired    = "event_trigger_fired"
+	RelationChanged = "relation_changed"
+	StateChanged    = "state_changed"
+	// Changed covers every other entity, e.g. tags, notes and comments.
+	Changed = "changed"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind before
+// further events are dropped for it.
+const subscriberBuffer = 256
+
+type Event struct {
+	Type     string `json:"type"`
+	Entity   string `json:"entity"`
+	EntityID int    `json:"entity_id"`
+	Field    string `json:"field"`
+	Source   string `json:"source"`
+	UserID   int    `json:"user_id"`
+	Time     int64  `json:"time"`
+	// WorkspaceID is the workspace of the task an event of a task is about,
+	// resolved when it is published, or 0 when it could not be.
+	WorkspaceID int `json:"workspace_id"`
+	// Viewers are the users who could view that workspace when the event was
+	// published, or nil when every user could.
+	Viewers []int `json:"-"`
+}
+
+var mutex sync.Mutex
+var subscribers = make(map[chan Event]bool)
+
+// Subscribe returns a channel receiving every event published from now on and
+// a function that unsubscribes and closes the channel.
+func Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	mutex.Lock()
+	subscribers[ch] = true
+	mutex.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			mutex.Lock()
+			delete(subscribers, ch)
+			mutex.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish delivers the event to every subscriber without blocking.
+func Publish(event Event) {
+	if event.Time == 0 {
+		event.Time = time.Now().UnixMilli()
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	for ch := range subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package exporter
+
+import (
+	"atodo_go/backup"
+	"atodo_go/table"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatMarkdown = "markdown"
+	FormatOrg      = "org"
+	FormatTodoTxt  = "todotxt"
+)
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Format describes how an export is downloaded.
+type Format struct {
+	ContentType string
+	Extension   string
+	render      func(tree *tree) string
+}
+
+var formats = map[string]Format{
+	FormatMarkdown: {ContentType: "text/markdown; charset=utf-8", Extension: ".md", render: renderMarkdown},
+	FormatOrg:      {ContentType: "text/org; charset=utf-8", Extension: ".org", render: renderOrg},
+	FormatTodoTxt:  {ContentType: "text/plain; charset=utf-8", Extension: ".txt", render: renderTodoTxt},
+}
+
+const priorityPrefix = "priority-"
+
+const day = 24 * time.Hour
+
+// tree indexes an archive by parent, with siblings in relation order.
+type tree struct {
+	archive  *backup.Archive
+	byID     map[int]backup.Task
+	children map[int][]backup.Task
+}
+
+// relationOrder sorts siblings so that the source of a relation comes before
+// its target, taking the lowest ID first among those that are ready. Tasks
+// on a cycle follow in ID order.
+func relationOrder(siblings []backup.Task, relations []backup.Relation) []backup.Task {
+	sort.Slice(siblings, func(i, j int) bool { return siblings[i].ID < siblings[j].ID })
+	incoming := make(map[int]int)
+	outgoing := make(map[int][]int)
+	present := make(map[int]bool, len(siblings))
+	for _, task := range siblings {
+		present[task.ID] = true
+	}
+	for _, relation := range relations {
+		if present[relation.Source] && present[relation.Target] {
+			incoming[relation.Target]++
+			outgoing[relation.Source] = append(outgoing[relation.Source], relation.Target)
+		}
+	}
+	ordered := make([]backup.Task, 0, len(siblings))
+	placed := make(map[int]bool, len(siblings))
+	for len(ordered) < len(siblings) {
+		next := -1
+		for i, task := range siblings {
+			if !placed[task.ID] && incoming[task.ID] == 0 {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			for i, task := range siblings {
+				if !placed[task.ID] {
+					next = i
+					break
+				}
+			}
+		}
+		task := siblings[next]
+		placed[task.ID] = true
+		ordered = append(ordered, task)
+		for _, target := range outgoing[task.ID] {
+			incoming[target]--
+		}
+	}
+	return ordered
+}
+
+func newTree(archive *backup.Archive) *tree {
+	t := &tree{
+		archive:  archive,
+		byID:     make(map[int]backup.Task, len(archive.Tasks)),
+		children: make(map[int][]backup.Task),
+	}
+	for _, task := range archive.Tasks {
+		t.byID[task.ID] = task
+		if task.ID != archive.Root {
+			t.children[task.ParentTask] = append(t.children[task.ParentTask], task)
+		}
+	}
+	relations := make(map[int][]backup.Relation)
+	for _, relation := range archive.Relations {
+		relations[relation.ParentTask] = append(relations[relation.ParentTask], relation)
+	}
+	for parent, siblings := range t.children {
+		t.children[parent] = relationOrder(siblings, relations[parent])
+	}
+	return t
+}
+
+func (t *tree) root() backup.Task {
+	return t.byID[t.archive.Root]
+}
+
+// walk visits the tasks below the task depth first, its children at depth.
+func (t *tree) walk(id int, depth int, visit func(task backup.Task, depth int)) {
+	for _, child := range t.children[id] {
+		visit(child, depth)
+		t.walk(child.ID, depth+1, visit)
+	}
+}
+
+// priority splits the priority tag, written as priority-a, from the others.
+func priority(task backup.Task) (string, []string) {
+	found := ""
+	tags := make([]string, 0, len(task.Tags))
+	for _, tag := range task.Tags {
+		letter := strings.TrimPrefix(tag.Name, priorityPrefix)
+		if found == "" && letter != tag.Name && len(letter) == 1 {
+			found = strings.ToUpper(letter)
+			continue
+		}
+		tags = append(tags, tag.Name)
+	}
+	return found, tags
+}
+
+func deadline(task backup.Task) (time.Time, bool) {
+	if task.Deadline <= 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(task.Deadline), true
+}
+
+func availableFrom(task backup.Task) (time.Time, bool) {
+	if task.AvailableFrom <= 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(task.AvailableFrom), true
+}
+
+// repeat returns the deadline step of a periodic task.
+func repeat(task backup.Task) time.Duration {
+	for _, afterEffect := range task.AfterEffects {
+		var info table.PeriodicT
+		if afterEffect.Type != "Periodic" || json.Unmarshal(afterEffect.Info, &info) != nil || len(info.Intervals) == 0 {
+			continue
+		}
+		return time.Duration(info.DeadlineStep()) * time.Millisecond
+	}
+	return 0
+}
+
+// shortRepeat writes a step as the "1w" of todo.txt and Org, in the largest
+// unit dividing it, or "" for steps of no whole hours.
+func shortRepeat(step time.Duration) string {
+	if step <= 0 {
+		return ""
+	}
+	for _, unit := range []struct {
+		suffix   string
+		duration time.Duration
+	}{{"w", 7 * day}, {"d", day}, {"h", time.Hour}} {
+		if step%unit.duration == 0 {
+			return strconv.FormatInt(int64(step/unit.duration), 10) + unit.suffix
+		}
+	}
+	return ""
+}
+
+// date writes the day alone when the time is midnight.
+func date(t time.Time) string {
+	local := t.Local()
+	if local.Hour() == 0 && local.Minute() == 0 {
+		return local.Format("2006-01-02")
+	}
+	return local.Format("2006-01-02 15:04")
+}
+
+// Render writes the archive in the format.
+func Render(format string, archive *backup.Archive) (string, error) {
+	info, ok := formats[format]
+	if !ok {
+		return "", ErrUnknownFormat
+	}
+	return info.render(newTree(archive)), nil
+}
+
+// GetFormat returns how the format is downloaded.
+func GetFormat(format string) (Format, error) {
+	info, ok := formats[format]
+	if !ok {
+		return Format{}, ErrUnknownFormat
+	}
+	return info, nil
+}
+
+// Export renders the task and its subtasks in the format and returns it with
+// the name of the root task.
+func Export(ctx context.Context, format string, taskID int) (string, string, error) {
+	if _, ok := formats[format]; !ok {
+		return "", "", ErrUnknownFormat
+	}
+	archive, err := backup.Export(ctx, taskID, time.Now())
+	if err != nil {
+		return "", "", err
+	}
+	t := newTree(archive)
+	return formats[format].render(t), t.root().Name, nil
+}
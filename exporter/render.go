@@ -0,0 +1,171 @@
+package exporter
+
+import (
+	"atodo_go/backup"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var orgTagUnsafe = regexp.MustCompile(`[^\p{L}\p{N}_@#%]`)
+
+// oneLine joins the lines of a name, which line based formats cannot split.
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func indentLines(text string, indent string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(indent+line, " ")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// renderMarkdown writes an outline of checkboxes below a heading naming the
+// root task.
+func renderMarkdown(t *tree) string {
+	var builder strings.Builder
+	root := t.root()
+	builder.WriteString("# " + oneLine(root.Name) + "\n")
+	if root.Goal != "" {
+		builder.WriteString("\n" + root.Goal + "\n")
+	}
+	builder.WriteString("\n")
+	t.walk(root.ID, 0, func(task backup.Task, depth int) {
+		indent := strings.Repeat("  ", depth)
+		box := "[ ]"
+		if task.Status == "Done" {
+			box = "[x]"
+		}
+		line := indent + "- " + box + " " + oneLine(task.Name)
+		details := make([]string, 0)
+		if task.Status == "Suspended" {
+			details = append(details, "suspended")
+		}
+		if due, ok := deadline(task); ok {
+			details = append(details, "due "+date(due))
+		}
+		if every := shortRepeat(repeat(task)); every != "" {
+			details = append(details, "every "+every)
+		}
+		if len(details) != 0 {
+			line += " _(" + strings.Join(details, ", ") + ")_"
+		}
+		letter, tags := priority(task)
+		if letter != "" {
+			line += " **" + letter + "**"
+		}
+		for _, tag := range tags {
+			line += " #" + tag
+		}
+		builder.WriteString(line + "\n")
+		if task.Goal != "" {
+			builder.WriteString(indentLines(task.Goal, indent+"  "))
+		}
+	})
+	return builder.String()
+}
+
+func orgTimestamp(t time.Time, step time.Duration) string {
+	local := t.Local()
+	stamp := local.Format("2006-01-02 Mon")
+	if local.Hour() != 0 || local.Minute() != 0 {
+		stamp += local.Format(" 15:04")
+	}
+	if every := shortRepeat(step); every != "" {
+		stamp += " +" + every
+	}
+	return "<" + stamp + ">"
+}
+
+// renderOrg writes a headline per task with TODO, WAITING or DONE and the
+// planning line of its deadline and defer date, titled by the root task.
+func renderOrg(t *tree) string {
+	var builder strings.Builder
+	root := t.root()
+	builder.WriteString("#+TITLE: " + oneLine(root.Name) + "\n")
+	if root.Goal != "" {
+		builder.WriteString(root.Goal + "\n")
+	}
+	t.walk(root.ID, 1, func(task backup.Task, depth int) {
+		keyword := "TODO"
+		switch task.Status {
+		case "Done":
+			keyword = "DONE"
+		case "Suspended":
+			keyword = "WAITING"
+		}
+		line := strings.Repeat("*", depth) + " " + keyword
+		letter, tags := priority(task)
+		if letter != "" {
+			line += " [#" + letter + "]"
+		}
+		line += " " + oneLine(task.Name)
+		if len(tags) != 0 {
+			for i, tag := range tags {
+				tags[i] = orgTagUnsafe.ReplaceAllString(tag, "_")
+			}
+			line += " :" + strings.Join(tags, ":") + ":"
+		}
+		builder.WriteString(line + "\n")
+		indent := strings.Repeat(" ", depth+1)
+		planning := make([]string, 0, 2)
+		if due, ok := deadline(task); ok {
+			planning = append(planning, "DEADLINE: "+orgTimestamp(due, repeat(task)))
+		}
+		if from, ok := availableFrom(task); ok {
+			planning = append(planning, "SCHEDULED: "+orgTimestamp(from, 0))
+		}
+		if len(planning) != 0 {
+			builder.WriteString(indent + strings.Join(planning, " ") + "\n")
+		}
+		if task.Goal != "" {
+			builder.WriteString(indentLines(task.Goal, indent))
+		}
+	})
+	return builder.String()
+}
+
+// todoTxtWord makes a name usable as a +project or @context.
+func todoTxtWord(name string) string {
+	return strings.Join(strings.Fields(name), "_")
+}
+
+// renderTodoTxt writes a line per task below the root, with its parent task
+// as +project, tags as @contexts and due:, t: and rec: for its deadline, defer
+// date and repeat.
+func renderTodoTxt(t *tree) string {
+	var builder strings.Builder
+	root := t.root()
+	t.walk(root.ID, 1, func(task backup.Task, depth int) {
+		words := make([]string, 0)
+		letter, tags := priority(task)
+		if task.Status == "Done" {
+			words = append(words, "x")
+		} else if letter != "" {
+			words = append(words, "("+letter+")")
+		}
+		words = append(words, oneLine(task.Name))
+		if task.ParentTask != root.ID {
+			words = append(words, "+"+todoTxtWord(t.byID[task.ParentTask].Name))
+		}
+		for _, tag := range tags {
+			words = append(words, "@"+todoTxtWord(tag))
+		}
+		if due, ok := deadline(task); ok {
+			words = append(words, "due:"+due.Local().Format("2006-01-02"))
+		}
+		if from, ok := availableFrom(task); ok {
+			words = append(words, "t:"+from.Local().Format("2006-01-02"))
+		}
+		if every := shortRepeat(repeat(task)); every != "" {
+			words = append(words, "rec:"+every)
+		}
+		if task.Status == "Done" && letter != "" {
+			words = append(words, "pri:"+letter)
+		}
+		builder.WriteString(strings.Join(words, " ") + "\n")
+	})
+	return builder.String()
+}
@@ -0,0 +1,353 @@
+package focus
+
+import (
+	"atodo_go/notify"
+	"atodo_go/table"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const SourceFocus = "focus"
+
+type Config struct {
+	FocusLength      int64 `json:"focus_length"`
+	ShortBreakLength int64 `json:"short_break_length"`
+	LongBreakLength  int64 `json:"long_break_length"`
+	LongBreakEvery   int   `json:"long_break_every"`
+	AutoStartFocus   bool  `json:"auto_start_focus"`
+}
+
+type SessionShow struct {
+	Id        int    `json:"id"`
+	TaskId    int    `json:"task_id"`
+	Kind      string `json:"kind"`
+	Status    string `json:"status"`
+	Cycle     int    `json:"cycle"`
+	Length    int64  `json:"length"`
+	Remaining int64  `json:"remaining"`
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"`
+}
+
+// configSettingPrefix keys the Config of each user among the settings.
+const configSettingPrefix = "focus.user."
+
+var (
+	mutex sync.Mutex
+	// timers holds the timer of the running session of each user.
+	timers        = make(map[int]*time.Timer)
+	defaultConfig = Config{
+		FocusLength:      (25 * time.Minute).Milliseconds(),
+		ShortBreakLength: (5 * time.Minute).Milliseconds(),
+		LongBreakLength:  (15 * time.Minute).Milliseconds(),
+		LongBreakEvery:   4,
+	}
+)
+
+func configSettingKey(userID int) string {
+	return configSettingPrefix + strconv.Itoa(userID)
+}
+
+// GetConfig returns the Config of the current user, or the default one if
+// the user has not set any.
+func GetConfig(ctx context.Context) (Config, error) {
+	value, ok, err := table.GetSetting(ctx, configSettingKey(table.CurrentUserID(ctx)))
+	if err != nil || !ok || value == "" {
+		return defaultConfig, err
+	}
+	var config Config
+	err = json.Unmarshal([]byte(value), &config)
+	if err != nil {
+		return defaultConfig, err
+	}
+	return config, nil
+}
+
+// SetConfig validates and stores the Config of the current user.
+func SetConfig(ctx context.Context, newConfig Config) error {
+	if newConfig.FocusLength <= 0 || newConfig.ShortBreakLength <= 0 || newConfig.LongBreakLength <= 0 {
+		return errors.New("session lengths must be positive")
+	}
+	if newConfig.LongBreakEvery <= 0 {
+		return errors.New("long_break_every must be positive")
+	}
+	marshal, err := json.Marshal(newConfig)
+	if err != nil {
+		return err
+	}
+	return table.SetSetting(ctx, configSettingKey(table.CurrentUserID(ctx)), string(marshal))
+}
+
+func toShow(session table.FocusSession, now time.Time) SessionShow {
+	show := SessionShow{
+		Id:        session.ID,
+		TaskId:    session.TaskID,
+		Kind:      string(session.Kind),
+		Status:    string(session.Status),
+		Cycle:     session.Cycle,
+		Length:    session.Length,
+		Remaining: session.Remaining(now),
+		StartTime: session.StartTime.UnixMilli(),
+	}
+	if show.Remaining < 0 {
+		show.Remaining = 0
+	}
+	if session.EndTime != nil {
+		show.EndTime = session.EndTime.UnixMilli()
+	}
+	return show
+}
+
+// Restore re-arms the timers of the sessions left running by a previous
+// process. Mutations are attributed to SourceFocus.
+func Restore(ctx context.Context) error {
+	ctx = table.WithSource(ctx, SourceFocus)
+	mutex.Lock()
+	defer mutex.Unlock()
+	sessions, err := table.GetRunningFocusSessions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		schedule(session)
+	}
+	return nil
+}
+
+// schedule arms the timer of the session's user for the end of the running
+// session. The caller holds mutex.
+func schedule(session table.FocusSession) {
+	stopTimer(session.UserID)
+	remaining := session.Remaining(time.Now())
+	if remaining < 0 {
+		remaining = 0
+	}
+	actor := table.Actor{Source: SourceFocus, UserID: session.UserID}
+	timers[session.UserID] = time.AfterFunc(time.Duration(remaining)*time.Millisecond, func() {
+		onBoundary(table.WithActor(context.Background(), actor))
+	})
+}
+
+func stopTimer(userID int) {
+	timer, ok := timers[userID]
+	if ok {
+		timer.Stop()
+		delete(timers, userID)
+	}
+}
+
+func sendBoundary(event string, task table.Task, title string, message string) {
+	notify.Send(notify.Notification{Event: event, Title: task.Name + " - " + title, Message: message, TaskID: task.ID})
+}
+
+func onBoundary(ctx context.Context) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	session, err := table.GetActiveFocusSession(ctx)
+	if err != nil {
+		log.Println("Failed to load focus session: ", err)
+		return
+	}
+	if session == nil || session.Status != table.FocusRunning {
+		return
+	}
+	now := time.Now()
+	if session.Remaining(now) > 0 {
+		schedule(*session)
+		return
+	}
+	err = finish(ctx, session, table.FocusCompleted, now)
+	if err != nil {
+		log.Println("Failed to complete focus session: ", err)
+		return
+	}
+	config, err := GetConfig(ctx)
+	if err != nil {
+		log.Println("Failed to load focus config: ", err)
+		return
+	}
+
+	task, err := table.GetTaskByID(ctx, session.TaskID)
+	if err != nil {
+		log.Println("Failed to load focus task: ", err)
+		return
+	}
+	next := table.FocusSession{TaskID: session.TaskID, Cycle: session.Cycle}
+	switch session.Kind {
+	case table.FocusKind:
+		if session.Cycle >= config.LongBreakEvery {
+			next.Kind = table.LongBreakKind
+			next.Length = config.LongBreakLength
+			sendBoundary(notify.EventFocusFinished, task, "Focus Finished", "Time for a long break")
+		} else {
+			next.Kind = table.ShortBreakKind
+			next.Length = config.ShortBreakLength
+			sendBoundary(notify.EventFocusFinished, task, "Focus Finished", "Time for a short break")
+		}
+	default:
+		sendBoundary(notify.EventBreakFinished, task, "Break Finished", "Back to work")
+		if !config.AutoStartFocus {
+			stopTimer(session.UserID)
+			return
+		}
+		next.Kind = table.FocusKind
+		next.Length = config.FocusLength
+		next.Cycle = nextCycle(session)
+	}
+	_, err = begin(ctx, next, now)
+	if err != nil {
+		log.Println("Failed to start next focus session: ", err)
+	}
+}
+
+func nextCycle(last *table.FocusSession) int {
+	if last == nil || last.Kind != table.ShortBreakKind {
+		return 1
+	}
+	return last.Cycle + 1
+}
+
+func begin(ctx context.Context, session table.FocusSession, now time.Time) (*table.FocusSession, error) {
+	session.Status = table.FocusRunning
+	session.StartTime = now
+	session.ResumedAt = now
+	id, err := table.AddFocusSession(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	session.ID = id
+	schedule(session)
+	return &session, nil
+}
+
+func finish(ctx context.Context, session *table.FocusSession, status table.FocusSessionStatus, now time.Time) error {
+	if session.Status == table.FocusRunning {
+		session.Elapsed += now.Sub(session.ResumedAt).Milliseconds()
+	}
+	session.Status = status
+	session.EndTime = &now
+	return table.UpdateFocusSession(ctx, *session)
+}
+
+// Start begins a focus session on the task currently being done.
+func Start(ctx context.Context) (*SessionShow, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	active, err := table.GetActiveFocusSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		return nil, errors.New("a focus session is already active")
+	}
+	taskId, err := table.GetNowDoingTask(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if taskId <= 0 {
+		return nil, errors.New("no task is being done, start focus session failed")
+	}
+	last, err := table.GetLastFocusSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil && last.TaskID != taskId {
+		last = nil
+	}
+	config, err := GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session, err := begin(ctx, table.FocusSession{
+		TaskID: taskId,
+		Kind:   table.FocusKind,
+		Length: config.FocusLength,
+		Cycle:  nextCycle(last),
+	}, now)
+	if err != nil {
+		return nil, err
+	}
+	show := toShow(*session, now)
+	return &show, nil
+}
+
+func Pause(ctx context.Context) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	session, err := table.GetActiveFocusSession(ctx)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.Status != table.FocusRunning {
+		return errors.New("no running focus session")
+	}
+	stopTimer(session.UserID)
+	session.Elapsed += time.Since(session.ResumedAt).Milliseconds()
+	session.Status = table.FocusPaused
+	return table.UpdateFocusSession(ctx, *session)
+}
+
+func Resume(ctx context.Context) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	session, err := table.GetActiveFocusSession(ctx)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.Status != table.FocusPaused {
+		return errors.New("no paused focus session")
+	}
+	session.Status = table.FocusRunning
+	session.ResumedAt = time.Now()
+	err = table.UpdateFocusSession(ctx, *session)
+	if err != nil {
+		return err
+	}
+	schedule(*session)
+	return nil
+}
+
+func Stop(ctx context.Context) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	session, err := table.GetActiveFocusSession(ctx)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return errors.New("no active focus session")
+	}
+	stopTimer(session.UserID)
+	return finish(ctx, session, table.FocusCancelled, time.Now())
+}
+
+// GetState returns the active session, or nil when idle.
+func GetState(ctx context.Context) (*SessionShow, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	session, err := table.GetActiveFocusSession(ctx)
+	if err != nil || session == nil {
+		return nil, err
+	}
+	show := toShow(*session, time.Now())
+	return &show, nil
+}
+
+func GetSessions(ctx context.Context, taskId int) ([]SessionShow, error) {
+	sessions, err := table.GetFocusSessionsByTaskID(ctx, taskId)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	shows := make([]SessionShow, 0, len(sessions))
+	for _, session := range sessions {
+		shows = append(shows, toShow(session, now))
+	}
+	return shows, nil
+}
@@ -0,0 +1,205 @@
+package importer
+
+import (
+	"atodo_go/backup"
+	"atodo_go/table"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatTodoTxt     = "todotxt"
+	FormatTaskwarrior = "taskwarrior"
+	FormatOrg         = "org"
+)
+
+var ErrUnknownFormat = errors.New("unknown import format")
+
+const day = 24 * time.Hour
+
+// defaultNames name the task the imported tasks are put below.
+var defaultNames = map[string]string{
+	FormatTodoTxt:     "todo.txt import",
+	FormatTaskwarrior: "Taskwarrior import",
+	FormatOrg:         "Org import",
+}
+
+// builder assembles an archive with IDs local to it, so imports share the
+// validation and ID remapping of backup.Import.
+type builder struct {
+	archive  *backup.Archive
+	groups   map[string]int
+	warnings []string
+}
+
+func newBuilder(rootName string) *builder {
+	b := &builder{
+		archive: &backup.Archive{
+			Version:   backup.Version,
+			Root:      1,
+			Relations: make([]backup.Relation, 0),
+		},
+		groups:   make(map[string]int),
+		warnings: make([]string, 0),
+	}
+	b.add(-1, rootName)
+	return b
+}
+
+func (b *builder) warn(warning string) {
+	b.warnings = append(b.warnings, warning)
+}
+
+// add appends a Todo task without a deadline and returns its ID.
+func (b *builder) add(parent int, name string) int {
+	id := len(b.archive.Tasks) + 1
+	b.archive.Tasks = append(b.archive.Tasks, backup.Task{
+		ID:           id,
+		ParentTask:   parent,
+		Name:         name,
+		Status:       "Todo",
+		Tags:         make([]backup.Tag, 0),
+		Checklist:    make([]backup.ChecklistItem, 0),
+		Triggers:     make([]backup.Typed, 0),
+		AfterEffects: make([]backup.Typed, 0),
+	})
+	return id
+}
+
+// task returns the task with the ID for editing. The pointer is valid until
+// the next add.
+func (b *builder) task(id int) *backup.Task {
+	return &b.archive.Tasks[id-1]
+}
+
+// group returns the task named name below parent, adding it on first use.
+// Projects become such tasks.
+func (b *builder) group(parent int, name string) int {
+	key := strconv.Itoa(parent) + "/" + name
+	id, ok := b.groups[key]
+	if !ok {
+		id = b.add(parent, name)
+		b.groups[key] = id
+	}
+	return id
+}
+
+// path returns the task for a project path such as "Home.Garden", adding the
+// missing tasks along it.
+func (b *builder) path(parent int, names []string) int {
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			parent = b.group(parent, name)
+		}
+	}
+	return parent
+}
+
+func (b *builder) tag(id int, name string) {
+	err := table.ValidateTagName(name)
+	if err != nil {
+		b.warn("tag " + strconv.Quote(name) + " is skipped: " + err.Error())
+		return
+	}
+	task := b.task(id)
+	for _, tag := range task.Tags {
+		if tag.Name == name {
+			return
+		}
+	}
+	task.Tags = append(task.Tags, backup.Tag{Name: name})
+}
+
+func (b *builder) priority(id int, priority string) {
+	if priority != "" {
+		b.tag(id, "priority-"+strings.ToLower(priority))
+	}
+}
+
+func (b *builder) deadline(id int, deadline time.Time) {
+	b.task(id).Deadline = deadline.UnixMilli()
+}
+
+func (b *builder) availableFrom(id int, from time.Time) {
+	b.task(id).AvailableFrom = from.UnixMilli()
+}
+
+// suspend suspends the task until the time, when the scheduler resumes it.
+func (b *builder) suspend(id int, until time.Time) {
+	info, _ := json.Marshal(table.SuspendedTimeInfo{Timestamp: until.UnixMilli()})
+	task := b.task(id)
+	task.Status = "Suspended"
+	task.Suspension = &backup.Typed{Type: "Time", Info: info}
+}
+
+// repeat makes the task periodic, moving its deadline by the step each time
+// it is completed. A task without a deadline has nothing to move, so its
+// repeat is skipped.
+func (b *builder) repeat(id int, step time.Duration) {
+	task := b.task(id)
+	if task.Deadline == 0 {
+		b.warn(strconv.Quote(task.Name) + " repeats without a due date, the repeat is skipped")
+		return
+	}
+	info, _ := json.Marshal(table.PeriodicT{Intervals: []int{int(step.Milliseconds())}, Step: step.Milliseconds()})
+	task.AfterEffects = append(task.AfterEffects, backup.Typed{Type: "Periodic", Info: info})
+}
+
+// depend records that source has to be done before target. Only subtasks of
+// the same task can be related.
+func (b *builder) depend(source int, target int) bool {
+	parent := b.task(target).ParentTask
+	if b.task(source).ParentTask != parent || source == target {
+		return false
+	}
+	for _, relation := range b.archive.Relations {
+		if relation.Source == source && relation.Target == target {
+			return true
+		}
+	}
+	b.archive.Relations = append(b.archive.Relations, backup.Relation{ParentTask: parent, Source: source, Target: target})
+	return true
+}
+
+// Parse converts the data into an archive below a task with the name, or the
+// format's default name, and returns warnings about what was skipped.
+func Parse(format string, data string, name string) (*backup.Archive, []string, error) {
+	if name == "" {
+		name = defaultNames[format]
+	}
+	b := newBuilder(name)
+	var err error
+	switch format {
+	case FormatTodoTxt:
+		err = parseTodoTxt(b, data)
+	case FormatTaskwarrior:
+		err = parseTaskwarrior(b, data)
+	case FormatOrg:
+		err = parseOrg(b, data)
+	default:
+		return nil, nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return b.archive, b.warnings, nil
+}
+
+// Import parses the data and imports it below the parent like a backup.
+func Import(ctx context.Context, format string, data string, name string, parentID int, dryRun bool) (*backup.Result, error) {
+	archive, warnings, err := Parse(format, data, name)
+	if err != nil {
+		return nil, err
+	}
+	result, err := backup.Import(ctx, archive, parentID, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	result.Warnings = append(warnings, result.Warnings...)
+	return result, nil
+}
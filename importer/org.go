@@ -0,0 +1,124 @@
+package importer
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	orgHeadline = regexp.MustCompile(`^(\*+)\s+(?:(TODO|NEXT|WAITING|DONE|CANCELLED|CANCELED)(?:\s+|$))?(?:\[#([A-Za-z0-9])\]\s*)?(.*?)(?:\s+(:[^\s]+:))?\s*$`)
+	orgPlanning = regexp.MustCompile(`(DEADLINE|SCHEDULED|CLOSED):\s*[<\[]([^>\]]+)[>\]]`)
+	orgDrawer   = regexp.MustCompile(`^\s*:[A-Za-z_-]+:\s*$`)
+	orgClock    = regexp.MustCompile(`^\d{1,2}:\d{2}$`)
+)
+
+// orgDone are the keywords of finished headlines.
+var orgDone = map[string]bool{"DONE": true, "CANCELLED": true, "CANCELED": true}
+
+// parseOrgTimestamp reads the inside of <2030-01-02 Wed 09:30 +1w> into its
+// time and repeat, if any.
+func parseOrgTimestamp(value string) (time.Time, time.Duration, bool) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return time.Time{}, 0, false
+	}
+	date, err := time.ParseInLocation(dateLayout, fields[0], time.Local)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	var repeat time.Duration
+	for _, field := range fields[1:] {
+		switch {
+		case orgClock.MatchString(field):
+			clock, err := time.Parse("15:04", field)
+			if err == nil {
+				date = date.Add(time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute)
+			}
+		case strings.HasPrefix(field, "+") || strings.HasPrefix(field, ".+"):
+			step, ok := parseShortRepeat(strings.TrimLeft(field, ".+"))
+			if ok {
+				repeat = step
+			}
+		}
+	}
+	return date, repeat, true
+}
+
+// parseOrg turns headlines into tasks below the headline one level up. TODO
+// and DONE keywords set the status, [#A] the priority tag, trailing :tags:
+// the tags, DEADLINE the deadline and its repeater a periodic after-effect,
+// SCHEDULED the defer date, and the text below a headline its note. Drawers
+// are skipped.
+func parseOrg(b *builder, data string) error {
+	type level struct {
+		depth int
+		id    int
+	}
+	stack := []level{{depth: 0, id: b.archive.Root}}
+	current := -1
+	body := make([]string, 0)
+	inDrawer := false
+	flush := func() {
+		if current != -1 {
+			b.task(current).Note = strings.TrimSpace(strings.Join(body, "\n"))
+		}
+		body = body[:0]
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if match := orgHeadline.FindStringSubmatch(line); match != nil {
+			flush()
+			inDrawer = false
+			depth := len(match[1])
+			for len(stack) > 1 && stack[len(stack)-1].depth >= depth {
+				stack = stack[:len(stack)-1]
+			}
+			current = b.add(stack[len(stack)-1].id, strings.TrimSpace(match[4]))
+			stack = append(stack, level{depth: depth, id: current})
+			if orgDone[match[2]] {
+				b.task(current).Status = "Done"
+			}
+			b.priority(current, match[3])
+			for _, tag := range strings.Split(strings.Trim(match[5], ":"), ":") {
+				if tag != "" {
+					b.tag(current, tag)
+				}
+			}
+			continue
+		}
+		if current == -1 {
+			continue
+		}
+		if inDrawer {
+			inDrawer = strings.TrimSpace(line) != ":END:"
+			continue
+		}
+		if orgDrawer.MatchString(line) {
+			inDrawer = true
+			continue
+		}
+		planning := orgPlanning.FindAllStringSubmatch(line, -1)
+		if planning == nil {
+			body = append(body, line)
+			continue
+		}
+		for _, entry := range planning {
+			date, repeat, ok := parseOrgTimestamp(entry[2])
+			if !ok {
+				b.warn("invalid timestamp " + entry[0])
+				continue
+			}
+			switch entry[1] {
+			case "DEADLINE":
+				b.deadline(current, date)
+				if repeat != 0 {
+					b.repeat(current, repeat)
+				}
+			case "SCHEDULED":
+				b.availableFrom(current, date)
+			}
+		}
+	}
+	flush()
+	return nil
+}
@@ -0,0 +1,214 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const taskwarriorLayout = "20060102T150405Z"
+
+var taskwarriorRepeat = regexp.MustCompile(`^(\d*)\s*([a-z]+)$`)
+
+// taskwarriorUnits are the recurrence periods Taskwarrior accepts, with
+// months and years approximated.
+var taskwarriorUnits = map[string]time.Duration{
+	"hourly":     time.Hour,
+	"daily":      day,
+	"day":        day,
+	"weekdays":   day,
+	"weekly":     7 * day,
+	"biweekly":   14 * day,
+	"fortnight":  14 * day,
+	"monthly":    30 * day,
+	"quarterly":  91 * day,
+	"semiannual": 182 * day,
+	"annual":     365 * day,
+	"yearly":     365 * day,
+	"biannual":   730 * day,
+	"h":          time.Hour,
+	"hrs":        time.Hour,
+	"hours":      time.Hour,
+	"d":          day,
+	"days":       day,
+	"w":          7 * day,
+	"wk":         7 * day,
+	"wks":        7 * day,
+	"weeks":      7 * day,
+	"mo":         30 * day,
+	"mos":        30 * day,
+	"months":     30 * day,
+	"q":          91 * day,
+	"qtrs":       91 * day,
+	"y":          365 * day,
+	"yrs":        365 * day,
+	"years":      365 * day,
+}
+
+type taskwarriorAnnotation struct {
+	Description string `json:"description"`
+}
+
+type taskwarriorTask struct {
+	UUID        string                  `json:"uuid"`
+	Description string                  `json:"description"`
+	Status      string                  `json:"status"`
+	Project     string                  `json:"project"`
+	Priority    string                  `json:"priority"`
+	Tags        []string                `json:"tags"`
+	Due         string                  `json:"due"`
+	Wait        string                  `json:"wait"`
+	Scheduled   string                  `json:"scheduled"`
+	Recur       string                  `json:"recur"`
+	Parent      string                  `json:"parent"`
+	Depends     json.RawMessage         `json:"depends"`
+	Annotations []taskwarriorAnnotation `json:"annotations"`
+}
+
+// dependencies reads depends, a list of UUIDs in current exports and a comma
+// separated string in older ones.
+func (task taskwarriorTask) dependencies() []string {
+	if len(task.Depends) == 0 {
+		return nil
+	}
+	var list []string
+	if json.Unmarshal(task.Depends, &list) == nil {
+		return list
+	}
+	var joined string
+	if json.Unmarshal(task.Depends, &joined) == nil && joined != "" {
+		return strings.Split(joined, ",")
+	}
+	return nil
+}
+
+func parseTaskwarriorRepeat(value string) (time.Duration, bool) {
+	match := taskwarriorRepeat.FindStringSubmatch(strings.ToLower(strings.TrimSpace(value)))
+	if match == nil {
+		return 0, false
+	}
+	unit, ok := taskwarriorUnits[match[2]]
+	if !ok {
+		return 0, false
+	}
+	count := 1
+	if match[1] != "" {
+		count, _ = strconv.Atoi(match[1])
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return time.Duration(count) * unit, true
+}
+
+// readTaskwarrior accepts the JSON array of "task export" as well as the one
+// task per line of older versions.
+func readTaskwarrior(data string) ([]taskwarriorTask, error) {
+	data = strings.TrimSpace(data)
+	var tasks []taskwarriorTask
+	if strings.HasPrefix(data, "[") {
+		err := json.Unmarshal([]byte(data), &tasks)
+		if err != nil {
+			return nil, err
+		}
+		return tasks, nil
+	}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSuffix(strings.TrimSpace(line), ",")
+		if line == "" {
+			continue
+		}
+		var task taskwarriorTask
+		err := json.Unmarshal([]byte(line), &task)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// parseTaskwarrior maps projects to parent tasks, due to the deadline,
+// scheduled to the defer date, wait to a suspension, recur to a periodic
+// after-effect and depends to relations between tasks of the same project.
+// Deleted tasks and the generated instances of recurring tasks are skipped.
+func parseTaskwarrior(b *builder, data string) error {
+	tasks, err := readTaskwarrior(data)
+	if err != nil {
+		return errors.New("invalid Taskwarrior export: " + err.Error())
+	}
+	byUUID := make(map[string]int)
+	skippedInstances := 0
+	for _, task := range tasks {
+		if task.Status == "deleted" {
+			continue
+		}
+		if task.Parent != "" {
+			skippedInstances++
+			continue
+		}
+		parent := b.path(b.archive.Root, strings.Split(task.Project, "."))
+		id := b.add(parent, task.Description)
+		byUUID[task.UUID] = id
+		if task.Status == "completed" {
+			b.task(id).Status = "Done"
+		}
+		b.priority(id, task.Priority)
+		for _, tag := range task.Tags {
+			b.tag(id, tag)
+		}
+		notes := make([]string, 0, len(task.Annotations))
+		for _, annotation := range task.Annotations {
+			notes = append(notes, annotation.Description)
+		}
+		b.task(id).Note = strings.Join(notes, "\n")
+		dates := []struct{ field, value string }{{"due", task.Due}, {"scheduled", task.Scheduled}, {"wait", task.Wait}}
+		for _, date := range dates {
+			if date.value == "" {
+				continue
+			}
+			parsed, err := time.Parse(taskwarriorLayout, date.value)
+			if err != nil {
+				b.warn(task.UUID + ": invalid " + date.field + " date " + strconv.Quote(date.value))
+				continue
+			}
+			switch date.field {
+			case "due":
+				b.deadline(id, parsed)
+			case "scheduled":
+				b.availableFrom(id, parsed)
+			case "wait":
+				if task.Status != "completed" {
+					b.suspend(id, parsed)
+				}
+			}
+		}
+		if task.Recur != "" {
+			step, ok := parseTaskwarriorRepeat(task.Recur)
+			if ok {
+				b.repeat(id, step)
+			} else {
+				b.warn(task.UUID + ": unsupported recurrence " + strconv.Quote(task.Recur))
+			}
+		}
+	}
+	for _, task := range tasks {
+		target, ok := byUUID[task.UUID]
+		if !ok {
+			continue
+		}
+		for _, dependency := range task.dependencies() {
+			source, ok := byUUID[strings.TrimSpace(dependency)]
+			if !ok || !b.depend(source, target) {
+				b.warn(task.UUID + ": dependency on " + dependency + " is dropped, only tasks of the same project can depend on each other")
+			}
+		}
+	}
+	if skippedInstances != 0 {
+		b.warn(strconv.Itoa(skippedInstances) + " instances of recurring tasks are skipped, their recurring tasks are imported instead")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package importer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+var (
+	todoTxtPriority = regexp.MustCompile(`^\([A-Z]\)$`)
+	// repeats are written as "1w" or, to count from the due date, "+1w"
+	shortRepeat = regexp.MustCompile(`^\+?(\d+)([hdwmy])$`)
+)
+
+// shortUnits are the units of todo.txt and Org repeats, m being months.
+var shortUnits = map[string]time.Duration{
+	"h": time.Hour,
+	"d": day,
+	"w": 7 * day,
+	"m": 30 * day,
+	"y": 365 * day,
+}
+
+func parseShortRepeat(value string) (time.Duration, bool) {
+	match := shortRepeat.FindStringSubmatch(value)
+	if match == nil {
+		return 0, false
+	}
+	count, err := strconv.Atoi(match[1])
+	if err != nil || count == 0 {
+		return 0, false
+	}
+	return time.Duration(count) * shortUnits[match[2]], true
+}
+
+func isDate(token string) bool {
+	_, err := time.ParseInLocation(dateLayout, token, time.Local)
+	return err == nil
+}
+
+// parseTodoTxt reads one task per line. A leading "x" marks it done, "(A)" is
+// its priority, the first +project its parent task, further projects and
+// @contexts its tags, and due:, t: and rec: its deadline, defer date and
+// repeat.
+func parseTodoTxt(b *builder, data string) error {
+	for number, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		tokens := strings.Fields(line)
+		if len(tokens) == 0 {
+			continue
+		}
+		done := false
+		if tokens[0] == "x" {
+			done = true
+			tokens = tokens[1:]
+		}
+		priority := ""
+		if len(tokens) != 0 && todoTxtPriority.MatchString(tokens[0]) {
+			priority = tokens[0][1:2]
+			tokens = tokens[1:]
+		}
+		// completion and creation dates
+		for len(tokens) != 0 && isDate(tokens[0]) {
+			tokens = tokens[1:]
+		}
+		words := make([]string, 0, len(tokens))
+		projects := make([]string, 0)
+		contexts := make([]string, 0)
+		var due, threshold time.Time
+		var repeat time.Duration
+		for _, token := range tokens {
+			key, value, isPair := strings.Cut(token, ":")
+			switch {
+			case len(token) > 1 && token[0] == '+':
+				projects = append(projects, token[1:])
+				continue
+			case len(token) > 1 && token[0] == '@':
+				contexts = append(contexts, token[1:])
+				continue
+			case isPair && key == "pri" && len(value) == 1:
+				priority = value
+				continue
+			case isPair && (key == "due" || key == "t"):
+				date, err := time.ParseInLocation(dateLayout, value, time.Local)
+				if err == nil {
+					if key == "due" {
+						due = date
+					} else {
+						threshold = date
+					}
+					continue
+				}
+				b.warn("line " + strconv.Itoa(number+1) + ": invalid date " + strconv.Quote(token))
+			case isPair && key == "rec":
+				step, ok := parseShortRepeat(value)
+				if ok {
+					repeat = step
+					continue
+				}
+				b.warn("line " + strconv.Itoa(number+1) + ": invalid repeat " + strconv.Quote(token))
+			}
+			words = append(words, token)
+		}
+		parent := b.archive.Root
+		if len(projects) != 0 {
+			parent = b.group(parent, projects[0])
+			projects = projects[1:]
+		}
+		id := b.add(parent, strings.Join(words, " "))
+		if done {
+			b.task(id).Status = "Done"
+		}
+		b.priority(id, priority)
+		for _, project := range projects {
+			b.tag(id, project)
+		}
+		for _, context := range contexts {
+			b.tag(id, context)
+		}
+		if !due.IsZero() {
+			b.deadline(id, due)
+		}
+		if !threshold.IsZero() {
+			b.availableFrom(id, threshold)
+		}
+		if repeat != 0 {
+			b.repeat(id, repeat)
+		}
+	}
+	return nil
+}
@@ -1,14 +1,33 @@
 package main
 
 import (
+	"atodo_go/digest"
+	"atodo_go/focus"
+	"atodo_go/notify"
+	"atodo_go/reminder"
 	"atodo_go/table"
 	"atodo_go/web"
+	"atodo_go/webhook"
+	"context"
+	"log"
 )
 
 func main() {
+	ctx := context.Background()
 	err := table.InitDB()
 	if err != nil {
 		return
 	}
+	err = focus.Restore(ctx)
+	if err != nil {
+		log.Println("Failed to restore focus session: ", err)
+	}
+	err = notify.Load(ctx)
+	if err != nil {
+		log.Println("Failed to load notification config: ", err)
+	}
+	webhook.Start(ctx)
+	reminder.Start(ctx)
+	digest.Start(ctx)
 	web.RunWebServer(web.InitWebInterface())
 }
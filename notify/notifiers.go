@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gen2brain/beeep"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var client = &http.Client{Timeout: sendTimeout}
+
+// DesktopNotifier shows a desktop notification on the machine running the
+// server. It fails on headless servers.
+type DesktopNotifier struct{}
+
+func (DesktopNotifier) Notify(notification Notification) error {
+	return beeep.Notify(notification.Title, notification.Message, "")
+}
+
+// LogNotifier writes notifications to the server log.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(notification Notification) error {
+	log.Printf("Notification [%s] %s: %s", notification.Event, notification.Title, notification.Message)
+	return nil
+}
+
+func post(request *http.Request) error {
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// WebhookNotifier posts the notification as JSON. With a secret, the body is
+// signed like outbound webhooks in the X-Atodo-Signature header.
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+}
+
+func (notifier WebhookNotifier) Notify(notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, notifier.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if notifier.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(notifier.Secret))
+		mac.Write(body)
+		request.Header.Set("X-Atodo-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	return post(request)
+}
+
+// NtfyNotifier publishes to an ntfy-style topic URL, which takes the message
+// as the body and the title and priority as headers.
+type NtfyNotifier struct {
+	URL      string
+	Token    string
+	Priority string
+}
+
+func (notifier NtfyNotifier) Notify(notification Notification) error {
+	request, err := http.NewRequest(http.MethodPost, notifier.URL, strings.NewReader(notification.Message))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Title", notification.Title)
+	request.Header.Set("Tags", notification.Event)
+	if notifier.Priority != "" {
+		request.Header.Set("Priority", notifier.Priority)
+	}
+	if notifier.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+notifier.Token)
+	}
+	return post(request)
+}
+
+// EmailNotifier sends the notification over SMTP, upgrading to TLS when the
+// server offers STARTTLS.
+type EmailNotifier struct {
+	Config NotifierConfig
+}
+
+func (notifier EmailNotifier) message(notification Notification) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("From: " + notifier.Config.From + "\r\n")
+	buffer.WriteString("To: " + strings.Join(notifier.Config.To, ", ") + "\r\n")
+	buffer.WriteString("Subject: " + strings.ReplaceAll(notification.Title, "\n", " ") + "\r\n")
+	buffer.WriteString("Date: " + time.UnixMilli(notification.Time).Format(time.RFC1123Z) + "\r\n")
+	buffer.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	buffer.WriteString(notification.Message + "\r\n")
+	return buffer.Bytes()
+}
+
+func (notifier EmailNotifier) Notify(notification Notification) error {
+	port := notifier.Config.Port
+	if port == 0 {
+		port = 587
+	}
+	host := notifier.Config.Host
+	connection, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), sendTimeout)
+	if err != nil {
+		return err
+	}
+	_ = connection.SetDeadline(time.Now().Add(sendTimeout))
+	smtpClient, err := smtp.NewClient(connection, host)
+	if err != nil {
+		_ = connection.Close()
+		return err
+	}
+	defer smtpClient.Close()
+	if ok, _ := smtpClient.Extension("STARTTLS"); ok {
+		err = smtpClient.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return err
+		}
+	}
+	if notifier.Config.Username != "" {
+		err = smtpClient.Auth(smtp.PlainAuth("", notifier.Config.Username, notifier.Config.Password, host))
+		if err != nil {
+			return err
+		}
+	}
+	err = smtpClient.Mail(notifier.Config.From)
+	if err != nil {
+		return err
+	}
+	for _, to := range notifier.Config.To {
+		err = smtpClient.Rcpt(to)
+		if err != nil {
+			return err
+		}
+	}
+	writer, err := smtpClient.Data()
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(notifier.message(notification))
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	return smtpClient.Quit()
+}
@@ -0,0 +1,317 @@
+package notify
+
+import (
+	"atodo_go/event_bus"
+	"atodo_go/table"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	EventTaskResumed   = event_bus.TaskResumed
+	EventTaskReminder  = "task_reminder"
+	EventTaskOverdue   = event_bus.TaskOverdue
+	EventDigest        = "digest"
+	EventFocusFinished = "focus_finished"
+	EventBreakFinished = "break_finished"
+	EventTest          = "test"
+)
+
+// RedactedSecret replaces the secrets of a configuration sent to clients.
+// Sending it back keeps the stored secret.
+const RedactedSecret = "********"
+
+// DefaultRoute is used for events without a route of their own.
+const DefaultRoute = "*"
+
+const settingKey = "notify"
+
+const (
+	TypeDesktop = "desktop"
+	TypeEmail   = "email"
+	TypeWebhook = "webhook"
+	TypeNtfy    = "ntfy"
+	TypeLog     = "log"
+)
+
+const sendTimeout = 10 * time.Second
+
+type Notification struct {
+	Event   string `json:"event"`
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	TaskID  int    `json:"task_id"`
+	Time    int64  `json:"time"`
+}
+
+type Notifier interface {
+	Notify(notification Notification) error
+}
+
+// NotifierConfig configures one named notifier. Which fields are used
+// depends on Type.
+type NotifierConfig struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	URL      string   `json:"url,omitempty"`
+	Secret   string   `json:"secret,omitempty"`
+	Token    string   `json:"token,omitempty"`
+	Priority string   `json:"priority,omitempty"`
+	Host     string   `json:"host,omitempty"`
+	Port     int      `json:"port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+}
+
+// Config lists the notifiers and, per event, the names of the notifiers it
+// is sent to. Events without a route use DefaultRoute.
+type Config struct {
+	Notifiers []NotifierConfig    `json:"notifiers"`
+	Routes    map[string][]string `json:"routes"`
+}
+
+var (
+	mutex     sync.Mutex
+	config    = defaultConfig()
+	notifiers = mustBuild(config)
+)
+
+func defaultConfig() Config {
+	return Config{
+		Notifiers: []NotifierConfig{{Name: TypeDesktop, Type: TypeDesktop}},
+		Routes:    map[string][]string{DefaultRoute: {TypeDesktop}},
+	}
+}
+
+func build(notifierConfig NotifierConfig) (Notifier, error) {
+	switch notifierConfig.Type {
+	case TypeDesktop:
+		return DesktopNotifier{}, nil
+	case TypeLog:
+		return LogNotifier{}, nil
+	case TypeEmail:
+		if notifierConfig.Host == "" || notifierConfig.From == "" || len(notifierConfig.To) == 0 {
+			return nil, errors.New("email notifiers need host, from and to")
+		}
+		return EmailNotifier{Config: notifierConfig}, nil
+	case TypeWebhook:
+		if notifierConfig.URL == "" {
+			return nil, errors.New("webhook notifiers need a url")
+		}
+		return WebhookNotifier{URL: notifierConfig.URL, Secret: notifierConfig.Secret}, nil
+	case TypeNtfy:
+		if notifierConfig.URL == "" {
+			return nil, errors.New("ntfy notifiers need a url")
+		}
+		return NtfyNotifier{URL: notifierConfig.URL, Token: notifierConfig.Token, Priority: notifierConfig.Priority}, nil
+	}
+	return nil, fmt.Errorf("unknown notifier type %q", notifierConfig.Type)
+}
+
+func buildAll(newConfig Config) (map[string]Notifier, error) {
+	built := make(map[string]Notifier, len(newConfig.Notifiers))
+	for _, notifierConfig := range newConfig.Notifiers {
+		if notifierConfig.Name == "" {
+			return nil, errors.New("notifiers need a name")
+		}
+		if _, ok := built[notifierConfig.Name]; ok {
+			return nil, fmt.Errorf("duplicate notifier %q", notifierConfig.Name)
+		}
+		notifier, err := build(notifierConfig)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %q: %w", notifierConfig.Name, err)
+		}
+		built[notifierConfig.Name] = notifier
+	}
+	for event, names := range newConfig.Routes {
+		for _, name := range names {
+			if _, ok := built[name]; !ok {
+				return nil, fmt.Errorf("route %q uses unknown notifier %q", event, name)
+			}
+		}
+	}
+	return built, nil
+}
+
+func mustBuild(newConfig Config) map[string]Notifier {
+	built, err := buildAll(newConfig)
+	if err != nil {
+		panic(err)
+	}
+	return built
+}
+
+// Redacted returns a copy of the notifier with its SMTP password, ntfy token
+// and webhook secret replaced by RedactedSecret.
+func (notifierConfig NotifierConfig) Redacted() NotifierConfig {
+	if notifierConfig.Password != "" {
+		notifierConfig.Password = RedactedSecret
+	}
+	if notifierConfig.Token != "" {
+		notifierConfig.Token = RedactedSecret
+	}
+	if notifierConfig.Secret != "" {
+		notifierConfig.Secret = RedactedSecret
+	}
+	return notifierConfig
+}
+
+// keepSecrets puts back the secrets of old that were sent as RedactedSecret.
+func (notifierConfig NotifierConfig) keepSecrets(old NotifierConfig) NotifierConfig {
+	if notifierConfig.Password == RedactedSecret {
+		notifierConfig.Password = old.Password
+	}
+	if notifierConfig.Token == RedactedSecret {
+		notifierConfig.Token = old.Token
+	}
+	if notifierConfig.Secret == RedactedSecret {
+		notifierConfig.Secret = old.Secret
+	}
+	return notifierConfig
+}
+
+// Redacted returns a copy of the configuration with the secrets of every
+// notifier redacted.
+func (config Config) Redacted() Config {
+	redacted := Config{Notifiers: make([]NotifierConfig, 0, len(config.Notifiers)), Routes: config.Routes}
+	for _, notifierConfig := range config.Notifiers {
+		redacted.Notifiers = append(redacted.Notifiers, notifierConfig.Redacted())
+	}
+	return redacted
+}
+
+// keepSecrets puts back the secrets of the notifiers of the same name that a
+// client sent as RedactedSecret.
+func keepSecrets(newConfig Config, current Config) Config {
+	stored := make(map[string]NotifierConfig, len(current.Notifiers))
+	for _, notifierConfig := range current.Notifiers {
+		stored[notifierConfig.Name] = notifierConfig
+	}
+	kept := Config{Notifiers: make([]NotifierConfig, 0, len(newConfig.Notifiers)), Routes: newConfig.Routes}
+	for _, notifierConfig := range newConfig.Notifiers {
+		kept.Notifiers = append(kept.Notifiers, notifierConfig.keepSecrets(stored[notifierConfig.Name]))
+	}
+	return kept
+}
+
+func GetConfig() Config {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return config
+}
+
+// SetConfig validates, stores and applies the configuration. Secrets sent as
+// RedactedSecret keep their stored value.
+func SetConfig(ctx context.Context, newConfig Config) error {
+	newConfig = keepSecrets(newConfig, GetConfig())
+	built, err := buildAll(newConfig)
+	if err != nil {
+		return err
+	}
+	marshal, err := json.Marshal(newConfig)
+	if err != nil {
+		return err
+	}
+	err = table.SetSetting(ctx, settingKey, string(marshal))
+	if err != nil {
+		return err
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	config = newConfig
+	notifiers = built
+	return nil
+}
+
+// Load applies the stored configuration, if any.
+func Load(ctx context.Context) error {
+	value, ok, err := table.GetSetting(ctx, settingKey)
+	if err != nil || !ok {
+		return err
+	}
+	var stored Config
+	err = json.Unmarshal([]byte(value), &stored)
+	if err != nil {
+		return err
+	}
+	built, err := buildAll(stored)
+	if err != nil {
+		return err
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	config = stored
+	notifiers = built
+	return nil
+}
+
+func route(event string) []Notifier {
+	mutex.Lock()
+	defer mutex.Unlock()
+	names, ok := config.Routes[event]
+	if !ok {
+		names = config.Routes[DefaultRoute]
+	}
+	routed := make([]Notifier, 0, len(names))
+	for _, name := range names {
+		routed = append(routed, notifiers[name])
+	}
+	return routed
+}
+
+// deliver calls the notifier, turning a panic into an error.
+func deliver(notifier Notifier, notification Notification) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("notifier panicked: %v", r)
+		}
+	}()
+	return notifier.Notify(notification)
+}
+
+// sendThrough delivers the notification through the notifiers in the
+// background, logging failures.
+func sendThrough(notifiers []Notifier, notification Notification) {
+	if notification.Time == 0 {
+		notification.Time = time.Now().UnixMilli()
+	}
+	for _, notifier := range notifiers {
+		go func(notifier Notifier) {
+			err := deliver(notifier, notification)
+			if err != nil {
+				log.Printf("Failed to send %s notification: %v", notification.Event, err)
+			}
+		}(notifier)
+	}
+}
+
+// Send routes the notification to its notifiers in the background. Failures
+// are logged and never reach the caller, so a broken notifier cannot block
+// or fail scheduling.
+func Send(notification Notification) {
+	sendThrough(route(notification.Event), notification)
+}
+
+// Test sends a test notification through the named notifier and waits for
+// the result.
+func Test(name string) error {
+	mutex.Lock()
+	notifier, ok := notifiers[name]
+	mutex.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown notifier %q", name)
+	}
+	return deliver(notifier, Notification{
+		Event:   EventTest,
+		Title:   "atodo test notification",
+		Message: "Notifications from atodo reach this notifier.",
+		Time:    time.Now().UnixMilli(),
+	})
+}
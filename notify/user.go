@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"atodo_go/table"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+// userSettingPrefix keys the notifier of each user among the settings.
+const userSettingPrefix = "notify.user."
+
+func userSettingKey(userID int) string {
+	return userSettingPrefix + strconv.Itoa(userID)
+}
+
+// GetUserNotifier returns the notifier through which the user receives
+// personal notifications such as digests, or nil if the user has none.
+func GetUserNotifier(ctx context.Context, userID int) (*NotifierConfig, error) {
+	value, ok, err := table.GetSetting(ctx, userSettingKey(userID))
+	if err != nil || !ok || value == "" {
+		return nil, err
+	}
+	var notifierConfig NotifierConfig
+	err = json.Unmarshal([]byte(value), &notifierConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &notifierConfig, nil
+}
+
+// SetUserNotifier validates and stores the notifier of the user, or removes
+// it when the type is empty. Secrets sent as RedactedSecret keep their
+// stored value. Desktop notifications would show on the server rather than
+// reach the user, so they are not accepted.
+func SetUserNotifier(ctx context.Context, userID int, notifierConfig NotifierConfig) error {
+	if notifierConfig.Type == "" {
+		return table.SetSetting(ctx, userSettingKey(userID), "")
+	}
+	if notifierConfig.Type == TypeDesktop {
+		return errors.New("desktop notifiers cannot be used for a user")
+	}
+	old, err := GetUserNotifier(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if old != nil {
+		notifierConfig = notifierConfig.keepSecrets(*old)
+	}
+	_, err = build(notifierConfig)
+	if err != nil {
+		return err
+	}
+	marshal, err := json.Marshal(notifierConfig)
+	if err != nil {
+		return err
+	}
+	return table.SetSetting(ctx, userSettingKey(userID), string(marshal))
+}
+
+// SendToUser delivers the notification through the notifier of the user in
+// the background, like Send, and reports whether the user has a notifier.
+func SendToUser(ctx context.Context, userID int, notification Notification) (bool, error) {
+	notifierConfig, err := GetUserNotifier(ctx, userID)
+	if err != nil || notifierConfig == nil {
+		return false, err
+	}
+	notifier, err := build(*notifierConfig)
+	if err != nil {
+		return false, err
+	}
+	sendThrough([]Notifier{notifier}, notification)
+	return true, nil
+}
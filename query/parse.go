@@ -0,0 +1,139 @@
+package query
+
+import (
+	"atodo_go/bool_expr"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse parses a query such as
+//
+//	status:todo and (tag:@office or tag:@home) and due:..7d and not overdue
+//
+// Terms are combined with "and", "or", "not" and parentheses; adjacent terms
+// are joined with "and". Supported terms:
+//
+//	status:todo|suspended|done   tag:NAME          subtree:ID
+//	trigger:event|dependency|none                  text:WORD
+//	due:FROM..TO                 overdue           available
+//
+// Bounds of due are offsets from now such as -2d, 12h or 1w, absolute dates
+// such as 2024-06-01, or "today"; either bound may be left empty.
+func Parse(query string) (Expr, error) {
+	return bool_expr.Parse(syntax, query, parseTerm)
+}
+
+var syntax = bool_expr.Syntax{
+	Name: "query",
+	And:  []string{"and"},
+	Or:   []string{"or"},
+	Not:  []string{"not"},
+}
+
+func parseTerm(token string) (Expr, error) {
+	key, value, _ := strings.Cut(token, ":")
+	switch strings.ToLower(key) {
+	case "overdue":
+		return overdueExpr{}, nil
+	case "available":
+		return availableExpr{}, nil
+	case "status":
+		status, err := parseStatus(value)
+		if err != nil {
+			return nil, err
+		}
+		return statusExpr{status: status}, nil
+	case "tag":
+		if value == "" {
+			return nil, errors.New("tag needs a name")
+		}
+		return tagExpr{name: value}, nil
+	case "subtree":
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("subtree needs a task id: %q", value)
+		}
+		return subtreeExpr{id: id}, nil
+	case "trigger":
+		value = strings.ToLower(value)
+		if value != "event" && value != "dependency" && value != "none" {
+			return nil, fmt.Errorf("unknown trigger type %q", value)
+		}
+		return triggerExpr{kind: value}, nil
+	case "text":
+		if value == "" {
+			return nil, errors.New("text needs a word")
+		}
+		return textExpr{word: strings.ToLower(value)}, nil
+	case "due":
+		return parseDue(value)
+	default:
+		return nil, fmt.Errorf("unknown query term %q", token)
+	}
+}
+
+func parseDue(value string) (Expr, error) {
+	if strings.EqualFold(value, "today") {
+		return dueExpr{from: dayBound(0), to: dayBound(1)}, nil
+	}
+	fromText, toText, isRange := strings.Cut(value, "..")
+	if !isRange {
+		fromText, toText = "", value
+	}
+	if fromText == "" && toText == "" {
+		return nil, errors.New("due needs a bound")
+	}
+	from, err := parseBound(fromText)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseBound(toText)
+	if err != nil {
+		return nil, err
+	}
+	return dueExpr{from: from, to: to}, nil
+}
+
+// bound resolves to a time relative to now when the query is evaluated, so a
+// saved "due:..7d" keeps meaning "within a week".
+type bound func(now time.Time) time.Time
+
+func dayBound(days int) bound {
+	return func(now time.Time) time.Time {
+		year, month, day := now.Date()
+		return time.Date(year, month, day+days, 0, 0, 0, 0, now.Location())
+	}
+}
+
+func parseBound(text string) (bound, error) {
+	if text == "" {
+		return nil, nil
+	}
+	if strings.EqualFold(text, "today") {
+		return dayBound(0), nil
+	}
+	if date, err := time.ParseInLocation("2006-01-02", text, time.Local); err == nil {
+		return func(time.Time) time.Time { return date }, nil
+	}
+	unit := text[len(text)-1]
+	amount, err := strconv.Atoi(text[:len(text)-1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid due bound %q", text)
+	}
+	var step time.Duration
+	switch unit {
+	case 'h':
+		step = time.Hour
+	case 'd':
+		step = 24 * time.Hour
+	case 'w':
+		step = 7 * 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("invalid due unit in %q, use h, d or w", text)
+	}
+	offset := time.Duration(amount) * step
+	return func(now time.Time) time.Time { return now.Add(offset) }, nil
+}
@@ -0,0 +1,204 @@
+package query
+
+import (
+	"atodo_go/bool_expr"
+	"atodo_go/schedule"
+	"atodo_go/table"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Expr = bool_expr.Expr[*taskContext]
+
+// taskContext loads what the terms need about a task on first use. The first
+// error is kept in err and stops evaluation.
+type taskContext struct {
+	context context.Context
+	task    table.Task
+	now     time.Time
+	parents map[int]int
+	tags    map[string]bool
+	trigger *string
+	err     error
+}
+
+func (ctx *taskContext) getTags() map[string]bool {
+	if ctx.tags == nil {
+		names, err := table.GetEffectiveTagNames(ctx.context, ctx.task.ID)
+		if err != nil {
+			ctx.err = err
+		}
+		ctx.tags = make(map[string]bool, len(names))
+		for _, name := range names {
+			ctx.tags[name] = true
+		}
+	}
+	return ctx.tags
+}
+
+func (ctx *taskContext) getTrigger() string {
+	if ctx.trigger == nil {
+		kind := "none"
+		triggers, err := table.GetTaskTriggersByID(ctx.context, ctx.task.ID)
+		if err != nil {
+			ctx.err = err
+		}
+		for _, trigger := range triggers {
+			name, err := trigger.Type.String()
+			if err == nil {
+				kind = strings.ToLower(name)
+			}
+		}
+		ctx.trigger = &kind
+	}
+	return *ctx.trigger
+}
+
+type statusExpr struct{ status table.TaskStatus }
+type tagExpr struct{ name string }
+type subtreeExpr struct{ id int }
+type triggerExpr struct{ kind string }
+type textExpr struct{ word string }
+type dueExpr struct{ from, to bound }
+type overdueExpr struct{}
+type availableExpr struct{}
+
+func (e statusExpr) Match(ctx *taskContext) bool { return ctx.task.Status == e.status }
+func (e tagExpr) Match(ctx *taskContext) bool    { return ctx.getTags()[e.name] }
+func (e triggerExpr) Match(ctx *taskContext) bool {
+	return ctx.getTrigger() == e.kind
+}
+
+func (e subtreeExpr) Match(ctx *taskContext) bool {
+	visited := make(map[int]bool)
+	id := ctx.task.ID
+	for !visited[id] {
+		visited[id] = true
+		parent, ok := ctx.parents[id]
+		if !ok {
+			return false
+		}
+		if parent == e.id {
+			return true
+		}
+		id = parent
+	}
+	return false
+}
+
+func (e textExpr) Match(ctx *taskContext) bool {
+	return strings.Contains(strings.ToLower(ctx.task.Name), e.word) ||
+		strings.Contains(strings.ToLower(ctx.task.Goal), e.word)
+}
+
+// hasDeadline reports whether the task has a deadline; tasks without one are
+// stored with the Unix epoch.
+func (ctx *taskContext) hasDeadline() bool {
+	return ctx.task.Deadline.UnixMilli() > 0
+}
+
+func (e dueExpr) Match(ctx *taskContext) bool {
+	if !ctx.hasDeadline() {
+		return false
+	}
+	if e.from != nil && ctx.task.Deadline.Before(e.from(ctx.now)) {
+		return false
+	}
+	if e.to != nil && !ctx.task.Deadline.Before(e.to(ctx.now)) {
+		return false
+	}
+	return true
+}
+
+func (overdueExpr) Match(ctx *taskContext) bool {
+	return ctx.task.Status != table.Done && ctx.hasDeadline() && ctx.task.Deadline.Before(ctx.now)
+}
+
+func (availableExpr) Match(ctx *taskContext) bool {
+	return ctx.task.IsAvailable(ctx.now)
+}
+
+func parseStatus(value string) (table.TaskStatus, error) {
+	for _, status := range []table.TaskStatus{table.Todo, table.Suspended, table.Done} {
+		name, _ := status.String()
+		if strings.EqualFold(name, value) {
+			return status, nil
+		}
+	}
+	return table.Todo, errors.New("unknown status " + value)
+}
+
+// Evaluate runs a query over every task the context's user can view and
+// returns the matches ordered by deadline.
+func Evaluate(ctx context.Context, query string) ([]schedule.TaskShow, error) {
+	expr, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := table.SearchTasks(ctx, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	parents := make(map[int]int, len(tasks))
+	for _, task := range tasks {
+		parents[task.ID] = task.ParentTask
+	}
+	now := time.Now()
+	userID := table.CurrentUserID(ctx)
+	results := make([]schedule.TaskShow, 0)
+	for _, task := range tasks {
+		ok, err := table.HasWorkspaceRole(ctx, userID, task.ID, table.RoleViewer)
+		if err != nil || !ok {
+			continue
+		}
+		ctx := &taskContext{context: ctx, task: task, now: now, parents: parents}
+		matched := expr.Match(ctx)
+		if ctx.err != nil {
+			return nil, ctx.err
+		}
+		if matched {
+			results = append(results, schedule.TaskShow{
+				Id:         task.ID,
+				Name:       task.Name,
+				Goal:       task.Goal,
+				Deadline:   task.Deadline.UnixMilli(),
+				InWorkTime: task.InWorkTime,
+			})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Deadline < results[j].Deadline
+	})
+	err = schedule.FillCommentCounts(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// SaveList validates the query and stores it under name.
+func SaveList(ctx context.Context, name, query, description string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("list name is empty")
+	}
+	_, err := Parse(query)
+	if err != nil {
+		return err
+	}
+	return table.AddOrUpdateSavedQuery(ctx, table.SavedQuery{
+		Name:        name,
+		Query:       query,
+		Description: description,
+	})
+}
+
+func EvaluateList(ctx context.Context, name string) ([]schedule.TaskShow, error) {
+	savedQuery, err := table.GetSavedQueryByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return Evaluate(ctx, savedQuery.Query)
+}
@@ -0,0 +1,56 @@
+package reminder
+
+import (
+	"atodo_go/notify"
+	"atodo_go/table"
+	"context"
+	"log"
+	"time"
+)
+
+const interval = time.Minute
+
+// Process sends a notification for every reminder that fell due and returns
+// them.
+func Process(ctx context.Context, now time.Time) ([]table.DueReminder, error) {
+	due, err := table.CollectDueReminders(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, reminder := range due {
+		notify.Send(notification(reminder))
+	}
+	return due, nil
+}
+
+func notification(reminder table.DueReminder) notify.Notification {
+	deadline := reminder.Deadline.Format("2006-01-02 15:04")
+	if reminder.Kind == table.ReminderOverdue {
+		return notify.Notification{
+			Event:   notify.EventTaskOverdue,
+			Title:   reminder.Name + " - Overdue since " + deadline,
+			Message: reminder.Goal,
+			TaskID:  reminder.TaskID,
+		}
+	}
+	return notify.Notification{
+		Event:   notify.EventTaskReminder,
+		Title:   reminder.Name + " - Due " + deadline,
+		Message: reminder.Goal,
+		TaskID:  reminder.TaskID,
+	}
+}
+
+// Start checks for due reminders every minute.
+func Start(ctx context.Context) {
+	ctx = table.WithSource(ctx, table.SourceScheduler)
+	go func() {
+		ticker := time.NewTicker(interval)
+		for now := range ticker.C {
+			_, err := Process(ctx, now)
+			if err != nil {
+				log.Println("Failed to send reminders: ", err)
+			}
+		}
+	}()
+}
@@ -1,19 +1,38 @@
 package schedule
 
 import (
+	"atodo_go/notify"
 	"atodo_go/table"
+	"atodo_go/tag_filter"
+	"context"
 	"errors"
-	"github.com/gen2brain/beeep"
 	"sort"
 	"time"
 )
 
 type TaskShow struct {
-	Id         int    `json:"id"`
-	Name       string `json:"name"`
-	Goal       string `json:"goal"`
-	Deadline   int64  `json:"deadline"`
-	InWorkTime bool   `json:"in_work_time"`
+	Id           int    `json:"id"`
+	Name         string `json:"name"`
+	Goal         string `json:"goal"`
+	Deadline     int64  `json:"deadline"`
+	InWorkTime   bool   `json:"in_work_time"`
+	CommentCount int    `json:"comment_count"`
+}
+
+// FillCommentCounts sets CommentCount on every task in place.
+func FillCommentCounts(ctx context.Context, tasks []TaskShow) error {
+	ids := make([]int, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.Id)
+	}
+	counts, err := table.GetTaskCommentCounts(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range tasks {
+		tasks[i].CommentCount = counts[tasks[i].Id]
+	}
+	return nil
 }
 
 type SuspendedInfo interface {
@@ -57,17 +76,28 @@ type EventTriggerTaskShow struct {
 	EventDescription string `json:"event_description"`
 }
 
+type UpcomingTaskShow struct {
+	Id            int    `json:"id"`
+	Name          string `json:"name"`
+	Goal          string `json:"goal"`
+	Deadline      int64  `json:"deadline"`
+	InWorkTime    bool   `json:"in_work_time"`
+	AvailableFrom int64  `json:"available_from"`
+}
+
 type TSchedule struct {
 	Tasks            []TaskShow             `json:"tasks"`
 	SuspendedTasks   []SuspendedTaskShow    `json:"suspended_tasks"`
 	EventTriggerTask []EventTriggerTaskShow `json:"event_trigger_tasks"`
+	UpcomingTasks    []UpcomingTaskShow     `json:"upcoming_tasks"`
 }
 
-func suspendedTaskPreprocess(task table.Task) (error, bool) {
+func suspendedTaskPreprocess(ctx context.Context, task table.Task) (error, bool) {
+	ctx = table.WithSource(ctx, table.SourceScheduler)
 	now := time.Now()
 	millis := now.UnixMilli()
 	updated := false
-	info, err := table.GetSuspendedTask(task.ID)
+	info, err := table.GetSuspendedTask(ctx, task.ID)
 	if err != nil {
 		return err, false
 	}
@@ -81,19 +111,21 @@ func suspendedTaskPreprocess(task table.Task) (error, bool) {
 		resumeTime = timeInfo.Timestamp
 		if resumeTime <= millis {
 			task.Status = table.Todo
-			err := table.UpdateTaskStatus(task.ID, task.Status)
+			err := table.UpdateTaskStatus(ctx, task.ID, task.Status)
 			if err != nil {
 				return err, false
 			}
-			err = table.DeleteSuspendedTasks(task.ID)
+			err = table.DeleteSuspendedTasks(ctx, task.ID)
 			if err != nil {
 				return err, false
 			}
 			updated = true
-			err = beeep.Notify(task.Name+" - Time Resumed", task.Goal, "")
-			if err != nil {
-				return err, false
-			}
+			notify.Send(notify.Notification{
+				Event:   notify.EventTaskResumed,
+				Title:   task.Name + " - Time Resumed",
+				Message: task.Goal,
+				TaskID:  task.ID,
+			})
 		}
 	case table.Email:
 		return errors.New("email type suspended task is not supported"), false
@@ -110,14 +142,108 @@ func GetFirstElementFromSet[T comparable](set map[T]bool) *T {
 	return nil
 }
 
-func Schedule() (*TSchedule, error) {
+func Schedule(ctx context.Context) (*TSchedule, error) {
+	return ScheduleFiltered(ctx, tag_filter.TagFilter{})
+}
+
+func filterShows[T any](shows []T, getId func(T) int, keep func(id int) (bool, error)) ([]T, error) {
+	filtered := make([]T, 0, len(shows))
+	for _, show := range shows {
+		ok, err := keep(getId(show))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			filtered = append(filtered, show)
+		}
+	}
+	return filtered, nil
+}
+
+// filter keeps only the tasks for which keep returns true.
+func (schedule *TSchedule) filter(keep func(id int) (bool, error)) error {
+	var err error
+	schedule.Tasks, err = filterShows(schedule.Tasks, func(show TaskShow) int { return show.Id }, keep)
+	if err != nil {
+		return err
+	}
+	schedule.SuspendedTasks, err = filterShows(schedule.SuspendedTasks, func(show SuspendedTaskShow) int { return show.Id }, keep)
+	if err != nil {
+		return err
+	}
+	schedule.EventTriggerTask, err = filterShows(schedule.EventTriggerTask, func(show EventTriggerTaskShow) int { return show.Id }, keep)
+	if err != nil {
+		return err
+	}
+	schedule.UpcomingTasks, err = filterShows(schedule.UpcomingTasks, func(show UpcomingTaskShow) int { return show.Id }, keep)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ScheduleFiltered schedules like Schedule and keeps only the tasks the
+// current user can view whose own or inherited tags match the filter.
+func ScheduleFiltered(ctx context.Context, filter tag_filter.TagFilter) (*TSchedule, error) {
+	match, err := filter.Compile()
+	if err != nil {
+		return nil, err
+	}
+	result, err := buildSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID := table.CurrentUserID(ctx)
+	err = result.filter(func(id int) (bool, error) {
+		return table.HasWorkspaceRole(ctx, userID, id, table.RoleViewer)
+	})
+	if err != nil || filter.IsEmpty() {
+		return result, err
+	}
+	err = result.filter(func(id int) (bool, error) {
+		tags, err := table.GetEffectiveTagNames(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		return match(tags), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ScheduleAssignedTo schedules like ScheduleFiltered and keeps only the tasks
+// assigned to the user.
+func ScheduleAssignedTo(ctx context.Context, userID int, filter tag_filter.TagFilter) (*TSchedule, error) {
+	result, err := ScheduleFiltered(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	assigned, err := table.GetTasksAssignedTo(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	err = result.filter(func(id int) (bool, error) {
+		return assigned[id], nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func buildSchedule(ctx context.Context) (*TSchedule, error) {
 	tasksIdSet := make(map[int]bool)
 	tasks := make([]TaskShow, 0)
 	suspendedTasksIdSet := make(map[int]bool)
 	suspendedTasks := make([]SuspendedTaskShow, 0)
 	eventTriggerTasksIdSet := make(map[int]bool)
 	eventTriggerTasks := make([]EventTriggerTaskShow, 0)
-	nowViewingTask, err := table.GetRootTask()
+	upcomingTasksIdSet := make(map[int]bool)
+	upcomingTasks := make([]UpcomingTaskShow, 0)
+	now := time.Now()
+	nowViewingTask, err := table.GetRootTask(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -127,12 +253,12 @@ func Schedule() (*TSchedule, error) {
 	subTasks := make([]int, 0)
 	for len(waitForViewing) > 0 {
 		taskId := *GetFirstElementFromSet(waitForViewing)
-		task, err := table.GetTaskByID(taskId)
+		task, err := table.GetTaskByID(ctx, taskId)
 		if err != nil {
 			return nil, err
 		}
 		if task.Status == table.Suspended {
-			err, updated := suspendedTaskPreprocess(task)
+			err, updated := suspendedTaskPreprocess(ctx, task)
 			if err != nil {
 				return nil, err
 			}
@@ -149,7 +275,7 @@ func Schedule() (*TSchedule, error) {
 				Deadline:   task.Deadline.UnixMilli(),
 				InWorkTime: task.InWorkTime,
 			}
-			suspendedTaskInfo, err := table.GetSuspendedTask(task.ID)
+			suspendedTaskInfo, err := table.GetSuspendedTask(ctx, task.ID)
 			if err != nil {
 				return nil, err
 			}
@@ -181,15 +307,30 @@ func Schedule() (*TSchedule, error) {
 			delete(waitForViewing, taskId)
 			continue
 		case table.Todo:
+			if !task.IsAvailable(now) {
+				if !upcomingTasksIdSet[task.ID] {
+					upcomingTasks = append(upcomingTasks, UpcomingTaskShow{
+						Id:            task.ID,
+						Name:          task.Name,
+						Goal:          task.Goal,
+						Deadline:      task.Deadline.UnixMilli(),
+						InWorkTime:    task.InWorkTime,
+						AvailableFrom: task.AvailableFrom.UnixMilli(),
+					})
+					upcomingTasksIdSet[task.ID] = true
+				}
+				delete(waitForViewing, taskId)
+				continue
+			}
 			sourceTasks = sourceTasks[:0]
 			subTasks = subTasks[:0]
-			sourceTasks, err = table.GetSourceTasks(task.ID)
+			sourceTasks, err = table.GetSourceTasks(ctx, task.ID)
 			if err != nil {
 				return nil, err
 			}
 			newTasks := make([]int, 0)
 			for _, taskId := range sourceTasks {
-				task, err := table.GetTaskByID(taskId)
+				task, err := table.GetTaskByID(ctx, taskId)
 				if err != nil {
 					return nil, err
 				}
@@ -206,8 +347,8 @@ func Schedule() (*TSchedule, error) {
 				continue
 			}
 
-			if table.HaveSubTasks(taskId) {
-				subTasks, err = table.GetSubTasksConnectedToEnd(taskId)
+			if table.HaveSubTasks(ctx, taskId) {
+				subTasks, err = table.GetSubTasksConnectedToEnd(ctx, taskId)
 				for _, taskId := range subTasks {
 					waitForViewing[taskId] = true
 				}
@@ -215,7 +356,7 @@ func Schedule() (*TSchedule, error) {
 				continue
 			}
 
-			taskTriggers, err := table.GetTaskTriggersByID(taskId)
+			taskTriggers, err := table.GetTaskTriggersByID(ctx, taskId)
 			if err != nil {
 				return nil, err
 			}
@@ -270,9 +411,19 @@ func Schedule() (*TSchedule, error) {
 		return eventTriggerTasks[i].Deadline < eventTriggerTasks[j].Deadline
 	})
 
+	sort.Slice(upcomingTasks, func(i, j int) bool {
+		return upcomingTasks[i].AvailableFrom < upcomingTasks[j].AvailableFrom
+	})
+
+	err = FillCommentCounts(ctx, tasks)
+	if err != nil {
+		return nil, err
+	}
+
 	return &TSchedule{
 		Tasks:            tasks,
 		SuspendedTasks:   suspendedTasks,
 		EventTriggerTask: eventTriggerTasks,
+		UpcomingTasks:    upcomingTasks,
 	}, nil
 }
@@ -0,0 +1,111 @@
+package search
+
+import (
+	"atodo_go/table"
+	"context"
+)
+
+type SearchRequest struct {
+	Query        string   `json:"query"`
+	Statuses     []string `json:"statuses"`
+	DeadlineFrom int64    `json:"deadline_from"`
+	DeadlineTo   int64    `json:"deadline_to"`
+	Workspace    int      `json:"workspace"`
+	Limit        int      `json:"limit"`
+}
+
+type SearchResult struct {
+	Id       int      `json:"id"`
+	Name     string   `json:"name"`
+	Goal     string   `json:"goal"`
+	Status   string   `json:"status"`
+	Deadline int64    `json:"deadline"`
+	Path     []string `json:"path"`
+	PathIds  []int    `json:"path_ids"`
+}
+
+const defaultLimit = 50
+
+// getPath returns the names and ids from the task up to its root, in the same
+// order as task_show.GetShowStack.
+func getPath(ctx context.Context, task table.Task) ([]string, []int, error) {
+	names := []string{task.Name}
+	ids := []int{task.ID}
+	visited := map[int]bool{task.ID: true}
+	for task.ParentTask != -1 && !visited[task.ParentTask] {
+		var err error
+		task, err = table.GetTaskByID(ctx, task.ParentTask)
+		if err != nil {
+			return nil, nil, err
+		}
+		visited[task.ID] = true
+		names = append(names, task.Name)
+		ids = append(ids, task.ID)
+	}
+	return names, ids, nil
+}
+
+func contains(ids []int, id int) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+func Search(ctx context.Context, request SearchRequest) ([]SearchResult, error) {
+	statuses := make([]table.TaskStatus, 0, len(request.Statuses))
+	for _, name := range request.Statuses {
+		var status table.TaskStatus
+		status.FromString(name)
+		statuses = append(statuses, status)
+	}
+	tasks, err := table.SearchTasks(ctx, request.Query, statuses)
+	if err != nil {
+		return nil, err
+	}
+	limit := request.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	userID := table.CurrentUserID(ctx)
+	results := make([]SearchResult, 0)
+	for _, task := range tasks {
+		if len(results) >= limit {
+			break
+		}
+		deadline := task.Deadline.UnixMilli()
+		if request.DeadlineFrom != 0 && deadline < request.DeadlineFrom {
+			continue
+		}
+		if request.DeadlineTo != 0 && deadline >= request.DeadlineTo {
+			continue
+		}
+		ok, err := table.HasWorkspaceRole(ctx, userID, task.ID, table.RoleViewer)
+		if err != nil || !ok {
+			continue
+		}
+		path, pathIds, err := getPath(ctx, task)
+		if err != nil {
+			return nil, err
+		}
+		if request.Workspace != 0 && !contains(pathIds, request.Workspace) {
+			continue
+		}
+		status, err := task.Status.String()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, SearchResult{
+			Id:       task.ID,
+			Name:     task.Name,
+			Goal:     task.Goal,
+			Status:   status,
+			Deadline: deadline,
+			Path:     path,
+			PathIds:  pathIds,
+		})
+	}
+	return results, nil
+}
@@ -1,15 +1,18 @@
 package table
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"time"
 )
 
+// defaultAppStateID is the state used outside of an authenticated request,
+// e.g. by background timers. Every user has their own row keyed by user ID.
 const defaultAppStateID = 0
 
 type AppState struct {
-	ID              int       `gorm:"primaryKey;check:id=0"`
+	ID              int       `gorm:"primaryKey;autoIncrement:false"`
 	RootTask        int       `gorm:"column:root_task"`
 	NowViewingTask  int       `gorm:"column:now_viewing_task"`
 	NowSelectedTask int       `gorm:"column:now_selected_task"`
@@ -23,6 +26,14 @@ func (AppState) TableName() string {
 }
 
 func InitAppStateTable() error {
+	// app_state used to be limited to a single row
+	if DB.Migrator().HasConstraint(&AppState{}, "chk_app_state_id") {
+		err := DB.Migrator().DropConstraint(&AppState{}, "chk_app_state_id")
+		if err != nil {
+			log.Fatal("Failed to migrate AppState table: ", err)
+			return err
+		}
+	}
 	err := DB.AutoMigrate(&AppState{})
 	if err != nil {
 		log.Fatal("Failed to migrate AppState table: ", err)
@@ -32,105 +43,131 @@ func InitAppStateTable() error {
 	return nil
 }
 
-func getAppState() (AppState, error) {
-	var appState AppState
+// getAppState returns the state of the context's user, creating it from the
+// default state the first time it is used.
+func getAppState(ctx context.Context) (AppState, error) {
+	appStateID := ActorOf(ctx).UserID
+	var appStates []AppState
 	// find by id
-	err := DB.First(&appState, defaultAppStateID).Error
+	err := db(ctx).Limit(1).Find(&appStates, appStateID).Error
+	if err != nil {
+		return AppState{}, err
+	}
+	if len(appStates) != 0 {
+		return appStates[0], nil
+	}
+	var appState AppState
+	if appStateID != defaultAppStateID {
+		db(ctx).Limit(1).Find(&appState, defaultAppStateID)
+	}
+	appState.ID = appStateID
+	err = db(ctx).Create(&appState).Error
 	if err != nil {
 		return appState, err
 	}
 	return appState, nil
 }
 
-func SetRootTask(rootTask int) error {
-	// where id = 1
-	DB.Model(&AppState{}).Where(fmt.Sprintf("id = %d", defaultAppStateID)).Update("root_task", rootTask)
-	return nil
+func SetRootTask(ctx context.Context, rootTask int) error {
+	old, _ := getAppState(ctx)
+	db(ctx).Model(&AppState{}).Where(fmt.Sprintf("id = %d", ActorOf(ctx).UserID)).Update("root_task", rootTask)
+	return audit(ctx, EntityAppState, ActorOf(ctx).UserID, "root_task", old.RootTask, rootTask)
 }
 
-func GetRootTask() (int, error) {
-	appState, err := getAppState()
+func GetRootTask(ctx context.Context) (int, error) {
+	appState, err := getAppState(ctx)
 	if err != nil {
 		return -1, err
 	}
 	return appState.RootTask, nil
 }
 
-func SetNowViewingTask(nowViewingTask int) error {
-	DB.Model(&AppState{}).Where(fmt.Sprintf("id = %d", defaultAppStateID)).Update("now_viewing_task", nowViewingTask)
-	return nil
+func SetNowViewingTask(ctx context.Context, nowViewingTask int) error {
+	old, _ := getAppState(ctx)
+	db(ctx).Model(&AppState{}).Where(fmt.Sprintf("id = %d", ActorOf(ctx).UserID)).Update("now_viewing_task", nowViewingTask)
+	return audit(ctx, EntityAppState, ActorOf(ctx).UserID, "now_viewing_task", old.NowViewingTask, nowViewingTask)
 }
 
-func GetNowViewingTask() (int, error) {
-	appState, err := getAppState()
+func GetNowViewingTask(ctx context.Context) (int, error) {
+	appState, err := getAppState(ctx)
 	if err != nil {
 		return -1, err
 	}
 	return appState.NowViewingTask, nil
 }
 
-func SetNowSelectedTask(nowSelectedTask int) error {
-	DB.Model(&AppState{}).Where(fmt.Sprintf("id = %d", defaultAppStateID)).Update("now_selected_task", nowSelectedTask)
-	return nil
+func SetNowSelectedTask(ctx context.Context, nowSelectedTask int) error {
+	old, _ := getAppState(ctx)
+	db(ctx).Model(&AppState{}).Where(fmt.Sprintf("id = %d", ActorOf(ctx).UserID)).Update("now_selected_task", nowSelectedTask)
+	return audit(ctx, EntityAppState, ActorOf(ctx).UserID, "now_selected_task", old.NowSelectedTask, nowSelectedTask)
 }
 
-func GetNowSelectedTask() (int, error) {
-	appState, err := getAppState()
+func GetNowSelectedTask(ctx context.Context) (int, error) {
+	appState, err := getAppState(ctx)
 	if err != nil {
 		return -1, err
 	}
 	return appState.NowSelectedTask, nil
 }
 
-func BackToParentTask() error {
-	nowViewingTask, err := GetNowViewingTask()
+func BackToParentTask(ctx context.Context) error {
+	nowViewingTask, err := GetNowViewingTask(ctx)
 	if nowViewingTask == -1 {
 		return err
 	}
-	task, err := GetTaskByID(nowViewingTask)
+	task, err := GetTaskByID(ctx, nowViewingTask)
 	if err != nil || task.ParentTask == -1 {
 		return err
 	}
-	err = SetNowViewingTask(task.ParentTask)
+	err = SetNowViewingTask(ctx, task.ParentTask)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func SetWorkTime(workTime int64) error {
-	DB.Model(&AppState{}).Where(fmt.Sprintf("id = %d", defaultAppStateID)).Update("work_time", time.Unix(workTime, 0))
-	return nil
+func SetWorkTime(ctx context.Context, workTime int64) error {
+	old, _ := getAppState(ctx)
+	db(ctx).Model(&AppState{}).Where(fmt.Sprintf("id = %d", ActorOf(ctx).UserID)).Update("work_time", time.Unix(workTime, 0))
+	return audit(ctx, EntityAppState, ActorOf(ctx).UserID, "work_time", old.WorkTime, time.Unix(workTime, 0))
 }
 
-func GetWorkTime() (int64, error) {
-	appState, err := getAppState()
+func GetWorkTime(ctx context.Context) (int64, error) {
+	appState, err := getAppState(ctx)
 	if err != nil {
 		return -1, err
 	}
 	return appState.WorkTime.Unix(), nil
 }
 
-func SetNowDoingTask(nowDoingTask int) error {
-	DB.Model(&AppState{}).Where(fmt.Sprintf("id = %d", defaultAppStateID)).Update("now_doing_task", nowDoingTask)
-	return nil
+func SetNowDoingTask(ctx context.Context, nowDoingTask int) error {
+	old, _ := getAppState(ctx)
+	if old.NowDoingTask != nowDoingTask {
+		err := switchTimeEntry(ctx, nowDoingTask)
+		if err != nil {
+			return err
+		}
+	}
+	db(ctx).Model(&AppState{}).Where(fmt.Sprintf("id = %d", ActorOf(ctx).UserID)).Update("now_doing_task", nowDoingTask)
+	return audit(ctx, EntityAppState, ActorOf(ctx).UserID, "now_doing_task", old.NowDoingTask, nowDoingTask)
 }
 
-func GetNowDoingTask() (int, error) {
-	appState, err := getAppState()
+func GetNowDoingTask(ctx context.Context) (int, error) {
+	appState, err := getAppState(ctx)
 	if err != nil {
 		return -1, err
 	}
 	return appState.NowDoingTask, nil
 }
 
-func SetNowIsWorkTime(nowIsWorkTime bool) error {
-	DB.Model(&AppState{}).Where(fmt.Sprintf("id = %d", defaultAppStateID)).Update("now_is_work_time", nowIsWorkTime)
-	return nil
+func SetNowIsWorkTime(ctx context.Context, nowIsWorkTime bool) error {
+	old, _ := getAppState(ctx)
+	db(ctx).Model(&AppState{}).Where(fmt.Sprintf("id = %d", ActorOf(ctx).UserID)).Update("now_is_work_time", nowIsWorkTime)
+	return audit(ctx, EntityAppState, ActorOf(ctx).UserID, "now_is_work_time", old.NowIsWorkTime, nowIsWorkTime)
 }
 
-func GetNowIsWorkTime() (bool, error) {
-	appState, err := getAppState()
+func GetNowIsWorkTime(ctx context.Context) (bool, error) {
+	appState, err := getAppState(ctx)
 	if err != nil {
 		return false, err
 	}
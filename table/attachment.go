@@ -0,0 +1,191 @@
+package table
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const EntityAttachment = "attachment"
+
+// AttachmentDir holds uploaded files, next to data.db.
+const AttachmentDir = "./attachments"
+
+type Attachment struct {
+	ID         int       `gorm:"primaryKey;autoIncrement"`
+	TaskID     int       `gorm:"column:task_id;index"`
+	FileName   string    `gorm:"column:file_name"`
+	StoredName string    `gorm:"column:stored_name"`
+	MimeType   string    `gorm:"column:mime_type"`
+	Size       int64     `gorm:"column:size"`
+	CreatedAt  time.Time `gorm:"column:created_at"`
+}
+
+func (Attachment) TableName() string {
+	return "attachment"
+}
+
+// Path returns where the attachment's content is stored on disk.
+func (attachment Attachment) Path() string {
+	return filepath.Join(AttachmentDir, attachment.StoredName)
+}
+
+type AttachmentShow struct {
+	ID        int    `json:"id"`
+	FileName  string `json:"file_name"`
+	MimeType  string `json:"mime_type"`
+	Size      int64  `json:"size"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+func InitAttachmentTable() error {
+	err := DB.AutoMigrate(&Attachment{})
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(AttachmentDir, 0755)
+}
+
+func newStoredName() (string, error) {
+	bytes := make([]byte, 16)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// writeAttachmentFile stores the content, removing what was written of it
+// if it cannot be read or written in full.
+func writeAttachmentFile(storedName string, content io.Reader) (int64, error) {
+	path := filepath.Join(AttachmentDir, storedName)
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(file, content)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return 0, err
+	}
+	return size, nil
+}
+
+func AddAttachment(ctx context.Context, taskID int, fileName, mimeType string, content io.Reader) (int, error) {
+	task, err := findTask(ctx, taskID)
+	if err != nil {
+		return -1, err
+	}
+	if task.ID == 0 {
+		return -1, errors.New("task not found")
+	}
+	storedName, err := newStoredName()
+	if err != nil {
+		return -1, err
+	}
+	size, err := writeAttachmentFile(storedName, content)
+	if err != nil {
+		return -1, err
+	}
+	attachment := Attachment{
+		TaskID:     taskID,
+		FileName:   filepath.Base(fileName),
+		StoredName: storedName,
+		MimeType:   mimeType,
+		Size:       size,
+		CreatedAt:  time.Now(),
+	}
+	err = db(ctx).Create(&attachment).Error
+	if err != nil {
+		_ = os.Remove(attachment.Path())
+		return -1, err
+	}
+	return attachment.ID, audit(ctx, EntityAttachment, attachment.ID, WholeRecord, nil, attachment)
+}
+
+func GetAttachment(ctx context.Context, id int) (Attachment, error) {
+	var attachment Attachment
+	err := db(ctx).First(&attachment, id).Error
+	return attachment, err
+}
+
+func GetAttachmentsByTaskID(ctx context.Context, taskID int) ([]AttachmentShow, error) {
+	var attachments []Attachment
+	err := db(ctx).Where("task_id = ?", taskID).Order("id").Find(&attachments).Error
+	if err != nil {
+		return nil, err
+	}
+	shows := make([]AttachmentShow, 0, len(attachments))
+	for _, attachment := range attachments {
+		shows = append(shows, AttachmentShow{
+			ID:        attachment.ID,
+			FileName:  attachment.FileName,
+			MimeType:  attachment.MimeType,
+			Size:      attachment.Size,
+			CreatedAt: attachment.CreatedAt.UnixMilli(),
+		})
+	}
+	return shows, nil
+}
+
+func DeleteAttachment(ctx context.Context, id int) error {
+	attachment, err := GetAttachment(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Delete(&Attachment{}, id).Error
+	if err != nil {
+		return err
+	}
+	err = os.Remove(attachment.Path())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return audit(ctx, EntityAttachment, id, WholeRecord, attachment, nil)
+}
+
+func DeleteAttachmentsByTaskID(ctx context.Context, taskID int) error {
+	var attachments []Attachment
+	err := db(ctx).Where("task_id = ?", taskID).Find(&attachments).Error
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		err := DeleteAttachment(ctx, attachment.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyAttachments gives the copy its own files so deleting either task keeps
+// the other's attachments intact.
+func copyAttachments(ctx context.Context, id int, newId int) error {
+	var attachments []Attachment
+	err := db(ctx).Where("task_id = ?", id).Find(&attachments).Error
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		file, err := os.Open(attachment.Path())
+		if err != nil {
+			return err
+		}
+		_, err = AddAttachment(ctx, newId, attachment.FileName, attachment.MimeType, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package table
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	SourceSystem    = "system"
+	SourceScheduler = "scheduler"
+	SourceTrigger   = "trigger"
+)
+
+const (
+	EntityTask            = "task"
+	EntityTaskRelation    = "task_relation"
+	EntityTaskTrigger     = "task_trigger"
+	EntityTaskAfterEffect = "task_after_effect"
+	EntitySuspendedTask   = "suspended_task"
+	EntityAppState        = "app_state"
+)
+
+// WholeRecord is the field recorded when a whole row is created or deleted.
+const WholeRecord = "*"
+
+type AuditLog struct {
+	ID        int       `gorm:"primaryKey;autoIncrement"`
+	Entity    string    `gorm:"column:entity;index:idx_audit_entity"`
+	EntityID  int       `gorm:"column:entity_id;index:idx_audit_entity"`
+	Field     string    `gorm:"column:field"`
+	OldValue  string    `gorm:"column:old_value;type:text"`
+	NewValue  string    `gorm:"column:new_value;type:text"`
+	Source    string    `gorm:"column:source"`
+	UserID    int       `gorm:"column:user_id;index"`
+	CreatedAt time.Time `gorm:"column:created_at;index"`
+	// WorkspaceID is the workspace of the task an entry of a task entity is
+	// about when it was made, or 0.
+	WorkspaceID int `gorm:"column:workspace_id;index"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
+
+func InitAuditLogTable() error {
+	err := DB.AutoMigrate(&AuditLog{})
+	if err != nil {
+		return err
+	}
+	return backfillAuditWorkspaces(context.Background())
+}
+
+// backfillAuditWorkspaces resolves the workspace of the entries made before
+// it was recorded. Entries of tasks that no longer exist get none.
+func backfillAuditWorkspaces(ctx context.Context) error {
+	var taskIDs []int
+	err := db(ctx).Model(&AuditLog{}).Distinct("entity_id").
+		Where("workspace_id IS NULL AND entity IN ?", taskEntityNames()).Pluck("entity_id", &taskIDs).Error
+	if err != nil {
+		return err
+	}
+	for _, taskID := range taskIDs {
+		workspaceID := auditWorkspaceID(ctx, EntityTask, taskID, nil)
+		err = db(ctx).Model(&AuditLog{}).Where("workspace_id IS NULL AND entity IN ? AND entity_id = ?", taskEntityNames(), taskID).
+			Update("workspace_id", workspaceID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return db(ctx).Model(&AuditLog{}).Where("workspace_id IS NULL").Update("workspace_id", 0).Error
+}
+
+// Actor is who the mutations made with a context are attributed to: the
+// source that made them, e.g. the route of a request, and the signed in user,
+// whose AppState is used. Without one, changes are made by the system.
+type Actor struct {
+	Source string
+	UserID int
+}
+
+type actorKey struct{}
+
+// WithActor returns a context whose mutations are attributed to the actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// WithSource attributes the mutations made with the returned context to
+// source, keeping the user.
+func WithSource(ctx context.Context, source string) context.Context {
+	actor := ActorOf(ctx)
+	actor.Source = source
+	return WithActor(ctx, actor)
+}
+
+// WithUser makes the returned context use the user's AppState, keeping the
+// source.
+func WithUser(ctx context.Context, userID int) context.Context {
+	actor := ActorOf(ctx)
+	actor.UserID = userID
+	return WithActor(ctx, actor)
+}
+
+// ActorOf returns the actor of the context.
+func ActorOf(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	if actor.Source == "" {
+		actor.Source = SourceSystem
+	}
+	return actor
+}
+
+func auditValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return fmt.Sprintf("%d", v.UnixMilli())
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprintf("%d", v.UnixMilli())
+	case TaskStatus:
+		name, _ := v.String()
+		return name
+	case int, int64, bool:
+		return fmt.Sprint(v)
+	default:
+		marshal, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(marshal)
+	}
+}
+
+func audit(ctx context.Context, entity string, entityID int, field string, oldValue, newValue any) error {
+	oldString := auditValue(oldValue)
+	newString := auditValue(newValue)
+	if field != WholeRecord && oldString == newString {
+		return nil
+	}
+	actor := ActorOf(ctx)
+	workspaceID := auditWorkspaceID(ctx, entity, entityID, oldValue)
+	err := db(ctx).Create(&AuditLog{
+		Entity:      entity,
+		EntityID:    entityID,
+		Field:       field,
+		OldValue:    oldString,
+		NewValue:    newString,
+		Source:      actor.Source,
+		UserID:      actor.UserID,
+		CreatedAt:   time.Now(),
+		WorkspaceID: workspaceID,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return publishChange(ctx, entity, entityID, workspaceID, field, oldString, newString)
+}
+
+type AuditFilter struct {
+	Entity   string `json:"entity"`
+	EntityID *int   `json:"entity_id"`
+	Field    string `json:"field"`
+	Source   string `json:"source"`
+	UserID   *int   `json:"user_id"`
+	Since    int64  `json:"since"`
+	Until    int64  `json:"until"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"page_size"`
+}
+
+type AuditLogShow struct {
+	ID        int    `json:"id"`
+	Entity    string `json:"entity"`
+	EntityID  int    `json:"entity_id"`
+	Field     string `json:"field"`
+	OldValue  string `json:"old_value"`
+	NewValue  string `json:"new_value"`
+	Source    string `json:"source"`
+	UserID    int    `json:"user_id"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type AuditPage struct {
+	Total   int64          `json:"total"`
+	Page    int            `json:"page"`
+	Entries []AuditLogShow `json:"entries"`
+}
+
+const defaultAuditPageSize = 50
+
+// taskEntities are the entities whose ID is the ID of a task.
+var taskEntities = map[string]bool{
+	EntityTask:            true,
+	EntityTaskRelation:    true,
+	EntityTaskTrigger:     true,
+	EntityTaskAfterEffect: true,
+	EntitySuspendedTask:   true,
+}
+
+// auditWorkspaceID returns the workspace of the task a change of a task
+// entity is about, or 0 if it cannot be resolved. A deleted task is resolved
+// through the parent it had, which is deleted after it.
+func auditWorkspaceID(ctx context.Context, entity string, entityID int, oldValue any) int {
+	if !taskEntities[entity] {
+		return 0
+	}
+	taskID := entityID
+	if task, ok := oldValue.(Task); ok && task.ID == entityID {
+		if task.ParentTask == -1 {
+			return task.ID
+		}
+		taskID = task.ParentTask
+	}
+	workspaceID, err := GetWorkspaceID(ctx, taskID)
+	if err != nil {
+		return 0
+	}
+	return workspaceID
+}
+
+func taskEntityNames() []string {
+	names := make([]string, 0, len(taskEntities))
+	for name := range taskEntities {
+		names = append(names, name)
+	}
+	return names
+}
+
+// visibleToUser narrows the query to the entries the user may see: their own
+// changes and those of tasks in workspaces they can view, i.e. that they are
+// a member of or that have no members. Entries of workspaces that no longer
+// exist only show to their authors.
+func visibleToUser(ctx context.Context, query *gorm.DB, userID int) *gorm.DB {
+	memberships := db(ctx).Model(&WorkspaceMember{}).Select("workspace_id")
+	workspaces := db(ctx).Model(&Task{}).Select("id").Where("parent_task = ?", -1)
+	return query.Where(
+		db(ctx).Where("user_id = ?", userID).
+			Or("workspace_id IN (?)", memberships.Session(&gorm.Session{}).Where("user_id = ?", userID)).
+			Or("workspace_id IN (?) AND workspace_id NOT IN (?)", workspaces, memberships),
+	)
+}
+
+// QueryAuditLog returns matching entries newest first. Pages start at 0.
+// Signed in users only see their own changes and those of the tasks they can
+// view.
+func QueryAuditLog(ctx context.Context, filter AuditFilter) (AuditPage, error) {
+	query := db(ctx).Model(&AuditLog{})
+	if filter.Entity != "" {
+		query = query.Where("entity = ?", filter.Entity)
+	}
+	if filter.EntityID != nil {
+		query = query.Where("entity_id = ?", *filter.EntityID)
+	}
+	if filter.Field != "" {
+		query = query.Where("field = ?", filter.Field)
+	}
+	if filter.Source != "" {
+		query = query.Where("source LIKE ?", filter.Source+"%")
+	}
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.Since != 0 {
+		query = query.Where("created_at >= ?", time.UnixMilli(filter.Since))
+	}
+	if filter.Until != 0 {
+		query = query.Where("created_at < ?", time.UnixMilli(filter.Until))
+	}
+	page := AuditPage{Page: filter.Page, Entries: []AuditLogShow{}}
+	pageSize := filter.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultAuditPageSize
+	}
+	if userID := ActorOf(ctx).UserID; userID != defaultAppStateID {
+		query = visibleToUser(ctx, query, userID)
+	}
+	err := query.Count(&page.Total).Error
+	if err != nil {
+		return page, err
+	}
+	var logs []AuditLog
+	err = query.Order("id DESC").Limit(pageSize).Offset(max(filter.Page, 0) * pageSize).Find(&logs).Error
+	if err != nil {
+		return page, err
+	}
+	for _, log := range logs {
+		page.Entries = append(page.Entries, AuditLogShow{
+			ID:        log.ID,
+			Entity:    log.Entity,
+			EntityID:  log.EntityID,
+			Field:     log.Field,
+			OldValue:  log.OldValue,
+			NewValue:  log.NewValue,
+			Source:    log.Source,
+			UserID:    log.UserID,
+			CreatedAt: log.CreatedAt.UnixMilli(),
+		})
+	}
+	return page, nil
+}
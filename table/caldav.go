@@ -0,0 +1,168 @@
+package table
+
+import (
+	"context"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// CaldavResource remembers the resource name and UID a CalDAV client chose
+// for a task it created. Other tasks are served as "<id>.ics" with a UID
+// derived from their ID. Rows of eliminated tasks are kept, marked Deleted,
+// so sync reports can name the resources that went away.
+type CaldavResource struct {
+	TaskID  int    `gorm:"primaryKey;autoIncrement:false;column:task_id"`
+	Name    string `gorm:"column:name;index"`
+	UID     string `gorm:"column:uid;index"`
+	Deleted bool   `gorm:"column:deleted"`
+}
+
+func (CaldavResource) TableName() string {
+	return "caldav_resource"
+}
+
+// CaldavVisibility remembers whether a user could view a task when the
+// user's CalDAV collection was last listed, and the latest audit log entry
+// at the time this last changed. Sync reports use it to announce the tasks a
+// user gained or lost access to, and to name as removed only the tasks the
+// user could see before.
+type CaldavVisibility struct {
+	UserID    int  `gorm:"primaryKey;autoIncrement:false;column:user_id"`
+	TaskID    int  `gorm:"primaryKey;autoIncrement:false;column:task_id"`
+	Visible   bool `gorm:"column:visible"`
+	ChangedAt int  `gorm:"column:changed_at"`
+}
+
+func (CaldavVisibility) TableName() string {
+	return "caldav_visibility"
+}
+
+// TaskVersion is the latest audit log entry of a task, which changes with
+// every change to the task.
+type TaskVersion struct {
+	AuditID    int
+	ModifiedAt time.Time
+}
+
+func InitCaldavTable() error {
+	err := DB.AutoMigrate(&CaldavResource{}, &CaldavVisibility{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetCaldavResources returns the resources by task ID, including those of
+// eliminated tasks.
+func GetCaldavResources(ctx context.Context) (map[int]CaldavResource, error) {
+	var resources []CaldavResource
+	err := db(ctx).Find(&resources).Error
+	if err != nil {
+		return nil, err
+	}
+	byTask := make(map[int]CaldavResource, len(resources))
+	for _, resource := range resources {
+		byTask[resource.TaskID] = resource
+	}
+	return byTask, nil
+}
+
+func findCaldavResource(ctx context.Context, column string, value string) (*CaldavResource, error) {
+	var resources []CaldavResource
+	err := db(ctx).Where(column+" = ? AND deleted = ?", value, false).Limit(1).Find(&resources).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) == 0 {
+		return nil, nil
+	}
+	return &resources[0], nil
+}
+
+func GetCaldavResourceByName(ctx context.Context, name string) (*CaldavResource, error) {
+	return findCaldavResource(ctx, "name", name)
+}
+
+func GetCaldavResourceByUID(ctx context.Context, uid string) (*CaldavResource, error) {
+	return findCaldavResource(ctx, "uid", uid)
+}
+
+func SaveCaldavResource(ctx context.Context, resource CaldavResource) error {
+	err := db(ctx).Save(&resource).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func MarkCaldavResourceDeleted(ctx context.Context, taskID int) error {
+	err := db(ctx).Model(&CaldavResource{}).Where("task_id = ?", taskID).Update("deleted", true).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetCaldavVisibilities returns the visibilities of the user by task ID.
+func GetCaldavVisibilities(ctx context.Context, userID int) (map[int]CaldavVisibility, error) {
+	var visibilities []CaldavVisibility
+	err := db(ctx).Find(&visibilities, "user_id = ?", userID).Error
+	if err != nil {
+		return nil, err
+	}
+	byTask := make(map[int]CaldavVisibility, len(visibilities))
+	for _, visibility := range visibilities {
+		byTask[visibility.TaskID] = visibility
+	}
+	return byTask, nil
+}
+
+// SaveCaldavVisibility is not audited, as that would change the sync token
+// on every listing. Save would insert the rows of the system user 0 anew.
+func SaveCaldavVisibility(ctx context.Context, visibility CaldavVisibility) error {
+	err := db(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&visibility).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetTaskVersions returns the version of every task that was audited.
+func GetTaskVersions(ctx context.Context) (map[int]TaskVersion, error) {
+	latest := db(ctx).Model(&AuditLog{}).Select("MAX(id)").Where("entity = ?", EntityTask).Group("entity_id")
+	var entries []AuditLog
+	err := db(ctx).Where("id IN (?)", latest).Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[int]TaskVersion, len(entries))
+	for _, entry := range entries {
+		versions[entry.EntityID] = TaskVersion{AuditID: entry.ID, ModifiedAt: entry.CreatedAt}
+	}
+	return versions, nil
+}
+
+// GetLatestAuditID returns the ID of the newest audit log entry, or 0.
+func GetLatestAuditID(ctx context.Context) (int, error) {
+	var ids []int
+	err := db(ctx).Model(&AuditLog{}).Order("id DESC").Limit(1).Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return ids[0], nil
+}
+
+// GetTaskChangesSince returns the IDs of the tasks changed after the audit
+// log entry since.
+func GetTaskChangesSince(ctx context.Context, since int) ([]int, error) {
+	var ids []int
+	err := db(ctx).Model(&AuditLog{}).Distinct("entity_id").
+		Where("entity = ? AND id > ?", EntityTask, since).Order("entity_id").Pluck("entity_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
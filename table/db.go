@@ -1,6 +1,8 @@
 package table
 
 import (
+	"atodo_go/event_bus"
+	"context"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"log"
@@ -8,6 +10,41 @@ import (
 
 var DB *gorm.DB
 
+type txKey struct{}
+
+type pendingKey struct{}
+
+// db returns the transaction the context runs in, or DB outside of one.
+func db(ctx context.Context) *gorm.DB {
+	tx, ok := ctx.Value(txKey{}).(*gorm.DB)
+	if ok {
+		return tx
+	}
+	return DB
+}
+
+// Transaction runs fn with a context whose table functions share one
+// transaction, committed if fn returns nil and rolled back otherwise. Events
+// of the changes are published after the commit. Nested calls join the
+// outer transaction.
+func Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+	pending := make([]event_bus.Event, 0)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		txCtx := context.WithValue(ctx, txKey{}, tx)
+		return fn(context.WithValue(txCtx, pendingKey{}, &pending))
+	})
+	if err != nil {
+		return err
+	}
+	for _, event := range pending {
+		event_bus.Publish(event)
+	}
+	return nil
+}
+
 func InitDB() error {
 	// check if db file exists
 	// if not, create it
@@ -16,7 +53,9 @@ func InitDB() error {
 	if DB == nil {
 		// try to open db
 		// if failed, create it
-		DB, err = gorm.Open(sqlite.Open("./data.db"), &gorm.Config{})
+		// requests run concurrently, so writers wait for each other instead
+		// of failing, and transactions take the write lock up front
+		DB, err = gorm.Open(sqlite.Open("./data.db?_busy_timeout=5000&_txlock=immediate"), &gorm.Config{})
 		if err != nil {
 			log.Fatal("Failed to open db: ", err)
 			return err
@@ -49,6 +88,82 @@ func InitDB() error {
 		if err != nil {
 			return err
 		}
+		err = InitTaskCompletionTable()
+		if err != nil {
+			return err
+		}
+		err = InitAuditLogTable()
+		if err != nil {
+			return err
+		}
+		err = InitTimeEntryTable()
+		if err != nil {
+			return err
+		}
+		err = InitFocusSessionTable()
+		if err != nil {
+			return err
+		}
+		err = InitTagTable()
+		if err != nil {
+			return err
+		}
+		err = InitTaskSearchTable()
+		if err != nil {
+			return err
+		}
+		err = InitSavedQueryTable()
+		if err != nil {
+			return err
+		}
+		err = InitTaskNoteTable()
+		if err != nil {
+			return err
+		}
+		err = InitAttachmentTable()
+		if err != nil {
+			return err
+		}
+		err = InitTaskCommentTable()
+		if err != nil {
+			return err
+		}
+		err = InitUserTable()
+		if err != nil {
+			return err
+		}
+		err = InitWorkspaceTable()
+		if err != nil {
+			return err
+		}
+		err = InitTaskOverdueTable()
+		if err != nil {
+			return err
+		}
+		err = InitWebhookTable()
+		if err != nil {
+			return err
+		}
+		err = InitInboundHookTable()
+		if err != nil {
+			return err
+		}
+		err = InitSettingTable()
+		if err != nil {
+			return err
+		}
+		err = InitReminderTable()
+		if err != nil {
+			return err
+		}
+		err = InitDigestTable()
+		if err != nil {
+			return err
+		}
+		err = InitCaldavTable()
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
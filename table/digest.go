@@ -0,0 +1,156 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const EntityDigestSubscription = "digest_subscription"
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestSubscription delivers the digest of a user through the user's
+// notifier every day, or every week on Weekday, at Hour local time.
+type DigestSubscription struct {
+	UserID     int        `gorm:"primaryKey;autoIncrement:false;column:user_id"`
+	Period     string     `gorm:"column:period"`
+	Hour       int        `gorm:"column:hour"`
+	Weekday    int        `gorm:"column:weekday"`
+	Format     string     `gorm:"column:format"`
+	LastSentAt *time.Time `gorm:"column:last_sent_at"`
+}
+
+func (DigestSubscription) TableName() string {
+	return "digest_subscription"
+}
+
+type DigestSubscriptionShow struct {
+	Period     string `json:"period"`
+	Hour       int    `json:"hour"`
+	Weekday    int    `json:"weekday"`
+	Format     string `json:"format"`
+	LastSentAt int64  `json:"last_sent_at"`
+}
+
+func InitDigestTable() error {
+	err := DB.AutoMigrate(&DigestSubscription{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (subscription DigestSubscription) Show() DigestSubscriptionShow {
+	show := DigestSubscriptionShow{
+		Period:  subscription.Period,
+		Hour:    subscription.Hour,
+		Weekday: subscription.Weekday,
+		Format:  subscription.Format,
+	}
+	if subscription.LastSentAt != nil {
+		show.LastSentAt = subscription.LastSentAt.UnixMilli()
+	}
+	return show
+}
+
+// LastDue returns the most recent time at or before now the digest was due.
+func (subscription DigestSubscription) LastDue(now time.Time) time.Time {
+	year, month, day := now.Date()
+	due := time.Date(year, month, day, subscription.Hour, 0, 0, 0, now.Location())
+	if subscription.Period == DigestWeekly {
+		due = due.AddDate(0, 0, subscription.Weekday-int(now.Weekday()))
+	}
+	for due.After(now) {
+		if subscription.Period == DigestWeekly {
+			due = due.AddDate(0, 0, -7)
+		} else {
+			due = due.AddDate(0, 0, -1)
+		}
+	}
+	return due
+}
+
+// IsDue reports whether the digest is due and was not sent since.
+func (subscription DigestSubscription) IsDue(now time.Time) bool {
+	return subscription.LastSentAt == nil || subscription.LastSentAt.Before(subscription.LastDue(now))
+}
+
+func GetDigestSubscription(ctx context.Context, userID int) (*DigestSubscription, error) {
+	var subscriptions []DigestSubscription
+	err := db(ctx).Where("user_id = ?", userID).Limit(1).Find(&subscriptions).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(subscriptions) == 0 {
+		return nil, nil
+	}
+	return &subscriptions[0], nil
+}
+
+func GetDigestSubscriptions(ctx context.Context) ([]DigestSubscription, error) {
+	var subscriptions []DigestSubscription
+	err := db(ctx).Order("user_id").Find(&subscriptions).Error
+	if err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// SetDigestSubscription subscribes the user to the digest. The digest that
+// was last due before now is not sent, only the next one.
+func SetDigestSubscription(ctx context.Context, userID int, period string, hour int, weekday int, format string, now time.Time) error {
+	if period != DigestDaily && period != DigestWeekly {
+		return errors.New("unknown digest period: " + period)
+	}
+	if hour < 0 || hour > 23 {
+		return errors.New("hour must be between 0 and 23")
+	}
+	if weekday < 0 || weekday > 6 {
+		return errors.New("weekday must be between 0 and 6")
+	}
+	old, err := GetDigestSubscription(ctx, userID)
+	if err != nil {
+		return err
+	}
+	subscription := DigestSubscription{
+		UserID:     userID,
+		Period:     period,
+		Hour:       hour,
+		Weekday:    weekday,
+		Format:     format,
+		LastSentAt: &now,
+	}
+	err = db(ctx).Save(&subscription).Error
+	if err != nil {
+		return err
+	}
+	var oldShow any
+	if old != nil {
+		oldShow = old.Show()
+	}
+	return audit(ctx, EntityDigestSubscription, userID, WholeRecord, oldShow, subscription.Show())
+}
+
+func DeleteDigestSubscription(ctx context.Context, userID int) error {
+	old, err := GetDigestSubscription(ctx, userID)
+	if err != nil || old == nil {
+		return err
+	}
+	err = db(ctx).Delete(&DigestSubscription{}, "user_id = ?", userID).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityDigestSubscription, userID, WholeRecord, old.Show(), nil)
+}
+
+func MarkDigestSent(ctx context.Context, userID int, at time.Time) error {
+	err := db(ctx).Model(&DigestSubscription{}).Where("user_id = ?", userID).Update("last_sent_at", at).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
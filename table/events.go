@@ -0,0 +1,96 @@
+package table
+
+import (
+	"atodo_go/event_bus"
+	"context"
+	"time"
+)
+
+// publishChange announces an audited change on the event bus. Events only
+// carry IDs, so clients fetch the new data through the usual endpoints.
+func publishChange(ctx context.Context, entity string, entityID int, workspaceID int, field string, oldValue, newValue string) error {
+	actor := ActorOf(ctx)
+	event := event_bus.Event{
+		Type:        event_bus.Changed,
+		Entity:      entity,
+		EntityID:    entityID,
+		Field:       field,
+		Source:      actor.Source,
+		UserID:      actor.UserID,
+		WorkspaceID: workspaceID,
+	}
+	switch entity {
+	case EntityTask:
+		switch {
+		case field == WholeRecord && oldValue == "":
+			event.Type = event_bus.TaskCreated
+		case field == WholeRecord && newValue == "":
+			event.Type = event_bus.TaskEliminated
+		case field == "status" && newValue == "Done":
+			event.Type = event_bus.TaskCompleted
+		case field == "status" && oldValue == "Suspended" && newValue == "Todo":
+			event.Type = event_bus.TaskResumed
+		default:
+			event.Type = event_bus.TaskUpdated
+		}
+	case EntityTaskRelation:
+		event.Type = event_bus.RelationChanged
+	case EntityAppState:
+		event.Type = event_bus.StateChanged
+	}
+	return publish(ctx, event)
+}
+
+// publishTaskEvent announces something that happened to a task without
+// changing one of its fields.
+func publishTaskEvent(ctx context.Context, eventType string, taskID int) error {
+	actor := ActorOf(ctx)
+	return publish(ctx, event_bus.Event{
+		Type:        eventType,
+		Entity:      EntityTask,
+		EntityID:    taskID,
+		Source:      actor.Source,
+		UserID:      actor.UserID,
+		WorkspaceID: auditWorkspaceID(ctx, EntityTask, taskID, nil),
+	})
+}
+
+// workspaceViewers returns the users who can view the workspace, or nil if
+// it has no members and every user can.
+func workspaceViewers(ctx context.Context, workspaceID int) ([]int, error) {
+	members, err := getWorkspaceMembers(ctx, workspaceID)
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	viewers := make([]int, 0, len(members))
+	for _, member := range members {
+		viewers = append(viewers, member.UserID)
+	}
+	return viewers, nil
+}
+
+// publish records who may see the event, queues the webhook deliveries of the event and announces it, or
+// inside a transaction once it is committed.
+func publish(ctx context.Context, event event_bus.Event) error {
+	event.Time = time.Now().UnixMilli()
+	if event.WorkspaceID != 0 {
+		// the members are resolved now, as they are gone once their workspace
+		// is eliminated
+		viewers, err := workspaceViewers(ctx, event.WorkspaceID)
+		if err != nil {
+			return err
+		}
+		event.Viewers = viewers
+	}
+	err := queueWebhookDeliveries(ctx, event)
+	if err != nil {
+		return err
+	}
+	pending, ok := ctx.Value(pendingKey{}).(*[]event_bus.Event)
+	if ok {
+		*pending = append(*pending, event)
+		return nil
+	}
+	event_bus.Publish(event)
+	return nil
+}
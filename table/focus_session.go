@@ -0,0 +1,138 @@
+package table
+
+import (
+	"context"
+	"time"
+)
+
+const EntityFocusSession = "focus_session"
+
+type FocusSessionKind string
+
+const (
+	FocusKind      FocusSessionKind = "focus"
+	ShortBreakKind FocusSessionKind = "short_break"
+	LongBreakKind  FocusSessionKind = "long_break"
+)
+
+type FocusSessionStatus string
+
+const (
+	FocusRunning   FocusSessionStatus = "running"
+	FocusPaused    FocusSessionStatus = "paused"
+	FocusCompleted FocusSessionStatus = "completed"
+	FocusCancelled FocusSessionStatus = "cancelled"
+)
+
+type FocusSession struct {
+	ID        int                `gorm:"primaryKey;autoIncrement"`
+	TaskID    int                `gorm:"column:task_id;index"`
+	UserID    int                `gorm:"column:user_id;index"`
+	Kind      FocusSessionKind   `gorm:"column:kind"`
+	Status    FocusSessionStatus `gorm:"column:status;index"`
+	Cycle     int                `gorm:"column:cycle"`
+	Length    int64              `gorm:"column:length"`
+	Elapsed   int64              `gorm:"column:elapsed"`
+	StartTime time.Time          `gorm:"column:start_time"`
+	ResumedAt time.Time          `gorm:"column:resumed_at"`
+	EndTime   *time.Time         `gorm:"column:end_time"`
+}
+
+func (FocusSession) TableName() string {
+	return "focus_session"
+}
+
+// Remaining returns the milliseconds left in the session at now.
+func (session FocusSession) Remaining(now time.Time) int64 {
+	elapsed := session.Elapsed
+	if session.Status == FocusRunning {
+		elapsed += now.Sub(session.ResumedAt).Milliseconds()
+	}
+	return session.Length - elapsed
+}
+
+func InitFocusSessionTable() error {
+	err := DB.AutoMigrate(&FocusSession{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// AddFocusSession records a session of the current user.
+func AddFocusSession(ctx context.Context, session FocusSession) (int, error) {
+	session.UserID = ActorOf(ctx).UserID
+	err := db(ctx).Create(&session).Error
+	if err != nil {
+		return -1, err
+	}
+	return session.ID, audit(ctx, EntityFocusSession, session.ID, WholeRecord, nil, session)
+}
+
+func UpdateFocusSession(ctx context.Context, session FocusSession) error {
+	var old FocusSession
+	err := db(ctx).First(&old, session.ID).Error
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Save(&session).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityFocusSession, session.ID, "status", string(old.Status), string(session.Status))
+}
+
+// GetActiveFocusSession returns the running or paused session of the
+// current user, if any.
+func GetActiveFocusSession(ctx context.Context) (*FocusSession, error) {
+	var sessions []FocusSession
+	err := db(ctx).Where("status IN ? AND user_id = ?", []FocusSessionStatus{FocusRunning, FocusPaused}, ActorOf(ctx).UserID).
+		Order("id DESC").Limit(1).Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+	return &sessions[0], nil
+}
+
+// GetRunningFocusSessions returns the running sessions of every user.
+func GetRunningFocusSessions(ctx context.Context) ([]FocusSession, error) {
+	var sessions []FocusSession
+	err := db(ctx).Where("status = ?", FocusRunning).Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// GetLastFocusSession returns the latest session of the current user.
+func GetLastFocusSession(ctx context.Context) (*FocusSession, error) {
+	var sessions []FocusSession
+	err := db(ctx).Where("user_id = ?", ActorOf(ctx).UserID).Order("id DESC").Limit(1).Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+	return &sessions[0], nil
+}
+
+func GetFocusSessionsByTaskID(ctx context.Context, taskID int) ([]FocusSession, error) {
+	var sessions []FocusSession
+	err := db(ctx).Order("id").Find(&sessions, "task_id = ?", taskID).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func DeleteFocusSessionsByTaskID(ctx context.Context, taskID int) error {
+	err := db(ctx).Delete(&FocusSession{}, "task_id = ?", taskID).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
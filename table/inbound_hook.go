@@ -0,0 +1,274 @@
+package table
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gorm.io/datatypes"
+	"strings"
+	"time"
+)
+
+const EntityInboundHook = "inbound_hook"
+
+// InboundHook fires the Event triggers named EventName when its secret URL is
+// called. Only the token hash is stored; the secret signs requests.
+type InboundHook struct {
+	ID          int            `gorm:"primaryKey;autoIncrement"`
+	UserID      int            `gorm:"column:user_id;index"`
+	EventName   string         `gorm:"column:event_name"`
+	TokenHash   string         `gorm:"column:token_hash;uniqueIndex"`
+	Secret      string         `gorm:"column:secret"`
+	Match       datatypes.JSON `gorm:"column:payload_match"`
+	CreatedAt   time.Time      `gorm:"column:created_at"`
+	LastFiredAt *time.Time     `gorm:"column:last_fired_at"`
+}
+
+func (InboundHook) TableName() string {
+	return "inbound_hook"
+}
+
+// InboundHookReceipt remembers a signature that was accepted so the same
+// request cannot be replayed while its timestamp is still valid.
+type InboundHookReceipt struct {
+	HookID        int       `gorm:"primaryKey;column:hook_id"`
+	SignatureHash string    `gorm:"primaryKey;column:signature_hash"`
+	ReceivedAt    time.Time `gorm:"column:received_at;index"`
+}
+
+func (InboundHookReceipt) TableName() string {
+	return "inbound_hook_receipt"
+}
+
+// TaskEventPayload is the payload of the request that released a task.
+type TaskEventPayload struct {
+	ID         int       `gorm:"primaryKey;autoIncrement"`
+	TaskID     int       `gorm:"column:task_id;index"`
+	EventName  string    `gorm:"column:event_name"`
+	Payload    string    `gorm:"column:payload;type:text"`
+	ReceivedAt time.Time `gorm:"column:received_at"`
+}
+
+func (TaskEventPayload) TableName() string {
+	return "task_event_payload"
+}
+
+type InboundHookShow struct {
+	ID          int            `json:"id"`
+	EventName   string         `json:"event_name"`
+	Match       map[string]any `json:"match"`
+	CreatedAt   int64          `json:"created_at"`
+	LastFiredAt int64          `json:"last_fired_at"`
+}
+
+type TaskEventPayloadShow struct {
+	EventName  string `json:"event_name"`
+	Payload    string `json:"payload"`
+	ReceivedAt int64  `json:"received_at"`
+}
+
+func InitInboundHookTable() error {
+	err := DB.AutoMigrate(&InboundHook{}, &InboundHookReceipt{}, &TaskEventPayload{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (hook InboundHook) GetMatch() map[string]any {
+	match := make(map[string]any)
+	_ = json.Unmarshal(hook.Match, &match)
+	return match
+}
+
+func (hook InboundHook) Show() InboundHookShow {
+	show := InboundHookShow{
+		ID:        hook.ID,
+		EventName: hook.EventName,
+		Match:     hook.GetMatch(),
+		CreatedAt: hook.CreatedAt.UnixMilli(),
+	}
+	if hook.LastFiredAt != nil {
+		show.LastFiredAt = hook.LastFiredAt.UnixMilli()
+	}
+	return show
+}
+
+// CreateInboundHook returns the ID, the URL token and the signing secret of
+// the new hook. Neither can be read again.
+func CreateInboundHook(ctx context.Context, userID int, eventName string, match map[string]any) (int, string, string, error) {
+	eventName = strings.TrimSpace(eventName)
+	if eventName == "" {
+		return -1, "", "", errors.New("event name must not be empty")
+	}
+	token, err := newToken()
+	if err != nil {
+		return -1, "", "", err
+	}
+	secret, err := newToken()
+	if err != nil {
+		return -1, "", "", err
+	}
+	if match == nil {
+		match = map[string]any{}
+	}
+	marshal, err := json.Marshal(match)
+	if err != nil {
+		return -1, "", "", err
+	}
+	hook := InboundHook{
+		UserID:    userID,
+		EventName: eventName,
+		TokenHash: hashToken(token),
+		Secret:    secret,
+		Match:     marshal,
+		CreatedAt: time.Now(),
+	}
+	err = db(ctx).Create(&hook).Error
+	if err != nil {
+		return -1, "", "", err
+	}
+	err = audit(ctx, EntityInboundHook, hook.ID, WholeRecord, nil, hook.Show())
+	if err != nil {
+		return -1, "", "", err
+	}
+	return hook.ID, token, secret, nil
+}
+
+func GetInboundHookByToken(ctx context.Context, token string) (*InboundHook, error) {
+	var hooks []InboundHook
+	err := db(ctx).Where("token_hash = ?", hashToken(token)).Limit(1).Find(&hooks).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(hooks) == 0 {
+		return nil, errors.New("inbound hook not found")
+	}
+	return &hooks[0], nil
+}
+
+func GetInboundHooksByUser(ctx context.Context, userID int) ([]InboundHookShow, error) {
+	var hooks []InboundHook
+	err := db(ctx).Where("user_id = ?", userID).Order("id").Find(&hooks).Error
+	if err != nil {
+		return nil, err
+	}
+	shows := make([]InboundHookShow, 0, len(hooks))
+	for _, hook := range hooks {
+		shows = append(shows, hook.Show())
+	}
+	return shows, nil
+}
+
+func DeleteInboundHook(ctx context.Context, userID int, id int) error {
+	var hooks []InboundHook
+	err := db(ctx).Where("id = ? AND user_id = ?", id, userID).Limit(1).Find(&hooks).Error
+	if err != nil {
+		return err
+	}
+	if len(hooks) == 0 {
+		return errors.New("inbound hook not found")
+	}
+	err = db(ctx).Delete(&InboundHookReceipt{}, "hook_id = ?", id).Error
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Delete(&InboundHook{}, id).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityInboundHook, id, WholeRecord, hooks[0].Show(), nil)
+}
+
+// RecordInboundHookReceipt stores the signature of an accepted request and
+// reports false if it was already seen. Receipts older than keepSince are
+// pruned, since their timestamps would be rejected anyway.
+func RecordInboundHookReceipt(ctx context.Context, hookID int, signatureHash string, now time.Time, keepSince time.Time) (bool, error) {
+	err := db(ctx).Delete(&InboundHookReceipt{}, "received_at < ?", keepSince).Error
+	if err != nil {
+		return false, err
+	}
+	var count int64
+	err = db(ctx).Model(&InboundHookReceipt{}).
+		Where("hook_id = ? AND signature_hash = ?", hookID, signatureHash).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	if count != 0 {
+		return false, nil
+	}
+	err = db(ctx).Create(&InboundHookReceipt{HookID: hookID, SignatureHash: signatureHash, ReceivedAt: now}).Error
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FireEventTriggersByName fires the Event triggers with the given name on
+// every task the user may edit and stores the payload on each of them.
+func FireEventTriggersByName(ctx context.Context, userID int, eventName string, payload string, now time.Time) ([]int, error) {
+	var taskTriggers []TaskTrigger
+	err := db(ctx).Find(&taskTriggers, "type = ?", Event).Error
+	if err != nil {
+		return nil, err
+	}
+	fired := make([]int, 0)
+	for _, taskTrigger := range taskTriggers {
+		info, err := taskTrigger.GetEventInfo()
+		if err != nil || info.EventName != eventName {
+			continue
+		}
+		ok, err := HasWorkspaceRole(ctx, userID, taskTrigger.ID, RoleEditor)
+		if err != nil || !ok {
+			continue
+		}
+		err = FireEventTrigger(ctx, taskTrigger.ID)
+		if err != nil {
+			return nil, err
+		}
+		err = db(ctx).Create(&TaskEventPayload{
+			TaskID:     taskTrigger.ID,
+			EventName:  eventName,
+			Payload:    payload,
+			ReceivedAt: now,
+		}).Error
+		if err != nil {
+			return nil, err
+		}
+		fired = append(fired, taskTrigger.ID)
+	}
+	return fired, nil
+}
+
+func MarkInboundHookFired(ctx context.Context, id int, now time.Time) error {
+	err := db(ctx).Model(&InboundHook{}).Where("id = ?", id).Update("last_fired_at", now).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func GetTaskEventPayloads(ctx context.Context, taskID int) ([]TaskEventPayloadShow, error) {
+	var payloads []TaskEventPayload
+	err := db(ctx).Where("task_id = ?", taskID).Order("id").Find(&payloads).Error
+	if err != nil {
+		return nil, err
+	}
+	shows := make([]TaskEventPayloadShow, 0, len(payloads))
+	for _, payload := range payloads {
+		shows = append(shows, TaskEventPayloadShow{
+			EventName:  payload.EventName,
+			Payload:    payload.Payload,
+			ReceivedAt: payload.ReceivedAt.UnixMilli(),
+		})
+	}
+	return shows, nil
+}
+
+func DeleteTaskEventPayloadsByTaskID(ctx context.Context, taskID int) error {
+	err := db(ctx).Delete(&TaskEventPayload{}, "task_id = ?", taskID).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
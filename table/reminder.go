@@ -0,0 +1,389 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm/clause"
+	"sort"
+	"time"
+)
+
+const (
+	EntityTaskReminder      = "task_reminder"
+	EntityWorkspaceReminder = "workspace_reminder"
+)
+
+const (
+	ReminderBefore  = "before"
+	ReminderOverdue = "overdue"
+)
+
+// TaskReminder reminds of the task Offset milliseconds before its deadline.
+type TaskReminder struct {
+	TaskID int   `gorm:"primaryKey;column:task_id"`
+	Offset int64 `gorm:"primaryKey;column:offset_ms"`
+}
+
+func (TaskReminder) TableName() string {
+	return "task_reminder"
+}
+
+// WorkspaceReminder is a default rule of a workspace. Before rules remind of
+// tasks that have no reminders of their own; Overdue rules escalate Offset
+// milliseconds after the deadline of every task in the workspace.
+type WorkspaceReminder struct {
+	WorkspaceID int    `gorm:"primaryKey;column:workspace_id"`
+	Kind        string `gorm:"primaryKey;column:kind"`
+	Offset      int64  `gorm:"primaryKey;column:offset_ms"`
+}
+
+func (WorkspaceReminder) TableName() string {
+	return "workspace_reminder"
+}
+
+// ReminderSent remembers the deadline a reminder last fired for, so each
+// reminder fires once and moving the deadline arms it again.
+type ReminderSent struct {
+	TaskID   int       `gorm:"primaryKey;column:task_id"`
+	Kind     string    `gorm:"primaryKey;column:kind"`
+	Offset   int64     `gorm:"primaryKey;column:offset_ms"`
+	Deadline time.Time `gorm:"column:deadline"`
+}
+
+func (ReminderSent) TableName() string {
+	return "reminder_sent"
+}
+
+// ReminderSnooze holds back the reminders of a task until Until.
+type ReminderSnooze struct {
+	TaskID int       `gorm:"primaryKey;column:task_id"`
+	Until  time.Time `gorm:"column:until"`
+}
+
+func (ReminderSnooze) TableName() string {
+	return "reminder_snooze"
+}
+
+type TaskRemindersShow struct {
+	Offsets      []int64 `json:"offsets"`
+	SnoozedUntil int64   `json:"snoozed_until"`
+}
+
+type WorkspaceRemindersShow struct {
+	Before  []int64 `json:"before"`
+	Overdue []int64 `json:"overdue"`
+}
+
+// DueReminder is a reminder that is due now. Offset is before the deadline
+// for Before reminders and after it for Overdue ones.
+type DueReminder struct {
+	TaskID   int
+	Name     string
+	Goal     string
+	Kind     string
+	Offset   int64
+	Deadline time.Time
+}
+
+func InitReminderTable() error {
+	err := DB.AutoMigrate(&TaskReminder{}, &WorkspaceReminder{}, &ReminderSent{}, &ReminderSnooze{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// normalizeOffsets sorts the offsets and drops duplicates.
+func normalizeOffsets(offsets []int64) ([]int64, error) {
+	seen := make(map[int64]bool, len(offsets))
+	normalized := make([]int64, 0, len(offsets))
+	for _, offset := range offsets {
+		if offset < 0 {
+			return nil, errors.New("reminder offsets must not be negative")
+		}
+		if !seen[offset] {
+			seen[offset] = true
+			normalized = append(normalized, offset)
+		}
+	}
+	sort.Slice(normalized, func(i, j int) bool { return normalized[i] < normalized[j] })
+	return normalized, nil
+}
+
+func GetTaskReminders(ctx context.Context, taskID int) ([]int64, error) {
+	var reminders []TaskReminder
+	err := db(ctx).Where("task_id = ?", taskID).Order("offset_ms").Find(&reminders).Error
+	if err != nil {
+		return nil, err
+	}
+	offsets := make([]int64, 0, len(reminders))
+	for _, reminder := range reminders {
+		offsets = append(offsets, reminder.Offset)
+	}
+	return offsets, nil
+}
+
+// SetTaskReminders replaces the reminders of the task. Without reminders of
+// its own the task uses the Before rules of its workspace.
+func SetTaskReminders(ctx context.Context, taskID int, offsets []int64) error {
+	offsets, err := normalizeOffsets(offsets)
+	if err != nil {
+		return err
+	}
+	task, err := findTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if task.ID == 0 {
+		return errors.New("task not found")
+	}
+	old, err := GetTaskReminders(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Delete(&TaskReminder{}, "task_id = ?", taskID).Error
+	if err != nil {
+		return err
+	}
+	for _, offset := range offsets {
+		err = db(ctx).Create(&TaskReminder{TaskID: taskID, Offset: offset}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return audit(ctx, EntityTaskReminder, taskID, "offsets", old, offsets)
+}
+
+func GetTaskRemindersShow(ctx context.Context, taskID int) (TaskRemindersShow, error) {
+	offsets, err := GetTaskReminders(ctx, taskID)
+	if err != nil {
+		return TaskRemindersShow{}, err
+	}
+	show := TaskRemindersShow{Offsets: offsets}
+	var snoozes []ReminderSnooze
+	err = db(ctx).Where("task_id = ?", taskID).Limit(1).Find(&snoozes).Error
+	if err != nil {
+		return TaskRemindersShow{}, err
+	}
+	if len(snoozes) != 0 {
+		show.SnoozedUntil = snoozes[0].Until.UnixMilli()
+	}
+	return show, nil
+}
+
+func GetWorkspaceReminders(ctx context.Context, workspaceID int) (WorkspaceRemindersShow, error) {
+	var rules []WorkspaceReminder
+	err := db(ctx).Where("workspace_id = ?", workspaceID).Order("offset_ms").Find(&rules).Error
+	if err != nil {
+		return WorkspaceRemindersShow{}, err
+	}
+	show := WorkspaceRemindersShow{Before: make([]int64, 0), Overdue: make([]int64, 0)}
+	for _, rule := range rules {
+		switch rule.Kind {
+		case ReminderBefore:
+			show.Before = append(show.Before, rule.Offset)
+		case ReminderOverdue:
+			show.Overdue = append(show.Overdue, rule.Offset)
+		}
+	}
+	return show, nil
+}
+
+// SetWorkspaceReminders replaces the default rules of a workspace.
+func SetWorkspaceReminders(ctx context.Context, workspaceID int, before []int64, overdue []int64) error {
+	task, err := findTask(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	if task.ID == 0 || task.ParentTask != -1 {
+		return errors.New("reminder rules can only be set on top-level tasks")
+	}
+	rules := WorkspaceRemindersShow{}
+	rules.Before, err = normalizeOffsets(before)
+	if err != nil {
+		return err
+	}
+	rules.Overdue, err = normalizeOffsets(overdue)
+	if err != nil {
+		return err
+	}
+	old, err := GetWorkspaceReminders(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Delete(&WorkspaceReminder{}, "workspace_id = ?", workspaceID).Error
+	if err != nil {
+		return err
+	}
+	for kind, offsets := range map[string][]int64{ReminderBefore: rules.Before, ReminderOverdue: rules.Overdue} {
+		for _, offset := range offsets {
+			err = db(ctx).Create(&WorkspaceReminder{WorkspaceID: workspaceID, Kind: kind, Offset: offset}).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return audit(ctx, EntityWorkspaceReminder, workspaceID, WholeRecord, old, rules)
+}
+
+// SnoozeTaskReminders holds back the reminders of the task until the given
+// time. Reminders that fall due meanwhile fire once the snooze ends. A time
+// in the past ends the snooze.
+func SnoozeTaskReminders(ctx context.Context, taskID int, until time.Time) error {
+	show, err := GetTaskRemindersShow(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if until.After(time.Now()) {
+		err = db(ctx).Save(&ReminderSnooze{TaskID: taskID, Until: until}).Error
+	} else {
+		err = db(ctx).Delete(&ReminderSnooze{}, "task_id = ?", taskID).Error
+	}
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityTaskReminder, taskID, "snoozed_until", show.SnoozedUntil, until.UnixMilli())
+}
+
+type reminderKey struct {
+	taskID int
+	kind   string
+	offset int64
+}
+
+func reminderTime(task Task, kind string, offset int64) time.Time {
+	if kind == ReminderBefore {
+		return task.Deadline.Add(-time.Duration(offset) * time.Millisecond)
+	}
+	return task.Deadline.Add(time.Duration(offset) * time.Millisecond)
+}
+
+// dueOffset returns the latest due offset that has not fired for the deadline
+// yet and marks every due offset as fired, so a task that missed several
+// reminders, e.g. while snoozed, is reminded once.
+func dueOffset(ctx context.Context, task Task, kind string, offsets []int64, now time.Time, sent map[reminderKey]time.Time) (int64, bool, error) {
+	found := false
+	var latest int64
+	var latestAt time.Time
+	for _, offset := range offsets {
+		at := reminderTime(task, kind, offset)
+		if at.After(now) {
+			continue
+		}
+		deadline, ok := sent[reminderKey{task.ID, kind, offset}]
+		if ok && deadline.Equal(task.Deadline) {
+			continue
+		}
+		// Save would insert, since an offset of 0 looks like an unset key
+		err := db(ctx).Clauses(clause.OnConflict{UpdateAll: true}).
+			Create(&ReminderSent{TaskID: task.ID, Kind: kind, Offset: offset, Deadline: task.Deadline}).Error
+		if err != nil {
+			return 0, false, err
+		}
+		if !found || at.After(latestAt) {
+			latest, latestAt = offset, at
+		}
+		found = true
+	}
+	return latest, found, nil
+}
+
+// CollectDueReminders returns the reminders of unfinished tasks that fell due
+// since they last fired and marks them as fired. Before reminders stop at the
+// deadline, where the Overdue rules of the workspace take over. Snoozed tasks
+// are skipped.
+func CollectDueReminders(ctx context.Context, now time.Time) ([]DueReminder, error) {
+	var tasks []Task
+	err := db(ctx).Where("status = ?", Todo).Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+	var reminders []TaskReminder
+	err = db(ctx).Find(&reminders).Error
+	if err != nil {
+		return nil, err
+	}
+	taskOffsets := make(map[int][]int64)
+	for _, reminder := range reminders {
+		taskOffsets[reminder.TaskID] = append(taskOffsets[reminder.TaskID], reminder.Offset)
+	}
+	var snoozes []ReminderSnooze
+	err = db(ctx).Where("until > ?", now).Find(&snoozes).Error
+	if err != nil {
+		return nil, err
+	}
+	snoozed := make(map[int]bool, len(snoozes))
+	for _, snooze := range snoozes {
+		snoozed[snooze.TaskID] = true
+	}
+	var sentRecords []ReminderSent
+	err = db(ctx).Find(&sentRecords).Error
+	if err != nil {
+		return nil, err
+	}
+	sent := make(map[reminderKey]time.Time, len(sentRecords))
+	for _, record := range sentRecords {
+		sent[reminderKey{record.TaskID, record.Kind, record.Offset}] = record.Deadline
+	}
+	workspaceRules := make(map[int]WorkspaceRemindersShow)
+	due := make([]DueReminder, 0)
+	for _, task := range tasks {
+		if task.Deadline.UnixMilli() <= 0 || snoozed[task.ID] {
+			continue
+		}
+		workspaceID, err := GetWorkspaceID(ctx, task.ID)
+		if err != nil {
+			continue
+		}
+		rules, ok := workspaceRules[workspaceID]
+		if !ok {
+			rules, err = GetWorkspaceReminders(ctx, workspaceID)
+			if err != nil {
+				return nil, err
+			}
+			workspaceRules[workspaceID] = rules
+		}
+		kind, offsets := ReminderBefore, taskOffsets[task.ID]
+		if len(offsets) == 0 {
+			offsets = rules.Before
+		}
+		if !task.Deadline.After(now) {
+			kind, offsets = ReminderOverdue, rules.Overdue
+		}
+		offset, found, err := dueOffset(ctx, task, kind, offsets, now, sent)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			due = append(due, DueReminder{
+				TaskID:   task.ID,
+				Name:     task.Name,
+				Goal:     task.Goal,
+				Kind:     kind,
+				Offset:   offset,
+				Deadline: task.Deadline,
+			})
+		}
+	}
+	return due, nil
+}
+
+func DeleteRemindersByTaskID(ctx context.Context, taskID int) error {
+	err := db(ctx).Delete(&TaskReminder{}, "task_id = ?", taskID).Error
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Delete(&ReminderSent{}, "task_id = ?", taskID).Error
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Delete(&ReminderSnooze{}, "task_id = ?", taskID).Error
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Delete(&WorkspaceReminder{}, "workspace_id = ?", taskID).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
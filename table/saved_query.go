@@ -0,0 +1,95 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const EntitySavedQuery = "saved_query"
+
+var ErrSavedQueryNotFound = errors.New("saved query not found")
+
+// SavedQuery is a query saved by a user. Names are unique per user.
+type SavedQuery struct {
+	ID          int    `gorm:"primaryKey;autoIncrement"`
+	UserID      int    `gorm:"column:user_id;uniqueIndex:idx_saved_query_user_name"`
+	Name        string `gorm:"column:name;uniqueIndex:idx_saved_query_user_name"`
+	Query       string `gorm:"column:query;type:text"`
+	Description string `gorm:"column:description;type:text"`
+}
+
+func (SavedQuery) TableName() string {
+	return "saved_query"
+}
+
+func InitSavedQueryTable() error {
+	// names used to be unique across all users
+	if DB.Migrator().HasIndex(&SavedQuery{}, "idx_saved_query_name") {
+		err := DB.Migrator().DropIndex(&SavedQuery{}, "idx_saved_query_name")
+		if err != nil {
+			return err
+		}
+	}
+	err := DB.AutoMigrate(&SavedQuery{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetSavedQueryByName returns the query the context's user saved under name.
+func GetSavedQueryByName(ctx context.Context, name string) (*SavedQuery, error) {
+	var queries []SavedQuery
+	err := db(ctx).Where("user_id = ? AND name = ?", ActorOf(ctx).UserID, name).Limit(1).Find(&queries).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSavedQueryNotFound, name)
+	}
+	return &queries[0], nil
+}
+
+// GetAllSavedQueries returns the queries of the context's user.
+func GetAllSavedQueries(ctx context.Context) ([]SavedQuery, error) {
+	var queries []SavedQuery
+	err := db(ctx).Where("user_id = ?", ActorOf(ctx).UserID).Order("name").Find(&queries).Error
+	if err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// AddOrUpdateSavedQuery stores the query under its name for the context's
+// user, replacing any query the user already saved with that name.
+func AddOrUpdateSavedQuery(ctx context.Context, savedQuery SavedQuery) error {
+	savedQuery.UserID = ActorOf(ctx).UserID
+	var existing []SavedQuery
+	err := db(ctx).Where("user_id = ? AND name = ?", savedQuery.UserID, savedQuery.Name).Limit(1).Find(&existing).Error
+	if err != nil {
+		return err
+	}
+	var old any
+	if len(existing) != 0 {
+		savedQuery.ID = existing[0].ID
+		old = existing[0]
+	}
+	err = db(ctx).Save(&savedQuery).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntitySavedQuery, savedQuery.ID, WholeRecord, old, savedQuery)
+}
+
+func DeleteSavedQuery(ctx context.Context, name string) error {
+	old, err := GetSavedQueryByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Delete(&SavedQuery{}, old.ID).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntitySavedQuery, old.ID, WholeRecord, *old, nil)
+}
@@ -0,0 +1,46 @@
+package table
+
+import "context"
+
+const EntitySetting = "setting"
+
+// Setting stores server-wide configuration as text, usually JSON, by key.
+type Setting struct {
+	Key   string `gorm:"primaryKey;column:key"`
+	Value string `gorm:"column:value;type:text"`
+}
+
+func (Setting) TableName() string {
+	return "setting"
+}
+
+func InitSettingTable() error {
+	err := DB.AutoMigrate(&Setting{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetSetting returns the value stored under key and whether there is one.
+func GetSetting(ctx context.Context, key string) (string, bool, error) {
+	var settings []Setting
+	err := db(ctx).Where("key = ?", key).Limit(1).Find(&settings).Error
+	if err != nil {
+		return "", false, err
+	}
+	if len(settings) == 0 {
+		return "", false, nil
+	}
+	return settings[0].Value, true, nil
+}
+
+// SetSetting stores the value under key. Settings may hold credentials, so
+// the audit log only records that the key changed.
+func SetSetting(ctx context.Context, key string, value string) error {
+	err := db(ctx).Save(&Setting{Key: key, Value: value}).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntitySetting, 0, key, "", "changed")
+}
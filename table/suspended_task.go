@@ -1,6 +1,7 @@
 package table
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"gorm.io/datatypes"
@@ -145,38 +146,62 @@ func InitSuspendedTaskTable() error {
 	return nil
 }
 
-func AddSuspendedTask(task SuspendedTask) int {
-	err := DB.Create(&task).Error
+func AddSuspendedTask(ctx context.Context, task SuspendedTask) int {
+	err := db(ctx).Create(&task).Error
+	if err != nil {
+		return -1
+	}
+	err = audit(ctx, EntitySuspendedTask, task.ID, WholeRecord, nil, task)
 	if err != nil {
 		return -1
 	}
 	return task.ID
 }
 
-func DeleteSuspendedTasks(id int) error {
-	err := DB.Delete(&SuspendedTask{}, id).Error
+func DeleteSuspendedTasks(ctx context.Context, id int) error {
+	var tasks []SuspendedTask
+	err := db(ctx).Find(&tasks, id).Error
 	if err != nil {
 		return err
 	}
+	err = db(ctx).Delete(&SuspendedTask{}, id).Error
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		err := audit(ctx, EntitySuspendedTask, id, WholeRecord, task, nil)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func GetSuspendedTask(id int) (SuspendedTask, error) {
+func GetSuspendedTask(ctx context.Context, id int) (SuspendedTask, error) {
 	var task SuspendedTask
-	err := DB.First(&task, id).Error
+	err := db(ctx).First(&task, id).Error
 	return task, err
 }
 
-func IsTaskSuspended(id int) bool {
+func IsTaskSuspended(ctx context.Context, id int) bool {
 	var count int64
-	DB.Model(&SuspendedTask{}).Where("id = ?", id).Count(&count)
+	db(ctx).Model(&SuspendedTask{}).Where("id = ?", id).Count(&count)
 	return count > 0
 }
 
-func AddOrUpdateSuspendedTask(task SuspendedTask) error {
-	err := DB.Save(&task).Error
+func AddOrUpdateSuspendedTask(ctx context.Context, task SuspendedTask) error {
+	var old any
+	var tasks []SuspendedTask
+	err := db(ctx).Find(&tasks, task.ID).Error
 	if err != nil {
 		return err
 	}
-	return nil
+	if len(tasks) != 0 {
+		old = tasks[0]
+	}
+	err = db(ctx).Save(&task).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntitySuspendedTask, task.ID, WholeRecord, old, task)
 }
@@ -0,0 +1,246 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+)
+
+const (
+	EntityTag     = "tag"
+	EntityTaskTag = "task_tag"
+)
+
+type Tag struct {
+	ID    int    `gorm:"primaryKey;autoIncrement"`
+	Name  string `gorm:"column:name;uniqueIndex"`
+	Color string `gorm:"column:color"`
+}
+
+func (Tag) TableName() string {
+	return "tag"
+}
+
+type TaskTag struct {
+	TaskID int `gorm:"primaryKey;column:task_id"`
+	TagID  int `gorm:"primaryKey;column:tag_id;index"`
+}
+
+func (TaskTag) TableName() string {
+	return "task_tag"
+}
+
+func InitTagTable() error {
+	err := DB.AutoMigrate(&Tag{}, &TaskTag{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func normalizeTagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("tag name is empty")
+	}
+	if strings.ContainsAny(name, " \t\n()") {
+		return "", errors.New("tag name must not contain spaces or parentheses")
+	}
+	// tag expressions read a leading ! as not
+	if strings.HasPrefix(name, "!") {
+		return "", errors.New("tag name must not start with !")
+	}
+	return name, nil
+}
+
+// ValidateTagName reports why the name cannot be used for a tag, if it cannot.
+func ValidateTagName(name string) error {
+	_, err := normalizeTagName(name)
+	return err
+}
+
+func CreateTag(ctx context.Context, name, color string) (int, error) {
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return -1, err
+	}
+	tag := Tag{Name: name, Color: color}
+	err = db(ctx).Create(&tag).Error
+	if err != nil {
+		return -1, err
+	}
+	return tag.ID, audit(ctx, EntityTag, tag.ID, WholeRecord, nil, tag)
+}
+
+func UpdateTag(ctx context.Context, id int, name, color string) error {
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return err
+	}
+	var old Tag
+	err = db(ctx).First(&old, id).Error
+	if err != nil {
+		return err
+	}
+	tag := Tag{ID: id, Name: name, Color: color}
+	err = db(ctx).Save(&tag).Error
+	if err != nil {
+		return err
+	}
+	err = audit(ctx, EntityTag, id, "name", old.Name, name)
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityTag, id, "color", old.Color, color)
+}
+
+// DeleteTag removes the tag from every task, each removal audited like one
+// made on its own, and then deletes it.
+func DeleteTag(ctx context.Context, id int) error {
+	var old Tag
+	err := db(ctx).First(&old, id).Error
+	if err != nil {
+		return err
+	}
+	taskIDs, err := GetTaskIDsByTagID(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, taskID := range taskIDs {
+		err = RemoveTagFromTask(ctx, taskID, id)
+		if err != nil {
+			return err
+		}
+	}
+	err = db(ctx).Delete(&Tag{}, id).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityTag, id, WholeRecord, old, nil)
+}
+
+func GetAllTags(ctx context.Context) ([]Tag, error) {
+	var tags []Tag
+	err := db(ctx).Order("name").Find(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// GetTaskIDsByTagID returns the tasks that carry the tag.
+func GetTaskIDsByTagID(ctx context.Context, tagID int) ([]int, error) {
+	var ids []int
+	err := db(ctx).Model(&TaskTag{}).Where("tag_id = ?", tagID).Order("task_id").Pluck("task_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func GetTagByName(ctx context.Context, name string) (*Tag, error) {
+	var tags []Tag
+	err := db(ctx).Where("name = ?", name).Limit(1).Find(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	return &tags[0], nil
+}
+
+func AddTagToTask(ctx context.Context, taskID, tagID int) error {
+	taskTag := TaskTag{TaskID: taskID, TagID: tagID}
+	err := db(ctx).Save(&taskTag).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityTaskTag, taskID, WholeRecord, nil, taskTag)
+}
+
+func RemoveTagFromTask(ctx context.Context, taskID, tagID int) error {
+	err := db(ctx).Delete(&TaskTag{}, "task_id = ? AND tag_id = ?", taskID, tagID).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityTaskTag, taskID, WholeRecord, TaskTag{TaskID: taskID, TagID: tagID}, nil)
+}
+
+func DeleteTaskTagsByTaskID(ctx context.Context, taskID int) error {
+	err := db(ctx).Delete(&TaskTag{}, "task_id = ?", taskID).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func GetTagsByTaskID(ctx context.Context, taskID int) ([]Tag, error) {
+	var tags []Tag
+	err := db(ctx).Joins("JOIN task_tag ON task_tag.tag_id = tag.id").
+		Where("task_tag.task_id = ?", taskID).Order("tag.name").Find(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func GetTagNamesByTaskID(ctx context.Context, taskID int) ([]string, error) {
+	tags, err := GetTagsByTaskID(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names, nil
+}
+
+// GetEffectiveTagNames returns the tags of the task together with the tags of
+// all of its ancestors, so tagging a project tags everything below it.
+func GetEffectiveTagNames(ctx context.Context, taskID int) ([]string, error) {
+	nameSet := make(map[string]bool)
+	visited := make(map[int]bool)
+	id := taskID
+	for id != -1 && !visited[id] {
+		visited[id] = true
+		names, err := GetTagNamesByTaskID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			nameSet[name] = true
+		}
+		task, err := findTask(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if task.ID == 0 {
+			break
+		}
+		id = task.ParentTask
+	}
+	names := make([]string, 0, len(nameSet))
+	for name := range nameSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func copyTaskTags(ctx context.Context, id int, newId int) error {
+	var taskTags []TaskTag
+	err := db(ctx).Find(&taskTags, "task_id = ?", id).Error
+	if err != nil {
+		return err
+	}
+	for _, taskTag := range taskTags {
+		err := AddTagToTask(ctx, newId, taskTag.TagID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
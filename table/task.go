@@ -1,6 +1,7 @@
 package table
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -50,17 +51,37 @@ type Task struct {
 	Deadline             time.Time `gorm:"type:timestamp"`
 	InWorkTime           bool      `gorm:"column:in_work_time"`
 	Status               TaskStatus
-	ParentTask           int    `gorm:"column:parent_task"`
-	PositionX            int    `gorm:"column:position_x"`
-	PositionY            int    `gorm:"column:position_y"`
-	DependencyConstraint string `gorm:"column:dependency_constraint"`
-	SubtaskConstraint    string `gorm:"column:subtask_constraint"`
+	ParentTask           int       `gorm:"column:parent_task"`
+	PositionX            int       `gorm:"column:position_x"`
+	PositionY            int       `gorm:"column:position_y"`
+	DependencyConstraint string    `gorm:"column:dependency_constraint"`
+	SubtaskConstraint    string    `gorm:"column:subtask_constraint"`
+	AvailableFrom        time.Time `gorm:"column:available_from;type:timestamp"`
 }
 
 func (Task) TableName() string {
 	return "task"
 }
 
+// IsAvailable reports whether the task's defer date, if any, has passed.
+func (task Task) IsAvailable(now time.Time) bool {
+	return task.AvailableFrom.IsZero() || !task.AvailableFrom.After(now)
+}
+
+func timeToMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func millisToTime(millis int64) time.Time {
+	if millis == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(millis)
+}
+
 func (task Task) Equal(other Task) bool {
 	return task.ID == other.ID &&
 		task.RootTask == other.RootTask &&
@@ -69,7 +90,8 @@ func (task Task) Equal(other Task) bool {
 		task.Deadline == other.Deadline &&
 		task.InWorkTime == other.InWorkTime &&
 		task.Status == other.Status &&
-		task.ParentTask == other.ParentTask
+		task.ParentTask == other.ParentTask &&
+		task.AvailableFrom.Equal(other.AvailableFrom)
 }
 
 func InitTaskTable() error {
@@ -82,99 +104,148 @@ func InitTaskTable() error {
 	return nil
 }
 
-func AddTask(task Task) int {
-	err := DB.Create(&task).Error
+func AddTask(ctx context.Context, task Task) int {
+	err := db(ctx).Create(&task).Error
 	if err != nil {
 		log.Fatal("Failed to add task: ", err)
 		return -1
 	}
 	log.Println("Task added: ", task.ID)
+	err = audit(ctx, EntityTask, task.ID, WholeRecord, nil, task)
+	if err != nil {
+		log.Println("Failed to audit task creation: ", err)
+	}
+	if task.ParentTask == -1 {
+		claimWorkspace(ctx, task.ID)
+	}
 	return task.ID
 }
 
-func DeleteTask(id int) error {
-	err := DB.Delete(&Task{}, id).Error
+func DeleteTask(ctx context.Context, id int) error {
+	old, err := findTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Delete(&Task{}, id).Error
 	if err != nil {
 		log.Fatal("Failed to delete task: ", err)
 		return err
 	}
-	return nil
+	return audit(ctx, EntityTask, id, WholeRecord, old, nil)
 }
 
-func ClearAllTasks() error {
+func ClearAllTasks(ctx context.Context) error {
 	// get table name from model
-	err := DB.Exec("DELETE FROM " + (&Task{}).TableName()).Error
+	err := db(ctx).Exec("DELETE FROM " + (&Task{}).TableName()).Error
 	if err != nil {
 		log.Fatal("Failed to delete all tasks: ", err)
 		return err
 	}
 	log.Println("All tasks deleted")
-	return nil
+	return audit(ctx, EntityTask, -1, WholeRecord, "all", nil)
 }
 
-func UpdateTaskName(id int, name string) error {
-	err := DB.Model(&Task{}).Where("id = ?", id).Update("name", name).Error
+func UpdateTaskName(ctx context.Context, id int, name string) error {
+	old, err := findTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Model(&Task{}).Where("id = ?", id).Update("name", name).Error
 	if err != nil {
 		log.Fatal("Failed to update task name: ", err)
 		return err
 	}
 	log.Println("Task name updated: ", id, name)
-	return nil
+	return audit(ctx, EntityTask, id, "name", old.Name, name)
 }
 
-func UpdateTaskGoal(id int, goal string) error {
-	err := DB.Model(&Task{}).Where("id = ?", id).Update("goal", goal).Error
+func UpdateTaskGoal(ctx context.Context, id int, goal string) error {
+	old, err := findTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Model(&Task{}).Where("id = ?", id).Update("goal", goal).Error
 	if err != nil {
 		log.Fatal("Failed to update task goal: ", err)
 		return err
 	}
 	log.Println("Task goal updated: ", id, goal)
-	return nil
+	return audit(ctx, EntityTask, id, "goal", old.Goal, goal)
 }
 
-func UpdateTaskDeadline(id int, deadline int64) error {
-	err := DB.Model(&Task{}).Where("id = ?", id).Update("deadline", deadline).Error
+func UpdateTaskDeadline(ctx context.Context, id int, deadline int64) error {
+	old, err := findTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Model(&Task{}).Where("id = ?", id).Update("deadline", time.UnixMilli(deadline)).Error
 	if err != nil {
 		log.Fatal("Failed to update task deadline: ", err)
 		return err
 	}
 	log.Println("Task deadline updated: ", id, deadline)
-	return nil
+	return audit(ctx, EntityTask, id, "deadline", old.Deadline, time.UnixMilli(deadline))
 }
 
-func UpdateTaskInWorkTime(id int, inWorkTime bool) error {
-	err := DB.Model(&Task{}).Where("id = ?", id).Update("in_work_time", inWorkTime).Error
+func UpdateTaskInWorkTime(ctx context.Context, id int, inWorkTime bool) error {
+	old, err := findTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Model(&Task{}).Where("id = ?", id).Update("in_work_time", inWorkTime).Error
 	if err != nil {
 		log.Fatal("Failed to update task in work time: ", err)
 		return err
 	}
 	log.Println("Task in work time updated: ", id, inWorkTime)
-	return nil
+	return audit(ctx, EntityTask, id, "in_work_time", old.InWorkTime, inWorkTime)
 }
 
-func UpdateTaskStatus(id int, status TaskStatus) error {
-	err := DB.Model(&Task{}).Where("id = ?", id).Update("status", status).Error
+func UpdateTaskStatus(ctx context.Context, id int, status TaskStatus) error {
+	old, err := findTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Model(&Task{}).Where("id = ?", id).Update("status", status).Error
 	if err != nil {
 		log.Fatal("Failed to update task status: ", err)
 		return err
 	}
 	log.Println("Task status updated: ", id, status)
-	return nil
+	return audit(ctx, EntityTask, id, "status", old.Status, status)
 }
 
-func UpdateTaskParentTask(id int, parentTask int) error {
-	err := DB.Model(&Task{}).Where("id = ?", id).Update("parent_task", parentTask).Error
+func UpdateTaskAvailableFrom(ctx context.Context, id int, availableFrom int64) error {
+	old, err := findTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Model(&Task{}).Where("id = ?", id).Update("available_from", millisToTime(availableFrom)).Error
+	if err != nil {
+		log.Fatal("Failed to update task available from: ", err)
+		return err
+	}
+	log.Println("Task available from updated: ", id, availableFrom)
+	return audit(ctx, EntityTask, id, "available_from", old.AvailableFrom, millisToTime(availableFrom))
+}
+
+func UpdateTaskParentTask(ctx context.Context, id int, parentTask int) error {
+	old, err := findTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Model(&Task{}).Where("id = ?", id).Update("parent_task", parentTask).Error
 	if err != nil {
 		log.Fatal("Failed to update task parent task: ", err)
 		return err
 	}
 	log.Println("Task parent task updated: ", id, parentTask)
-	return nil
+	return audit(ctx, EntityTask, id, "parent_task", old.ParentTask, parentTask)
 }
 
-func GetAllTasks() ([]Task, error) {
+func GetAllTasks(ctx context.Context) ([]Task, error) {
 	var tasks []Task
-	err := DB.Find(&tasks).Error
+	err := db(ctx).Find(&tasks).Error
 	if err != nil {
 		log.Fatal("Failed to get all tasks: ", err)
 		return nil, err
@@ -183,9 +254,37 @@ func GetAllTasks() ([]Task, error) {
 	return tasks, nil
 }
 
-func GetTaskByID(id int) (Task, error) {
+func findTask(ctx context.Context, id int) (Task, error) {
 	var task Task
-	err := DB.First(&task, id).Error
+	err := db(ctx).Limit(1).Find(&task, id).Error
+	return task, err
+}
+
+// LookupTask returns nil instead of failing when the task does not exist.
+func LookupTask(ctx context.Context, id int) (*Task, error) {
+	task, err := findTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if task.ID == 0 {
+		return nil, nil
+	}
+	return &task, nil
+}
+
+// GetUnfinishedTasks returns every task that is not done, by ID.
+func GetUnfinishedTasks(ctx context.Context) ([]Task, error) {
+	var tasks []Task
+	err := db(ctx).Where("status <> ?", Done).Order("id").Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func GetTaskByID(ctx context.Context, id int) (Task, error) {
+	var task Task
+	err := db(ctx).First(&task, id).Error
 	if err != nil {
 		log.Fatal("Failed to get task by ID: ", err)
 		return task, err
@@ -193,9 +292,9 @@ func GetTaskByID(id int) (Task, error) {
 	return task, nil
 }
 
-func GetTasksByRootTask(rootTask int) ([]Task, error) {
+func GetTasksByRootTask(ctx context.Context, rootTask int) ([]Task, error) {
 	var tasks []Task
-	err := DB.Where("root_task = ?", rootTask).Find(&tasks).Error
+	err := db(ctx).Where("root_task = ?", rootTask).Find(&tasks).Error
 	if err != nil {
 		log.Fatal("Failed to get tasks by root task: ", err)
 		return nil, err
@@ -204,9 +303,9 @@ func GetTasksByRootTask(rootTask int) ([]Task, error) {
 	return tasks, nil
 }
 
-func GetTasksByParentTask(parentTask int) ([]Task, error) {
+func GetTasksByParentTask(ctx context.Context, parentTask int) ([]Task, error) {
 	var tasks []Task
-	err := DB.Where("parent_task = ?", parentTask).Find(&tasks).Error
+	err := db(ctx).Where("parent_task = ?", parentTask).Find(&tasks).Error
 	if err != nil {
 		log.Fatal("Failed to get tasks by parent task: ", err)
 		return nil, err
@@ -214,72 +313,136 @@ func GetTasksByParentTask(parentTask int) ([]Task, error) {
 	return tasks, nil
 }
 
-func CreateTask(name string, goal string, deadline int64, inWorkTime bool) (int, error) {
-	nowViewingTask, err := GetNowViewingTask()
+func CreateTask(ctx context.Context, name string, goal string, deadline int64, inWorkTime bool) (int, error) {
+	nowViewingTask, err := GetNowViewingTask(ctx)
 	if err != nil {
 		return -1, err
 	}
+	return CreateTaskUnder(ctx, nowViewingTask, name, goal, deadline, inWorkTime)
+}
+
+// CreateTaskUnder creates a task like CreateTask below the given parent
+// instead of the task being viewed.
+func CreateTaskUnder(ctx context.Context, parentTask int, name string, goal string, deadline int64, inWorkTime bool) (int, error) {
 	task := Task{
 		Name:       name,
 		Goal:       goal,
 		RootTask:   0,
 		Deadline:   time.UnixMilli(deadline),
 		InWorkTime: inWorkTime,
-		ParentTask: nowViewingTask,
+		ParentTask: parentTask,
 		Status:     Todo,
 	}
 	fmt.Println("Task created: ", task)
-	task.ID = AddTask(task)
-	err = UpdatePosition(task.ID, 0, 0)
+	task.ID = AddTask(ctx, task)
+	err := UpdatePosition(ctx, task.ID, 0, 0)
 	if err != nil {
 		return -1, err
 	}
-	err = UpdateConstraints(task.ID, "", "")
+	err = UpdateConstraints(ctx, task.ID, "", "")
 	if err != nil {
 		return -1, err
 	}
 	return task.ID, nil
 }
 
-func EliminateTask(id int) error {
-	task, err := GetTaskByID(id)
+func EliminateTask(ctx context.Context, id int) error {
+	task, err := GetTaskByID(ctx, id)
 	if err != nil || task.ID == -1 {
 		return err
 	}
-	tasks, err := GetTasksByParentTask(id)
-	err = DeleteAllRelatedTaskRelations(id)
+	tasks, err := GetTasksByParentTask(ctx, id)
+	err = DeleteAllRelatedTaskRelations(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = DeleteTaskTriggersByID(ctx, id)
 	if err != nil {
 		return err
 	}
-	err = DeleteTaskTriggersByID(id)
+	err = DeleteTaskAfterEffectByID(ctx, id)
 	if err != nil {
 		return err
 	}
-	err = DeleteTaskAfterEffectByID(id)
+	err = DeleteSuspendedTasks(ctx, id)
 	if err != nil {
 		return err
 	}
-	err = DeleteSuspendedTasks(id)
+	err = DeleteTaskCompletionsByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = DeleteTimeEntriesByTaskID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = DeleteFocusSessionsByTaskID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = DeleteTaskTagsByTaskID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = DeleteTaskNotesByTaskID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = DeleteChecklistByTaskID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = DeleteAttachmentsByTaskID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = DeleteTaskCommentsByTaskID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = DeleteTaskAssigneesByTaskID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = DeleteTaskOverdueByTaskID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = DeleteTaskEventPayloadsByTaskID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = DeleteRemindersByTaskID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = MarkCaldavResourceDeleted(ctx, id)
 	if err != nil {
 		return err
 	}
 	for _, task := range tasks {
-		err := EliminateTask(task.ID)
+		err := EliminateTask(ctx, task.ID)
 		if err != nil {
 			return err
 		}
 	}
-	return DeleteTask(id)
+	err = DeleteTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	// the members decide who is told about the eliminations above
+	return DeleteWorkspaceMembersByWorkspaceID(ctx, id)
 }
 
 type TaskDetail struct {
 	Task struct {
-		ID         int    `json:"id"`
-		Name       string `json:"name"`
-		Goal       string `json:"goal"`
-		Deadline   int64  `json:"deadline"`
-		InWorkTime bool   `json:"in_work_time"`
-		Status     string `json:"status"`
+		ID            int    `json:"id"`
+		Name          string `json:"name"`
+		Goal          string `json:"goal"`
+		Deadline      int64  `json:"deadline"`
+		InWorkTime    bool   `json:"in_work_time"`
+		Status        string `json:"status"`
+		AvailableFrom int64  `json:"available_from"`
 	} `json:"task"`
 	TriggerTypes       []string `json:"trigger_type"`
 	AfterEffectTypes   []string `json:"after_effect_type"`
@@ -297,15 +460,20 @@ type TaskDetail struct {
 		NowAt     int   `json:"now_at"`
 		Period    int   `json:"period"`
 		Intervals []int `json:"intervals"`
+		Step      int64 `json:"step"`
 	} `json:"after_effect"`
 	TaskConstraint struct {
 		DependencyConstraint string `json:"dependency_constraint"`
 		SubtaskConstraint    string `json:"subtask_constraint"`
 	} `json:"task_constraint"`
+	// Note and Checklist are left untouched by SetDetailedTask when omitted.
+	Note        *string             `json:"note"`
+	Checklist   []ChecklistItemShow `json:"checklist"`
+	Attachments []AttachmentShow    `json:"attachments"`
 }
 
-func GetDetailedTask(id int) (TaskDetail, error) {
-	task, err := GetTaskByID(id)
+func GetDetailedTask(ctx context.Context, id int) (TaskDetail, error) {
+	task, err := GetTaskByID(ctx, id)
 	if err != nil {
 		return TaskDetail{}, err
 	}
@@ -315,6 +483,7 @@ func GetDetailedTask(id int) (TaskDetail, error) {
 	taskDetail.Task.Goal = task.Goal
 	taskDetail.Task.Deadline = task.Deadline.UnixMilli()
 	taskDetail.Task.InWorkTime = task.InWorkTime
+	taskDetail.Task.AvailableFrom = timeToMillis(task.AvailableFrom)
 	statusString, err := task.Status.String()
 	taskDetail.SuspendedTaskTypes = []string{}
 	taskDetail.TriggerTypes = []string{}
@@ -325,7 +494,7 @@ func GetDetailedTask(id int) (TaskDetail, error) {
 		return TaskDetail{}, err
 	}
 	taskDetail.Task.Status = statusString
-	triggers, err := GetTaskTriggersByID(id)
+	triggers, err := GetTaskTriggersByID(ctx, id)
 	if err != nil {
 		return TaskDetail{}, err
 	}
@@ -343,7 +512,7 @@ func GetDetailedTask(id int) (TaskDetail, error) {
 		taskDetail.Trigger.EventDescription = eventInfo.EventDescription
 	}
 
-	afterEffects, err := GetTaskAfterEffectsByID(id)
+	afterEffects, err := GetTaskAfterEffectsByID(ctx, id)
 	if err != nil {
 		return TaskDetail{}, err
 	}
@@ -360,10 +529,11 @@ func GetDetailedTask(id int) (TaskDetail, error) {
 		taskDetail.AfterEffect.NowAt = periodicInfo.NowAt
 		taskDetail.AfterEffect.Period = periodicInfo.Period
 		taskDetail.AfterEffect.Intervals = periodicInfo.Intervals
+		taskDetail.AfterEffect.Step = periodicInfo.Step
 	}
 
 	if task.Status == Suspended {
-		suspendedTask, err := GetSuspendedTask(id)
+		suspendedTask, err := GetSuspendedTask(ctx, id)
 		if err != nil {
 			return TaskDetail{}, err
 		}
@@ -395,11 +565,25 @@ func GetDetailedTask(id int) (TaskDetail, error) {
 	taskDetail.TaskConstraint.DependencyConstraint = task.DependencyConstraint
 	taskDetail.TaskConstraint.SubtaskConstraint = task.SubtaskConstraint
 
+	note, err := GetTaskNote(ctx, id)
+	if err != nil {
+		return TaskDetail{}, err
+	}
+	taskDetail.Note = &note
+	taskDetail.Checklist, err = GetChecklist(ctx, id)
+	if err != nil {
+		return TaskDetail{}, err
+	}
+	taskDetail.Attachments, err = GetAttachmentsByTaskID(ctx, id)
+	if err != nil {
+		return TaskDetail{}, err
+	}
+
 	return taskDetail, nil
 }
 
-func updateTaskTriggers(taskDetail TaskDetail) error {
-	err := DeleteTaskTriggersByID(taskDetail.Task.ID)
+func updateTaskTriggers(ctx context.Context, taskDetail TaskDetail) error {
+	err := DeleteTaskTriggersByID(ctx, taskDetail.Task.ID)
 	if err != nil {
 		return err
 	}
@@ -413,7 +597,7 @@ func updateTaskTriggers(taskDetail TaskDetail) error {
 			if err != nil {
 				return err
 			}
-			err = AddOrUpdateTaskTrigger(taskTrigger)
+			err = AddOrUpdateTaskTrigger(ctx, taskTrigger)
 			if err != nil {
 				return err
 			}
@@ -424,8 +608,8 @@ func updateTaskTriggers(taskDetail TaskDetail) error {
 	return nil
 }
 
-func updateTaskAfterEffects(taskDetail TaskDetail) error {
-	err := DeleteTaskAfterEffectByID(taskDetail.Task.ID)
+func updateTaskAfterEffects(ctx context.Context, taskDetail TaskDetail) error {
+	err := DeleteTaskAfterEffectByID(ctx, taskDetail.Task.ID)
 	if err != nil {
 		return err
 	}
@@ -439,11 +623,12 @@ func updateTaskAfterEffects(taskDetail TaskDetail) error {
 				NowAt:     taskDetail.AfterEffect.NowAt,
 				Period:    taskDetail.AfterEffect.Period,
 				Intervals: taskDetail.AfterEffect.Intervals,
+				Step:      taskDetail.AfterEffect.Step,
 			})
 			if err != nil {
 				return err
 			}
-			err = AddOrUpdateTaskAfterEffect(taskAfterEffect)
+			err = AddOrUpdateTaskAfterEffect(ctx, taskAfterEffect)
 			if err != nil {
 				return err
 			}
@@ -452,8 +637,8 @@ func updateTaskAfterEffects(taskDetail TaskDetail) error {
 	return nil
 }
 
-func updateSuspendedTask(taskDetail TaskDetail) error {
-	err := DeleteSuspendedTasks(taskDetail.Task.ID)
+func updateSuspendedTask(ctx context.Context, taskDetail TaskDetail) error {
+	err := DeleteSuspendedTasks(ctx, taskDetail.Task.ID)
 	if err != nil {
 		return err
 	}
@@ -469,7 +654,7 @@ func updateSuspendedTask(taskDetail TaskDetail) error {
 					Timestamp: parse.UnixMilli(),
 				}
 				jsonData, err := json.Marshal(suspendedTimeInfo)
-				err = AddOrUpdateSuspendedTask(SuspendedTask{
+				err = AddOrUpdateSuspendedTask(ctx, SuspendedTask{
 					ID:   taskDetail.Task.ID,
 					Type: Time,
 					Info: jsonData,
@@ -483,7 +668,7 @@ func updateSuspendedTask(taskDetail TaskDetail) error {
 					Keywords: taskDetail.SuspendedTask.Keywords,
 				}
 				jsonData, err := json.Marshal(suspendedEmailInfo)
-				err = AddOrUpdateSuspendedTask(SuspendedTask{
+				err = AddOrUpdateSuspendedTask(ctx, SuspendedTask{
 					ID:   taskDetail.Task.ID,
 					Type: Email,
 					Info: jsonData,
@@ -499,49 +684,99 @@ func updateSuspendedTask(taskDetail TaskDetail) error {
 	return nil
 }
 
-func SetDetailedTask(taskDetail TaskDetail) error {
-	task, err := GetTaskByID(taskDetail.Task.ID)
+func SetDetailedTask(ctx context.Context, taskDetail TaskDetail) error {
+	task, err := GetTaskByID(ctx, taskDetail.Task.ID)
 	if err != nil {
 		return err
 	}
 	if task.ID == -1 {
 		return fmt.Errorf("task not found")
 	}
+	old := task
 	task.Name = taskDetail.Task.Name
 	task.Goal = taskDetail.Task.Goal
 	task.Deadline = time.UnixMilli(taskDetail.Task.Deadline)
 	task.InWorkTime = taskDetail.Task.InWorkTime
+	task.AvailableFrom = millisToTime(taskDetail.Task.AvailableFrom)
 	task.Status.FromString(taskDetail.Task.Status)
-	task.ParentTask, err = GetNowViewingTask()
+	task.ParentTask, err = GetNowViewingTask(ctx)
 	if err != nil {
 		return err
 	}
-	err = updateTaskTriggers(taskDetail)
+	err = updateTaskTriggers(ctx, taskDetail)
 	if err != nil {
 		return err
 	}
-	err = updateTaskAfterEffects(taskDetail)
+	err = updateTaskAfterEffects(ctx, taskDetail)
 	if err != nil {
 		return err
 	}
-	err = updateSuspendedTask(taskDetail)
+	err = updateSuspendedTask(ctx, taskDetail)
 	if err != nil {
 		return err
 	}
+	if taskDetail.Note != nil {
+		err = SetTaskNote(ctx, task.ID, *taskDetail.Note)
+		if err != nil {
+			return err
+		}
+	}
+	if taskDetail.Checklist != nil {
+		err = SetChecklist(ctx, task.ID, taskDetail.Checklist)
+		if err != nil {
+			return err
+		}
+	}
 	if len(taskDetail.SuspendedTaskTypes) == 0 && task.Status == Suspended {
 		task.Status = Todo
 	}
 
-	err = DB.Save(&task).Error
+	err = db(ctx).Save(&task).Error
 	if err != nil {
 		return err
 	}
+	if old.ParentTask != -1 && task.ParentTask == -1 {
+		// a task moved to the top level is a new workspace of the mover,
+		// not one open to everyone or to the members it had before
+		err = DeleteWorkspaceMembersByWorkspaceID(ctx, task.ID)
+		if err != nil {
+			return err
+		}
+		claimWorkspace(ctx, task.ID)
+	}
+	return auditTaskChanges(ctx, old, task)
+}
+
+func auditTaskChanges(ctx context.Context, old, task Task) error {
+	changes := []struct {
+		field    string
+		oldValue any
+		newValue any
+	}{
+		{"name", old.Name, task.Name},
+		{"goal", old.Goal, task.Goal},
+		{"deadline", old.Deadline, task.Deadline},
+		{"in_work_time", old.InWorkTime, task.InWorkTime},
+		{"status", old.Status, task.Status},
+		{"parent_task", old.ParentTask, task.ParentTask},
+		{"position_x", old.PositionX, task.PositionX},
+		{"position_y", old.PositionY, task.PositionY},
+		{"dependency_constraint", old.DependencyConstraint, task.DependencyConstraint},
+		{"subtask_constraint", old.SubtaskConstraint, task.SubtaskConstraint},
+		{"available_from", old.AvailableFrom, task.AvailableFrom},
+	}
+	for _, change := range changes {
+		err := audit(ctx, EntityTask, task.ID, change.field, change.oldValue, change.newValue)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func HaveSubTasks(id int) bool {
+func HaveSubTasks(ctx context.Context, id int) bool {
 	var count int64
-	err := DB.Model(&Task{}).Where("parent_task = ?", id).Count(&count).Error
+	err := db(ctx).Model(&Task{}).Where("parent_task = ?", id).Count(&count).Error
 	if err != nil {
 		log.Fatal("Failed to get subtasks count: ", err)
 		return false
@@ -549,9 +784,9 @@ func HaveSubTasks(id int) bool {
 	return count > 0
 }
 
-func GetSubTasks(id int) ([]Task, error) {
+func GetSubTasks(ctx context.Context, id int) ([]Task, error) {
 	var tasks []Task
-	err := DB.Where("parent_task = ?", id).Find(&tasks).Error
+	err := db(ctx).Where("parent_task = ?", id).Find(&tasks).Error
 	if err != nil {
 		log.Fatal("Failed to get subtasks: ", err)
 		return nil, err
@@ -559,9 +794,9 @@ func GetSubTasks(id int) ([]Task, error) {
 	return tasks, nil
 }
 
-func GetSubTasksID(id int) ([]int, error) {
+func GetSubTasksID(ctx context.Context, id int) ([]int, error) {
 	var taskIDs []int
-	err := DB.Model(&Task{}).Where("parent_task = ?", id).Pluck("id", &taskIDs).Error
+	err := db(ctx).Model(&Task{}).Where("parent_task = ?", id).Pluck("id", &taskIDs).Error
 	if err != nil {
 		log.Fatal("Failed to get subtasks ID: ", err)
 		return nil, err
@@ -569,26 +804,26 @@ func GetSubTasksID(id int) ([]int, error) {
 	return taskIDs, nil
 }
 
-func CheckParentStatus(id int) bool {
-	task, err := GetTaskByID(id)
+func CheckParentStatus(ctx context.Context, id int) bool {
+	task, err := GetTaskByID(ctx, id)
 	if err != nil {
 		return false
 	}
 
 	parentTaskID := task.ParentTask
 	var count int64
-	err = DB.Model(&Task{}).Where("parent_task = ? AND status != ?", parentTaskID, Done).Count(&count).Error
+	err = db(ctx).Model(&Task{}).Where("parent_task = ? AND status != ?", parentTaskID, Done).Count(&count).Error
 	if err != nil {
 		log.Fatal("Failed to get subtasks count: ", err)
 		return false
 	}
 
 	if count == 0 {
-		err := UpdateTaskStatus(parentTaskID, Done)
+		err := UpdateTaskStatus(ctx, parentTaskID, Done)
 		if err != nil {
 			return false
 		}
-		CheckParentStatus(parentTaskID)
+		CheckParentStatus(ctx, parentTaskID)
 	}
 	return true
 }
@@ -603,8 +838,8 @@ type UpdateTaskUIs struct {
 	} `json:"task_uis"`
 }
 
-func UpdatePositions(updateTaskUIs UpdateTaskUIs) error {
-	nowViewingTask, err := GetNowViewingTask()
+func UpdatePositions(ctx context.Context, updateTaskUIs UpdateTaskUIs) error {
+	nowViewingTask, err := GetNowViewingTask(ctx)
 	if err != nil {
 		return err
 	}
@@ -613,9 +848,12 @@ func UpdatePositions(updateTaskUIs UpdateTaskUIs) error {
 	}
 
 	for _, taskUI := range updateTaskUIs.TaskUIs {
-		err := DB.Model(&Task{}).Where("id = ?", taskUI.ID).Updates(Task{PositionX: taskUI.Position.X, PositionY: taskUI.Position.Y}).Error
+		id, err := strconv.Atoi(taskUI.ID)
+		if err != nil {
+			return err
+		}
+		err = UpdatePosition(ctx, id, taskUI.Position.X, taskUI.Position.Y)
 		if err != nil {
-			log.Fatal("Failed to update positions")
 			return err
 		}
 	}
@@ -623,60 +861,90 @@ func UpdatePositions(updateTaskUIs UpdateTaskUIs) error {
 	return nil
 }
 
-func UpdatePosition(id, positionX, positionY int) error {
-	err := DB.Model(&Task{}).Where("id = ?", id).Updates(Task{PositionX: positionX, PositionY: positionY}).Error
+func UpdatePosition(ctx context.Context, id, positionX, positionY int) error {
+	old, err := findTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	// a map, as Updates skips the zero fields of a struct
+	err = db(ctx).Model(&Task{}).Where("id = ?", id).Updates(map[string]any{"position_x": positionX, "position_y": positionY}).Error
 	if err != nil {
 		log.Fatal("Failed to update position")
 		return err
 	}
-	return nil
+	task, err := findTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	return auditTaskChanges(ctx, old, task)
 }
 
-func UpdateConstraints(id int, dependencyConstraint, subtaskConstraint string) error {
-	err := DB.Model(&Task{}).Where("id = ?", id).Updates(Task{DependencyConstraint: dependencyConstraint, SubtaskConstraint: subtaskConstraint}).Error
+func UpdateConstraints(ctx context.Context, id int, dependencyConstraint, subtaskConstraint string) error {
+	old, err := findTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Model(&Task{}).Where("id = ?", id).Updates(Task{DependencyConstraint: dependencyConstraint, SubtaskConstraint: subtaskConstraint}).Error
 	if err != nil {
 		log.Fatal("Failed to update constraints")
 		return err
 	}
-	return nil
+	task, err := findTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	return auditTaskChanges(ctx, old, task)
 }
 
-func checkParentStatus(id int) {
-	task, err := GetTaskByID(id)
-	if err != nil {
+func checkParentStatus(ctx context.Context, id int) {
+	task, err := GetTaskByID(ctx, id)
+	// top-level tasks have no parent to complete
+	if err != nil || task.ParentTask == -1 {
 		return
 	}
 	parentTaskID := task.ParentTask
 	var count int64
-	err = DB.Model(&Task{}).Where("parent_task = ? AND status != ?", parentTaskID, Done).Count(&count).Error
+	err = db(ctx).Model(&Task{}).Where("parent_task = ? AND status != ?", parentTaskID, Done).Count(&count).Error
 	if err != nil {
 		return
 	}
 	if count == 0 {
-		err := UpdateTaskStatus(parentTaskID, Done)
+		err := UpdateTaskStatus(ctx, parentTaskID, Done)
 		if err != nil {
 			return
 		}
-		checkParentStatus(parentTaskID)
+		err = recordCompletion(ctx, parentTaskID, CompletedBySubtasks)
+		if err != nil {
+			return
+		}
+		checkParentStatus(ctx, parentTaskID)
 	}
 }
 
 const deltaTime int64 = 60 * 60 * 24 * 1000
 
-func CompleteTask(id int) error {
-	task, err := GetTaskByID(id)
+func CompleteTask(ctx context.Context, id int) error {
+	return CompleteTaskBy(ctx, id, CompletedByUser)
+}
+
+func CompleteTaskBy(ctx context.Context, id int, completedBy string) error {
+	task, err := GetTaskByID(ctx, id)
 	if err != nil {
 		return err
 	}
 	if task.ID == -1 {
 		return fmt.Errorf("task not found")
 	}
-	err = UpdateTaskStatus(id, Done)
+	err = recordCompletion(ctx, id, completedBy)
 	if err != nil {
 		return err
 	}
-	checkParentStatus(id)
-	affect, err := GetTaskAfterEffectsByID(id)
+	err = UpdateTaskStatus(ctx, id, Done)
+	if err != nil {
+		return err
+	}
+	checkParentStatus(ctx, id)
+	affect, err := GetTaskAfterEffectsByID(ctx, id)
 	if len(affect) == 0 {
 		return nil
 	}
@@ -687,7 +955,7 @@ func CompleteTask(id int) error {
 			return err
 		}
 		if len(periodicInfo.Intervals) == 0 {
-			err = DeleteTaskAfterEffectByID(id)
+			err = DeleteTaskAfterEffectByID(ctx, id)
 			if err != nil {
 				return err
 			}
@@ -697,21 +965,22 @@ func CompleteTask(id int) error {
 			NowAt:     periodicInfo.NowAt,
 			Period:    periodicInfo.Period,
 			Intervals: periodicInfo.Intervals,
+			Step:      periodicInfo.Step,
 		}
 		if periodicT.NowAt == len(periodicT.Intervals)-1 {
 			periodicInfo.NowAt = 0
 			periodicInfo.Period++
-			err := UpdateTaskStatus(id, Todo)
+			err := UpdateTaskStatus(ctx, id, Todo)
 			if err != nil {
 				return err
 			}
-			err = UpdateTaskDeadline(id, task.Deadline.UnixMilli()+deltaTime)
+			err = UpdateTaskDeadline(ctx, id, task.Deadline.UnixMilli()+periodicT.DeadlineStep())
 			if err != nil {
 				return err
 			}
 		} else {
 			periodicInfo.NowAt++
-			err = UpdateTaskDeadline(id, task.Deadline.UnixMilli()+int64(periodicT.Intervals[periodicT.NowAt]))
+			err = UpdateTaskDeadline(ctx, id, task.Deadline.UnixMilli()+periodicT.DeadlineStep())
 			if err != nil {
 				return err
 			}
@@ -720,7 +989,7 @@ func CompleteTask(id int) error {
 		if err != nil {
 			return err
 		}
-		err = AddOrUpdateTaskAfterEffect(afterEffect)
+		err = AddOrUpdateTaskAfterEffect(ctx, afterEffect)
 		if err != nil {
 			return err
 		}
@@ -728,14 +997,14 @@ func CompleteTask(id int) error {
 	return nil
 }
 
-func GetSubTasksConnectedToEnd(id int) ([]int, error) {
+func GetSubTasksConnectedToEnd(ctx context.Context, id int) ([]int, error) {
 	var subTasksConnectedToEnd []int
-	tasks, err := GetTasksByParentTask(id)
+	tasks, err := GetTasksByParentTask(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	relations, err := GetRelationByParentTask(id)
+	relations, err := GetRelationByParentTask(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -786,20 +1055,28 @@ func GetSubTasksConnectedToEnd(id int) ([]int, error) {
 	return subTasksConnectedToEnd, nil
 }
 
-func CopyTask(id int) (int, error) {
-	nowViewingTask, err := GetNowViewingTask()
+type CopyOptions struct {
+	Comments bool
+}
+
+func CopyTask(ctx context.Context, id int) (int, error) {
+	return CopyTaskWithOptions(ctx, id, CopyOptions{})
+}
+
+func CopyTaskWithOptions(ctx context.Context, id int, options CopyOptions) (int, error) {
+	nowViewingTask, err := GetNowViewingTask(ctx)
 	if err != nil {
 		return -1, err
 	}
-	id, err = copyTaskAndSubTasks(id, nowViewingTask)
+	id, err = copyTaskAndSubTasks(ctx, id, nowViewingTask, options)
 	if err != nil {
 		return -1, err
 	}
 	return id, nil
 }
 
-func copyTaskAndSubTasks(id int, parentID int) (int, error) {
-	task, err := GetTaskByID(id)
+func copyTaskAndSubTasks(ctx context.Context, id int, parentID int, options CopyOptions) (int, error) {
+	task, err := GetTaskByID(ctx, id)
 	if err != nil {
 		return -1, err
 	}
@@ -820,32 +1097,55 @@ func copyTaskAndSubTasks(id int, parentID int) (int, error) {
 		PositionY:            task.PositionY,
 		DependencyConstraint: task.DependencyConstraint,
 		SubtaskConstraint:    task.SubtaskConstraint,
+		AvailableFrom:        task.AvailableFrom,
 	}
 
-	newId := AddTask(newTask)
+	newId := AddTask(ctx, newTask)
 	if newId == -1 {
 		return -1, fmt.Errorf("failed to add task")
 	}
 
-	err = copySuspendedTask(id, newId)
+	err = copySuspendedTask(ctx, id, newId)
+	if err != nil {
+		return -1, err
+	}
+
+	err = copyTaskAfterEffect(ctx, id, newId)
+	if err != nil {
+		return -1, err
+	}
+
+	err = copyTaskTrigger(ctx, id, newId)
+	if err != nil {
+		return -1, err
+	}
+
+	err = copyTaskTags(ctx, id, newId)
 	if err != nil {
 		return -1, err
 	}
 
-	err = copyTaskAfterEffect(id, newId)
+	err = copyTaskNotes(ctx, id, newId)
 	if err != nil {
 		return -1, err
 	}
 
-	err = copyTaskTrigger(id, newId)
+	err = copyAttachments(ctx, id, newId)
 	if err != nil {
 		return -1, err
 	}
 
+	if options.Comments {
+		err = copyTaskComments(ctx, id, newId)
+		if err != nil {
+			return -1, err
+		}
+	}
+
 	id2NewIdMap := make(map[int]int)
 	id2NewIdMap[id] = newId
 
-	subTasks, err := GetSubTasksID(id)
+	subTasks, err := GetSubTasksID(ctx, id)
 	if err != nil {
 		return -1, err
 	}
@@ -853,21 +1153,21 @@ func copyTaskAndSubTasks(id int, parentID int) (int, error) {
 		return newId, nil
 	}
 	for _, subTask := range subTasks {
-		id, err := copyTaskAndSubTasks(subTask, newId)
+		id, err := copyTaskAndSubTasks(ctx, subTask, newId, options)
 		id2NewIdMap[subTask] = id
 		if err != nil {
 			return -1, err
 		}
 	}
 
-	relations, err := GetRelationByParentTask(id)
+	relations, err := GetRelationByParentTask(ctx, id)
 	if err != nil {
 		return -1, err
 	}
 	for _, relation := range relations {
 		source := id2NewIdMap[relation.Source]
 		target := id2NewIdMap[relation.Target]
-		err := AddRelation(newId, source, target)
+		err := AddRelation(ctx, newId, source, target)
 		if err != nil {
 			return -1, err
 		}
@@ -875,18 +1175,18 @@ func copyTaskAndSubTasks(id int, parentID int) (int, error) {
 	return newId, nil
 }
 
-func copySuspendedTask(id int, newId int) error {
-	if !IsTaskSuspended(id) {
+func copySuspendedTask(ctx context.Context, id int, newId int) error {
+	if !IsTaskSuspended(ctx, id) {
 		return nil
 	}
-	suspendedTask, err := GetSuspendedTask(id)
+	suspendedTask, err := GetSuspendedTask(ctx, id)
 	if err != nil {
 		return err
 	}
 	if suspendedTask.ID == -1 {
 		return nil
 	}
-	err = AddOrUpdateSuspendedTask(SuspendedTask{
+	err = AddOrUpdateSuspendedTask(ctx, SuspendedTask{
 		ID:   newId,
 		Type: suspendedTask.Type,
 		Info: suspendedTask.Info,
@@ -897,8 +1197,8 @@ func copySuspendedTask(id int, newId int) error {
 	return nil
 }
 
-func copyTaskAfterEffect(id int, newId int) error {
-	afterEffects, err := GetTaskAfterEffectsByID(id)
+func copyTaskAfterEffect(ctx context.Context, id int, newId int) error {
+	afterEffects, err := GetTaskAfterEffectsByID(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -906,7 +1206,7 @@ func copyTaskAfterEffect(id int, newId int) error {
 		return nil
 	}
 	for _, afterEffect := range afterEffects {
-		err := AddOrUpdateTaskAfterEffect(TaskAfterEffect{
+		err := AddOrUpdateTaskAfterEffect(ctx, TaskAfterEffect{
 			ID:   newId,
 			Type: afterEffect.Type,
 			Info: afterEffect.Info,
@@ -918,8 +1218,8 @@ func copyTaskAfterEffect(id int, newId int) error {
 	return nil
 }
 
-func copyTaskTrigger(id int, newId int) error {
-	triggers, err := GetTaskTriggersByID(id)
+func copyTaskTrigger(ctx context.Context, id int, newId int) error {
+	triggers, err := GetTaskTriggersByID(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -927,7 +1227,7 @@ func copyTaskTrigger(id int, newId int) error {
 		return nil
 	}
 	for _, trigger := range triggers {
-		err := AddOrUpdateTaskTrigger(TaskTrigger{
+		err := AddOrUpdateTaskTrigger(ctx, TaskTrigger{
 			ID:   newId,
 			Type: trigger.Type,
 			Info: trigger.Info,
@@ -1,8 +1,11 @@
 package table
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"gorm.io/datatypes"
+	"gorm.io/gorm/clause"
 )
 
 type TaskAfterEffect struct {
@@ -39,59 +42,114 @@ func InitTaskAfterEffectTable() error {
 	return nil
 }
 
-func AddOrUpdateTaskAfterEffect(tae TaskAfterEffect) error {
-	err := DB.Create(&tae).Error
+func AddOrUpdateTaskAfterEffect(ctx context.Context, tae TaskAfterEffect) error {
+	var old any
+	var taes []TaskAfterEffect
+	err := db(ctx).Find(&taes, "id = ?", tae.ID).Error
 	if err != nil {
 		return err
 	}
-	return nil
+	if len(taes) != 0 {
+		old = taes[0]
+	}
+	// Periodic is the zero type, so Save cannot tell an update from an insert,
+	// and the table is keyed by the task ID alone
+	err = db(ctx).Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "id"}}, UpdateAll: true}).Create(&tae).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityTaskAfterEffect, tae.ID, WholeRecord, old, tae)
 }
 
-func DeleteTaskAfterEffect(id int, t AfterEffectType) error {
-	err := DB.Delete(&TaskAfterEffect{}, id, t).Error
+func DeleteTaskAfterEffect(ctx context.Context, id int, t AfterEffectType) error {
+	var taes []TaskAfterEffect
+	err := db(ctx).Find(&taes, "id = ? AND type = ?", id, t).Error
 	if err != nil {
 		return err
 	}
+	err = db(ctx).Delete(&TaskAfterEffect{}, id, t).Error
+	if err != nil {
+		return err
+	}
+	for _, tae := range taes {
+		err := audit(ctx, EntityTaskAfterEffect, id, WholeRecord, tae, nil)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func DeleteTaskAfterEffectByID(id int) error {
-	err := DB.Delete(&TaskAfterEffect{}, id).Error
+func DeleteTaskAfterEffectByID(ctx context.Context, id int) error {
+	taes, err := GetTaskAfterEffectsByID(ctx, id)
 	if err != nil {
 		return err
 	}
+	err = db(ctx).Delete(&TaskAfterEffect{}, id).Error
+	if err != nil {
+		return err
+	}
+	for _, tae := range taes {
+		err := audit(ctx, EntityTaskAfterEffect, id, WholeRecord, tae, nil)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func GetTaskAfterEffect(id int, t AfterEffectType) (*TaskAfterEffect, error) {
+func GetTaskAfterEffect(ctx context.Context, id int, t AfterEffectType) (*TaskAfterEffect, error) {
 	tae := TaskAfterEffect{}
-	err := DB.First(&tae, id, t).Error
+	err := db(ctx).First(&tae, id, t).Error
 	if err != nil {
 		return nil, err
 	}
 	return &tae, nil
 }
 
-func GetTaskAfterEffectsByID(id int) ([]TaskAfterEffect, error) {
+func GetTaskAfterEffectsByID(ctx context.Context, id int) ([]TaskAfterEffect, error) {
 	var taes []TaskAfterEffect
-	err := DB.Find(&taes, id).Error
+	err := db(ctx).Find(&taes, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return taes, nil
 }
 
+// PeriodicT moves the deadline of a task by its intervals in turn. Step is
+// how far the deadline moves after the last interval, a day when it is 0.
 type PeriodicT struct {
 	NowAt     int
 	Period    int
 	Intervals []int
+	Step      int64 `json:",omitempty"`
+}
+
+// Validate checks that NowAt points at one of the intervals.
+func (p *PeriodicT) Validate() error {
+	if p.NowAt < 0 || p.NowAt >= len(p.Intervals) {
+		return fmt.Errorf("NowAt %d is outside the %d intervals", p.NowAt, len(p.Intervals))
+	}
+	return nil
+}
+
+// DeadlineStep returns how far CompleteTask moves the deadline of a periodic
+// task, in milliseconds.
+func (p *PeriodicT) DeadlineStep() int64 {
+	if p.NowAt == len(p.Intervals)-1 {
+		if p.Step > 0 {
+			return p.Step
+		}
+		return deltaTime
+	}
+	return int64(p.Intervals[p.NowAt])
 }
 
 func (p *PeriodicT) Equal(other PeriodicT) bool {
 	if p.NowAt != other.NowAt {
 		return false
 	}
-	if p.Period != other.Period {
+	if p.Period != other.Period || p.Step != other.Step {
 		return false
 	}
 	if len(p.Intervals) != len(other.Intervals) {
@@ -123,7 +181,7 @@ func (tae *TaskAfterEffect) GetPeriodicInfo() (*PeriodicT, error) {
 	return &info, nil
 }
 
-func (tae *TaskAfterEffect) Equal(effect TaskAfterEffect) bool {
+func (tae *TaskAfterEffect) Equal(ctx context.Context, effect TaskAfterEffect) bool {
 	if tae.ID != effect.ID {
 		return false
 	}
@@ -143,7 +201,7 @@ func (tae *TaskAfterEffect) Equal(effect TaskAfterEffect) bool {
 		return false
 	}
 
-	DeleteTaskAfterEffect(tae.ID, tae.Type)
+	DeleteTaskAfterEffect(ctx, tae.ID, tae.Type)
 
 	return true
 }
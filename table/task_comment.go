@@ -0,0 +1,220 @@
+package table
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gorm.io/datatypes"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const EntityTaskComment = "task_comment"
+
+type TaskComment struct {
+	ID        int            `gorm:"primaryKey;autoIncrement"`
+	TaskID    int            `gorm:"column:task_id;index"`
+	UserID    int            `gorm:"column:user_id"`
+	Author    string         `gorm:"column:author"`
+	Content   string         `gorm:"column:content;type:text"`
+	Mentions  datatypes.JSON `gorm:"column:mentions"`
+	CreatedAt time.Time      `gorm:"column:created_at"`
+	UpdatedAt time.Time      `gorm:"column:updated_at"`
+}
+
+func (TaskComment) TableName() string {
+	return "task_comment"
+}
+
+type TaskCommentShow struct {
+	ID        int      `json:"id"`
+	TaskID    int      `json:"task_id"`
+	UserID    int      `json:"user_id"`
+	Author    string   `json:"author"`
+	Content   string   `json:"content"`
+	Mentions  []string `json:"mentions"`
+	CreatedAt int64    `json:"created_at"`
+	UpdatedAt int64    `json:"updated_at"`
+}
+
+func InitTaskCommentTable() error {
+	err := DB.AutoMigrate(&TaskComment{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+var mentionPattern = regexp.MustCompile(`(?:^|[\s(])@([\w.-]+)`)
+
+// ParseMentions returns the distinct names mentioned as @name in content.
+// Addresses like a@b.com are not mentions.
+func ParseMentions(content string) []string {
+	mentions := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		name := strings.TrimRight(match[1], ".-")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			mentions = append(mentions, name)
+		}
+	}
+	return mentions
+}
+
+func (comment TaskComment) toShow() TaskCommentShow {
+	show := TaskCommentShow{
+		ID:        comment.ID,
+		TaskID:    comment.TaskID,
+		UserID:    comment.UserID,
+		Author:    comment.Author,
+		Content:   comment.Content,
+		Mentions:  []string{},
+		CreatedAt: comment.CreatedAt.UnixMilli(),
+		UpdatedAt: comment.UpdatedAt.UnixMilli(),
+	}
+	_ = json.Unmarshal(comment.Mentions, &show.Mentions)
+	return show
+}
+
+// AddTaskComment adds a comment written by the context's user.
+func AddTaskComment(ctx context.Context, taskID int, content string) (int, error) {
+	if strings.TrimSpace(content) == "" {
+		return -1, errors.New("comment is empty")
+	}
+	mentions, err := json.Marshal(ParseMentions(content))
+	if err != nil {
+		return -1, err
+	}
+	userID := ActorOf(ctx).UserID
+	author := ""
+	if userID != 0 {
+		user, err := GetUserByID(ctx, userID)
+		if err != nil {
+			return -1, err
+		}
+		author = user.Name
+	}
+	now := time.Now()
+	comment := TaskComment{
+		TaskID:    taskID,
+		UserID:    userID,
+		Author:    author,
+		Content:   content,
+		Mentions:  mentions,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = db(ctx).Create(&comment).Error
+	if err != nil {
+		return -1, err
+	}
+	return comment.ID, audit(ctx, EntityTaskComment, comment.ID, WholeRecord, nil, comment.toShow())
+}
+
+func GetTaskComment(ctx context.Context, id int) (TaskComment, error) {
+	var comment TaskComment
+	err := db(ctx).First(&comment, id).Error
+	return comment, err
+}
+
+// UpdateTaskComment changes a comment of the context's user.
+func UpdateTaskComment(ctx context.Context, id int, content string) error {
+	if strings.TrimSpace(content) == "" {
+		return errors.New("comment is empty")
+	}
+	old, err := GetTaskComment(ctx, id)
+	if err != nil {
+		return err
+	}
+	if old.UserID != ActorOf(ctx).UserID {
+		return ErrForbidden
+	}
+	mentions, err := json.Marshal(ParseMentions(content))
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Model(&TaskComment{}).Where("id = ?", id).Updates(map[string]any{
+		"content":    content,
+		"mentions":   datatypes.JSON(mentions),
+		"updated_at": time.Now(),
+	}).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityTaskComment, id, "content", old.Content, content)
+}
+
+// DeleteTaskComment deletes a comment of the context's user.
+func DeleteTaskComment(ctx context.Context, id int) error {
+	old, err := GetTaskComment(ctx, id)
+	if err != nil {
+		return err
+	}
+	if old.UserID != ActorOf(ctx).UserID {
+		return ErrForbidden
+	}
+	err = db(ctx).Delete(&TaskComment{}, id).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityTaskComment, id, WholeRecord, old.toShow(), nil)
+}
+
+func GetTaskComments(ctx context.Context, taskID int) ([]TaskCommentShow, error) {
+	var comments []TaskComment
+	err := db(ctx).Where("task_id = ?", taskID).Order("created_at").Order("id").Find(&comments).Error
+	if err != nil {
+		return nil, err
+	}
+	shows := make([]TaskCommentShow, 0, len(comments))
+	for _, comment := range comments {
+		shows = append(shows, comment.toShow())
+	}
+	return shows, nil
+}
+
+// GetTaskCommentCounts returns the number of comments of each task; tasks
+// without comments are absent from the map.
+func GetTaskCommentCounts(ctx context.Context, taskIDs []int) (map[int]int, error) {
+	var rows []struct {
+		TaskID int
+		Count  int
+	}
+	err := db(ctx).Model(&TaskComment{}).Select("task_id, count(*) AS count").
+		Where("task_id IN ?", taskIDs).Group("task_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int]int, len(rows))
+	for _, row := range rows {
+		counts[row.TaskID] = row.Count
+	}
+	return counts, nil
+}
+
+func DeleteTaskCommentsByTaskID(ctx context.Context, taskID int) error {
+	err := db(ctx).Delete(&TaskComment{}, "task_id = ?", taskID).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func copyTaskComments(ctx context.Context, id int, newId int) error {
+	var comments []TaskComment
+	err := db(ctx).Where("task_id = ?", id).Order("id").Find(&comments).Error
+	if err != nil {
+		return err
+	}
+	for _, comment := range comments {
+		comment.ID = 0
+		comment.TaskID = newId
+		err := db(ctx).Create(&comment).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package table
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+const (
+	CompletedByUser     = "user"
+	CompletedBySubtasks = "subtasks"
+)
+
+type TaskCompletion struct {
+	ID          int       `gorm:"primaryKey;autoIncrement"`
+	TaskID      int       `gorm:"column:task_id;index"`
+	CompletedAt time.Time `gorm:"column:completed_at"`
+	Period      int       `gorm:"column:period"`
+	NowAt       int       `gorm:"column:now_at"`
+	CompletedBy string    `gorm:"column:completed_by"`
+}
+
+func (TaskCompletion) TableName() string {
+	return "task_completion"
+}
+
+func InitTaskCompletionTable() error {
+	err := DB.AutoMigrate(&TaskCompletion{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func AddTaskCompletion(ctx context.Context, completion TaskCompletion) error {
+	err := db(ctx).Create(&completion).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func DeleteTaskCompletionsByID(ctx context.Context, taskID int) error {
+	err := db(ctx).Delete(&TaskCompletion{}, "task_id = ?", taskID).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func GetTaskCompletionsByID(ctx context.Context, taskID int) ([]TaskCompletion, error) {
+	var completions []TaskCompletion
+	err := db(ctx).Order("completed_at").Find(&completions, "task_id = ?", taskID).Error
+	if err != nil {
+		return nil, err
+	}
+	return completions, nil
+}
+
+// GetTaskCompletionsBetween returns the completions in [from, to), oldest
+// first.
+func GetTaskCompletionsBetween(ctx context.Context, from time.Time, to time.Time) ([]TaskCompletion, error) {
+	var completions []TaskCompletion
+	err := db(ctx).Order("completed_at").
+		Find(&completions, "completed_at >= ? AND completed_at < ?", from, to).Error
+	if err != nil {
+		return nil, err
+	}
+	return completions, nil
+}
+
+// getPeriodicInfo returns the periodic state of the task, or nil if it does
+// not repeat.
+func getPeriodicInfo(ctx context.Context, id int) (*PeriodicT, error) {
+	afterEffects, err := GetTaskAfterEffectsByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, afterEffect := range afterEffects {
+		if afterEffect.Type == Periodic {
+			return afterEffect.GetPeriodicInfo()
+		}
+	}
+	return nil, nil
+}
+
+// recordCompletion stores a completion event, capturing the periodic state
+// the task was in before CompleteTask advanced it.
+func recordCompletion(ctx context.Context, id int, completedBy string) error {
+	completion := TaskCompletion{
+		TaskID:      id,
+		CompletedAt: time.Now(),
+		CompletedBy: completedBy,
+	}
+	periodicInfo, err := getPeriodicInfo(ctx, id)
+	if err != nil {
+		return err
+	}
+	if periodicInfo != nil {
+		completion.Period = periodicInfo.Period
+		completion.NowAt = periodicInfo.NowAt
+	}
+	return AddTaskCompletion(ctx, completion)
+}
+
+// streakStep returns how long a completion with the given NowAt keeps a
+// streak going: the deadline step of a periodic task, a day otherwise.
+func streakStep(periodic *PeriodicT, nowAt int) int64 {
+	if periodic == nil || nowAt < 0 || nowAt >= len(periodic.Intervals) {
+		return deltaTime
+	}
+	step := PeriodicT{NowAt: nowAt, Intervals: periodic.Intervals}
+	return step.DeadlineStep()
+}
+
+// streakSlot returns the start of the step long slot t falls into. Slots are
+// counted from the start of t's day in the location of now, so whole day
+// steps follow calendar days.
+func streakSlot(t time.Time, step int64, now time.Time) time.Time {
+	day := startOfDay(t.In(now.Location()))
+	if step >= deltaTime {
+		return day
+	}
+	offset := t.Sub(day).Milliseconds()
+	return day.Add(time.Duration(offset-offset%step) * time.Millisecond)
+}
+
+// nextStreakSlot returns the slot after slot, adding whole days by the
+// calendar so that daylight saving changes do not break a streak.
+func nextStreakSlot(slot time.Time, step int64) time.Time {
+	if step%deltaTime == 0 {
+		return slot.AddDate(0, 0, int(step/deltaTime))
+	}
+	return slot.Add(time.Duration(step) * time.Millisecond)
+}
+
+// ComputeStreak counts consecutive periods with at least one completion. A
+// period is the deadline step the task had at the completion, or a calendar
+// day for a task that is not periodic. The current streak only counts if
+// now is in the period of the last completion or the one after it.
+func ComputeStreak(completions []TaskCompletion, periodic *PeriodicT, now time.Time) (int, int) {
+	if len(completions) == 0 {
+		return 0, 0
+	}
+	completions = append([]TaskCompletion(nil), completions...)
+	sort.Slice(completions, func(i, j int) bool { return completions[i].CompletedAt.Before(completions[j].CompletedAt) })
+
+	longest := 1
+	streak := 1
+	step := streakStep(periodic, completions[0].NowAt)
+	slot := streakSlot(completions[0].CompletedAt, step, now)
+	for _, completion := range completions[1:] {
+		currentStep := streakStep(periodic, completion.NowAt)
+		current := streakSlot(completion.CompletedAt, currentStep, now)
+		if !current.After(slot) {
+			continue
+		}
+		if current.After(nextStreakSlot(slot, step)) {
+			streak = 1
+		} else {
+			streak++
+		}
+		slot, step = current, currentStep
+		if streak > longest {
+			longest = streak
+		}
+	}
+
+	if streakSlot(now, step, now).After(nextStreakSlot(slot, step)) {
+		streak = 0
+	}
+	return streak, longest
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+type TaskCompletionShow struct {
+	CompletedAt int64  `json:"completed_at"`
+	Period      int    `json:"period"`
+	NowAt       int    `json:"now_at"`
+	CompletedBy string `json:"completed_by"`
+}
+
+type TaskHistory struct {
+	Completions   []TaskCompletionShow `json:"completions"`
+	CurrentStreak int                  `json:"current_streak"`
+	LongestStreak int                  `json:"longest_streak"`
+}
+
+func GetTaskHistory(ctx context.Context, id int) (TaskHistory, error) {
+	completions, err := GetTaskCompletionsByID(ctx, id)
+	if err != nil {
+		return TaskHistory{}, err
+	}
+	history := TaskHistory{
+		Completions: make([]TaskCompletionShow, 0, len(completions)),
+	}
+	for _, completion := range completions {
+		history.Completions = append(history.Completions, TaskCompletionShow{
+			CompletedAt: completion.CompletedAt.UnixMilli(),
+			Period:      completion.Period,
+			NowAt:       completion.NowAt,
+			CompletedBy: completion.CompletedBy,
+		})
+	}
+	periodic, err := getPeriodicInfo(ctx, id)
+	if err != nil {
+		return TaskHistory{}, err
+	}
+	history.CurrentStreak, history.LongestStreak = ComputeStreak(completions, periodic, time.Now())
+	return history, nil
+}
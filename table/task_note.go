@@ -0,0 +1,288 @@
+package table
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	EntityTaskNote      = "task_note"
+	EntityChecklistItem = "checklist_item"
+)
+
+// TaskNote is one revision of a task's Markdown note. The revision with the
+// highest number is the current note.
+type TaskNote struct {
+	ID        int       `gorm:"primaryKey;autoIncrement"`
+	TaskID    int       `gorm:"column:task_id;index"`
+	Revision  int       `gorm:"column:revision"`
+	Content   string    `gorm:"column:content;type:text"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+func (TaskNote) TableName() string {
+	return "task_note"
+}
+
+type ChecklistItem struct {
+	ID       int    `gorm:"primaryKey;autoIncrement"`
+	TaskID   int    `gorm:"column:task_id;index"`
+	Position int    `gorm:"column:position"`
+	Text     string `gorm:"column:text;type:text"`
+	Checked  bool   `gorm:"column:checked"`
+}
+
+func (ChecklistItem) TableName() string {
+	return "checklist_item"
+}
+
+type ChecklistItemShow struct {
+	ID      int    `json:"id"`
+	Text    string `json:"text"`
+	Checked bool   `json:"checked"`
+}
+
+type TaskNoteRevisionShow struct {
+	Revision  int    `json:"revision"`
+	Content   string `json:"content"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+func InitTaskNoteTable() error {
+	err := DB.AutoMigrate(&TaskNote{}, &ChecklistItem{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func getLatestTaskNote(ctx context.Context, taskID int) (*TaskNote, error) {
+	var notes []TaskNote
+	err := db(ctx).Where("task_id = ?", taskID).Order("revision DESC").Limit(1).Find(&notes).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) == 0 {
+		return nil, nil
+	}
+	return &notes[0], nil
+}
+
+func GetTaskNote(ctx context.Context, taskID int) (string, error) {
+	note, err := getLatestTaskNote(ctx, taskID)
+	if err != nil || note == nil {
+		return "", err
+	}
+	return note.Content, nil
+}
+
+// SetTaskNote stores content as a new revision unless it equals the current one.
+func SetTaskNote(ctx context.Context, taskID int, content string) error {
+	latest, err := getLatestTaskNote(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	revision := 1
+	oldContent := ""
+	if latest != nil {
+		if latest.Content == content {
+			return nil
+		}
+		revision = latest.Revision + 1
+		oldContent = latest.Content
+	}
+	err = db(ctx).Create(&TaskNote{
+		TaskID:    taskID,
+		Revision:  revision,
+		Content:   content,
+		CreatedAt: time.Now(),
+	}).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityTaskNote, taskID, "content", oldContent, content)
+}
+
+func GetTaskNoteRevisions(ctx context.Context, taskID int) ([]TaskNoteRevisionShow, error) {
+	var notes []TaskNote
+	err := db(ctx).Where("task_id = ?", taskID).Order("revision").Find(&notes).Error
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]TaskNoteRevisionShow, 0, len(notes))
+	for _, note := range notes {
+		revisions = append(revisions, TaskNoteRevisionShow{
+			Revision:  note.Revision,
+			Content:   note.Content,
+			CreatedAt: note.CreatedAt.UnixMilli(),
+		})
+	}
+	return revisions, nil
+}
+
+func DeleteTaskNotesByTaskID(ctx context.Context, taskID int) error {
+	err := db(ctx).Delete(&TaskNote{}, "task_id = ?", taskID).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func GetChecklist(ctx context.Context, taskID int) ([]ChecklistItemShow, error) {
+	var items []ChecklistItem
+	err := db(ctx).Where("task_id = ?", taskID).Order("position").Order("id").Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	shows := make([]ChecklistItemShow, 0, len(items))
+	for _, item := range items {
+		shows = append(shows, ChecklistItemShow{ID: item.ID, Text: item.Text, Checked: item.Checked})
+	}
+	return shows, nil
+}
+
+func AddChecklistItem(ctx context.Context, taskID int, text string) (int, error) {
+	var count int64
+	err := db(ctx).Model(&ChecklistItem{}).Where("task_id = ?", taskID).Count(&count).Error
+	if err != nil {
+		return -1, err
+	}
+	item := ChecklistItem{TaskID: taskID, Position: int(count), Text: text}
+	err = db(ctx).Create(&item).Error
+	if err != nil {
+		return -1, err
+	}
+	return item.ID, audit(ctx, EntityChecklistItem, item.ID, WholeRecord, nil, item)
+}
+
+func GetChecklistItem(ctx context.Context, id int) (ChecklistItem, error) {
+	var item ChecklistItem
+	err := db(ctx).First(&item, id).Error
+	return item, err
+}
+
+func UpdateChecklistItem(ctx context.Context, id int, text string, checked bool) error {
+	var old ChecklistItem
+	err := db(ctx).First(&old, id).Error
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Model(&ChecklistItem{}).Where("id = ?", id).
+		Updates(map[string]any{"text": text, "checked": checked}).Error
+	if err != nil {
+		return err
+	}
+	err = audit(ctx, EntityChecklistItem, id, "text", old.Text, text)
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityChecklistItem, id, "checked", old.Checked, checked)
+}
+
+func DeleteChecklistItem(ctx context.Context, id int) error {
+	var old ChecklistItem
+	err := db(ctx).First(&old, id).Error
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Delete(&ChecklistItem{}, id).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityChecklistItem, id, WholeRecord, old, nil)
+}
+
+// SetChecklist replaces the checklist of a task, keeping the given order.
+// The replaced and the new items are audited one by one, like items deleted
+// and added on their own.
+func SetChecklist(ctx context.Context, taskID int, items []ChecklistItemShow) error {
+	current, err := GetChecklist(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if sameChecklist(current, items) {
+		return nil
+	}
+	var old []ChecklistItem
+	err = db(ctx).Where("task_id = ?", taskID).Order("position").Order("id").Find(&old).Error
+	if err != nil {
+		return err
+	}
+	err = DeleteChecklistByTaskID(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	for _, item := range old {
+		err := audit(ctx, EntityChecklistItem, item.ID, WholeRecord, item, nil)
+		if err != nil {
+			return err
+		}
+	}
+	for position, show := range items {
+		item := ChecklistItem{
+			TaskID:   taskID,
+			Position: position,
+			Text:     show.Text,
+			Checked:  show.Checked,
+		}
+		err := db(ctx).Create(&item).Error
+		if err != nil {
+			return err
+		}
+		err = audit(ctx, EntityChecklistItem, item.ID, WholeRecord, nil, item)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sameChecklist(a, b []ChecklistItemShow) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Text != b[i].Text || a[i].Checked != b[i].Checked {
+			return false
+		}
+	}
+	return true
+}
+
+func DeleteChecklistByTaskID(ctx context.Context, taskID int) error {
+	err := db(ctx).Delete(&ChecklistItem{}, "task_id = ?", taskID).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func copyTaskNotes(ctx context.Context, id int, newId int) error {
+	var notes []TaskNote
+	err := db(ctx).Where("task_id = ?", id).Order("revision").Find(&notes).Error
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		note.ID = 0
+		note.TaskID = newId
+		err := db(ctx).Create(&note).Error
+		if err != nil {
+			return err
+		}
+	}
+	var items []ChecklistItem
+	err = db(ctx).Where("task_id = ?", id).Find(&items).Error
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		item.ID = 0
+		item.TaskID = newId
+		err := db(ctx).Create(&item).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package table
+
+import (
+	"atodo_go/event_bus"
+	"context"
+	"sort"
+	"time"
+)
+
+// TaskOverdue remembers which deadline a task was last reported overdue for,
+// so moving the deadline reports it again.
+type TaskOverdue struct {
+	TaskID   int       `gorm:"primaryKey;column:task_id"`
+	Deadline time.Time `gorm:"column:deadline"`
+}
+
+func (TaskOverdue) TableName() string {
+	return "task_overdue"
+}
+
+func InitTaskOverdueTable() error {
+	err := DB.AutoMigrate(&TaskOverdue{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// MarkOverdueTasks publishes a task_overdue event for every unfinished task
+// whose deadline passed since it was last reported and returns their IDs.
+// Tasks without a deadline are stored with the Unix epoch and are skipped.
+// Deadlines are compared here rather than in SQL because older rows store
+// them as integers.
+func MarkOverdueTasks(ctx context.Context, now time.Time) ([]int, error) {
+	var tasks []Task
+	err := db(ctx).Where("status = ?", Todo).Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+	var notices []TaskOverdue
+	err = db(ctx).Find(&notices).Error
+	if err != nil {
+		return nil, err
+	}
+	reported := make(map[int]time.Time, len(notices))
+	for _, notice := range notices {
+		reported[notice.TaskID] = notice.Deadline
+	}
+	ids := make([]int, 0)
+	for _, task := range tasks {
+		if task.Deadline.UnixMilli() <= 0 || !task.Deadline.Before(now) {
+			continue
+		}
+		deadline, ok := reported[task.ID]
+		if ok && deadline.Equal(task.Deadline) {
+			continue
+		}
+		err := db(ctx).Save(&TaskOverdue{TaskID: task.ID, Deadline: task.Deadline}).Error
+		if err != nil {
+			return nil, err
+		}
+		err = publishTaskEvent(ctx, event_bus.TaskOverdue, task.ID)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, task.ID)
+	}
+	return ids, nil
+}
+
+// GetOverdueTasks returns the unfinished tasks whose deadline passed, the
+// most overdue first.
+func GetOverdueTasks(ctx context.Context, now time.Time) ([]Task, error) {
+	tasks, err := GetUnfinishedTasks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	overdue := make([]Task, 0)
+	for _, task := range tasks {
+		if task.Deadline.UnixMilli() > 0 && task.Deadline.Before(now) {
+			overdue = append(overdue, task)
+		}
+	}
+	sort.Slice(overdue, func(i, j int) bool { return overdue[i].Deadline.Before(overdue[j].Deadline) })
+	return overdue, nil
+}
+
+func DeleteTaskOverdueByTaskID(ctx context.Context, taskID int) error {
+	err := db(ctx).Delete(&TaskOverdue{}, "task_id = ?", taskID).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
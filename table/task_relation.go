@@ -1,6 +1,9 @@
 package table
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
 
 type TaskRelation struct {
 	ParentTask int `gorm:"column:parent_task"`
@@ -20,66 +23,74 @@ func InitTaskRelationTable() error {
 	return nil
 }
 
-func AddRelation(parentTask, source, target int) error {
-	err := DB.Create(&TaskRelation{ParentTask: parentTask, Source: source, Target: target}).Error
+func AddRelation(ctx context.Context, parentTask, source, target int) error {
+	relation := TaskRelation{ParentTask: parentTask, Source: source, Target: target}
+	err := db(ctx).Create(&relation).Error
 	if err != nil {
 		return err
 	}
-	return nil
+	return audit(ctx, EntityTaskRelation, source, WholeRecord, nil, relation)
 }
 
-func AddRelationDefault(source, target int) error {
-	nowViewingTask, err2 := GetNowViewingTask()
+func AddRelationDefault(ctx context.Context, source, target int) error {
+	nowViewingTask, err2 := GetNowViewingTask(ctx)
 	if err2 != nil {
 		return err2
 	}
 	if nowViewingTask == -1 {
 		return errors.New("no task is being viewed, add relation failed")
 	}
-	err := DB.Create(&TaskRelation{ParentTask: nowViewingTask, Source: source, Target: target}).Error
-	if err != nil {
-		return err
-	}
-	return nil
+	return AddRelation(ctx, nowViewingTask, source, target)
+}
+
+func DeleteRelation(ctx context.Context, source, target int) error {
+	return deleteRelations(ctx, "source = ? AND target = ?", source, target)
 }
 
-func DeleteRelation(source, target int) error {
-	err := DB.Delete(&TaskRelation{}, "source = ? AND target = ?", source, target).Error
+func DeleteAllRelatedTaskRelations(ctx context.Context, task int) error {
+	return deleteRelations(ctx, "source = ? OR target = ?", task, task)
+}
+
+func deleteRelations(ctx context.Context, query string, args ...any) error {
+	var relations []TaskRelation
+	err := db(ctx).Where(query, args...).Find(&relations).Error
 	if err != nil {
 		return err
 	}
-	return nil
-}
-
-func DeleteAllRelatedTaskRelations(task int) error {
-	err := DB.Delete(&TaskRelation{}, "source = ? OR target = ?", task, task).Error
+	err = db(ctx).Where(query, args...).Delete(&TaskRelation{}).Error
 	if err != nil {
 		return err
 	}
+	for _, relation := range relations {
+		err := audit(ctx, EntityTaskRelation, relation.Source, WholeRecord, relation, nil)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func GetTargetTasks(source int) ([]int, error) {
+func GetTargetTasks(ctx context.Context, source int) ([]int, error) {
 	var targets []int
-	err := DB.Model(&TaskRelation{}).Where("source = ?", source).Pluck("target", &targets).Error
+	err := db(ctx).Model(&TaskRelation{}).Where("source = ?", source).Pluck("target", &targets).Error
 	if err != nil {
 		return nil, err
 	}
 	return targets, nil
 }
 
-func GetSourceTasks(target int) ([]int, error) {
+func GetSourceTasks(ctx context.Context, target int) ([]int, error) {
 	var sources []int
-	err := DB.Model(&TaskRelation{}).Where("target = ?", target).Pluck("source", &sources).Error
+	err := db(ctx).Model(&TaskRelation{}).Where("target = ?", target).Pluck("source", &sources).Error
 	if err != nil {
 		return nil, err
 	}
 	return sources, nil
 }
 
-func GetRelationByParentTask(parentTask int) ([]TaskRelation, error) {
+func GetRelationByParentTask(ctx context.Context, parentTask int) ([]TaskRelation, error) {
 	var relations []TaskRelation
-	err := DB.Find(&relations, "parent_task = ?", parentTask).Error
+	err := db(ctx).Find(&relations, "parent_task = ?", parentTask).Error
 	if err != nil {
 		return nil, err
 	}
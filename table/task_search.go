@@ -0,0 +1,113 @@
+package table
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+// ftsAvailable is false when SQLite was built without FTS5, in which case
+// searching falls back to LIKE matching.
+var ftsAvailable = false
+
+var ftsTriggers = []string{"task_fts_after_insert", "task_fts_after_delete", "task_fts_after_update"}
+
+// InitTaskSearchTable creates the task_fts index and the triggers that keep
+// it in sync with every insert, update and delete on the task table. Without
+// FTS5 the triggers of a database indexed by another build would fail every
+// write to the task table, so they are dropped and the index is rebuilt once
+// FTS5 is back.
+func InitTaskSearchTable() error {
+	var count int64
+	err := DB.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'trigger' AND name IN ?", ftsTriggers).Scan(&count).Error
+	if err != nil {
+		return err
+	}
+	synced := count == int64(len(ftsTriggers))
+	var fts5 bool
+	err = DB.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5).Error
+	if err != nil {
+		return err
+	}
+	if !fts5 {
+		log.Println("Full-text search unavailable, build with -tags sqlite_fts5")
+		return dropTaskSearchTriggers()
+	}
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS task_fts USING fts5(name, goal, content='task', content_rowid='id')`,
+		`CREATE TRIGGER IF NOT EXISTS task_fts_after_insert AFTER INSERT ON task BEGIN
+			INSERT INTO task_fts(rowid, name, goal) VALUES (new.id, new.name, new.goal);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS task_fts_after_delete AFTER DELETE ON task BEGIN
+			INSERT INTO task_fts(task_fts, rowid, name, goal) VALUES ('delete', old.id, old.name, old.goal);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS task_fts_after_update AFTER UPDATE OF name, goal ON task BEGIN
+			INSERT INTO task_fts(task_fts, rowid, name, goal) VALUES ('delete', old.id, old.name, old.goal);
+			INSERT INTO task_fts(rowid, name, goal) VALUES (new.id, new.name, new.goal);
+		END`,
+	}
+	for _, statement := range statements {
+		err := DB.Exec(statement).Error
+		if err != nil {
+			return err
+		}
+	}
+	if !synced {
+		err := DB.Exec("INSERT INTO task_fts(task_fts) VALUES ('rebuild')").Error
+		if err != nil {
+			return err
+		}
+	}
+	ftsAvailable = true
+	log.Println("Task search index ready")
+	return nil
+}
+
+// dropTaskSearchTriggers removes the triggers that write to task_fts. The
+// table itself can only be dropped with FTS5 and is left for later builds.
+func dropTaskSearchTriggers() error {
+	for _, trigger := range ftsTriggers {
+		err := DB.Exec("DROP TRIGGER IF EXISTS " + trigger).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ftsQuery turns free text into an FTS5 query matching every word as a prefix.
+func ftsQuery(text string) string {
+	terms := make([]string, 0)
+	for _, word := range strings.Fields(text) {
+		word = strings.ReplaceAll(word, `"`, `""`)
+		terms = append(terms, `"`+word+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// SearchTasks returns the tasks with one of the statuses whose name or goal
+// contain every word of text, best matches first. Empty arguments match all.
+func SearchTasks(ctx context.Context, text string, statuses []TaskStatus) ([]Task, error) {
+	var tasks []Task
+	query := db(ctx).Model(&Task{})
+	if strings.TrimSpace(text) != "" {
+		if ftsAvailable {
+			query = query.Joins("JOIN task_fts ON task_fts.rowid = task.id").
+				Where("task_fts MATCH ?", ftsQuery(text)).
+				Order("task_fts.rank")
+		} else {
+			for _, word := range strings.Fields(text) {
+				pattern := "%" + word + "%"
+				query = query.Where("task.name LIKE ? OR task.goal LIKE ?", pattern, pattern)
+			}
+		}
+	}
+	if len(statuses) != 0 {
+		query = query.Where("task.status IN ?", statuses)
+	}
+	err := query.Order("task.id").Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
@@ -1,6 +1,8 @@
 package table
 
 import (
+	"atodo_go/event_bus"
+	"context"
 	"encoding/json"
 	"errors"
 	"gorm.io/datatypes"
@@ -51,25 +53,35 @@ func InitTaskTriggerTable() error {
 	return nil
 }
 
-func AddOrUpdateTaskTrigger(taskTrigger TaskTrigger) error {
-	err := DB.Create(&taskTrigger).Error
+func AddOrUpdateTaskTrigger(ctx context.Context, taskTrigger TaskTrigger) error {
+	err := db(ctx).Create(&taskTrigger).Error
 	if err != nil {
 		return err
 	}
-	return nil
+	return audit(ctx, EntityTaskTrigger, taskTrigger.ID, WholeRecord, nil, taskTrigger)
 }
 
-func DeleteTaskTriggersByID(id int) error {
-	err := DB.Delete(&TaskTrigger{}, "id = ?", id).Error
+func DeleteTaskTriggersByID(ctx context.Context, id int) error {
+	taskTriggers, err := GetTaskTriggersByID(ctx, id)
 	if err != nil {
 		return err
 	}
+	err = db(ctx).Delete(&TaskTrigger{}, "id = ?", id).Error
+	if err != nil {
+		return err
+	}
+	for _, taskTrigger := range taskTriggers {
+		err := audit(ctx, EntityTaskTrigger, id, WholeRecord, taskTrigger, nil)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func GetTaskTriggersByID(id int) ([]TaskTrigger, error) {
+func GetTaskTriggersByID(ctx context.Context, id int) ([]TaskTrigger, error) {
 	var taskTriggers []TaskTrigger
-	err := DB.Find(&taskTriggers, "id = ?", id).Error
+	err := db(ctx).Find(&taskTriggers, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -113,3 +125,25 @@ func (t *TaskTrigger) GetEventInfo() (*EventInfo, error) {
 	}
 	return &eventInfo, nil
 }
+
+// FireEventTrigger records that the event a task was waiting for happened:
+// the trigger is removed so the task shows up as an ordinary task.
+func FireEventTrigger(ctx context.Context, taskID int) error {
+	var taskTriggers []TaskTrigger
+	err := db(ctx).Find(&taskTriggers, "id = ? AND type = ?", taskID, Event).Error
+	if err != nil {
+		return err
+	}
+	if len(taskTriggers) == 0 {
+		return errors.New("task has no event trigger")
+	}
+	err = db(ctx).Delete(&TaskTrigger{}, "id = ? AND type = ?", taskID, Event).Error
+	if err != nil {
+		return err
+	}
+	err = audit(ctx, EntityTaskTrigger, taskID, WholeRecord, taskTriggers[0], nil)
+	if err != nil {
+		return err
+	}
+	return publishTaskEvent(ctx, event_bus.TriggerFired, taskID)
+}
@@ -0,0 +1,206 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const EntityTimeEntry = "time_entry"
+
+type TimeEntry struct {
+	ID        int        `gorm:"primaryKey;autoIncrement"`
+	TaskID    int        `gorm:"column:task_id;index"`
+	UserID    int        `gorm:"column:user_id;index"`
+	StartTime time.Time  `gorm:"column:start_time;index"`
+	EndTime   *time.Time `gorm:"column:end_time"`
+	Manual    bool       `gorm:"column:manual"`
+}
+
+func (TimeEntry) TableName() string {
+	return "time_entry"
+}
+
+// Duration returns how long the entry ran, counting an open entry up to now.
+func (entry TimeEntry) Duration(now time.Time) time.Duration {
+	if entry.EndTime == nil {
+		return now.Sub(entry.StartTime)
+	}
+	return entry.EndTime.Sub(entry.StartTime)
+}
+
+func InitTimeEntryTable() error {
+	err := DB.AutoMigrate(&TimeEntry{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetOpenTimeEntry returns the running entry of the context's user.
+func GetOpenTimeEntry(ctx context.Context) (*TimeEntry, error) {
+	var entries []TimeEntry
+	err := db(ctx).Where("end_time IS NULL AND user_id = ?", ActorOf(ctx).UserID).Order("start_time DESC").Limit(1).Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return &entries[0], nil
+}
+
+func closeOpenTimeEntries(ctx context.Context, now time.Time) error {
+	var entries []TimeEntry
+	err := db(ctx).Where("end_time IS NULL AND user_id = ?", ActorOf(ctx).UserID).Find(&entries).Error
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		old := entry
+		entry.EndTime = &now
+		err := db(ctx).Save(&entry).Error
+		if err != nil {
+			return err
+		}
+		err = audit(ctx, EntityTimeEntry, entry.ID, "end_time", old.EndTime, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// switchTimeEntry closes the running entry of the context's user and, if a
+// task is given, starts a new one for it.
+func switchTimeEntry(ctx context.Context, taskID int) error {
+	now := time.Now()
+	err := closeOpenTimeEntries(ctx, now)
+	if err != nil {
+		return err
+	}
+	if taskID <= 0 {
+		return nil
+	}
+	entry := TimeEntry{TaskID: taskID, UserID: ActorOf(ctx).UserID, StartTime: now}
+	err = db(ctx).Create(&entry).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityTimeEntry, entry.ID, WholeRecord, nil, entry)
+}
+
+// AddTimeEntry records time the context's user spent on the task.
+func AddTimeEntry(ctx context.Context, taskID int, startTime, endTime int64) (int, error) {
+	if endTime <= startTime {
+		return -1, errors.New("time entry must end after it starts")
+	}
+	end := time.UnixMilli(endTime)
+	entry := TimeEntry{
+		TaskID:    taskID,
+		UserID:    ActorOf(ctx).UserID,
+		StartTime: time.UnixMilli(startTime),
+		EndTime:   &end,
+		Manual:    true,
+	}
+	err := db(ctx).Create(&entry).Error
+	if err != nil {
+		return -1, err
+	}
+	return entry.ID, audit(ctx, EntityTimeEntry, entry.ID, WholeRecord, nil, entry)
+}
+
+func GetTimeEntry(ctx context.Context, id int) (TimeEntry, error) {
+	var entry TimeEntry
+	err := db(ctx).First(&entry, id).Error
+	return entry, err
+}
+
+// UpdateTimeEntry changes an entry of the context's user.
+func UpdateTimeEntry(ctx context.Context, id int, startTime, endTime int64) error {
+	var entry TimeEntry
+	err := db(ctx).First(&entry, id).Error
+	if err != nil {
+		return err
+	}
+	if entry.UserID != ActorOf(ctx).UserID {
+		return ErrForbidden
+	}
+	if endTime != 0 && endTime <= startTime {
+		return errors.New("time entry must end after it starts")
+	}
+	if endTime == 0 && entry.EndTime != nil {
+		return errors.New("only the running time entry can be left open")
+	}
+	old := entry
+	entry.StartTime = time.UnixMilli(startTime)
+	if endTime != 0 {
+		end := time.UnixMilli(endTime)
+		entry.EndTime = &end
+	}
+	entry.Manual = true
+	err = db(ctx).Save(&entry).Error
+	if err != nil {
+		return err
+	}
+	err = audit(ctx, EntityTimeEntry, id, "start_time", old.StartTime, entry.StartTime)
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityTimeEntry, id, "end_time", old.EndTime, entry.EndTime)
+}
+
+// DeleteTimeEntry deletes an entry of the context's user.
+func DeleteTimeEntry(ctx context.Context, id int) error {
+	var entries []TimeEntry
+	err := db(ctx).Find(&entries, id).Error
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.UserID != ActorOf(ctx).UserID {
+			return ErrForbidden
+		}
+	}
+	err = db(ctx).Delete(&TimeEntry{}, id).Error
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		err := audit(ctx, EntityTimeEntry, id, WholeRecord, entry, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func DeleteTimeEntriesByTaskID(ctx context.Context, taskID int) error {
+	err := db(ctx).Delete(&TimeEntry{}, "task_id = ?", taskID).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetTimeEntries returns the entries of the context's user on the given tasks
+// overlapping [from, to). A zero bound is open, and a nil task list matches
+// every task.
+func GetTimeEntries(ctx context.Context, taskIDs []int, from, to time.Time) ([]TimeEntry, error) {
+	var entries []TimeEntry
+	query := db(ctx).Model(&TimeEntry{}).Where("user_id = ?", ActorOf(ctx).UserID)
+	if taskIDs != nil {
+		query = query.Where("task_id IN ?", taskIDs)
+	}
+	if !from.IsZero() {
+		query = query.Where("end_time IS NULL OR end_time > ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("start_time < ?", to)
+	}
+	err := query.Order("start_time").Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,410 @@
+package table
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"time"
+)
+
+const (
+	EntityUser   = "user"
+	EntityAPIKey = "api_key"
+)
+
+const sessionLifetime = 30 * 24 * time.Hour
+
+const minPasswordLength = 8
+
+var ErrInvalidCredentials = errors.New("invalid user name or password")
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+type User struct {
+	ID           int       `gorm:"primaryKey;autoIncrement"`
+	Name         string    `gorm:"column:name;uniqueIndex"`
+	PasswordHash string    `gorm:"column:password_hash"`
+	CreatedAt    time.Time `gorm:"column:created_at"`
+}
+
+func (User) TableName() string {
+	return "user"
+}
+
+// Session tokens and API keys are only stored as SHA-256 hashes; the plain
+// value is returned once when it is created.
+type Session struct {
+	TokenHash string    `gorm:"primaryKey;column:token_hash"`
+	UserID    int       `gorm:"column:user_id;index"`
+	ExpiresAt time.Time `gorm:"column:expires_at"`
+}
+
+func (Session) TableName() string {
+	return "session"
+}
+
+type APIKey struct {
+	ID         int        `gorm:"primaryKey;autoIncrement"`
+	UserID     int        `gorm:"column:user_id;index"`
+	Name       string     `gorm:"column:name"`
+	KeyHash    string     `gorm:"column:key_hash;uniqueIndex"`
+	CreatedAt  time.Time  `gorm:"column:created_at"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_key"
+}
+
+type UserShow struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type APIKeyShow struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	CreatedAt  int64  `json:"created_at"`
+	LastUsedAt int64  `json:"last_used_at"`
+}
+
+func InitUserTable() error {
+	err := DB.AutoMigrate(&User{}, &Session{}, &APIKey{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func newToken() (string, error) {
+	buffer := make([]byte, 32)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return errors.New("password must be at least 8 characters")
+	}
+	// bcrypt ignores everything after 72 bytes
+	if len(password) > 72 {
+		return errors.New("password must be at most 72 bytes")
+	}
+	return nil
+}
+
+func (user User) Show() UserShow {
+	return UserShow{
+		ID:        user.ID,
+		Name:      user.Name,
+		CreatedAt: user.CreatedAt.UnixMilli(),
+	}
+}
+
+func CountUsers(ctx context.Context) (int64, error) {
+	var count int64
+	err := db(ctx).Model(&User{}).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func GetUserByID(ctx context.Context, id int) (User, error) {
+	var user User
+	err := db(ctx).First(&user, id).Error
+	if err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
+func GetUserByName(ctx context.Context, name string) (*User, error) {
+	var users []User
+	err := db(ctx).Where("name = ?", name).Limit(1).Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, errors.New("user not found: " + name)
+	}
+	return &users[0], nil
+}
+
+// IsAdmin reports whether the user administers the server: the first account
+// created, or the system user 0.
+func IsAdmin(ctx context.Context, userID int) (bool, error) {
+	if userID == defaultAppStateID {
+		return true, nil
+	}
+	var ids []int
+	err := db(ctx).Model(&User{}).Order("id").Limit(1).Pluck("id", &ids).Error
+	if err != nil {
+		return false, err
+	}
+	return len(ids) != 0 && ids[0] == userID, nil
+}
+
+func GetAllUsers(ctx context.Context) ([]UserShow, error) {
+	var users []User
+	err := db(ctx).Order("name").Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	shows := make([]UserShow, 0, len(users))
+	for _, user := range users {
+		shows = append(shows, user.Show())
+	}
+	return shows, nil
+}
+
+func CreateUser(ctx context.Context, name string, password string) (int, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return -1, errors.New("user name must not be empty")
+	}
+	err := validatePassword(password)
+	if err != nil {
+		return -1, err
+	}
+	var count int64
+	err = db(ctx).Model(&User{}).Where("name = ?", name).Count(&count).Error
+	if err != nil {
+		return -1, err
+	}
+	if count != 0 {
+		return -1, errors.New("user already exists: " + name)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return -1, err
+	}
+	user := User{Name: name, PasswordHash: string(hash), CreatedAt: time.Now()}
+	err = db(ctx).Create(&user).Error
+	if err != nil {
+		return -1, err
+	}
+	err = audit(ctx, EntityUser, user.ID, WholeRecord, nil, user.Show())
+	if err != nil {
+		return -1, err
+	}
+	return user.ID, nil
+}
+
+// RegisterUser creates an account on behalf of registrarID, or of an
+// anonymous caller when it is -1. Anyone can create the first account, only
+// admins the later ones. The count and the insert share one transaction, so
+// two concurrent first sign-ups cannot both succeed.
+func RegisterUser(ctx context.Context, registrarID int, name string, password string) (int, error) {
+	id := -1
+	err := Transaction(ctx, func(ctx context.Context) error {
+		count, err := CountUsers(ctx)
+		if err != nil {
+			return err
+		}
+		if count != 0 {
+			ok := false
+			if registrarID != -1 {
+				ok, err = IsAdmin(ctx, registrarID)
+				if err != nil {
+					return err
+				}
+			}
+			if !ok {
+				return ErrForbidden
+			}
+		}
+		id, err = CreateUser(ctx, name, password)
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+func ChangePassword(ctx context.Context, userID int, oldPassword string, newPassword string) error {
+	user, err := GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(oldPassword))
+	if err != nil {
+		return ErrInvalidCredentials
+	}
+	err = validatePassword(newPassword)
+	if err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Model(&User{}).Where("id = ?", userID).Update("password_hash", string(hash)).Error
+	if err != nil {
+		return err
+	}
+	// sign out everywhere else
+	err = db(ctx).Where("user_id = ?", userID).Delete(&Session{}).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityUser, userID, "password", "", "changed")
+}
+
+// Login checks the password and returns a new session token.
+func Login(ctx context.Context, name string, password string) (string, time.Time, error) {
+	user, err := GetUserByName(ctx, name)
+	if err != nil {
+		return "", time.Time{}, ErrInvalidCredentials
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	if err != nil {
+		return "", time.Time{}, ErrInvalidCredentials
+	}
+	token, err := newToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	session := Session{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(sessionLifetime),
+	}
+	err = db(ctx).Create(&session).Error
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, session.ExpiresAt, nil
+}
+
+func Logout(ctx context.Context, token string) error {
+	err := db(ctx).Where("token_hash = ?", hashToken(token)).Delete(&Session{}).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Authenticate resolves a session token or an API key to its user.
+func Authenticate(ctx context.Context, token string) (User, error) {
+	if token == "" {
+		return User{}, ErrInvalidToken
+	}
+	hash := hashToken(token)
+	now := time.Now()
+	var sessions []Session
+	err := db(ctx).Where("token_hash = ?", hash).Limit(1).Find(&sessions).Error
+	if err != nil {
+		return User{}, err
+	}
+	if len(sessions) != 0 {
+		if sessions[0].ExpiresAt.Before(now) {
+			db(ctx).Where("token_hash = ?", hash).Delete(&Session{})
+			return User{}, ErrInvalidToken
+		}
+		return GetUserByID(ctx, sessions[0].UserID)
+	}
+	var keys []APIKey
+	err = db(ctx).Where("key_hash = ?", hash).Limit(1).Find(&keys).Error
+	if err != nil {
+		return User{}, err
+	}
+	if len(keys) == 0 {
+		return User{}, ErrInvalidToken
+	}
+	db(ctx).Model(&APIKey{}).Where("id = ?", keys[0].ID).Update("last_used_at", now)
+	return GetUserByID(ctx, keys[0].UserID)
+}
+
+// AuthenticatePassword checks credentials sent with HTTP basic auth, for
+// clients such as CalDAV apps that cannot send bearer tokens. The password
+// may be the account password or one of the user's API keys.
+func AuthenticatePassword(ctx context.Context, name string, password string) (User, error) {
+	user, err := GetUserByName(ctx, name)
+	if err != nil {
+		return User{}, ErrInvalidCredentials
+	}
+	keyUser, err := Authenticate(ctx, password)
+	if err == nil && keyUser.ID == user.ID {
+		return keyUser, nil
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	if err != nil {
+		return User{}, ErrInvalidCredentials
+	}
+	return *user, nil
+}
+
+// CreateAPIKey returns the ID and the plain key, which cannot be read again.
+func CreateAPIKey(ctx context.Context, userID int, name string) (int, string, error) {
+	token, err := newToken()
+	if err != nil {
+		return -1, "", err
+	}
+	key := APIKey{
+		UserID:    userID,
+		Name:      name,
+		KeyHash:   hashToken(token),
+		CreatedAt: time.Now(),
+	}
+	err = db(ctx).Create(&key).Error
+	if err != nil {
+		return -1, "", err
+	}
+	err = audit(ctx, EntityAPIKey, key.ID, WholeRecord, nil, key.Name)
+	if err != nil {
+		return -1, "", err
+	}
+	return key.ID, token, nil
+}
+
+func GetAPIKeys(ctx context.Context, userID int) ([]APIKeyShow, error) {
+	var keys []APIKey
+	err := db(ctx).Where("user_id = ?", userID).Order("id").Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	shows := make([]APIKeyShow, 0, len(keys))
+	for _, key := range keys {
+		show := APIKeyShow{
+			ID:        key.ID,
+			Name:      key.Name,
+			CreatedAt: key.CreatedAt.UnixMilli(),
+		}
+		if key.LastUsedAt != nil {
+			show.LastUsedAt = key.LastUsedAt.UnixMilli()
+		}
+		shows = append(shows, show)
+	}
+	return shows, nil
+}
+
+func DeleteAPIKey(ctx context.Context, userID int, id int) error {
+	var keys []APIKey
+	err := db(ctx).Where("id = ? AND user_id = ?", id, userID).Limit(1).Find(&keys).Error
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return errors.New("api key not found")
+	}
+	err = db(ctx).Delete(&APIKey{}, id).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityAPIKey, id, WholeRecord, keys[0].Name, nil)
+}
@@ -0,0 +1,432 @@
+package table
+
+import (
+	"atodo_go/event_bus"
+	"context"
+	"encoding/json"
+	"errors"
+	"gorm.io/datatypes"
+	"net/url"
+	"time"
+)
+
+const EntityWebhook = "webhook"
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookEvents lists the events webhooks can subscribe to.
+var WebhookEvents = []string{
+	event_bus.TaskCompleted,
+	event_bus.TaskOverdue,
+	event_bus.TaskResumed,
+	event_bus.TriggerFired,
+}
+
+type Webhook struct {
+	ID        int            `gorm:"primaryKey;autoIncrement"`
+	UserID    int            `gorm:"column:user_id;index"`
+	URL       string         `gorm:"column:url"`
+	Secret    string         `gorm:"column:secret"`
+	Events    datatypes.JSON `gorm:"column:events"`
+	Active    bool           `gorm:"column:active"`
+	CreatedAt time.Time      `gorm:"column:created_at"`
+}
+
+func (Webhook) TableName() string {
+	return "webhook"
+}
+
+// WebhookDelivery is one payload queued for one webhook. Pending deliveries
+// are retried at NextAttemptAt until they succeed or run out of attempts.
+type WebhookDelivery struct {
+	ID             int        `gorm:"primaryKey;autoIncrement"`
+	WebhookID      int        `gorm:"column:webhook_id;index"`
+	Event          string     `gorm:"column:event"`
+	Payload        string     `gorm:"column:payload;type:text"`
+	Status         string     `gorm:"column:status;index"`
+	Attempts       int        `gorm:"column:attempts"`
+	NextAttemptAt  time.Time  `gorm:"column:next_attempt_at;index"`
+	LastStatusCode int        `gorm:"column:last_status_code"`
+	LastError      string     `gorm:"column:last_error;type:text"`
+	CreatedAt      time.Time  `gorm:"column:created_at"`
+	DeliveredAt    *time.Time `gorm:"column:delivered_at"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_delivery"
+}
+
+// WebhookShow leaves out the secret, which is only returned on creation.
+type WebhookShow struct {
+	ID        int      `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Active    bool     `json:"active"`
+	CreatedAt int64    `json:"created_at"`
+}
+
+type WebhookDeliveryShow struct {
+	ID             int    `json:"id"`
+	WebhookID      int    `json:"webhook_id"`
+	Event          string `json:"event"`
+	Payload        string `json:"payload"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	NextAttemptAt  int64  `json:"next_attempt_at"`
+	LastStatusCode int    `json:"last_status_code"`
+	LastError      string `json:"last_error"`
+	CreatedAt      int64  `json:"created_at"`
+	DeliveredAt    int64  `json:"delivered_at"`
+}
+
+type WebhookTaskPayload struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Goal     string `json:"goal"`
+	Deadline int64  `json:"deadline"`
+	Status   string `json:"status"`
+}
+
+// WebhookPayload is the JSON body posted to a webhook.
+type WebhookPayload struct {
+	Event  string              `json:"event"`
+	Time   int64               `json:"time"`
+	Source string              `json:"source"`
+	Task   *WebhookTaskPayload `json:"task"`
+}
+
+func InitWebhookTable() error {
+	err := DB.AutoMigrate(&Webhook{}, &WebhookDelivery{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (webhook Webhook) GetEvents() []string {
+	events := make([]string, 0)
+	_ = json.Unmarshal(webhook.Events, &events)
+	return events
+}
+
+// Subscribes reports whether the webhook wants the event. A webhook without
+// events wants all of them.
+func (webhook Webhook) Subscribes(event string) bool {
+	events := webhook.GetEvents()
+	if len(events) == 0 {
+		return true
+	}
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (webhook Webhook) Show() WebhookShow {
+	return WebhookShow{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Events:    webhook.GetEvents(),
+		Active:    webhook.Active,
+		CreatedAt: webhook.CreatedAt.UnixMilli(),
+	}
+}
+
+func validateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return errors.New("webhook url must be http or https")
+	}
+	if parsed.Host == "" {
+		return errors.New("webhook url has no host")
+	}
+	return nil
+}
+
+// CreateWebhook stores a webhook and returns its ID and secret. A secret is
+// generated when none is given.
+func CreateWebhook(ctx context.Context, userID int, rawURL string, secret string, events []string) (int, string, error) {
+	err := validateWebhookURL(rawURL)
+	if err != nil {
+		return -1, "", err
+	}
+	if secret == "" {
+		secret, err = newToken()
+		if err != nil {
+			return -1, "", err
+		}
+	}
+	marshal, err := json.Marshal(events)
+	if err != nil {
+		return -1, "", err
+	}
+	webhook := Webhook{
+		UserID:    userID,
+		URL:       rawURL,
+		Secret:    secret,
+		Events:    marshal,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+	err = db(ctx).Create(&webhook).Error
+	if err != nil {
+		return -1, "", err
+	}
+	err = audit(ctx, EntityWebhook, webhook.ID, WholeRecord, nil, webhook.Show())
+	if err != nil {
+		return -1, "", err
+	}
+	return webhook.ID, secret, nil
+}
+
+func GetWebhook(ctx context.Context, userID int, id int) (*Webhook, error) {
+	var webhooks []Webhook
+	err := db(ctx).Where("id = ? AND user_id = ?", id, userID).Limit(1).Find(&webhooks).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(webhooks) == 0 {
+		return nil, errors.New("webhook not found")
+	}
+	return &webhooks[0], nil
+}
+
+func UpdateWebhook(ctx context.Context, userID int, id int, rawURL string, events []string, active bool) error {
+	old, err := GetWebhook(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	err = validateWebhookURL(rawURL)
+	if err != nil {
+		return err
+	}
+	marshal, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	webhook := *old
+	webhook.URL = rawURL
+	webhook.Events = marshal
+	webhook.Active = active
+	err = db(ctx).Save(&webhook).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityWebhook, id, WholeRecord, old.Show(), webhook.Show())
+}
+
+func DeleteWebhook(ctx context.Context, userID int, id int) error {
+	old, err := GetWebhook(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Delete(&WebhookDelivery{}, "webhook_id = ?", id).Error
+	if err != nil {
+		return err
+	}
+	err = db(ctx).Delete(&Webhook{}, id).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityWebhook, id, WholeRecord, old.Show(), nil)
+}
+
+func GetWebhooksByUser(ctx context.Context, userID int) ([]WebhookShow, error) {
+	var webhooks []Webhook
+	err := db(ctx).Where("user_id = ?", userID).Order("id").Find(&webhooks).Error
+	if err != nil {
+		return nil, err
+	}
+	shows := make([]WebhookShow, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		shows = append(shows, webhook.Show())
+	}
+	return shows, nil
+}
+
+func GetActiveWebhooks(ctx context.Context) ([]Webhook, error) {
+	var webhooks []Webhook
+	err := db(ctx).Where("active = ?", true).Find(&webhooks).Error
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func GetWebhookByID(ctx context.Context, id int) (*Webhook, error) {
+	var webhooks []Webhook
+	err := db(ctx).Where("id = ?", id).Limit(1).Find(&webhooks).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(webhooks) == 0 {
+		return nil, errors.New("webhook not found")
+	}
+	return &webhooks[0], nil
+}
+
+func isWebhookEvent(eventType string) bool {
+	for _, event := range WebhookEvents {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func buildWebhookPayload(ctx context.Context, event event_bus.Event) ([]byte, error) {
+	payload := WebhookPayload{Event: event.Type, Time: event.Time, Source: event.Source}
+	task, err := LookupTask(ctx, event.EntityID)
+	if err != nil {
+		return nil, err
+	}
+	if task != nil {
+		status, _ := task.Status.String()
+		payload.Task = &WebhookTaskPayload{
+			ID:       task.ID,
+			Name:     task.Name,
+			Goal:     task.Goal,
+			Deadline: task.Deadline.UnixMilli(),
+			Status:   status,
+		}
+	}
+	return json.Marshal(payload)
+}
+
+// queueWebhookDeliveries queues the event for every active webhook that
+// subscribes to it and whose owner can view the task. It runs with the
+// change that caused the event, so no delivery is lost when the event bus
+// drops events for a slow subscriber.
+func queueWebhookDeliveries(ctx context.Context, event event_bus.Event) error {
+	if !isWebhookEvent(event.Type) {
+		return nil
+	}
+	webhooks, err := GetActiveWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+	var body []byte
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(event.Type) {
+			continue
+		}
+		ok, err := HasWorkspaceRole(ctx, webhook.UserID, event.EntityID, RoleViewer)
+		if err != nil || !ok {
+			continue
+		}
+		if body == nil {
+			body, err = buildWebhookPayload(ctx, event)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = EnqueueWebhookDelivery(ctx, webhook.ID, event.Type, string(body), time.UnixMilli(event.Time))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func EnqueueWebhookDelivery(ctx context.Context, webhookID int, event string, payload string, now time.Time) (int, error) {
+	delivery := WebhookDelivery{
+		WebhookID:     webhookID,
+		Event:         event,
+		Payload:       payload,
+		Status:        DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	err := db(ctx).Create(&delivery).Error
+	if err != nil {
+		return -1, err
+	}
+	return delivery.ID, nil
+}
+
+// GetDueWebhookDeliveries returns the pending deliveries whose next attempt
+// is due. Deliveries of inactive webhooks wait until the webhook is
+// activated again.
+func GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	active := db(ctx).Model(&Webhook{}).Select("id").Where("active = ?", true)
+	err := db(ctx).Where("status = ? AND next_attempt_at <= ? AND webhook_id IN (?)", DeliveryPending, now, active).
+		Order("next_attempt_at").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func SaveWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error {
+	err := db(ctx).Save(&delivery).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RetryWebhookDelivery queues a delivery of one of the user's webhooks again,
+// with a fresh set of attempts.
+func RetryWebhookDelivery(ctx context.Context, userID int, id int, now time.Time) error {
+	var deliveries []WebhookDelivery
+	err := db(ctx).Where("id = ?", id).Limit(1).Find(&deliveries).Error
+	if err != nil {
+		return err
+	}
+	if len(deliveries) == 0 {
+		return errors.New("delivery not found")
+	}
+	_, err = GetWebhook(ctx, userID, deliveries[0].WebhookID)
+	if err != nil {
+		return err
+	}
+	delivery := deliveries[0]
+	delivery.Status = DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	return SaveWebhookDelivery(ctx, delivery)
+}
+
+// GetWebhookDeliveries returns the delivery log of a webhook, newest first.
+// Pages start at 0.
+func GetWebhookDeliveries(ctx context.Context, webhookID int, page int, pageSize int) ([]WebhookDeliveryShow, error) {
+	if pageSize <= 0 {
+		pageSize = defaultAuditPageSize
+	}
+	var deliveries []WebhookDelivery
+	err := db(ctx).Where("webhook_id = ?", webhookID).Order("id DESC").
+		Limit(pageSize).Offset(page * pageSize).Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	shows := make([]WebhookDeliveryShow, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		show := WebhookDeliveryShow{
+			ID:             delivery.ID,
+			WebhookID:      delivery.WebhookID,
+			Event:          delivery.Event,
+			Payload:        delivery.Payload,
+			Status:         delivery.Status,
+			Attempts:       delivery.Attempts,
+			NextAttemptAt:  delivery.NextAttemptAt.UnixMilli(),
+			LastStatusCode: delivery.LastStatusCode,
+			LastError:      delivery.LastError,
+			CreatedAt:      delivery.CreatedAt.UnixMilli(),
+		}
+		if delivery.DeliveredAt != nil {
+			show.DeliveredAt = delivery.DeliveredAt.UnixMilli()
+		}
+		shows = append(shows, show)
+	}
+	return shows, nil
+}
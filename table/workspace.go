@@ -0,0 +1,350 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+const (
+	EntityWorkspaceMember = "workspace_member"
+	EntityTaskAssignee    = "task_assignee"
+)
+
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+var roleRanks = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+var ErrForbidden = errors.New("permission denied")
+
+// WorkspaceMember grants a user a role on a workspace, i.e. a task without a
+// parent, and everything below it. A workspace without members is open to
+// every user, so data created before accounts existed stays reachable until
+// someone shares it.
+type WorkspaceMember struct {
+	WorkspaceID int    `gorm:"primaryKey;column:workspace_id"`
+	UserID      int    `gorm:"primaryKey;column:user_id;index"`
+	Role        string `gorm:"column:role"`
+}
+
+func (WorkspaceMember) TableName() string {
+	return "workspace_member"
+}
+
+type TaskAssignee struct {
+	TaskID int `gorm:"primaryKey;column:task_id"`
+	UserID int `gorm:"primaryKey;column:user_id;index"`
+}
+
+func (TaskAssignee) TableName() string {
+	return "task_assignee"
+}
+
+type WorkspaceShow struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+type WorkspaceMemberShow struct {
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+}
+
+func InitWorkspaceTable() error {
+	err := DB.AutoMigrate(&WorkspaceMember{}, &TaskAssignee{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// CurrentUserID returns the user of the context, or 0 outside of an
+// authenticated request.
+func CurrentUserID(ctx context.Context) int {
+	return ActorOf(ctx).UserID
+}
+
+func IsValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// GetWorkspaceID returns the top-level ancestor of the task.
+func GetWorkspaceID(ctx context.Context, taskID int) (int, error) {
+	visited := make(map[int]bool)
+	id := taskID
+	for !visited[id] {
+		visited[id] = true
+		task, err := findTask(ctx, id)
+		if err != nil {
+			return -1, err
+		}
+		if task.ID == 0 {
+			return -1, errors.New("task not found")
+		}
+		if task.ParentTask == -1 {
+			return task.ID, nil
+		}
+		id = task.ParentTask
+	}
+	return -1, errors.New("task hierarchy contains a cycle")
+}
+
+func getWorkspaceMembers(ctx context.Context, workspaceID int) ([]WorkspaceMember, error) {
+	var members []WorkspaceMember
+	err := db(ctx).Where("workspace_id = ?", workspaceID).Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// GetWorkspaceRole returns the role of the user on the workspace of the task,
+// or "" if the user has no access. The system user 0 owns everything.
+func GetWorkspaceRole(ctx context.Context, userID int, taskID int) (string, error) {
+	if userID == defaultAppStateID {
+		return RoleOwner, nil
+	}
+	workspaceID, err := GetWorkspaceID(ctx, taskID)
+	if err != nil {
+		return "", err
+	}
+	members, err := getWorkspaceMembers(ctx, workspaceID)
+	if err != nil {
+		return "", err
+	}
+	if len(members) == 0 {
+		return RoleOwner, nil
+	}
+	for _, member := range members {
+		if member.UserID == userID {
+			return member.Role, nil
+		}
+	}
+	return "", nil
+}
+
+// HasWorkspaceRole reports whether the user has at least the given role on the
+// workspace of the task.
+func HasWorkspaceRole(ctx context.Context, userID int, taskID int, role string) (bool, error) {
+	actual, err := GetWorkspaceRole(ctx, userID, taskID)
+	if err != nil {
+		return false, err
+	}
+	return roleRanks[actual] >= roleRanks[role], nil
+}
+
+// claimWorkspace makes the current user the owner of a new workspace.
+func claimWorkspace(ctx context.Context, workspaceID int) {
+	userID := ActorOf(ctx).UserID
+	if userID == defaultAppStateID {
+		return
+	}
+	err := setWorkspaceMember(ctx, workspaceID, userID, RoleOwner)
+	if err != nil {
+		log.Println("Failed to claim workspace: ", err)
+	}
+}
+
+func setWorkspaceMember(ctx context.Context, workspaceID int, userID int, role string) error {
+	var existing []WorkspaceMember
+	err := db(ctx).Where("workspace_id = ? AND user_id = ?", workspaceID, userID).Limit(1).Find(&existing).Error
+	if err != nil {
+		return err
+	}
+	var old any
+	if len(existing) != 0 {
+		old = existing[0].Role
+	}
+	member := WorkspaceMember{WorkspaceID: workspaceID, UserID: userID, Role: role}
+	err = db(ctx).Save(&member).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityWorkspaceMember, workspaceID, WholeRecord, old, member)
+}
+
+// ShareWorkspace gives the user a role on the workspace. Sharing a workspace
+// that has no members yet makes the current user its owner first.
+func ShareWorkspace(ctx context.Context, workspaceID int, userID int, role string) error {
+	if !IsValidRole(role) {
+		return errors.New("unknown role: " + role)
+	}
+	task, err := findTask(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	if task.ID == 0 || task.ParentTask != -1 {
+		return errors.New("only top-level tasks can be shared")
+	}
+	_, err = GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	members, err := getWorkspaceMembers(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	if currentUserID := ActorOf(ctx).UserID; len(members) == 0 && currentUserID != defaultAppStateID && currentUserID != userID {
+		err = setWorkspaceMember(ctx, workspaceID, currentUserID, RoleOwner)
+		if err != nil {
+			return err
+		}
+	}
+	if role != RoleOwner && isLastOwner(members, userID) {
+		return errors.New("a workspace needs at least one owner")
+	}
+	return setWorkspaceMember(ctx, workspaceID, userID, role)
+}
+
+func isLastOwner(members []WorkspaceMember, userID int) bool {
+	owners := 0
+	isOwner := false
+	for _, member := range members {
+		if member.Role == RoleOwner {
+			owners++
+			isOwner = isOwner || member.UserID == userID
+		}
+	}
+	return isOwner && owners == 1
+}
+
+func UnshareWorkspace(ctx context.Context, workspaceID int, userID int) error {
+	members, err := getWorkspaceMembers(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	if isLastOwner(members, userID) {
+		return errors.New("a workspace needs at least one owner")
+	}
+	var old *WorkspaceMember
+	for _, member := range members {
+		if member.UserID == userID {
+			old = &member
+		}
+	}
+	if old == nil {
+		return nil
+	}
+	err = db(ctx).Delete(&WorkspaceMember{}, "workspace_id = ? AND user_id = ?", workspaceID, userID).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityWorkspaceMember, workspaceID, WholeRecord, *old, nil)
+}
+
+func GetWorkspaceMembers(ctx context.Context, workspaceID int) ([]WorkspaceMemberShow, error) {
+	members, err := getWorkspaceMembers(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	shows := make([]WorkspaceMemberShow, 0, len(members))
+	for _, member := range members {
+		user, err := GetUserByID(ctx, member.UserID)
+		if err != nil {
+			return nil, err
+		}
+		shows = append(shows, WorkspaceMemberShow{UserID: user.ID, Name: user.Name, Role: member.Role})
+	}
+	return shows, nil
+}
+
+// GetAccessibleWorkspaces returns every workspace the user can view.
+func GetAccessibleWorkspaces(ctx context.Context, userID int) ([]WorkspaceShow, error) {
+	roots, err := GetTasksByParentTask(ctx, -1)
+	if err != nil {
+		return nil, err
+	}
+	workspaces := make([]WorkspaceShow, 0, len(roots))
+	for _, root := range roots {
+		role, err := GetWorkspaceRole(ctx, userID, root.ID)
+		if err != nil {
+			return nil, err
+		}
+		if role != "" {
+			workspaces = append(workspaces, WorkspaceShow{ID: root.ID, Name: root.Name, Role: role})
+		}
+	}
+	return workspaces, nil
+}
+
+func DeleteWorkspaceMembersByWorkspaceID(ctx context.Context, workspaceID int) error {
+	err := db(ctx).Delete(&WorkspaceMember{}, "workspace_id = ?", workspaceID).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// AssignTask assigns the task to a user who can view its workspace.
+func AssignTask(ctx context.Context, taskID int, userID int) error {
+	_, err := GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	ok, err := HasWorkspaceRole(ctx, userID, taskID, RoleViewer)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("the user cannot access this task")
+	}
+	assignee := TaskAssignee{TaskID: taskID, UserID: userID}
+	err = db(ctx).Save(&assignee).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityTaskAssignee, taskID, WholeRecord, nil, assignee)
+}
+
+func UnassignTask(ctx context.Context, taskID int, userID int) error {
+	err := db(ctx).Delete(&TaskAssignee{}, "task_id = ? AND user_id = ?", taskID, userID).Error
+	if err != nil {
+		return err
+	}
+	return audit(ctx, EntityTaskAssignee, taskID, WholeRecord, TaskAssignee{TaskID: taskID, UserID: userID}, nil)
+}
+
+func GetTaskAssignees(ctx context.Context, taskID int) ([]int, error) {
+	var assignees []TaskAssignee
+	err := db(ctx).Where("task_id = ?", taskID).Order("user_id").Find(&assignees).Error
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(assignees))
+	for _, assignee := range assignees {
+		ids = append(ids, assignee.UserID)
+	}
+	return ids, nil
+}
+
+func GetTasksAssignedTo(ctx context.Context, userID int) (map[int]bool, error) {
+	var assignees []TaskAssignee
+	err := db(ctx).Where("user_id = ?", userID).Find(&assignees).Error
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[int]bool, len(assignees))
+	for _, assignee := range assignees {
+		ids[assignee.TaskID] = true
+	}
+	return ids, nil
+}
+
+func DeleteTaskAssigneesByTaskID(ctx context.Context, taskID int) error {
+	err := db(ctx).Delete(&TaskAssignee{}, "task_id = ?", taskID).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package tag_filter
+
+import (
+	"atodo_go/bool_expr"
+	"strings"
+)
+
+// TagFilter selects tasks by their tags. A task must carry every Include tag,
+// none of the Exclude tags, and satisfy Expression, a boolean combination
+// such as "(@office or @home) and not waiting-on-review".
+type TagFilter struct {
+	Include    []string `json:"include"`
+	Exclude    []string `json:"exclude"`
+	Expression string   `json:"expression"`
+}
+
+func (filter TagFilter) IsEmpty() bool {
+	return len(filter.Include) == 0 && len(filter.Exclude) == 0 && strings.TrimSpace(filter.Expression) == ""
+}
+
+// Compile parses the expression once so the filter can be matched many times.
+func (filter TagFilter) Compile() (func(tags []string) bool, error) {
+	var expr Expr
+	if strings.TrimSpace(filter.Expression) != "" {
+		var err error
+		expr, err = Parse(filter.Expression)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return func(tags []string) bool {
+		tagSet := make(map[string]bool, len(tags))
+		for _, tag := range tags {
+			tagSet[tag] = true
+		}
+		for _, tag := range filter.Include {
+			if !tagSet[tag] {
+				return false
+			}
+		}
+		for _, tag := range filter.Exclude {
+			if tagSet[tag] {
+				return false
+			}
+		}
+		return expr == nil || expr.Match(tagSet)
+	}, nil
+}
+
+type Expr = bool_expr.Expr[map[string]bool]
+
+type tagExpr struct {
+	name string
+}
+
+func (e tagExpr) Match(tags map[string]bool) bool {
+	return tags[e.name]
+}
+
+var syntax = bool_expr.Syntax{
+	Name:      "tag expression",
+	And:       []string{"and", "&"},
+	Or:        []string{"or", "|"},
+	Not:       []string{"not", "!"},
+	Operators: "!",
+}
+
+// Parse parses a tag expression. "and", "or" and "not" (or "&", "|", "!")
+// combine tags, parentheses group, and adjacent terms are joined with "and".
+func Parse(expression string) (Expr, error) {
+	return bool_expr.Parse(syntax, expression, func(token string) (Expr, error) {
+		return tagExpr{name: token}, nil
+	})
+}
@@ -0,0 +1,325 @@
+package task_show
+
+import (
+	"atodo_go/table"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	GraphFormatDot     = "dot"
+	GraphFormatMermaid = "mermaid"
+	GraphFormatGantt   = "gantt"
+)
+
+var ErrUnknownGraphFormat = errors.New("unknown graph format")
+
+// statusColors fill the nodes by status, the same in every format.
+var statusColors = map[string]string{
+	"Todo":      "#e3f2fd",
+	"Suspended": "#fff3e0",
+	"Done":      "#e8f5e9",
+}
+
+const (
+	startColor = "#2e7d32"
+	endColor   = "#c62828"
+)
+
+// Schedule holds the dates of a node, zero when unset.
+type Schedule struct {
+	Deadline      time.Time
+	AvailableFrom time.Time
+}
+
+// Graph is the show data of a task. Built recursively, Subgraphs holds the
+// graphs of the nodes that have subtasks themselves, keyed by node ID.
+type Graph struct {
+	ID        int
+	Name      string
+	Data      *ShowData
+	Schedules map[string]Schedule
+	Subgraphs map[string]*Graph
+}
+
+// GetGraph returns the show data of the task, and with recursive those of all
+// its subtasks that have subtasks.
+func GetGraph(ctx context.Context, id int, recursive bool) (*Graph, error) {
+	task, err := table.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := GetShowDataByTaskID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := table.GetTasksByParentTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	graph := &Graph{
+		ID:        id,
+		Name:      task.Name,
+		Data:      data,
+		Schedules: make(map[string]Schedule),
+		Subgraphs: make(map[string]*Graph),
+	}
+	for _, task := range tasks {
+		schedule := Schedule{AvailableFrom: task.AvailableFrom}
+		if task.Deadline.UnixMilli() > 0 {
+			schedule.Deadline = task.Deadline
+		}
+		graph.Schedules[strconv.Itoa(task.ID)] = schedule
+	}
+	if !recursive {
+		return graph, nil
+	}
+	for _, node := range data.Nodes {
+		nodeID, err := strconv.Atoi(node.ID)
+		if err != nil {
+			return nil, err
+		}
+		subgraph, err := GetGraph(ctx, nodeID, true)
+		if err != nil {
+			return nil, err
+		}
+		if len(subgraph.Data.Nodes) != 0 {
+			graph.Subgraphs[node.ID] = subgraph
+		}
+	}
+	return graph, nil
+}
+
+// RenderGraph writes the graph as Graphviz DOT, a Mermaid flowchart or a
+// Mermaid Gantt chart. Gantt tasks without a defer date or dependency start
+// at now.
+func RenderGraph(format string, graph *Graph, now time.Time) (string, error) {
+	switch format {
+	case GraphFormatDot:
+		return renderDot(graph), nil
+	case GraphFormatMermaid:
+		return renderMermaid(graph), nil
+	case GraphFormatGantt:
+		return renderGantt(graph, now), nil
+	default:
+		return "", ErrUnknownGraphFormat
+	}
+}
+
+func IsGraphFormat(format string) bool {
+	return format == GraphFormatDot || format == GraphFormatMermaid || format == GraphFormatGantt
+}
+
+// ExportGraph renders the graph of the task.
+func ExportGraph(ctx context.Context, format string, id int, recursive bool, now time.Time) (string, error) {
+	if !IsGraphFormat(format) {
+		return "", ErrUnknownGraphFormat
+	}
+	graph, err := GetGraph(ctx, id, recursive)
+	if err != nil {
+		return "", err
+	}
+	return RenderGraph(format, graph, now)
+}
+
+func nodeKey(id string) string {
+	return "t" + id
+}
+
+// borderColor returns the highlight of a node connected to the start or end,
+// the end winning for unconnected nodes, which are both.
+func (graph *Graph) borderColor(id string) string {
+	if contains(graph.Data.NodeConnectedToEnd, id) {
+		return endColor
+	}
+	if contains(graph.Data.NodeConnectedToStart, id) {
+		return startColor
+	}
+	return ""
+}
+
+func contains(ids []string, id string) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// firstOf returns the first of the IDs, or the first node of the graph when
+// a cycle leaves none.
+func firstOf(ids []string, graph *Graph) string {
+	if len(ids) != 0 {
+		return ids[0]
+	}
+	return graph.Data.Nodes[0].ID
+}
+
+func dotQuote(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(oneLine(text)) + `"`
+}
+
+// renderDot writes a digraph with subgraphs as clusters. Edges to and from a
+// cluster are drawn to its first start and from its first end node.
+func renderDot(graph *Graph) string {
+	var builder strings.Builder
+	builder.WriteString("digraph " + dotQuote(graph.Name) + " {\n")
+	builder.WriteString("  compound=true;\n  rankdir=LR;\n")
+	builder.WriteString("  node [shape=box, style=\"rounded,filled\"];\n")
+	writeDotBody(&builder, graph, "  ")
+	builder.WriteString("}\n")
+	return builder.String()
+}
+
+func writeDotBody(builder *strings.Builder, graph *Graph, indent string) {
+	for _, node := range graph.Data.Nodes {
+		if subgraph, ok := graph.Subgraphs[node.ID]; ok {
+			builder.WriteString(indent + "subgraph " + dotQuote("cluster_"+node.ID) + " {\n")
+			builder.WriteString(indent + "  label=" + dotQuote(node.Name) + ";\n")
+			builder.WriteString(indent + "  style=filled;\n")
+			builder.WriteString(indent + "  fillcolor=" + dotQuote(statusColors[node.Status]) + ";\n")
+			if color := graph.borderColor(node.ID); color != "" {
+				builder.WriteString(indent + "  color=" + dotQuote(color) + ";\n" + indent + "  penwidth=2;\n")
+			}
+			writeDotBody(builder, subgraph, indent+"  ")
+			builder.WriteString(indent + "}\n")
+			continue
+		}
+		attributes := "label=" + dotQuote(node.Name) + ", fillcolor=" + dotQuote(statusColors[node.Status])
+		if color := graph.borderColor(node.ID); color != "" {
+			attributes += ", color=" + dotQuote(color) + ", penwidth=2"
+		}
+		builder.WriteString(indent + dotQuote(nodeKey(node.ID)) + " [" + attributes + "];\n")
+	}
+	for _, edge := range graph.Data.Edges {
+		source, target := nodeKey(edge.Source), nodeKey(edge.Target)
+		attributes := make([]string, 0, 2)
+		if subgraph, ok := graph.Subgraphs[edge.Source]; ok {
+			source = nodeKey(firstOf(subgraph.Data.NodeConnectedToEnd, subgraph))
+			attributes = append(attributes, "ltail="+dotQuote("cluster_"+edge.Source))
+		}
+		if subgraph, ok := graph.Subgraphs[edge.Target]; ok {
+			target = nodeKey(firstOf(subgraph.Data.NodeConnectedToStart, subgraph))
+			attributes = append(attributes, "lhead="+dotQuote("cluster_"+edge.Target))
+		}
+		line := indent + dotQuote(source) + " -> " + dotQuote(target)
+		if len(attributes) != 0 {
+			line += " [" + strings.Join(attributes, ", ") + "]"
+		}
+		builder.WriteString(line + ";\n")
+	}
+}
+
+func mermaidLabel(text string) string {
+	return `"` + strings.ReplaceAll(oneLine(text), `"`, "#quot;") + `"`
+}
+
+// renderMermaid writes a flowchart with subgraphs for subtasks, styled by
+// status classes and the startNode and endNode classes, as end is a keyword.
+func renderMermaid(graph *Graph) string {
+	var builder strings.Builder
+	builder.WriteString("flowchart LR\n")
+	writeMermaidBody(&builder, graph, "  ")
+	for _, status := range []string{"Todo", "Suspended", "Done"} {
+		builder.WriteString("  classDef " + strings.ToLower(status) + " fill:" + statusColors[status] + "\n")
+	}
+	builder.WriteString("  classDef startNode stroke:" + startColor + ",stroke-width:2px\n")
+	builder.WriteString("  classDef endNode stroke:" + endColor + ",stroke-width:2px\n")
+	return builder.String()
+}
+
+func writeMermaidBody(builder *strings.Builder, graph *Graph, indent string) {
+	for _, node := range graph.Data.Nodes {
+		key := nodeKey(node.ID)
+		if subgraph, ok := graph.Subgraphs[node.ID]; ok {
+			builder.WriteString(indent + "subgraph " + key + " [" + mermaidLabel(node.Name) + "]\n")
+			builder.WriteString(indent + "  direction LR\n")
+			writeMermaidBody(builder, subgraph, indent+"  ")
+			builder.WriteString(indent + "end\n")
+		} else {
+			builder.WriteString(indent + key + "[" + mermaidLabel(node.Name) + "]\n")
+		}
+	}
+	for _, edge := range graph.Data.Edges {
+		builder.WriteString(indent + nodeKey(edge.Source) + " --> " + nodeKey(edge.Target) + "\n")
+	}
+	for _, node := range graph.Data.Nodes {
+		classes := []string{strings.ToLower(node.Status)}
+		if contains(graph.Data.NodeConnectedToStart, node.ID) {
+			classes = append(classes, "startNode")
+		}
+		if contains(graph.Data.NodeConnectedToEnd, node.ID) {
+			classes = append(classes, "endNode")
+		}
+		for _, class := range classes {
+			builder.WriteString(indent + "class " + nodeKey(node.ID) + " " + class + "\n")
+		}
+	}
+}
+
+// ganttName drops the colons, which end a Mermaid Gantt task name.
+func ganttName(text string) string {
+	return oneLine(strings.ReplaceAll(text, ":", " "))
+}
+
+// renderGantt writes a Gantt chart with a section per graph. Tasks start after
+// the tasks they depend on, or at their defer date, and end at their deadline
+// or after a day. Done tasks are marked done, tasks connected to the start
+// active and those connected to the end critical.
+func renderGantt(graph *Graph, now time.Time) string {
+	var builder strings.Builder
+	builder.WriteString("gantt\n")
+	builder.WriteString("  title " + ganttName(graph.Name) + "\n")
+	builder.WriteString("  dateFormat YYYY-MM-DD\n")
+	writeGanttSection(&builder, graph, now)
+	return builder.String()
+}
+
+func writeGanttSection(builder *strings.Builder, graph *Graph, now time.Time) {
+	builder.WriteString("  section " + ganttName(graph.Name) + "\n")
+	sources := make(map[string][]string)
+	for _, edge := range graph.Data.Edges {
+		sources[edge.Target] = append(sources[edge.Target], nodeKey(edge.Source))
+	}
+	for _, node := range graph.Data.Nodes {
+		schedule := graph.Schedules[node.ID]
+		tags := make([]string, 0, 2)
+		if contains(graph.Data.NodeConnectedToEnd, node.ID) {
+			tags = append(tags, "crit")
+		}
+		if node.Status == "Done" {
+			tags = append(tags, "done")
+		} else if contains(graph.Data.NodeConnectedToStart, node.ID) {
+			tags = append(tags, "active")
+		}
+		tags = append(tags, nodeKey(node.ID))
+		start := now
+		if !schedule.AvailableFrom.IsZero() {
+			start = schedule.AvailableFrom
+		}
+		if after, ok := sources[node.ID]; ok {
+			tags = append(tags, "after "+strings.Join(after, " "))
+		} else {
+			tags = append(tags, start.Local().Format("2006-01-02"))
+		}
+		if !schedule.Deadline.IsZero() && schedule.Deadline.After(start) {
+			tags = append(tags, schedule.Deadline.Local().Format("2006-01-02"))
+		} else {
+			tags = append(tags, "1d")
+		}
+		builder.WriteString("  " + ganttName(node.Name) + " :" + strings.Join(tags, ", ") + "\n")
+	}
+	for _, node := range graph.Data.Nodes {
+		if subgraph, ok := graph.Subgraphs[node.ID]; ok {
+			writeGanttSection(builder, subgraph, now)
+		}
+	}
+}
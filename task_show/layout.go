@@ -0,0 +1,394 @@
+package task_show
+
+import (
+	"atodo_go/table"
+	"context"
+	"sort"
+)
+
+// Node sizes and gaps of the layout, in the units of Position. Layers run
+// from left to right, the nodes of a layer from top to bottom.
+const (
+	NodeWidth    = 200
+	NodeHeight   = 80
+	LayerSpacing = 250
+	NodeSpacing  = 120
+)
+
+// crossingSweeps is how often the layer order is swept up and down to reduce
+// edge crossings.
+const crossingSweeps = 8
+
+type edge struct {
+	source int
+	target int
+}
+
+// layered is the graph of a layout with its edges spanning one layer each.
+// Long edges are split by dummy nodes with negative IDs.
+type layered struct {
+	layers       [][]int
+	predecessors map[int][]int
+	successors   map[int][]int
+}
+
+// acyclicEdges returns the relations between the nodes with the edges closing
+// a cycle reversed.
+func acyclicEdges(ids []int, relations []table.TaskRelation) []edge {
+	known := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		known[id] = true
+	}
+	seen := make(map[edge]bool)
+	successors := make(map[int][]int)
+	for _, relation := range relations {
+		e := edge{relation.Source, relation.Target}
+		if !known[e.source] || !known[e.target] || e.source == e.target || seen[e] {
+			continue
+		}
+		seen[e] = true
+		successors[e.source] = append(successors[e.source], e.target)
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[int]int, len(ids))
+	edges := make([]edge, 0, len(seen))
+	var visit func(id int)
+	visit = func(id int) {
+		state[id] = visiting
+		for _, target := range successors[id] {
+			switch state[target] {
+			case visiting:
+				edges = append(edges, edge{target, id})
+			case visited:
+				edges = append(edges, edge{id, target})
+			default:
+				edges = append(edges, edge{id, target})
+				visit(target)
+			}
+		}
+		state[id] = visited
+	}
+	for _, id := range ids {
+		if state[id] == unvisited {
+			visit(id)
+		}
+	}
+	return edges
+}
+
+// assignLayers puts every node one layer right of its furthest predecessor
+// and splits the edges spanning more layers.
+func assignLayers(ids []int, edges []edge) *layered {
+	incoming := make(map[int]int, len(ids))
+	outgoing := make(map[int][]int, len(ids))
+	for _, e := range edges {
+		incoming[e.target]++
+		outgoing[e.source] = append(outgoing[e.source], e.target)
+	}
+	layer := make(map[int]int, len(ids))
+	queue := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if incoming[id] == 0 {
+			queue = append(queue, id)
+		}
+	}
+	for len(queue) != 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, target := range outgoing[id] {
+			if layer[id]+1 > layer[target] {
+				layer[target] = layer[id] + 1
+			}
+			incoming[target]--
+			if incoming[target] == 0 {
+				queue = append(queue, target)
+			}
+		}
+	}
+
+	graph := &layered{
+		predecessors: make(map[int][]int),
+		successors:   make(map[int][]int),
+	}
+	place := func(id int, at int) {
+		for len(graph.layers) <= at {
+			graph.layers = append(graph.layers, make([]int, 0))
+		}
+		graph.layers[at] = append(graph.layers[at], id)
+	}
+	connect := func(source int, target int) {
+		graph.successors[source] = append(graph.successors[source], target)
+		graph.predecessors[target] = append(graph.predecessors[target], source)
+	}
+	for _, id := range ids {
+		place(id, layer[id])
+	}
+	dummy := 0
+	for _, e := range edges {
+		source := e.source
+		for at := layer[e.source] + 1; at < layer[e.target]; at++ {
+			dummy--
+			place(dummy, at)
+			connect(source, dummy)
+			source = dummy
+		}
+		connect(source, e.target)
+	}
+	return graph
+}
+
+// crossings counts the crossing edges between neighbouring layers.
+func (graph *layered) crossings() int {
+	count := 0
+	for at := 0; at+1 < len(graph.layers); at++ {
+		index := make(map[int]int, len(graph.layers[at+1]))
+		for i, id := range graph.layers[at+1] {
+			index[id] = i
+		}
+		ends := make([][2]int, 0)
+		for i, id := range graph.layers[at] {
+			for _, target := range graph.successors[id] {
+				ends = append(ends, [2]int{i, index[target]})
+			}
+		}
+		for i := range ends {
+			for j := i + 1; j < len(ends); j++ {
+				if (ends[i][0]-ends[j][0])*(ends[i][1]-ends[j][1]) < 0 {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+// sortByBarycenter orders the layer by the mean index of each node's
+// neighbours in the fixed layer. Nodes without neighbours keep their index.
+func sortByBarycenter(layer []int, neighbours map[int][]int, fixed []int) {
+	index := make(map[int]int, len(fixed))
+	for i, id := range fixed {
+		index[id] = i
+	}
+	barycenter := make(map[int]float64, len(layer))
+	for i, id := range layer {
+		barycenter[id] = float64(i)
+		if len(neighbours[id]) == 0 {
+			continue
+		}
+		sum := 0
+		for _, neighbour := range neighbours[id] {
+			sum += index[neighbour]
+		}
+		barycenter[id] = float64(sum) / float64(len(neighbours[id]))
+	}
+	sort.SliceStable(layer, func(i, j int) bool {
+		return barycenter[layer[i]] < barycenter[layer[j]]
+	})
+}
+
+func copyLayers(layers [][]int) [][]int {
+	copied := make([][]int, len(layers))
+	for i, layer := range layers {
+		copied[i] = append([]int(nil), layer...)
+	}
+	return copied
+}
+
+// reduceCrossings sweeps down and up the layers, keeping the order with the
+// fewest crossings.
+func (graph *layered) reduceCrossings() {
+	best := copyLayers(graph.layers)
+	fewest := graph.crossings()
+	for sweep := 0; sweep < crossingSweeps && fewest > 0; sweep++ {
+		if sweep%2 == 0 {
+			for at := 1; at < len(graph.layers); at++ {
+				sortByBarycenter(graph.layers[at], graph.predecessors, graph.layers[at-1])
+			}
+		} else {
+			for at := len(graph.layers) - 2; at >= 0; at-- {
+				sortByBarycenter(graph.layers[at], graph.successors, graph.layers[at+1])
+			}
+		}
+		if count := graph.crossings(); count < fewest {
+			best = copyLayers(graph.layers)
+			fewest = count
+		}
+	}
+	graph.layers = best
+}
+
+// coordinates puts the layers LayerSpacing apart and each node level with the
+// mean of its predecessors, at least NodeSpacing below the node above it.
+func (graph *layered) coordinates() map[int]Position {
+	positions := make(map[int]Position)
+	for at, layer := range graph.layers {
+		next := 0
+		for i, id := range layer {
+			y := next
+			if predecessors := graph.predecessors[id]; len(predecessors) != 0 {
+				sum := 0
+				for _, predecessor := range predecessors {
+					sum += positions[predecessor].Y
+				}
+				y = sum / len(predecessors)
+			}
+			if i != 0 && y < next {
+				y = next
+			}
+			positions[id] = Position{X: at * LayerSpacing, Y: y}
+			next = y + NodeSpacing
+		}
+	}
+	return positions
+}
+
+// Layout arranges the nodes and their relations as a layered graph: cycles
+// are broken, nodes layered by their longest path from a start node, the
+// layers ordered to reduce crossings and the nodes placed level with their
+// predecessors. The current positions give the first order within a layer,
+// so a repeated layout keeps the arrangement where it can.
+func Layout(ids []int, relations []table.TaskRelation, positions map[int]Position) map[int]Position {
+	sorted := append([]int(nil), ids...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := positions[sorted[i]], positions[sorted[j]]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return sorted[i] < sorted[j]
+	})
+	graph := assignLayers(sorted, acyclicEdges(sorted, relations))
+	graph.reduceCrossings()
+	all := graph.coordinates()
+	layout := make(map[int]Position, len(ids))
+	for _, id := range ids {
+		layout[id] = all[id]
+	}
+	return layout
+}
+
+// subgraph returns the subtasks of the task, their positions and relations.
+func subgraph(ctx context.Context, parentID int) ([]int, map[int]Position, []table.TaskRelation, error) {
+	tasks, err := table.GetTasksByParentTask(ctx, parentID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	relations, err := table.GetRelationByParentTask(ctx, parentID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ids := make([]int, 0, len(tasks))
+	positions := make(map[int]Position, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+		positions[task.ID] = Position{X: task.PositionX, Y: task.PositionY}
+	}
+	return ids, positions, relations, nil
+}
+
+// AutoLayout lays out the subtasks of the task and saves the positions that
+// changed.
+func AutoLayout(ctx context.Context, parentID int) (map[int]Position, error) {
+	ids, positions, relations, err := subgraph(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	layout := Layout(ids, relations, positions)
+	for _, id := range ids {
+		if layout[id] == positions[id] {
+			continue
+		}
+		err = table.UpdatePosition(ctx, id, layout[id].X, layout[id].Y)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return layout, nil
+}
+
+func overlaps(a Position, b Position) bool {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return dx > -NodeWidth && dx < NodeWidth && dy > -NodeHeight && dy < NodeHeight
+}
+
+// Place returns where a node goes among the others: a layer right of its
+// predecessors, else left of its successors, else where it is, moved down
+// until it overlaps no other node.
+func Place(position Position, predecessors []Position, successors []Position, others []Position) Position {
+	mean := func(positions []Position) int {
+		sum := 0
+		for _, p := range positions {
+			sum += p.Y
+		}
+		return sum / len(positions)
+	}
+	if len(predecessors) != 0 {
+		position = Position{X: predecessors[0].X, Y: mean(predecessors)}
+		for _, p := range predecessors {
+			if p.X > position.X {
+				position.X = p.X
+			}
+		}
+		position.X += LayerSpacing
+	} else if len(successors) != 0 {
+		position = Position{X: successors[0].X, Y: mean(successors)}
+		for _, p := range successors {
+			if p.X < position.X {
+				position.X = p.X
+			}
+		}
+		position.X -= LayerSpacing
+	}
+	for moved := true; moved; {
+		moved = false
+		for _, other := range others {
+			if overlaps(position, other) {
+				position.Y += NodeSpacing
+				moved = true
+			}
+		}
+	}
+	return position
+}
+
+// PlaceTask moves a new, copied or imported task next to its relations and
+// off the siblings it would cover.
+func PlaceTask(ctx context.Context, id int) error {
+	task, err := table.GetTaskByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	ids, positions, relations, err := subgraph(ctx, task.ParentTask)
+	if err != nil {
+		return err
+	}
+	predecessors := make([]Position, 0)
+	successors := make([]Position, 0)
+	for _, relation := range relations {
+		if relation.Target == id && relation.Source != id {
+			predecessors = append(predecessors, positions[relation.Source])
+		}
+		if relation.Source == id && relation.Target != id {
+			successors = append(successors, positions[relation.Target])
+		}
+	}
+	others := make([]Position, 0, len(ids))
+	for _, other := range ids {
+		if other != id {
+			others = append(others, positions[other])
+		}
+	}
+	current := Position{X: task.PositionX, Y: task.PositionY}
+	position := Place(current, predecessors, successors, others)
+	if position == current {
+		return nil
+	}
+	return table.UpdatePosition(ctx, id, position.X, position.Y)
+}
@@ -2,6 +2,8 @@ package task_show
 
 import (
 	"atodo_go/table"
+	"atodo_go/tag_filter"
+	"context"
 	"fmt"
 	"sort"
 	"strconv"
@@ -13,10 +15,12 @@ type ShowEdge struct {
 }
 
 type ShowNode struct {
-	ID       string   `json:"id"`
-	Name     string   `json:"name"`
-	Position Position `json:"position"`
-	Status   string   `json:"status"`
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Position     Position `json:"position"`
+	Status       string   `json:"status"`
+	Tags         []string `json:"tags"`
+	CommentCount int      `json:"comment_count"`
 }
 
 type Position struct {
@@ -96,22 +100,22 @@ func inferenceStartAndEndNodes(nodes *[]ShowNode, relations *[]table.TaskRelatio
 	return &nodeConnectedToStart, &nodeConnectedToEnd
 }
 
-func GetShowStack() ([]string, error) {
-	nowViewingTaskID, err := table.GetNowViewingTask()
+func GetShowStack(ctx context.Context) ([]string, error) {
+	nowViewingTaskID, err := table.GetNowViewingTask(ctx)
 	if err != nil {
 		return nil, err
 	}
 	if nowViewingTaskID == -1 {
 		return nil, fmt.Errorf("no task is being viewed")
 	}
-	task, err := table.GetTaskByID(nowViewingTaskID)
+	task, err := table.GetTaskByID(ctx, nowViewingTaskID)
 	if err != nil {
 		return nil, err
 	}
 	var stack []string
 	stack = append(stack, task.Name)
 	for task.ParentTask != -1 {
-		task, err = table.GetTaskByID(task.ParentTask)
+		task, err = table.GetTaskByID(ctx, task.ParentTask)
 		if err != nil {
 			return nil, err
 		}
@@ -120,19 +124,26 @@ func GetShowStack() ([]string, error) {
 	return stack, nil
 }
 
-func GetShowData() (*ShowData, error) {
-	nowViewingTaskID, err := table.GetNowViewingTask()
+func GetShowData(ctx context.Context) (*ShowData, error) {
+	nowViewingTaskID, err := table.GetNowViewingTask(ctx)
 	if err != nil {
 		return nil, err
 	}
 	if nowViewingTaskID == -1 {
 		return nil, fmt.Errorf("no task is being viewed")
 	}
-	return GetShowDataByTaskID(nowViewingTaskID)
+	ok, err := table.HasWorkspaceRole(ctx, table.CurrentUserID(ctx), nowViewingTaskID, table.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, table.ErrForbidden
+	}
+	return GetShowDataByTaskID(ctx, nowViewingTaskID)
 }
 
-func GetShowDataByTaskID(id int) (*ShowData, error) {
-	tasks, err := table.GetTasksByParentTask(id)
+func GetShowDataByTaskID(ctx context.Context, id int) (*ShowData, error) {
+	tasks, err := table.GetTasksByParentTask(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -142,11 +153,23 @@ func GetShowDataByTaskID(id int) (*ShowData, error) {
 		NodeConnectedToStart: []string{},
 		NodeConnectedToEnd:   []string{},
 	}
+	taskIDs := make([]int, 0, len(tasks))
+	for _, task := range tasks {
+		taskIDs = append(taskIDs, task.ID)
+	}
+	commentCounts, err := table.GetTaskCommentCounts(ctx, taskIDs)
+	if err != nil {
+		return nil, err
+	}
 	for _, task := range tasks {
 		statusStr, err := task.Status.String()
 		if err != nil {
 			return nil, err
 		}
+		tags, err := table.GetTagNamesByTaskID(ctx, task.ID)
+		if err != nil {
+			return nil, err
+		}
 		showData.Nodes = append(showData.Nodes, ShowNode{
 			ID:   fmt.Sprintf("%d", task.ID),
 			Name: task.Name,
@@ -154,11 +177,13 @@ func GetShowDataByTaskID(id int) (*ShowData, error) {
 				X: task.PositionX,
 				Y: task.PositionY,
 			},
-			Status: statusStr,
+			Status:       statusStr,
+			Tags:         tags,
+			CommentCount: commentCounts[task.ID],
 		})
 	}
 
-	relations, err := table.GetRelationByParentTask(id)
+	relations, err := table.GetRelationByParentTask(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -174,3 +199,50 @@ func GetShowDataByTaskID(id int) (*ShowData, error) {
 	showData.NodeConnectedToEnd = *nodeConnectedToEnd
 	return &showData, nil
 }
+
+// GetShowDataFiltered returns the graph of the viewed task with only the nodes
+// whose own or inherited tags match the filter, and the edges between them.
+func GetShowDataFiltered(ctx context.Context, filter tag_filter.TagFilter) (*ShowData, error) {
+	match, err := filter.Compile()
+	if err != nil {
+		return nil, err
+	}
+	showData, err := GetShowData(ctx)
+	if err != nil || filter.IsEmpty() {
+		return showData, err
+	}
+	kept := make(map[string]bool)
+	nodes := make([]ShowNode, 0, len(showData.Nodes))
+	for _, node := range showData.Nodes {
+		nodeID, err := strconv.Atoi(node.ID)
+		if err != nil {
+			return nil, err
+		}
+		tags, err := table.GetEffectiveTagNames(ctx, nodeID)
+		if err != nil {
+			return nil, err
+		}
+		if match(tags) {
+			nodes = append(nodes, node)
+			kept[node.ID] = true
+		}
+	}
+	edges := make([]ShowEdge, 0, len(showData.Edges))
+	relations := make([]table.TaskRelation, 0, len(showData.Edges))
+	for _, edge := range showData.Edges {
+		if !kept[edge.Source] || !kept[edge.Target] {
+			continue
+		}
+		source, _ := strconv.Atoi(edge.Source)
+		target, _ := strconv.Atoi(edge.Target)
+		edges = append(edges, edge)
+		relations = append(relations, table.TaskRelation{Source: source, Target: target})
+	}
+	nodeConnectedToStart, nodeConnectedToEnd := inferenceStartAndEndNodes(&nodes, &relations)
+	return &ShowData{
+		Nodes:                nodes,
+		Edges:                edges,
+		NodeConnectedToStart: *nodeConnectedToStart,
+		NodeConnectedToEnd:   *nodeConnectedToEnd,
+	}, nil
+}
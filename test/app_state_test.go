@@ -1,27 +1,31 @@
 package test
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 import "atodo_go/table"
 
 func TestRootTask(t *testing.T) {
+	ctx := context.Background()
 	err := table.InitDB()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	rootTask, err := table.GetRootTask()
+	rootTask, err := table.GetRootTask(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	setRootTask := 100
-	err = table.SetRootTask(setRootTask)
+	err = table.SetRootTask(ctx, setRootTask)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	rootTaskN, err := table.GetRootTask()
+	rootTaskN, err := table.GetRootTask(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -30,7 +34,7 @@ func TestRootTask(t *testing.T) {
 		t.Fatal("Failed to set root task")
 	}
 
-	err = table.SetRootTask(rootTask)
+	err = table.SetRootTask(ctx, rootTask)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,109 @@
+package test
+
+import (
+	"atodo_go/table"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := table.AddTask(ctx, table.Task{
+		Name:       "Audit Task",
+		Deadline:   time.Now(),
+		Status:     table.Todo,
+		ParentTask: -1,
+	})
+
+	err = table.UpdateTaskName(table.WithSource(ctx, "test"), id, "Audit Task Renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := table.QueryAuditLog(ctx, table.AuditFilter{
+		Entity:   table.EntityTask,
+		EntityID: &id,
+		Field:    "name",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 {
+		t.Fatal("expected one name change, got", page.Total)
+	}
+	entry := page.Entries[0]
+	if entry.OldValue != "Audit Task" || entry.NewValue != "Audit Task Renamed" || entry.Source != "test" {
+		t.Fatal("unexpected audit entry", entry)
+	}
+
+	err = table.EliminateTask(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err = table.QueryAuditLog(ctx, table.AuditFilter{
+		Entity:   table.EntityTask,
+		EntityID: &id,
+		Field:    table.WholeRecord,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 {
+		t.Fatal("expected creation and deletion records, got", page.Total)
+	}
+}
+
+func TestAuditLogVisibility(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	suffix := time.Now().UnixNano()
+	owner, err := table.CreateUser(ctx, fmt.Sprintf("audit-owner-%d", suffix), "owner password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, err := table.CreateUser(ctx, fmt.Sprintf("audit-stranger-%d", suffix), "stranger password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerCtx := asUser(owner)
+	workspace := table.AddTask(ownerCtx, table.Task{Name: "Audit Workspace", Deadline: time.Now(), ParentTask: -1})
+	child := table.AddTask(ownerCtx, table.Task{Name: "Audit Child", Deadline: time.Now(), ParentTask: workspace})
+	open := table.AddTask(ctx, table.Task{Name: "Open Audit Workspace", Deadline: time.Now(), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, open)
+	}()
+
+	total := func(userID, taskID int) int64 {
+		page, err := table.QueryAuditLog(asUser(userID), table.AuditFilter{Entity: table.EntityTask, EntityID: &taskID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return page.Total
+	}
+	if total(stranger, child) != 0 || total(owner, child) == 0 {
+		t.Fatal("changes of a workspace should only show to its members")
+	}
+	if total(stranger, open) == 0 {
+		t.Fatal("changes of a workspace without members should show to everyone")
+	}
+
+	err = table.EliminateTask(ownerCtx, workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total(stranger, child) != 0 {
+		t.Fatal("changes of a deleted workspace should not show to other users")
+	}
+	if total(owner, child) != 2 {
+		t.Fatal("the owner should still see their creation and deletion of the task")
+	}
+}
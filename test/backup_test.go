@@ -0,0 +1,163 @@
+package test
+
+import (
+	"atodo_go/backup"
+	"atodo_go/table"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Date(2030, 1, 2, 9, 30, 0, 0, time.UTC)
+	workspace := table.AddTask(ctx, table.Task{Name: "Backup Workspace", Deadline: time.UnixMilli(0), ParentTask: -1})
+	first := table.AddTask(ctx, table.Task{Name: "First", Deadline: deadline, ParentTask: workspace, PositionX: 10, PositionY: 20})
+	second := table.AddTask(ctx, table.Task{Name: "Second", Deadline: deadline, Status: table.Suspended, ParentTask: workspace})
+	target := table.AddTask(ctx, table.Task{Name: "Import Target", Deadline: time.UnixMilli(0), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, workspace)
+		_ = table.EliminateTask(ctx, target)
+	}()
+	err = table.AddRelation(ctx, workspace, first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trigger := table.TaskTrigger{ID: second, Type: table.Dependency}
+	err = trigger.SetDependencyInfo(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.AddOrUpdateTaskTrigger(ctx, trigger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	suspended := table.SuspendedTask{ID: second, Type: table.Time}
+	err = suspended.SetTimeInfo(table.SuspendedTimeInfo{Timestamp: deadline.UnixMilli()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.AddSuspendedTask(ctx, suspended)
+	err = table.SetTaskNote(ctx, first, "remember the milk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := backup.Export(ctx, workspace, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Tasks) != 3 || len(archive.Relations) != 1 || archive.Version != backup.Version {
+		t.Fatalf("unexpected archive %+v", archive)
+	}
+	data, err := json.Marshal(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded backup.Archive
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dryRun, err := backup.Import(ctx, &decoded, target, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subTasks, _ := table.GetSubTasksID(ctx, target)
+	if !dryRun.DryRun || dryRun.Tasks != 3 || len(subTasks) != 0 {
+		t.Fatalf("dry run wrote tasks or miscounted: %+v", dryRun)
+	}
+
+	result, err := backup.Import(ctx, &decoded, target, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := table.GetTaskByID(ctx, result.Root)
+	if err != nil || root.ParentTask != target || root.Name != "Backup Workspace" {
+		t.Fatalf("unexpected imported root %+v", root)
+	}
+	newFirst, newSecond := result.IDs[first], result.IDs[second]
+	copied, _ := table.GetTaskByID(ctx, newFirst)
+	if copied.PositionX != 10 || copied.PositionY != 20 || !copied.Deadline.Equal(deadline) {
+		t.Errorf("unexpected imported task %+v", copied)
+	}
+	note, _ := table.GetTaskNote(ctx, newFirst)
+	if note != "remember the milk" {
+		t.Errorf("unexpected note %q", note)
+	}
+	targets, _ := table.GetTargetTasks(ctx, newFirst)
+	if len(targets) != 1 || targets[0] != newSecond {
+		t.Errorf("expected the relation to be remapped, got %v", targets)
+	}
+	triggers, _ := table.GetTaskTriggersByID(ctx, newSecond)
+	if len(triggers) != 1 {
+		t.Fatalf("expected one trigger, got %v", triggers)
+	}
+	info, _ := triggers[0].GetDependencyInfo()
+	if info.Source != newFirst {
+		t.Errorf("expected the dependency to be remapped to %d, got %d", newFirst, info.Source)
+	}
+	if !table.IsTaskSuspended(ctx, newSecond) {
+		t.Error("expected the suspension to be imported")
+	}
+
+	// the duplicate relation fails after the tasks are written
+	decoded.Relations = append(decoded.Relations, decoded.Relations[0])
+	_, err = backup.Import(ctx, &decoded, target, false)
+	if err == nil {
+		t.Fatal("expected a duplicate relation to fail the import")
+	}
+	subTasks, _ = table.GetSubTasksID(ctx, target)
+	if len(subTasks) != 1 {
+		t.Errorf("a failed import should leave nothing behind, got %v", subTasks)
+	}
+}
+
+func TestBackupValidate(t *testing.T) {
+	archive := &backup.Archive{
+		Version: backup.Version,
+		Root:    1,
+		Tasks: []backup.Task{
+			{ID: 1, ParentTask: -1, Status: "Todo"},
+			{ID: 2, ParentTask: 1, Status: "Todo", Triggers: []backup.Typed{{Type: "Dependency", Info: json.RawMessage(`{"source":99}`)}}},
+		},
+	}
+	warnings, err := backup.Validate(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "task 99") {
+		t.Errorf("expected a warning about the dropped dependency, got %v", warnings)
+	}
+
+	archive.Tasks[1].ParentTask = 3
+	_, err = backup.Validate(archive)
+	if err == nil {
+		t.Error("expected an orphaned task to be rejected")
+	}
+	archive.Tasks[1].ParentTask = 1
+	archive.Tasks[1].Status = "Later"
+	_, err = backup.Validate(archive)
+	if err == nil {
+		t.Error("expected an unknown status to be rejected")
+	}
+	archive.Tasks[1].Status = "Todo"
+	archive.Tasks[1].AfterEffects = []backup.Typed{{Type: "Periodic", Info: json.RawMessage(`{"NowAt":2,"Intervals":[3600000]}`)}}
+	_, err = backup.Validate(archive)
+	if err == nil {
+		t.Error("expected a periodic after-effect past its intervals to be rejected")
+	}
+	archive.Tasks[1].AfterEffects = nil
+	archive.Version = backup.Version + 1
+	_, err = backup.Validate(archive)
+	if err == nil {
+		t.Error("expected an unknown version to be rejected")
+	}
+}
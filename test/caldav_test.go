@@ -0,0 +1,261 @@
+package test
+
+import (
+	"atodo_go/caldav"
+	"atodo_go/table"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func vtodo(uid string, summary string, status string, parentUID string) []byte {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//test//test//EN",
+		"BEGIN:VTODO",
+		"UID:" + uid,
+		"SUMMARY:" + summary,
+		"DUE:20300102T093000Z",
+		"STATUS:" + status,
+		"RELATED-TO;RELTYPE=PARENT:" + parentUID,
+		"END:VTODO",
+		"END:VCALENDAR",
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func TestCaldavSync(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspace := table.AddTask(ctx, table.Task{Name: "Caldav Workspace", Deadline: time.UnixMilli(0), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, workspace)
+	}()
+	parentUID := "task-" + strconv.Itoa(workspace) + "@atodo"
+
+	token, err := caldav.SyncToken(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, isNew, err := caldav.Put(ctx, "phone-1.ics", vtodo("phone-1", "Buy milk\\, eggs", "NEEDS-ACTION", parentUID), "", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isNew || created.Task.Name != "Buy milk, eggs" || created.Task.ParentTask != workspace {
+		t.Fatalf("unexpected created resource %+v", created)
+	}
+	if !created.Task.Deadline.Equal(time.Date(2030, 1, 2, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected deadline %v", created.Task.Deadline)
+	}
+	data := created.Data()
+	for _, want := range []string{"UID:phone-1\r\n", "STATUS:NEEDS-ACTION\r\n", "RELATED-TO:" + parentUID + "\r\n"} {
+		if !strings.Contains(data, want) {
+			t.Errorf("expected %q in\n%s", want, data)
+		}
+	}
+
+	_, _, err = caldav.Put(ctx, "phone-2.ics", vtodo("phone-1", "Duplicate", "NEEDS-ACTION", parentUID), "", "")
+	if !errors.Is(err, caldav.ErrUIDConflict) {
+		t.Errorf("expected a UID conflict, got %v", err)
+	}
+	_, _, err = caldav.Put(ctx, "phone-1.ics", vtodo("phone-1", "Stale", "NEEDS-ACTION", parentUID), `"stale"`, "")
+	if !errors.Is(err, caldav.ErrPreconditionFailed) {
+		t.Errorf("expected a failed precondition, got %v", err)
+	}
+
+	updated, isNew, err := caldav.Put(ctx, "phone-1.ics", vtodo("phone-1", "Buy milk", "COMPLETED", parentUID), created.ETag(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isNew || updated.Task.Status != table.Done || updated.Task.Name != "Buy milk" {
+		t.Errorf("unexpected updated resource %+v", updated)
+	}
+	if updated.ETag() == created.ETag() {
+		t.Error("expected the ETag to change with the task")
+	}
+	untitled := strings.Replace(string(vtodo("phone-1", "", "COMPLETED", parentUID)), "SUMMARY:\r\n", "", 1)
+	updated, _, err = caldav.Put(ctx, "phone-1.ics", []byte(untitled), updated.ETag(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Task.Name != "Buy milk" {
+		t.Errorf("a VTODO without SUMMARY should keep the name, got %q", updated.Task.Name)
+	}
+
+	changed, removed, next, err := caldav.Changes(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(changed))
+	for _, resource := range changed {
+		names = append(names, resource.Name)
+	}
+	if !strings.Contains(strings.Join(names, " "), "phone-1.ics") || len(removed) != 0 {
+		t.Errorf("unexpected changes %v, removed %v", names, removed)
+	}
+
+	err = caldav.Delete(ctx, "phone-1.ics", created.ETag())
+	if !errors.Is(err, caldav.ErrPreconditionFailed) {
+		t.Errorf("expected a failed precondition, got %v", err)
+	}
+	err = caldav.Delete(ctx, "phone-1.ics", updated.ETag())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = caldav.Lookup(ctx, "phone-1.ics")
+	if !errors.Is(err, caldav.ErrNotFound) {
+		t.Errorf("expected the resource to be gone, got %v", err)
+	}
+	after, err := caldav.SyncToken(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.UpdateTaskName(ctx, workspace, "Caldav Workspace Renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, removed, _, err = caldav.Changes(ctx, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] != "phone-1.ics" {
+		t.Errorf("unexpected removed %v", removed)
+	}
+	changed, removed, _, err = caldav.Changes(ctx, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 1 || changed[0].Task.ID != workspace || len(removed) != 0 {
+		t.Errorf("a token taken after the delete should only see the rename, got %d changed, removed %v", len(changed), removed)
+	}
+
+	_, _, _, err = caldav.Changes(ctx, "urn:atodo:sync:bogus")
+	if !errors.Is(err, caldav.ErrInvalidSyncToken) {
+		t.Errorf("expected an invalid sync token, got %v", err)
+	}
+}
+
+func TestCaldavSyncPermissions(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	suffix := time.Now().UnixNano()
+	owner, err := table.CreateUser(ctx, fmt.Sprintf("caldav-owner-%d", suffix), "owner password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	viewer, err := table.CreateUser(ctx, fmt.Sprintf("caldav-viewer-%d", suffix), "viewer password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerCtx, viewerCtx := asUser(owner), asUser(viewer)
+	workspace := table.AddTask(ownerCtx, table.Task{Name: "Caldav Shared", Deadline: time.UnixMilli(0), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, workspace)
+	}()
+	private := table.AddTask(ownerCtx, table.Task{Name: "Caldav Private", Deadline: time.UnixMilli(0), ParentTask: -1})
+	err = table.ShareWorkspace(ownerCtx, workspace, viewer, table.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspaceName := strconv.Itoa(workspace) + ".ics"
+	privateName := strconv.Itoa(private) + ".ics"
+
+	resources, _, token, err := caldav.Changes(viewerCtx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		names = append(names, resource.Name)
+	}
+	if !slices.Contains(names, workspaceName) || slices.Contains(names, privateName) {
+		t.Fatalf("unexpected resources %v", names)
+	}
+
+	err = table.EliminateTask(ownerCtx, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.UnshareWorkspace(ownerCtx, workspace, viewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, client := range []string{"first", "second"} {
+		_, removed, _, err := caldav.Changes(viewerCtx, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Contains(removed, workspaceName) {
+			t.Errorf("%s client: losing access should remove the workspace, removed %v", client, removed)
+		}
+		if slices.Contains(removed, privateName) {
+			t.Errorf("%s client: a task the user never saw should not be named, removed %v", client, removed)
+		}
+	}
+
+	_, removed, token, err := caldav.Changes(viewerCtx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.ShareWorkspace(ownerCtx, workspace, viewer, table.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, _, _, err := caldav.Changes(viewerCtx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names = names[:0]
+	for _, resource := range changed {
+		names = append(names, resource.Name)
+	}
+	if !slices.Contains(names, workspaceName) {
+		t.Errorf("regaining access should bring the workspace back, changed %v removed %v", names, removed)
+	}
+}
+
+func TestCaldavReport(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspace := table.AddTask(ctx, table.Task{Name: "Report Workspace", Deadline: time.UnixMilli(0), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, workspace)
+	}()
+	name := strconv.Itoa(workspace) + ".ics"
+	body := `<?xml version="1.0"?>
+<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/><C:calendar-data/><D:unknown/></D:prop>
+  <D:href>` + caldav.CollectionPath + name + `</D:href>
+  <D:href>` + caldav.CollectionPath + `missing.ics</D:href>
+</C:calendar-multiget>`
+	data, err := caldav.Report(ctx, caldav.CollectionPath, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := string(data)
+	for _, want := range []string{
+		"<D:href>" + caldav.CollectionPath + name + "</D:href>",
+		"SUMMARY:Report Workspace",
+		"<D:unknown/></D:prop><D:status>HTTP/1.1 404 Not Found</D:status>",
+		"<D:href>" + caldav.CollectionPath + "missing.ics</D:href><D:status>HTTP/1.1 404 Not Found</D:status>",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected %q in\n%s", want, report)
+		}
+	}
+}
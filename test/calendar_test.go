@@ -0,0 +1,111 @@
+package test
+
+import (
+	"atodo_go/calendar"
+	"atodo_go/table"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarExport(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Date(2030, 1, 2, 9, 30, 0, 0, time.UTC)
+	workspace := table.AddTask(ctx, table.Task{Name: "Calendar Workspace", Deadline: time.UnixMilli(0), ParentTask: -1})
+	other := table.AddTask(ctx, table.Task{Name: "Other Workspace", Deadline: deadline, ParentTask: -1})
+	report := table.AddTask(ctx, table.Task{Name: "Report, final; draft", Goal: "line one\nline two", Deadline: deadline, ParentTask: workspace})
+	standup := table.AddTask(ctx, table.Task{Name: "Standup", Deadline: deadline, ParentTask: workspace})
+	paused := table.AddTask(ctx, table.Task{Name: "Paused", Deadline: time.UnixMilli(0), Status: table.Suspended, ParentTask: workspace})
+	shifts := table.AddTask(ctx, table.Task{Name: "Shifts", Deadline: deadline, ParentTask: workspace})
+	defer func() {
+		_ = table.EliminateTask(ctx, workspace)
+		_ = table.EliminateTask(ctx, other)
+	}()
+	afterEffect := table.TaskAfterEffect{ID: standup, Type: table.Periodic}
+	err = afterEffect.SetPeriodicInfo(table.PeriodicT{Intervals: []int{int(time.Hour.Milliseconds())}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.AddOrUpdateTaskAfterEffect(ctx, afterEffect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an hour, then a day: no single RRULE repeats it
+	afterEffect = table.TaskAfterEffect{ID: shifts, Type: table.Periodic}
+	err = afterEffect.SetPeriodicInfo(table.PeriodicT{Intervals: []int{int(time.Hour.Milliseconds()), 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.AddOrUpdateTaskAfterEffect(ctx, afterEffect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	suspended := table.SuspendedTask{ID: paused, Type: table.Time}
+	err = suspended.SetTimeInfo(table.SuspendedTimeInfo{Timestamp: deadline.Add(time.Hour).UnixMilli()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.AddSuspendedTask(ctx, suspended)
+
+	stamp := time.Date(2029, 12, 1, 0, 0, 0, 0, time.UTC)
+	events, err := calendar.Export(ctx, calendar.Filter{WorkspaceID: workspace}, calendar.VariantEvent, stamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"UID:task-" + strconv.Itoa(report) + "-deadline@atodo\r\n",
+		"SUMMARY:Report\\, final\\; draft\r\n",
+		"DESCRIPTION:line one\\nline two\r\n",
+		"DTSTART:20300102T093000Z\r\n",
+		"DTSTAMP:20291201T000000Z\r\n",
+		"UID:task-" + strconv.Itoa(standup) + "-deadline@atodo\r\nDTSTAMP:20291201T000000Z\r\nSUMMARY:Standup\r\nDTSTART:20300102T093000Z\r\nRRULE:FREQ=DAILY;INTERVAL=1\r\n",
+		"UID:task-" + strconv.Itoa(paused) + "-resume@atodo\r\nDTSTAMP:20291201T000000Z\r\nSUMMARY:Resume: Paused\r\nDTSTART:20300102T103000Z\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(events, want) {
+			t.Errorf("expected %q in\n%s", want, events)
+		}
+	}
+	if !strings.Contains(events, "SUMMARY:Shifts\r\nDTSTART:20300102T093000Z\r\nTRANSP") {
+		t.Errorf("a task with differing steps should not repeat\n%s", events)
+	}
+	if strings.Contains(events, "Other Workspace") {
+		t.Error("the workspace filter should leave out other workspaces")
+	}
+
+	todos, err := calendar.Export(ctx, calendar.Filter{WorkspaceID: workspace}, calendar.VariantTodo, stamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"BEGIN:VTODO\r\nUID:task-" + strconv.Itoa(report) + "@atodo\r\n",
+		"DUE:20300102T093000Z\r\nSTATUS:NEEDS-ACTION\r\n",
+		"DTSTART:20300102T103000Z\r\nSTATUS:IN-PROCESS\r\n",
+		"SUMMARY:Standup\r\nDTSTART:20300101T093000Z\r\nDUE:20300102T093000Z\r\nRRULE:FREQ=DAILY;INTERVAL=1\r\n",
+	} {
+		if !strings.Contains(todos, want) {
+			t.Errorf("expected %q in\n%s", want, todos)
+		}
+	}
+}
+
+func TestCalendarFolding(t *testing.T) {
+	component := calendar.Component{Name: "VEVENT"}
+	component.Add("SUMMARY", calendar.Text(strings.Repeat("é", 60)))
+	for _, line := range strings.Split(strings.TrimSuffix(component.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+	}
+	unfolded := strings.ReplaceAll(component.String(), "\r\n ", "")
+	if !strings.Contains(unfolded, "SUMMARY:"+strings.Repeat("é", 60)+"\r\n") {
+		t.Error("folding should not change the value", unfolded)
+	}
+}
@@ -0,0 +1,174 @@
+package test
+
+import (
+	"atodo_go/digest"
+	"atodo_go/notify"
+	"atodo_go/table"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateDigest(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousRoot, err := table.GetRootTask(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	root := table.AddTask(ctx, table.Task{Name: "Digest Root", Deadline: time.UnixMilli(0), ParentTask: -1})
+	ready := table.AddTask(ctx, table.Task{Name: "Ready <Task>", Goal: "ship it", Deadline: now.Add(48 * time.Hour), ParentTask: root})
+	late := table.AddTask(ctx, table.Task{Name: "Late Task", Deadline: now.Add(-time.Hour), ParentTask: root})
+	defer func() {
+		_ = table.SetRootTask(ctx, previousRoot)
+		_ = table.EliminateTask(ctx, root)
+	}()
+	err = table.SetRootTask(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.AddTaskCompletion(ctx, table.TaskCompletion{TaskID: late, CompletedAt: now.AddDate(0, 0, -1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := digest.Generate(ctx, table.DigestDaily, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasReady := false
+	for _, task := range result.Ready {
+		hasReady = hasReady || task.Id == ready
+	}
+	if !hasReady {
+		t.Error("expected the ready task in the digest", result.Ready)
+	}
+	if len(result.Overdue) != 1 || result.Overdue[0].Id != late {
+		t.Error("expected the late task to be overdue", result.Overdue)
+	}
+	if len(result.Completed) != 1 || result.Completed[0].Id != late {
+		t.Error("expected yesterday's completion", result.Completed)
+	}
+
+	markdown, _, err := digest.Render(result, digest.FormatMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(markdown, "- **Ready <Task>** — ship it") || !strings.Contains(markdown, "## Overdue (1)") {
+		t.Error("unexpected markdown digest", markdown)
+	}
+	html, contentType, err := digest.Render(result, digest.FormatHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(contentType, "text/html") || !strings.Contains(html, "Ready &lt;Task&gt;") {
+		t.Error("expected task names to be escaped in the html digest", html)
+	}
+	_, _, err = digest.Render(result, "pdf")
+	if err == nil {
+		t.Error("expected unknown formats to be rejected")
+	}
+}
+
+func TestDigestSubscriptionDue(t *testing.T) {
+	now := time.Date(2026, 10, 21, 10, 30, 0, 0, time.Local) // a Wednesday
+	daily := table.DigestSubscription{Period: table.DigestDaily, Hour: 8}
+	if !daily.LastDue(now).Equal(time.Date(2026, 10, 21, 8, 0, 0, 0, time.Local)) {
+		t.Error("unexpected daily due time", daily.LastDue(now))
+	}
+	weekly := table.DigestSubscription{Period: table.DigestWeekly, Hour: 8, Weekday: int(time.Friday)}
+	if !weekly.LastDue(now).Equal(time.Date(2026, 10, 16, 8, 0, 0, 0, time.Local)) {
+		t.Error("unexpected weekly due time", weekly.LastDue(now))
+	}
+	sent := time.Date(2026, 10, 21, 9, 0, 0, 0, time.Local)
+	daily.LastSentAt = &sent
+	if daily.IsDue(now) {
+		t.Error("the daily digest was already sent today")
+	}
+	if !daily.IsDue(now.AddDate(0, 0, 1)) {
+		t.Error("the daily digest should be due the next day")
+	}
+}
+
+func TestDeliverDigestToUserNotifier(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("Title")
+	}))
+	defer server.Close()
+
+	suffix := time.Now().UnixNano()
+	subscribed := make([]int, 0, 2)
+	for _, name := range []string{"digest-with-%d", "digest-without-%d"} {
+		userID, err := table.CreateUser(ctx, fmt.Sprintf(name, suffix), "digest password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		userCtx := asUser(userID)
+		root := table.AddTask(userCtx, table.Task{Name: "Digest Workspace", Deadline: time.UnixMilli(0), ParentTask: -1})
+		defer func() {
+			_ = table.EliminateTask(ctx, root)
+			_ = table.DeleteDigestSubscription(ctx, userID)
+		}()
+		err = table.SetRootTask(userCtx, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = table.SetDigestSubscription(ctx, userID, table.DigestDaily, 0, 0, digest.FormatText, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		subscribed = append(subscribed, userID)
+	}
+	withNotifier, withoutNotifier := subscribed[0], subscribed[1]
+	err = notify.SetUserNotifier(ctx, withNotifier, notify.NotifierConfig{Type: notify.TypeNtfy, URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := table.GetDigestSubscription(ctx, withoutNotifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delivered, err := digest.Deliver(ctx, time.Now().AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contains := func(ids []int, id int) bool {
+		for _, i := range ids {
+			if i == id {
+				return true
+			}
+		}
+		return false
+	}
+	if !contains(delivered, withNotifier) || contains(delivered, withoutNotifier) {
+		t.Fatal("only the user with a notifier should get the digest, got", delivered)
+	}
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the digest should reach the user's notifier")
+	}
+	subscription, err := table.GetDigestSubscription(ctx, withoutNotifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !subscription.LastSentAt.Equal(*before.LastSentAt) {
+		t.Fatal("the digest of a user without notifier should stay due")
+	}
+}
@@ -0,0 +1,180 @@
+package test
+
+import (
+	"atodo_go/event_bus"
+	"atodo_go/table"
+	"atodo_go/web"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func nextEvent(t *testing.T, events <-chan event_bus.Event, eventType string, id int) {
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Type == eventType && event.EntityID == id {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no %s event for task %d", eventType, id)
+		}
+	}
+}
+
+func TestTaskEvents(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, cancel := event_bus.Subscribe()
+	defer cancel()
+
+	id := table.AddTask(ctx, table.Task{Name: "Evented Task", Deadline: time.Now(), ParentTask: -1})
+	nextEvent(t, events, event_bus.TaskCreated, id)
+
+	err = table.UpdateTaskName(ctx, id, "Renamed Evented Task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, events, event_bus.TaskUpdated, id)
+
+	err = table.CompleteTask(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, events, event_bus.TaskCompleted, id)
+
+	err = table.EliminateTask(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, events, event_bus.TaskEliminated, id)
+}
+
+// openEventStream subscribes to the event stream of the user with the token
+// and returns the events it sends.
+func openEventStream(t *testing.T, server *httptest.Server, token string) <-chan event_bus.Event {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	request, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/events/stream?access_token="+token, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != 200 {
+		t.Fatal("unexpected status", response.StatusCode)
+	}
+	events := make(chan event_bus.Event, 64)
+	ready := make(chan bool)
+	go func() {
+		defer response.Body.Close()
+		scanner := bufio.NewScanner(response.Body)
+		eventType := ""
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				eventType = strings.TrimPrefix(line, "event:")
+			case strings.HasPrefix(line, "data:") && eventType == "ready":
+				close(ready)
+			case strings.HasPrefix(line, "data:"):
+				var event event_bus.Event
+				if json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &event) == nil {
+					events <- event
+				}
+			}
+		}
+	}()
+	select {
+	case <-ready:
+	case <-time.After(time.Second):
+		t.Fatal("the event stream did not open")
+	}
+	return events
+}
+
+// eliminatedBefore returns the tasks whose elimination the stream sends
+// before the creation of the marker task.
+func eliminatedBefore(t *testing.T, events <-chan event_bus.Event, markerID int) map[int]bool {
+	eliminated := make(map[int]bool)
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Type == event_bus.TaskCreated && event.EntityID == markerID {
+				return eliminated
+			}
+			if event.Type == event_bus.TaskEliminated {
+				eliminated[event.EntityID] = true
+			}
+		case <-timeout:
+			t.Fatal("the stream did not send the marker task")
+		}
+	}
+}
+
+func TestEventStreamVisibility(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	suffix := time.Now().UnixNano()
+	users := make(map[string]int)
+	tokens := make(map[string]string)
+	for _, role := range []string{"owner", "viewer", "stranger"} {
+		name := fmt.Sprintf("stream-%s-%d", role, suffix)
+		users[role], err = table.CreateUser(ctx, name, role+" password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[role], _, err = table.Login(ctx, name, role+" password")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ownerCtx := asUser(users["owner"])
+	workspace := table.AddTask(ownerCtx, table.Task{Name: "Streamed Workspace", Deadline: time.Now(), ParentTask: -1})
+	child := table.AddTask(ownerCtx, table.Task{Name: "Streamed Child", Deadline: time.Now(), ParentTask: workspace})
+	err = table.ShareWorkspace(ownerCtx, workspace, users["viewer"], table.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(web.InitWebInterface())
+	// cleanups run last in first, so the streams are closed before the server
+	t.Cleanup(server.Close)
+	viewerEvents := openEventStream(t, server, tokens["viewer"])
+	strangerEvents := openEventStream(t, server, tokens["stranger"])
+
+	err = table.EliminateTask(ownerCtx, workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a task of a workspace without members is seen by everyone
+	marker := table.AddTask(ctx, table.Task{Name: "Stream Marker", Deadline: time.Now(), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, marker)
+	}()
+
+	eliminated := eliminatedBefore(t, viewerEvents, marker)
+	if !eliminated[workspace] || !eliminated[child] {
+		t.Fatal("the viewer should be told about the eliminated tasks", eliminated)
+	}
+	eliminated = eliminatedBefore(t, strangerEvents, marker)
+	if eliminated[workspace] || eliminated[child] {
+		t.Fatal("the stranger should not be told about tasks it could not view", eliminated)
+	}
+}
@@ -0,0 +1,110 @@
+package test
+
+import (
+	"atodo_go/backup"
+	"atodo_go/exporter"
+	"atodo_go/table"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exportArchive(t *testing.T) *backup.Archive {
+	due := time.Date(2030, 1, 2, 9, 30, 0, 0, time.Local).UnixMilli()
+	daily, err := json.Marshal(table.PeriodicT{Intervals: []int{int((24 * time.Hour).Milliseconds())}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &backup.Archive{
+		Version: backup.Version,
+		Root:    1,
+		Tasks: []backup.Task{
+			{ID: 1, ParentTask: -1, Name: "Launch", Status: "Todo"},
+			// IDs in reverse of the relation order
+			{ID: 2, ParentTask: 1, Name: "Ship", Status: "Todo", Deadline: due,
+				AfterEffects: []backup.Typed{{Type: "Periodic", Info: daily}}},
+			{ID: 3, ParentTask: 1, Name: "Build", Status: "Done", Goal: "all of it",
+				Tags: []backup.Tag{{Name: "priority-a"}, {Name: "work"}}},
+			{ID: 4, ParentTask: 3, Name: "Test", Status: "Suspended"},
+		},
+		Relations: []backup.Relation{{ParentTask: 1, Source: 3, Target: 2}},
+	}
+}
+
+func TestExportMarkdown(t *testing.T) {
+	data, err := exporter.Render(exporter.FormatMarkdown, exportArchive(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Launch\n\n" +
+		"- [x] Build **A** #work\n" +
+		"  all of it\n" +
+		"  - [ ] Test _(suspended)_\n" +
+		"- [ ] Ship _(due 2030-01-02 09:30, every 1d)_\n"
+	if data != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, data)
+	}
+}
+
+func TestExportOrg(t *testing.T) {
+	data, err := exporter.Render(exporter.FormatOrg, exportArchive(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#+TITLE: Launch\n" +
+		"* DONE [#A] Build :work:\n" +
+		"  all of it\n" +
+		"** WAITING Test\n" +
+		"* TODO Ship\n" +
+		"  DEADLINE: <2030-01-02 Wed 09:30 +1d>\n"
+	if data != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, data)
+	}
+}
+
+func TestExportTodoTxt(t *testing.T) {
+	data, err := exporter.Render(exporter.FormatTodoTxt, exportArchive(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(data), "\n")
+	want := []string{
+		"x Build @work pri:A",
+		"Test +Build",
+		"Ship due:2030-01-02 rec:1d",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(want, "\n"), data)
+	}
+	_, err = exporter.Render("pdf", exportArchive(t))
+	if err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
+
+func TestExportSubtree(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspace := table.AddTask(ctx, table.Task{Name: "Export Workspace", Deadline: time.UnixMilli(0), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, workspace)
+	}()
+	first := table.AddTask(ctx, table.Task{Name: "First", Deadline: time.UnixMilli(0), ParentTask: workspace})
+	second := table.AddTask(ctx, table.Task{Name: "Second", Deadline: time.UnixMilli(0), Status: table.Done, ParentTask: workspace})
+	err = table.AddRelation(ctx, workspace, second, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, name, err := exporter.Export(ctx, exporter.FormatMarkdown, workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Export Workspace" || data != "# Export Workspace\n\n- [x] Second\n- [ ] First\n" {
+		t.Errorf("unexpected export %q of %q", data, name)
+	}
+}
@@ -0,0 +1,105 @@
+package test
+
+import (
+	"atodo_go/focus"
+	"atodo_go/table"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFocusSessionsPerUser(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := table.AddTask(ctx, table.Task{Name: "Focus Root", Deadline: time.Now(), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, root)
+	}()
+	first := table.AddTask(ctx, table.Task{Name: "First Focus", Deadline: time.Now(), ParentTask: root})
+	second := table.AddTask(ctx, table.Task{Name: "Second Focus", Deadline: time.Now(), ParentTask: root})
+
+	suffix := time.Now().UnixNano()
+	alice, err := table.CreateUser(ctx, fmt.Sprintf("focus-alice-%d", suffix), "alice password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := table.CreateUser(ctx, fmt.Sprintf("focus-bob-%d", suffix), "bob password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceCtx, bobCtx := asUser(alice), asUser(bob)
+
+	err = focus.SetConfig(aliceCtx, focus.Config{
+		FocusLength:      (50 * time.Minute).Milliseconds(),
+		ShortBreakLength: (10 * time.Minute).Milliseconds(),
+		LongBreakLength:  (30 * time.Minute).Milliseconds(),
+		LongBreakEvery:   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := focus.GetConfig(bobCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.FocusLength != (25 * time.Minute).Milliseconds() {
+		t.Fatal("the config of one user should not change another's", config)
+	}
+
+	err = table.SetNowDoingTask(aliceCtx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.SetNowDoingTask(bobCtx, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceSession, err := focus.Start(aliceCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = focus.Stop(aliceCtx)
+	}()
+	bobSession, err := focus.Start(bobCtx)
+	if err != nil {
+		t.Fatal("another user's session should not block a start:", err)
+	}
+	defer func() {
+		_ = focus.Stop(bobCtx)
+	}()
+	if aliceSession.TaskId != first || aliceSession.Length != (50*time.Minute).Milliseconds() {
+		t.Fatal("session should use the user's task and config", aliceSession)
+	}
+	if bobSession.TaskId != second || bobSession.Length != (25*time.Minute).Milliseconds() {
+		t.Fatal("session should use the user's task and config", bobSession)
+	}
+
+	err = focus.Pause(aliceCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := focus.GetState(bobCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state == nil || state.Id != bobSession.Id || state.Status != string(table.FocusRunning) {
+		t.Fatal("pausing should not touch another user's session", state)
+	}
+
+	err = focus.Stop(bobCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err = focus.GetState(aliceCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state == nil || state.Id != aliceSession.Id || state.Status != string(table.FocusPaused) {
+		t.Fatal("stopping should not touch another user's session", state)
+	}
+}
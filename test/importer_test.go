@@ -0,0 +1,225 @@
+package test
+
+import (
+	"atodo_go/backup"
+	"atodo_go/importer"
+	"atodo_go/table"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func archiveTask(t *testing.T, archive *backup.Archive, name string) backup.Task {
+	for _, task := range archive.Tasks {
+		if task.Name == name {
+			return task
+		}
+	}
+	t.Fatalf("task %q not found in %+v", name, archive.Tasks)
+	return backup.Task{}
+}
+
+func tagNames(task backup.Task) map[string]bool {
+	names := make(map[string]bool)
+	for _, tag := range task.Tags {
+		names[tag.Name] = true
+	}
+	return names
+}
+
+func periodicStep(t *testing.T, task backup.Task) int64 {
+	if len(task.AfterEffects) != 1 {
+		t.Fatalf("expected one after-effect on %q, got %v", task.Name, task.AfterEffects)
+	}
+	var info table.PeriodicT
+	err := json.Unmarshal(task.AfterEffects[0].Info, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.DeadlineStep()
+}
+
+func TestImportTodoTxt(t *testing.T) {
+	data := "(A) 2024-01-01 Call mom +Family @phone due:2030-01-02 rec:1w\n" +
+		"x 2024-01-03 2024-01-01 Pay rent +Home t:2030-01-01\n" +
+		"\n" +
+		"Buy milk @store\n" +
+		"Water plants rec:3d\n"
+	archive, warnings, err := importer.Parse(importer.FormatTodoTxt, data, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "Water plants") {
+		t.Errorf("expected a warning about the repeat without due date, got %v", warnings)
+	}
+	if plants := archiveTask(t, archive, "Water plants"); len(plants.AfterEffects) != 0 {
+		t.Errorf("expected the repeat without due date to be skipped, got %v", plants.AfterEffects)
+	}
+	family := archiveTask(t, archive, "Family")
+	call := archiveTask(t, archive, "Call mom")
+	if call.ParentTask != family.ID || family.ParentTask != archive.Root {
+		t.Errorf("expected the project to become the parent task, got %+v", call)
+	}
+	tags := tagNames(call)
+	if !tags["priority-a"] || !tags["phone"] {
+		t.Errorf("unexpected tags %v", call.Tags)
+	}
+	due := time.Date(2030, 1, 2, 0, 0, 0, 0, time.Local)
+	if call.Deadline != due.UnixMilli() {
+		t.Errorf("unexpected deadline %d", call.Deadline)
+	}
+	if periodicStep(t, call) != (7 * 24 * time.Hour).Milliseconds() {
+		t.Errorf("unexpected repeat %v", call.AfterEffects)
+	}
+	rent := archiveTask(t, archive, "Pay rent")
+	if rent.Status != "Done" || rent.AvailableFrom != time.Date(2030, 1, 1, 0, 0, 0, 0, time.Local).UnixMilli() {
+		t.Errorf("unexpected task %+v", rent)
+	}
+	if milk := archiveTask(t, archive, "Buy milk"); milk.ParentTask != archive.Root {
+		t.Errorf("expected a task without project below the root, got %+v", milk)
+	}
+}
+
+func TestImportTaskwarrior(t *testing.T) {
+	data := `[
+{"uuid":"a","description":"Design","status":"pending","project":"Work.Site","priority":"H","tags":["web"],"due":"20300102T093000Z","annotations":[{"description":"use the new colors"}]},
+{"uuid":"b","description":"Build","status":"pending","project":"Work.Site","depends":"a","scheduled":"20300101T000000Z"},
+{"uuid":"c","description":"Report","status":"recurring","recur":"weekly","due":"20300105T000000Z"},
+{"uuid":"d","description":"Report","status":"pending","parent":"c"},
+{"uuid":"e","description":"Old","status":"deleted"},
+{"uuid":"f","description":"Later","status":"waiting","wait":"20300101T000000Z","depends":["a"]}
+]`
+	archive, warnings, err := importer.Parse(importer.FormatTaskwarrior, data, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Tasks) != 7 {
+		t.Errorf("expected the root, two projects and four tasks, got %+v", archive.Tasks)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("expected warnings about the cross-project dependency and the skipped instance, got %v", warnings)
+	}
+	design := archiveTask(t, archive, "Design")
+	build := archiveTask(t, archive, "Build")
+	site := archiveTask(t, archive, "Site")
+	if design.ParentTask != site.ID || archiveTask(t, archive, "Work").ID != site.ParentTask {
+		t.Errorf("expected the project path to become parent tasks")
+	}
+	if design.Note != "use the new colors" || !tagNames(design)["priority-h"] || !tagNames(design)["web"] {
+		t.Errorf("unexpected task %+v", design)
+	}
+	if len(archive.Relations) != 1 || archive.Relations[0].Source != design.ID || archive.Relations[0].Target != build.ID {
+		t.Errorf("expected Build to depend on Design, got %v", archive.Relations)
+	}
+	if build.AvailableFrom != time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli() {
+		t.Errorf("unexpected defer date %d", build.AvailableFrom)
+	}
+	report := archiveTask(t, archive, "Report")
+	if periodicStep(t, report) != (7 * 24 * time.Hour).Milliseconds() {
+		t.Errorf("unexpected repeat %v", report.AfterEffects)
+	}
+	later := archiveTask(t, archive, "Later")
+	if later.Status != "Suspended" || later.Suspension == nil || later.Suspension.Type != "Time" {
+		t.Errorf("expected wait to suspend the task, got %+v", later)
+	}
+}
+
+func TestImportOrg(t *testing.T) {
+	data := `#+TITLE: Plans
+* TODO [#B] Garden :outdoor:
+  DEADLINE: <2030-01-02 Wed 09:30 +1w> SCHEDULED: <2030-01-01 Tue>
+  :PROPERTIES:
+  :ID: 123
+  :END:
+  Water the plants.
+** DONE Buy seeds
+*** Compare prices
+* Books
+`
+	archive, warnings, err := importer.Parse(importer.FormatOrg, data, "Plans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+	root := archiveTask(t, archive, "Plans")
+	garden := archiveTask(t, archive, "Garden")
+	seeds := archiveTask(t, archive, "Buy seeds")
+	prices := archiveTask(t, archive, "Compare prices")
+	books := archiveTask(t, archive, "Books")
+	if garden.ParentTask != root.ID || seeds.ParentTask != garden.ID || prices.ParentTask != seeds.ID || books.ParentTask != root.ID {
+		t.Errorf("unexpected hierarchy %+v", archive.Tasks)
+	}
+	if garden.Note != "Water the plants." || !tagNames(garden)["outdoor"] || !tagNames(garden)["priority-b"] {
+		t.Errorf("unexpected task %+v", garden)
+	}
+	if garden.Deadline != time.Date(2030, 1, 2, 9, 30, 0, 0, time.Local).UnixMilli() ||
+		garden.AvailableFrom != time.Date(2030, 1, 1, 0, 0, 0, 0, time.Local).UnixMilli() {
+		t.Errorf("unexpected dates %+v", garden)
+	}
+	if periodicStep(t, garden) != (7 * 24 * time.Hour).Milliseconds() {
+		t.Errorf("unexpected repeat %v", garden.AfterEffects)
+	}
+	if seeds.Status != "Done" || garden.Status != "Todo" {
+		t.Errorf("unexpected statuses %q and %q", garden.Status, seeds.Status)
+	}
+}
+
+func TestImportExternal(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := table.AddTask(ctx, table.Task{Name: "External Import", Deadline: time.UnixMilli(0), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, target)
+	}()
+	result, err := importer.Import(ctx, importer.FormatOrg, "* TODO First\n* Second\n", "", target, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := table.GetTaskByID(ctx, result.Root)
+	if root.ParentTask != target || root.Name != "Org import" || result.Tasks != 3 {
+		t.Errorf("unexpected import %+v of %+v", result, root)
+	}
+	subTasks, _ := table.GetSubTasksID(ctx, result.Root)
+	if len(subTasks) != 2 {
+		t.Errorf("expected two imported tasks, got %v", subTasks)
+	}
+	_, err = importer.Import(ctx, "csv", "", "", target, true)
+	if err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
+
+func TestImportExternalLayout(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := table.AddTask(ctx, table.Task{Name: "External Layout", Deadline: time.UnixMilli(0), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, target)
+	}()
+	result, err := importer.Import(ctx, importer.FormatTodoTxt, "First\nSecond\nThird\n", "", target, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := table.GetTasksByParentTask(ctx, result.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[[2]int]bool)
+	for _, task := range tasks {
+		position := [2]int{task.PositionX, task.PositionY}
+		if seen[position] {
+			t.Errorf("expected imported tasks not to share positions, got %+v", tasks)
+		}
+		seen[position] = true
+	}
+}
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"atodo_go/table"
+	"atodo_go/webhook"
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestInboundHook(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, err := table.CreateUser(ctx, fmt.Sprintf("ci-%d", time.Now().UnixNano()), "ci password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userCtx := asUser(userID)
+	workspace := table.AddTask(userCtx, table.Task{Name: "Release", Deadline: time.Now(), ParentTask: -1})
+	waiting := table.AddTask(userCtx, table.Task{Name: "Announce release", Deadline: time.Now(), ParentTask: workspace})
+	defer func() {
+		_ = table.EliminateTask(ctx, workspace)
+	}()
+	trigger := table.TaskTrigger{ID: waiting, Type: table.Event}
+	err = trigger.SetEventInfo("deploy", "production deploy finished")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.AddOrUpdateTaskTrigger(ctx, trigger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hookID, token, secret, err := table.CreateInboundHook(ctx, userID, "deploy", map[string]any{"build.status": "success"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = table.DeleteInboundHook(ctx, userID, hookID)
+	}()
+
+	now := time.Now()
+	receive := func(body string, signedAt time.Time, signature string) (webhook.InboundResult, error) {
+		timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+		if signature == "" {
+			signature = webhook.SignInbound(secret, timestamp, []byte(body))
+		}
+		return webhook.Receive(table.WithSource(ctx, "test"), token, timestamp, signature, []byte(body), now)
+	}
+
+	_, err = receive(`{"build":{"status":"success"}}`, now, "sha256=forged")
+	if err != webhook.ErrInvalidSignature {
+		t.Fatal("expected invalid signature, got", err)
+	}
+	_, err = receive(`{"build":{"status":"success"}}`, now.Add(-time.Hour), "")
+	if err != webhook.ErrStaleRequest {
+		t.Fatal("expected stale request, got", err)
+	}
+	result, err := receive(`{"build":{"status":"failure"}}`, now, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Matched || len(result.Fired) != 0 {
+		t.Fatal("failed builds should not fire the trigger")
+	}
+
+	body := `{"build":{"status":"success","id":42}}`
+	result, err = receive(body, now, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Matched || len(result.Fired) != 1 || result.Fired[0] != waiting {
+		t.Fatal("the waiting task should be released", result)
+	}
+	triggers, err := table.GetTaskTriggersByID(ctx, waiting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggers) != 0 {
+		t.Fatal("the event trigger should be removed")
+	}
+	payloads, err := table.GetTaskEventPayloads(ctx, waiting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payloads) != 1 || payloads[0].Payload != body {
+		t.Fatal("the payload should be stored on the task", payloads)
+	}
+
+	_, err = receive(body, now, "")
+	if err != webhook.ErrReplayedRequest {
+		t.Fatal("expected replayed request, got", err)
+	}
+}
@@ -0,0 +1,18 @@
+package test
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain starts every run from a fresh database, so tests that compare
+// against everything stored do not see what earlier runs left behind.
+func TestMain(m *testing.M) {
+	for _, path := range []string{"data.db", "data.db-journal", "data.db-wal", "data.db-shm", "attachments"} {
+		err := os.RemoveAll(path)
+		if err != nil {
+			panic(err)
+		}
+	}
+	os.Exit(m.Run())
+}
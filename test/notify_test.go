@@ -0,0 +1,179 @@
+package test
+
+import (
+	"atodo_go/notify"
+	"atodo_go/table"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotifyRouting(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := notify.GetConfig()
+	defer func() { _ = notify.SetConfig(ctx, previous) }()
+
+	type push struct {
+		title string
+		body  string
+	}
+	received := make(chan push, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- push{title: r.Header.Get("Title"), body: string(body)}
+	}))
+	defer server.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	err = notify.SetConfig(ctx, notify.Config{
+		Notifiers: []notify.NotifierConfig{{Name: "push", Type: notify.TypeNtfy, URL: server.URL}},
+		Routes:    map[string][]string{notify.EventTaskResumed: {"missing"}},
+	})
+	if err == nil {
+		t.Fatal("expected a route to an unknown notifier to be rejected")
+	}
+	err = notify.SetConfig(ctx, notify.Config{
+		Notifiers: []notify.NotifierConfig{
+			{Name: "push", Type: notify.TypeNtfy, URL: server.URL},
+			{Name: "broken", Type: notify.TypeWebhook, URL: broken.URL},
+			{Name: "log", Type: notify.TypeLog},
+		},
+		Routes: map[string][]string{
+			notify.EventTaskResumed: {"broken", "push"},
+			notify.DefaultRoute:     {"log"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = notify.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notify.Send(notify.Notification{Event: notify.EventFocusFinished, Title: "focus", Message: "default route"})
+	notify.Send(notify.Notification{Event: notify.EventTaskResumed, Title: "resumed", Message: "goal"})
+	select {
+	case got := <-received:
+		if got.title != "resumed" || got.body != "goal" {
+			t.Errorf("unexpected push %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("push notifier was not called")
+	}
+	select {
+	case got := <-received:
+		t.Errorf("unexpected second push %+v", got)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if notify.Test("broken") == nil {
+		t.Error("expected the broken notifier to fail its test")
+	}
+	if notify.Test("push") != nil {
+		t.Error("expected the push notifier to pass its test")
+	}
+}
+
+func TestNotifySecrets(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := notify.GetConfig()
+	defer func() { _ = notify.SetConfig(ctx, previous) }()
+
+	err = notify.SetConfig(ctx, notify.Config{
+		Notifiers: []notify.NotifierConfig{
+			{Name: "push", Type: notify.TypeNtfy, URL: "http://localhost/push", Token: "ntfy token"},
+			{Name: "hook", Type: notify.TypeWebhook, URL: "http://localhost/hook"},
+		},
+		Routes: map[string][]string{notify.DefaultRoute: {"push"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	redacted := notify.GetConfig().Redacted()
+	if redacted.Notifiers[0].Token != notify.RedactedSecret || redacted.Notifiers[1].Secret != "" {
+		t.Fatal("only set secrets should be redacted", redacted.Notifiers)
+	}
+	if notify.GetConfig().Notifiers[0].Token != "ntfy token" {
+		t.Fatal("redacting should not change the stored config")
+	}
+
+	redacted.Notifiers[1].Secret = "new secret"
+	err = notify.SetConfig(ctx, redacted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := notify.GetConfig()
+	if stored.Notifiers[0].Token != "ntfy token" || stored.Notifiers[1].Secret != "new secret" {
+		t.Fatal("redacted secrets should be kept and new ones stored", stored.Notifiers)
+	}
+}
+
+func TestUserNotifier(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, err := table.CreateUser(ctx, fmt.Sprintf("notified-%d", time.Now().UnixNano()), "notified password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent, err := notify.SendToUser(ctx, userID, notify.Notification{Event: notify.EventDigest, Title: "digest"})
+	if err != nil || sent {
+		t.Fatal("a user without notifier should get nothing", err)
+	}
+	err = notify.SetUserNotifier(ctx, userID, notify.NotifierConfig{Type: notify.TypeDesktop})
+	if err == nil {
+		t.Fatal("desktop notifiers should not be accepted for a user")
+	}
+
+	err = notify.SetUserNotifier(ctx, userID, notify.NotifierConfig{Type: notify.TypeNtfy, URL: "http://localhost/push", Token: "user token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := notify.GetUserNotifier(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redacted := stored.Redacted()
+	if redacted.Token != notify.RedactedSecret {
+		t.Fatal("the token should be redacted", redacted)
+	}
+	redacted.URL = "http://localhost/other"
+	err = notify.SetUserNotifier(ctx, userID, redacted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err = notify.GetUserNotifier(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Token != "user token" || stored.URL != "http://localhost/other" {
+		t.Fatal("the redacted token should be kept", stored)
+	}
+
+	err = notify.SetUserNotifier(ctx, userID, notify.NotifierConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err = notify.GetUserNotifier(ctx, userID)
+	if err != nil || stored != nil {
+		t.Fatal("an empty type should remove the notifier", stored, err)
+	}
+}
@@ -0,0 +1,121 @@
+package test
+
+import (
+	"atodo_go/query"
+	"atodo_go/table"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestQueryParse(t *testing.T) {
+	valid := []string{
+		"status:todo",
+		"status:Done or overdue",
+		"(tag:@office or tag:@home) and not tag:waiting-on-review",
+		"due:..7d trigger:none available",
+		"due:-2d..1w",
+		"due:2024-06-01..2024-07-01 subtree:3",
+		"due:today text:report",
+	}
+	for _, q := range valid {
+		_, err := query.Parse(q)
+		if err != nil {
+			t.Fatal(q, err)
+		}
+	}
+	invalid := []string{"", "status:blocked", "subtree:abc", "due:5x", "trigger:email", "(overdue", "and overdue", "color:red"}
+	for _, q := range invalid {
+		_, err := query.Parse(q)
+		if err == nil {
+			t.Fatal("expected parse error for", q)
+		}
+	}
+}
+
+func TestQueryEvaluate(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	project := table.AddTask(ctx, table.Task{Name: "Query Project", Deadline: now.Add(48 * time.Hour), ParentTask: -1})
+	overdue := table.AddTask(ctx, table.Task{Name: "Query Overdue", Deadline: now.Add(-time.Hour), ParentTask: project})
+	later := table.AddTask(ctx, table.Task{Name: "Query Later", Deadline: now.Add(30 * 24 * time.Hour), ParentTask: project})
+	done := table.AddTask(ctx, table.Task{Name: "Query Done", Deadline: now.Add(-time.Hour), Status: table.Done, ParentTask: project})
+	table.AddTask(ctx, table.Task{Name: "Query Undated", Deadline: time.UnixMilli(0), ParentTask: project})
+	defer func() {
+		_ = table.EliminateTask(ctx, project)
+	}()
+
+	tasks, err := query.Evaluate(ctx, fmt.Sprintf("subtree:%d and overdue", project))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Id != overdue {
+		t.Fatal("expected only the overdue task", tasks)
+	}
+
+	tasks, err = query.Evaluate(ctx, fmt.Sprintf("subtree:%d and due:..7d and not status:done", project))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Id != overdue {
+		t.Fatal("expected only the overdue task", tasks)
+	}
+
+	tasks, err = query.Evaluate(ctx, fmt.Sprintf("subtree:%d and (status:done or due:7d..)", project))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 || tasks[0].Id != done || tasks[1].Id != later {
+		t.Fatal("expected the done and later tasks ordered by deadline", tasks)
+	}
+}
+
+func TestSavedQueriesPerUser(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	suffix := time.Now().UnixNano()
+	first, err := table.CreateUser(ctx, fmt.Sprintf("lister-%d", suffix), "lister password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := table.CreateUser(ctx, fmt.Sprintf("other-lister-%d", suffix), "lister password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = query.SaveList(asUser(first), "mine", "overdue", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = query.SaveList(asUser(second), "mine", "available", "")
+	if err != nil {
+		t.Fatal("names should only be unique per user:", err)
+	}
+	saved, err := table.GetSavedQueryByName(asUser(first), "mine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Query != "overdue" {
+		t.Fatal("each user should get their own list, got", saved.Query)
+	}
+	_, err = query.EvaluateList(asUser(first), "missing")
+	if !errors.Is(err, table.ErrSavedQueryNotFound) {
+		t.Fatal("expected an unknown list to be not found, got", err)
+	}
+	err = table.DeleteSavedQuery(asUser(first), "mine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.DeleteSavedQuery(asUser(second), "mine")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
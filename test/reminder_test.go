@@ -0,0 +1,98 @@
+package test
+
+import (
+	"atodo_go/table"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCollectDueReminders(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	hour := time.Hour.Milliseconds()
+	workspace := table.AddTask(ctx, table.Task{Name: "Reminder Workspace", Deadline: time.UnixMilli(0), ParentTask: -1})
+	soon := table.AddTask(ctx, table.Task{Name: "Due Soon", Deadline: now.Add(30 * time.Minute), ParentTask: workspace})
+	late := table.AddTask(ctx, table.Task{Name: "Due Earlier", Deadline: now.Add(-2 * time.Hour), ParentTask: workspace})
+	defer func() {
+		_ = table.EliminateTask(ctx, workspace)
+	}()
+	find := func(due []table.DueReminder, id int) *table.DueReminder {
+		for _, reminder := range due {
+			if reminder.TaskID == id {
+				return &reminder
+			}
+		}
+		return nil
+	}
+
+	err = table.SetWorkspaceReminders(ctx, workspace, []int64{24 * hour}, []int64{0, hour, 3 * hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.SetTaskReminders(ctx, soon, []int64{hour, 24 * hour, 10 * 60 * 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	due, err := table.CollectDueReminders(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reminder := find(due, soon)
+	if reminder == nil || reminder.Kind != table.ReminderBefore || reminder.Offset != hour {
+		t.Fatalf("expected the one hour reminder of the soon task, got %+v", reminder)
+	}
+	reminder = find(due, late)
+	if reminder == nil || reminder.Kind != table.ReminderOverdue || reminder.Offset != hour {
+		t.Fatalf("expected the one hour escalation of the late task, got %+v", reminder)
+	}
+
+	due, err = table.CollectDueReminders(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if find(due, soon) != nil || find(due, late) != nil {
+		t.Fatal("reminders should only fire once")
+	}
+
+	err = table.SnoozeTaskReminders(ctx, soon, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	due, err = table.CollectDueReminders(ctx, now.Add(25*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if find(due, soon) != nil {
+		t.Fatal("snoozed tasks should not be reminded")
+	}
+	err = table.SnoozeTaskReminders(ctx, soon, now.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	due, err = table.CollectDueReminders(ctx, now.Add(25*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reminder = find(due, soon)
+	if reminder == nil || reminder.Offset != 10*60*1000 {
+		t.Fatalf("expected the ten minute reminder after the snooze, got %+v", reminder)
+	}
+
+	err = table.UpdateTaskDeadline(ctx, late, now.Add(-4*time.Hour).UnixMilli())
+	if err != nil {
+		t.Fatal(err)
+	}
+	due, err = table.CollectDueReminders(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reminder = find(due, late)
+	if reminder == nil || reminder.Offset != 3*hour {
+		t.Fatalf("moving the deadline should arm the escalation again, got %+v", reminder)
+	}
+}
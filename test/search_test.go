@@ -0,0 +1,53 @@
+package test
+
+import (
+	"atodo_go/search"
+	"atodo_go/table"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspace := table.AddTask(ctx, table.Task{Name: "Search Workspace", Deadline: time.Now(), ParentTask: -1})
+	child := table.AddTask(ctx, table.Task{Name: "Write quarterly report", Goal: "numbers for finance", Deadline: time.Now(), ParentTask: workspace})
+	defer func() {
+		_ = table.EliminateTask(ctx, workspace)
+	}()
+
+	results, err := search.Search(ctx, search.SearchRequest{Query: "quarter financ", Workspace: workspace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Id != child {
+		t.Fatal("expected to find the child task", results)
+	}
+	if len(results[0].PathIds) != 2 || results[0].PathIds[1] != workspace {
+		t.Fatal("path should lead back to the workspace", results[0].PathIds)
+	}
+
+	err = table.UpdateTaskName(ctx, child, "Write yearly report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err = search.Search(ctx, search.SearchRequest{Query: "quarterly", Workspace: workspace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Fatal("renamed task should no longer match", results)
+	}
+
+	results, err = search.Search(ctx, search.SearchRequest{Query: "yearly", Workspace: workspace, Statuses: []string{"Done"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Fatal("status filter should exclude todo tasks", results)
+	}
+}
@@ -2,10 +2,12 @@ package test
 
 import (
 	"atodo_go/table"
+	"context"
 	"testing"
 )
 
 func TestAddSuspendedTask(t *testing.T) {
+	ctx := context.Background()
 	err := table.InitDB()
 	if err != nil {
 		return
@@ -18,11 +20,11 @@ func TestAddSuspendedTask(t *testing.T) {
 		return
 	}
 
-	if table.AddSuspendedTask(task) != 1 {
+	if table.AddSuspendedTask(ctx, task) != 1 {
 		t.Error("Failed to add suspended task")
 	}
 
-	suspendedTask, err := table.GetSuspendedTask(1)
+	suspendedTask, err := table.GetSuspendedTask(ctx, 1)
 	if err != nil {
 		return
 	}
@@ -38,11 +40,11 @@ func TestAddSuspendedTask(t *testing.T) {
 		return
 	}
 
-	if table.AddSuspendedTask(task) != 2 {
+	if table.AddSuspendedTask(ctx, task) != 2 {
 		t.Error("Failed to add suspended task")
 	}
 
-	suspendedTask, err = table.GetSuspendedTask(2)
+	suspendedTask, err = table.GetSuspendedTask(ctx, 2)
 	if err != nil {
 		return
 	}
@@ -51,12 +53,12 @@ func TestAddSuspendedTask(t *testing.T) {
 		t.Error("Failed to get suspended task")
 	}
 
-	err = table.DeleteSuspendedTasks(1)
+	err = table.DeleteSuspendedTasks(ctx, 1)
 	if err != nil {
 		return
 	}
 
-	err = table.DeleteSuspendedTasks(2)
+	err = table.DeleteSuspendedTasks(ctx, 2)
 	if err != nil {
 		return
 	}
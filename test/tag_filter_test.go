@@ -0,0 +1,100 @@
+package test
+
+import (
+	"atodo_go/table"
+	"atodo_go/tag_filter"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTagFilter(t *testing.T) {
+	cases := []struct {
+		filter tag_filter.TagFilter
+		tags   []string
+		match  bool
+	}{
+		{tag_filter.TagFilter{}, nil, true},
+		{tag_filter.TagFilter{Include: []string{"@office"}}, []string{"@office", "urgent"}, true},
+		{tag_filter.TagFilter{Include: []string{"@office", "urgent"}}, []string{"@office"}, false},
+		{tag_filter.TagFilter{Exclude: []string{"waiting-on-review"}}, []string{"waiting-on-review"}, false},
+		{tag_filter.TagFilter{Expression: "(@office or @home) and not waiting-on-review"}, []string{"@home"}, true},
+		{tag_filter.TagFilter{Expression: "(@office or @home) and not waiting-on-review"}, []string{"@home", "waiting-on-review"}, false},
+		{tag_filter.TagFilter{Expression: "@office urgent"}, []string{"@office"}, false},
+		{tag_filter.TagFilter{Expression: "!@office | urgent"}, []string{"@office", "urgent"}, true},
+		{tag_filter.TagFilter{Expression: "urgent! !@office"}, []string{"urgent!"}, true},
+		{tag_filter.TagFilter{Expression: "urgent! !@office"}, []string{"urgent"}, false},
+		{tag_filter.TagFilter{Expression: "!!wait!"}, []string{"wait!"}, true},
+	}
+	for i, c := range cases {
+		match, err := c.filter.Compile()
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if match(c.tags) != c.match {
+			t.Fatal("case", i, "expected", c.match)
+		}
+	}
+
+	for _, expression := range []string{"(@office", "and @office", "@office or", "()"} {
+		_, err := tag_filter.Parse(expression)
+		if err == nil {
+			t.Fatal("expected parse error for", expression)
+		}
+	}
+
+	for _, name := range []string{"!urgent", "two words", "(paren"} {
+		if table.ValidateTagName(name) == nil {
+			t.Fatal("expected tag name to be rejected:", name)
+		}
+	}
+	err := table.ValidateTagName("urgent!")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteTagAuditsTaskLinks(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := table.AddTask(ctx, table.Task{Name: "Tagged Root", Deadline: time.Now(), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, root)
+	}()
+	child := table.AddTask(ctx, table.Task{Name: "Tagged Child", Deadline: time.Now(), ParentTask: root})
+	tag, err := table.CreateTag(ctx, fmt.Sprintf("doomed-%d", time.Now().UnixNano()), "red")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, taskID := range []int{root, child} {
+		err = table.AddTagToTask(ctx, taskID, tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	taskIDs, err := table.GetTaskIDsByTagID(ctx, tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(taskIDs) != 2 {
+		t.Fatal("both tasks should carry the tag", taskIDs)
+	}
+
+	err = table.DeleteTag(ctx, tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, taskID := range []int{root, child} {
+		page, err := table.QueryAuditLog(ctx, table.AuditFilter{Entity: table.EntityTaskTag, EntityID: &taskID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Entries) != 2 || page.Entries[0].OldValue == "" || page.Entries[0].NewValue != "" {
+			t.Fatal("removing the tag from the task should be audited", page.Entries)
+		}
+	}
+}
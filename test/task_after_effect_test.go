@@ -2,10 +2,13 @@ package test
 
 import (
 	"atodo_go/table"
+	"context"
 	"testing"
+	"time"
 )
 
 func TestAddOrUpdateTaskAfterEffect(t *testing.T) {
+	ctx := context.Background()
 	taskAfterEffect := table.TaskAfterEffect{
 		ID:   1,
 		Type: table.Periodic,
@@ -20,17 +23,54 @@ func TestAddOrUpdateTaskAfterEffect(t *testing.T) {
 		return
 	}
 
-	err = table.AddOrUpdateTaskAfterEffect(taskAfterEffect)
+	err = table.AddOrUpdateTaskAfterEffect(ctx, taskAfterEffect)
 	if err != nil {
 		return
 	}
 
-	tae, err := table.GetTaskAfterEffect(taskAfterEffect.ID, taskAfterEffect.Type)
+	tae, err := table.GetTaskAfterEffect(ctx, taskAfterEffect.ID, taskAfterEffect.Type)
 	if err != nil {
 		t.Error(err)
 	}
 
-	if !tae.Equal(taskAfterEffect) {
+	if !tae.Equal(ctx, taskAfterEffect) {
 		t.Error("TaskAfterEffect not equal")
 	}
 }
+
+func TestPeriodicStep(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Date(2030, 1, 2, 9, 30, 0, 0, time.Local)
+	id := table.AddTask(ctx, table.Task{Name: "Weekly Review", Deadline: deadline, ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, id)
+	}()
+	week := (7 * 24 * time.Hour).Milliseconds()
+	afterEffect := table.TaskAfterEffect{ID: id, Type: table.Periodic}
+	err = afterEffect.SetPeriodicInfo(table.PeriodicT{Intervals: []int{int(week)}, Step: week})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.AddOrUpdateTaskAfterEffect(ctx, afterEffect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		err = table.CompleteTask(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		task, err := table.GetTaskByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !task.Deadline.Equal(deadline.Add(time.Duration(i) * 7 * 24 * time.Hour)) {
+			t.Fatal("completing should move the deadline by the step, got", task.Deadline)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package test
+
+import (
+	"atodo_go/table"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseMentions(t *testing.T) {
+	mentions := table.ParseMentions("@alice please check with @bob. mail carol@example.com (@alice)")
+	if !reflect.DeepEqual(mentions, []string{"alice", "bob"}) {
+		t.Fatal("unexpected mentions", mentions)
+	}
+}
+
+func TestTaskComments(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	suffix := time.Now().UnixNano()
+	alice, err := table.CreateUser(ctx, fmt.Sprintf("alice-%d", suffix), "alice password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := table.CreateUser(ctx, fmt.Sprintf("bob-%d", suffix), "bob password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := table.AddTask(ctx, table.Task{Name: "Discussed Task", Deadline: time.Now(), ParentTask: -1})
+
+	commentID, err := table.AddTaskComment(asUser(alice), id, "first draft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = table.AddTaskComment(asUser(bob), id, "looks good @alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.UpdateTaskComment(asUser(bob), commentID, "rewritten by bob")
+	if !errors.Is(err, table.ErrForbidden) {
+		t.Fatal("only the author should edit a comment, got", err)
+	}
+	err = table.DeleteTaskComment(asUser(bob), commentID)
+	if !errors.Is(err, table.ErrForbidden) {
+		t.Fatal("only the author should delete a comment, got", err)
+	}
+	err = table.UpdateTaskComment(asUser(alice), commentID, "second draft for @bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comments, err := table.GetTaskComments(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 2 || comments[0].Content != "second draft for @bob" || comments[0].Mentions[0] != "bob" {
+		t.Fatal("unexpected comments", comments)
+	}
+	if comments[0].UserID != alice || comments[0].Author != fmt.Sprintf("alice-%d", suffix) || comments[1].UserID != bob {
+		t.Fatal("comments should be written by the signed in users", comments)
+	}
+	counts, err := table.GetTaskCommentCounts(ctx, []int{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[id] != 2 {
+		t.Fatal("expected two comments, got", counts[id])
+	}
+
+	err = table.EliminateTask(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comments, err = table.GetTaskComments(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 0 {
+		t.Fatal("comments should be removed with the task")
+	}
+}
@@ -0,0 +1,113 @@
+package test
+
+import (
+	"atodo_go/table"
+	"context"
+	"testing"
+	"time"
+)
+
+func completionsAt(times ...time.Time) []table.TaskCompletion {
+	completions := make([]table.TaskCompletion, 0, len(times))
+	for _, t := range times {
+		completions = append(completions, table.TaskCompletion{CompletedAt: t})
+	}
+	return completions
+}
+
+func TestComputeStreak(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.Local)
+	day := func(offset int) time.Time {
+		return now.AddDate(0, 0, offset)
+	}
+
+	current, longest := table.ComputeStreak(nil, nil, now)
+	if current != 0 || longest != 0 {
+		t.Fatal("empty history should have no streak")
+	}
+
+	current, longest = table.ComputeStreak(completionsAt(day(-9), day(-8), day(-7), day(-7), day(-2), day(-1), day(0)), nil, now)
+	if current != 3 {
+		t.Fatal("current streak should be 3, got", current)
+	}
+	if longest != 3 {
+		t.Fatal("longest streak should be 3, got", longest)
+	}
+
+	current, longest = table.ComputeStreak(completionsAt(day(-6), day(-5), day(-4), day(-3), day(-1)), nil, now)
+	if current != 1 || longest != 4 {
+		t.Fatal("unexpected streak", current, longest)
+	}
+
+	current, _ = table.ComputeStreak(completionsAt(day(-3), day(-2)), nil, now)
+	if current != 0 {
+		t.Fatal("streak ending before yesterday should be broken")
+	}
+
+	weekly := &table.PeriodicT{Intervals: []int{int((7 * 24 * time.Hour).Milliseconds()), 0}}
+	current, longest = table.ComputeStreak(completionsAt(day(-20), day(-13), day(-7)), weekly, now)
+	if current != 3 || longest != 3 {
+		t.Fatal("weekly completions should keep a weekly streak, got", current, longest)
+	}
+
+	hourly := &table.PeriodicT{Intervals: []int{int(time.Hour.Milliseconds()), 0}}
+	current, longest = table.ComputeStreak(completionsAt(now.Add(-3*time.Hour), now.Add(-2*time.Hour), now.Add(-time.Hour)), hourly, now)
+	if current != 3 || longest != 3 {
+		t.Fatal("hourly completions should keep an hourly streak, got", current, longest)
+	}
+	current, _ = table.ComputeStreak(completionsAt(now.Add(-3*time.Hour)), hourly, now)
+	if current != 0 {
+		t.Fatal("hourly streak should break after two hours")
+	}
+
+	// completions read back from the database are in UTC; days are counted
+	// where now is
+	zone := time.FixedZone("UTC+10", 10*60*60)
+	zoned := time.Date(2024, 6, 10, 21, 0, 0, 0, zone)
+	current, _ = table.ComputeStreak(completionsAt(
+		time.Date(2024, 6, 9, 5, 0, 0, 0, zone).UTC(),
+		time.Date(2024, 6, 10, 20, 0, 0, 0, zone).UTC(),
+	), nil, zoned)
+	if current != 2 {
+		t.Fatal("consecutive local days should make a streak, got", current)
+	}
+}
+
+func TestTaskHistoryStreak(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := table.AddTask(ctx, table.Task{Name: "Weekly Task", Deadline: time.Now(), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, id)
+	}()
+	afterEffect := table.TaskAfterEffect{ID: id, Type: table.Periodic}
+	err = afterEffect.SetPeriodicInfo(table.PeriodicT{Intervals: []int{int((7 * 24 * time.Hour).Milliseconds()), 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.AddOrUpdateTaskAfterEffect(ctx, afterEffect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, offset := range []int{-15, -8, -1} {
+		err = table.AddTaskCompletion(ctx, table.TaskCompletion{TaskID: id, CompletedAt: now.AddDate(0, 0, offset)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := table.GetTaskHistory(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Completions) != 3 {
+		t.Fatal("expected 3 completions, got", len(history.Completions))
+	}
+	if history.CurrentStreak != 3 || history.LongestStreak != 3 {
+		t.Fatal("weekly completions should make a streak of 3, got", history.CurrentStreak, history.LongestStreak)
+	}
+}
@@ -0,0 +1,109 @@
+package test
+
+import (
+	"atodo_go/table"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestTaskNotesAndAttachments(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := table.AddTask(ctx, table.Task{Name: "Noted Task", Deadline: time.Now(), ParentTask: -1})
+
+	for _, content := range []string{"# Plan", "# Plan", "# Plan\n- step"} {
+		err = table.SetTaskNote(ctx, id, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	revisions, err := table.GetTaskNoteRevisions(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || revisions[1].Revision != 2 {
+		t.Fatal("unchanged content should not create a revision", revisions)
+	}
+
+	_, err = table.AddChecklistItem(ctx, id, "buy milk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachmentID, err := table.AddAttachment(ctx, id, "notes.txt", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachment, err := table.GetAttachment(ctx, attachmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attachment.Size != 5 {
+		t.Fatal("unexpected attachment size", attachment.Size)
+	}
+
+	stored, err := os.ReadDir(table.AttachmentDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = table.AddAttachment(ctx, id, "broken.txt", "text/plain", io.MultiReader(strings.NewReader("partial"), failingReader{}))
+	if err == nil {
+		t.Fatal("a failed upload should not be attached")
+	}
+	_, err = table.AddAttachment(ctx, -2, "orphan.txt", "text/plain", strings.NewReader("orphan"))
+	if err == nil {
+		t.Fatal("attachments should need an existing task")
+	}
+	files, err := os.ReadDir(table.AttachmentDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(stored) {
+		t.Fatal("failed attachments should leave no files behind")
+	}
+
+	err = table.SetChecklist(ctx, id, []table.ChecklistItemShow{{Text: "buy eggs"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checklist, err := table.GetChecklist(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := table.QueryAuditLog(ctx, table.AuditFilter{Entity: table.EntityChecklistItem, EntityID: &checklist[0].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Entries) != 1 || page.Entries[0].Field != table.WholeRecord {
+		t.Fatal("a set checklist item should be audited by its ID", page.Entries)
+	}
+
+	err = table.EliminateTask(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checklist, err = table.GetChecklist(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checklist) != 0 {
+		t.Fatal("checklist should be removed with the task")
+	}
+	_, err = os.Stat(attachment.Path())
+	if !os.IsNotExist(err) {
+		t.Fatal("attachment file should be removed with the task")
+	}
+}
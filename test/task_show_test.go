@@ -1,13 +1,20 @@
 package test
 
 import (
+	"atodo_go/table"
 	"atodo_go/task_show"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetShowData(t *testing.T) {
+	ctx := context.Background()
 	// test
-	data, err := task_show.GetShowData()
+	data, err := task_show.GetShowData(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -18,3 +25,173 @@ func TestGetShowData(t *testing.T) {
 		t.Fatal("len(data.Edges) != 0")
 	}
 }
+
+func TestExportGraph(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspace := table.AddTask(ctx, table.Task{Name: "Graph Workspace", Deadline: time.UnixMilli(0), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, workspace)
+	}()
+	due := time.Date(2030, 1, 10, 12, 0, 0, 0, time.Local)
+	design := table.AddTask(ctx, table.Task{Name: `Design "v2"`, Deadline: time.UnixMilli(0), Status: table.Done, ParentTask: workspace})
+	build := table.AddTask(ctx, table.Task{Name: "Build: all", Deadline: due, ParentTask: workspace})
+	test := table.AddTask(ctx, table.Task{Name: "Test", Deadline: time.UnixMilli(0), ParentTask: build})
+	err = table.AddRelation(ctx, workspace, design, build)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.Local)
+	ids := map[string]string{
+		"design": "t" + strconv.Itoa(design),
+		"build":  "t" + strconv.Itoa(build),
+		"test":   "t" + strconv.Itoa(test),
+	}
+
+	dot, err := task_show.ExportGraph(ctx, task_show.GraphFormatDot, workspace, true, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`digraph "Graph Workspace" {`,
+		`"` + ids["design"] + `" [label="Design \"v2\"", fillcolor="#e8f5e9", color="#2e7d32", penwidth=2];`,
+		`subgraph "cluster_` + strconv.Itoa(build) + `" {`,
+		`"` + ids["test"] + `" [label="Test", fillcolor="#e3f2fd", color="#c62828", penwidth=2];`,
+		`"` + ids["design"] + `" -> "` + ids["test"] + `" [lhead="cluster_` + strconv.Itoa(build) + `"];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("expected %q in\n%s", want, dot)
+		}
+	}
+
+	mermaid, err := task_show.ExportGraph(ctx, task_show.GraphFormatMermaid, workspace, false, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"flowchart LR\n",
+		ids["design"] + `["Design #quot;v2#quot;"]`,
+		ids["build"] + `["Build: all"]`,
+		ids["design"] + " --> " + ids["build"],
+		"class " + ids["design"] + " startNode",
+		"class " + ids["build"] + " endNode",
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("expected %q in\n%s", want, mermaid)
+		}
+	}
+	if strings.Contains(mermaid, "subgraph") {
+		t.Errorf("expected no subgraphs without recursion in\n%s", mermaid)
+	}
+
+	gantt, err := task_show.ExportGraph(ctx, task_show.GraphFormatGantt, workspace, true, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"  Design \"v2\" :done, " + ids["design"] + ", 2030-01-01, 1d\n",
+		"  Build all :crit, " + ids["build"] + ", after " + ids["design"] + ", 2030-01-10\n",
+		"  section Build all\n",
+		"  Test :crit, active, " + ids["test"] + ", 2030-01-01, 1d\n",
+	} {
+		if !strings.Contains(gantt, want) {
+			t.Errorf("expected %q in\n%s", want, gantt)
+		}
+	}
+
+	_, err = task_show.ExportGraph(ctx, "svg", workspace, false, now)
+	if !errors.Is(err, task_show.ErrUnknownGraphFormat) {
+		t.Errorf("expected an unknown format to be rejected, got %v", err)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	// 1 -> 2 -> 4, 1 -> 3 -> 4, 1 -> 4, 5 -> 6 -> 5 and 7 alone
+	relations := []table.TaskRelation{
+		{Source: 1, Target: 2}, {Source: 1, Target: 3}, {Source: 2, Target: 4},
+		{Source: 3, Target: 4}, {Source: 1, Target: 4}, {Source: 5, Target: 6},
+		{Source: 6, Target: 5},
+	}
+	layout := task_show.Layout([]int{1, 2, 3, 4, 5, 6, 7}, relations, map[int]task_show.Position{})
+	layer := func(id int) int {
+		return layout[id].X / task_show.LayerSpacing
+	}
+	for id, want := range map[int]int{1: 0, 2: 1, 3: 1, 4: 2, 5: 0, 6: 1, 7: 0} {
+		if layer(id) != want {
+			t.Errorf("expected task %d in layer %d, got %v", id, want, layout[id])
+		}
+	}
+	for a, pa := range layout {
+		for b, pb := range layout {
+			if a < b && pa.X == pb.X && pa.Y-pb.Y < task_show.NodeSpacing && pb.Y-pa.Y < task_show.NodeSpacing {
+				t.Errorf("tasks %d and %d overlap at %v and %v", a, b, pa, pb)
+			}
+		}
+	}
+	if layout[2].Y != layout[1].Y {
+		t.Errorf("expected task 2 level with task 1, got %v", layout)
+	}
+
+	again := task_show.Layout([]int{1, 2, 3, 4, 5, 6, 7}, relations, layout)
+	for id, position := range layout {
+		if again[id] != position {
+			t.Errorf("expected a repeated layout to keep task %d at %v, got %v", id, position, again[id])
+		}
+	}
+}
+
+func TestAutoLayoutAndPlaceTask(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspace := table.AddTask(ctx, table.Task{Name: "Layout Workspace", Deadline: time.UnixMilli(0), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, workspace)
+	}()
+	first := table.AddTask(ctx, table.Task{Name: "First", Deadline: time.UnixMilli(0), ParentTask: workspace})
+	second := table.AddTask(ctx, table.Task{Name: "Second", Deadline: time.UnixMilli(0), ParentTask: workspace})
+	err = table.AddRelation(ctx, workspace, first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	positions, err := task_show.AutoLayout(ctx, workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, _ := table.GetTaskByID(ctx, second)
+	if positions[second] != (task_show.Position{X: task_show.LayerSpacing, Y: 0}) ||
+		saved.PositionX != task_show.LayerSpacing || saved.PositionY != 0 {
+		t.Errorf("expected the second task a layer right of the first, got %v and %+v", positions, saved)
+	}
+
+	third, err := table.CreateTaskUnder(ctx, workspace, "Third", "", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = task_show.PlaceTask(ctx, third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	placed, _ := table.GetTaskByID(ctx, third)
+	if placed.PositionX != 0 || placed.PositionY != task_show.NodeSpacing {
+		t.Errorf("expected the new task below the first, got %d,%d", placed.PositionX, placed.PositionY)
+	}
+
+	err = table.AddRelation(ctx, workspace, second, third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = task_show.PlaceTask(ctx, third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	placed, _ = table.GetTaskByID(ctx, third)
+	if placed.PositionX != 2*task_show.LayerSpacing || placed.PositionY != 0 {
+		t.Errorf("expected the task a layer right of its predecessor, got %d,%d", placed.PositionX, placed.PositionY)
+	}
+}
@@ -2,11 +2,13 @@ package test
 
 import (
 	"atodo_go/table"
+	"context"
 	"testing"
 	"time"
 )
 
 func TestAddTask(t *testing.T) {
+	ctx := context.Background()
 	err := table.InitDB()
 	if err != nil {
 		t.Fatal(err)
@@ -21,13 +23,13 @@ func TestAddTask(t *testing.T) {
 		Status:     table.Todo,
 		ParentTask: 0,
 	}
-	id := table.AddTask(task)
+	id := table.AddTask(ctx, task)
 	if id == -1 {
 		t.Fatal("Failed to add task")
 	}
 
 	task.ID = id
-	task2, err := table.GetTaskByID(id)
+	task2, err := table.GetTaskByID(ctx, id)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -36,8 +38,82 @@ func TestAddTask(t *testing.T) {
 		t.Fatal("Failed to add task")
 	}
 
-	err = table.DeleteTask(id)
+	err = table.DeleteTask(ctx, id)
 	if err != nil {
 		t.Fatal(err)
 	}
 }
+
+func TestTaskAvailableFrom(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	id := table.AddTask(ctx, table.Task{
+		Name:          "Deferred Task",
+		Deadline:      now,
+		Status:        table.Todo,
+		ParentTask:    -1,
+		AvailableFrom: now.Add(time.Hour),
+	})
+	defer func() {
+		_ = table.DeleteTask(ctx, id)
+	}()
+
+	task, err := table.GetTaskByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.IsAvailable(now) {
+		t.Fatal("task deferred by an hour should not be available yet")
+	}
+	if task.Status != table.Todo {
+		t.Fatal("deferring a task must not change its status")
+	}
+
+	err = table.UpdateTaskAvailableFrom(ctx, id, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err = table.GetTaskByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !task.IsAvailable(now) {
+		t.Fatal("clearing the defer date should make the task available")
+	}
+}
+
+func TestUpdateTaskDeadline(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := table.AddTask(ctx, table.Task{Name: "Rescheduled Task", Deadline: time.Now(), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, id)
+	}()
+	deadline := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
+	err = table.UpdateTaskDeadline(ctx, id, deadline.UnixMilli())
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := table.GetTaskByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !task.Deadline.Equal(deadline) {
+		t.Fatal("deadline should round trip, got", task.Deadline)
+	}
+	var storage string
+	err = table.DB.Raw("SELECT typeof(deadline) FROM "+task.TableName()+" WHERE id = ?", id).Scan(&storage).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storage != "text" {
+		t.Fatal("deadline should be stored as a time like the other columns, got", storage)
+	}
+}
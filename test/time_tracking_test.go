@@ -0,0 +1,100 @@
+package test
+
+import (
+	"atodo_go/table"
+	"atodo_go/time_tracking"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTimeTrackingReport(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := table.AddTask(ctx, table.Task{Name: "Tracked Parent", Deadline: time.Now(), ParentTask: -1})
+	child := table.AddTask(ctx, table.Task{Name: "Tracked Child", Deadline: time.Now(), ParentTask: parent})
+	defer func() {
+		_ = table.EliminateTask(ctx, parent)
+	}()
+
+	start := time.Date(2024, 6, 10, 23, 0, 0, 0, time.Local)
+	_, err = table.AddTimeEntry(ctx, child, start.UnixMilli(), start.Add(2*time.Hour).UnixMilli())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentEntry, err := table.AddTimeEntry(ctx, parent, start.Add(3*time.Hour).UnixMilli(), start.Add(4*time.Hour).UnixMilli())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total, err := time_tracking.GetTotal(ctx, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != (3 * time.Hour).Milliseconds() {
+		t.Fatal("subtree total should include the child, got", total)
+	}
+
+	report, err := time_tracking.GetReport(ctx, time_tracking.GroupByDay, parent, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Rows) != 2 || report.Rows[0].Key != "2024-06-10" || report.Rows[0].Duration != time.Hour.Milliseconds() {
+		t.Fatal("entry crossing midnight should be split by day", report.Rows)
+	}
+
+	report, err = time_tracking.GetReport(ctx, time_tracking.GroupByTask, parent, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range report.Rows {
+		if row.TaskId == parent && row.Total != total {
+			t.Fatal("parent row should roll up child time", row)
+		}
+	}
+
+	other, err := table.CreateUser(ctx, fmt.Sprintf("tracker-%d", time.Now().UnixNano()), "tracker password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.SetNowDoingTask(asUser(other), child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	open, err := table.GetOpenTimeEntry(asUser(other))
+	if err != nil || open == nil || open.TaskID != child {
+		t.Fatal("the other user should be tracking the child", open, err)
+	}
+	err = table.SetNowDoingTask(ctx, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	open, err = table.GetOpenTimeEntry(asUser(other))
+	if err != nil || open == nil {
+		t.Fatal("switching tasks should not close the other user's entry", err)
+	}
+	err = table.SetNowDoingTask(ctx, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.SetNowDoingTask(asUser(other), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.DeleteTimeEntry(asUser(other), parentEntry)
+	if !errors.Is(err, table.ErrForbidden) {
+		t.Fatal("entries of other users should not be deletable, got", err)
+	}
+	otherTotal, err := time_tracking.GetTotal(asUser(other), parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherTotal >= total {
+		t.Fatal("totals should only count the user's own entries, got", otherTotal)
+	}
+}
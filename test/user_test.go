@@ -0,0 +1,132 @@
+package test
+
+import (
+	"atodo_go/table"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestUserAuthentication(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := fmt.Sprintf("alice-%d", time.Now().UnixNano())
+	id, err := table.CreateUser(ctx, name, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = table.CreateUser(ctx, name, "another password")
+	if err == nil {
+		t.Fatal("duplicate user names should be rejected")
+	}
+
+	_, _, err = table.Login(ctx, name, "wrong password")
+	if err != table.ErrInvalidCredentials {
+		t.Fatal("expected invalid credentials, got", err)
+	}
+	token, _, err := table.Login(ctx, name, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := table.Authenticate(ctx, token)
+	if err != nil || user.ID != id {
+		t.Fatal("session token should authenticate the user", err)
+	}
+
+	keyID, key, err := table.CreateAPIKey(ctx, id, "script")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err = table.Authenticate(ctx, key)
+	if err != nil || user.ID != id {
+		t.Fatal("api key should authenticate the user", err)
+	}
+	err = table.DeleteAPIKey(ctx, id, keyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = table.Authenticate(ctx, key)
+	if err != table.ErrInvalidToken {
+		t.Fatal("deleted api key should be rejected, got", err)
+	}
+
+	err = table.ChangePassword(ctx, id, "correct horse", "battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = table.Authenticate(ctx, token)
+	if err != table.ErrInvalidToken {
+		t.Fatal("sessions should end when the password changes, got", err)
+	}
+}
+
+func TestPerUserAppState(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := table.CreateUser(ctx, fmt.Sprintf("bob-%d", time.Now().UnixNano()), "password one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := table.CreateUser(ctx, fmt.Sprintf("carol-%d", time.Now().UnixNano()), "password two")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setViewing := func(userID int, task int) {
+		err := table.SetNowViewingTask(asUser(userID), task)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	getViewing := func(userID int) int {
+		task, err := table.GetNowViewingTask(asUser(userID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return task
+	}
+
+	setViewing(first, 11)
+	setViewing(second, 22)
+	if getViewing(first) != 11 || getViewing(second) != 22 {
+		t.Fatal("each user should keep their own app state")
+	}
+}
+
+func TestRegisterUser(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	suffix := time.Now().UnixNano()
+	_, err = table.CreateUser(ctx, fmt.Sprintf("first-%d", suffix), "first password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, err := table.CreateUser(ctx, fmt.Sprintf("member-%d", suffix), "member password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = table.RegisterUser(ctx, -1, fmt.Sprintf("anonymous-%d", suffix), "anonymous password")
+	if !errors.Is(err, table.ErrForbidden) {
+		t.Fatal("anonymous callers should not register once a user exists, got", err)
+	}
+	_, err = table.RegisterUser(ctx, member, fmt.Sprintf("invited-%d", suffix), "invited password")
+	if !errors.Is(err, table.ErrForbidden) {
+		t.Fatal("users who are not admins should not register others, got", err)
+	}
+	_, err = table.RegisterUser(ctx, 0, fmt.Sprintf("registered-%d", suffix), "registered password")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,181 @@
+package test
+
+import (
+	"atodo_go/event_bus"
+	"atodo_go/table"
+	"atodo_go/webhook"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookDelivery(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, err := table.CreateUser(ctx, fmt.Sprintf("hook-%d", time.Now().UnixNano()), "hook password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failing := true
+	received := make(chan webhook.Payload, 10)
+	var secret string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(webhook.SignatureHeader) != webhook.Sign(secret, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload webhook.Payload
+		_ = json.Unmarshal(body, &payload)
+		received <- payload
+	}))
+	defer server.Close()
+
+	webhookID, secret, err := table.CreateWebhook(ctx, userID, server.URL, "", []string{event_bus.TaskCompleted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = table.DeleteWebhook(ctx, userID, webhookID)
+	}()
+	taskID := table.AddTask(ctx, table.Task{Name: "Hooked Task", Deadline: time.Now(), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, taskID)
+	}()
+
+	// deliveries are queued with the change, without a subscriber on the bus
+	err = table.UpdateTaskName(ctx, taskID, "Renamed Hooked Task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.UpdateTaskStatus(ctx, taskID, table.Done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	_, err = webhook.ProcessDue(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := table.GetWebhookDeliveries(ctx, webhookID, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatal("only subscribed events should be queued, got", len(deliveries))
+	}
+	if deliveries[0].Status != table.DeliveryPending || deliveries[0].Attempts != 1 || deliveries[0].LastStatusCode != 503 {
+		t.Fatal("failed delivery should stay queued", deliveries[0])
+	}
+	if deliveries[0].NextAttemptAt != now.Add(webhook.Backoff(1)).UnixMilli() {
+		t.Fatal("failed delivery should back off")
+	}
+
+	failing = false
+	attempted, err := webhook.ProcessDue(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempted != 0 {
+		t.Fatal("delivery should wait for its backoff")
+	}
+	err = table.UpdateWebhook(ctx, userID, webhookID, server.URL, []string{event_bus.TaskCompleted}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempted, err = webhook.ProcessDue(ctx, now.Add(webhook.Backoff(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempted != 0 {
+		t.Fatal("deliveries of an inactive webhook should wait")
+	}
+	err = table.UpdateWebhook(ctx, userID, webhookID, server.URL, []string{event_bus.TaskCompleted}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = webhook.ProcessDue(ctx, now.Add(webhook.Backoff(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := <-received
+	if payload.Event != event_bus.TaskCompleted || payload.Task == nil || payload.Task.ID != taskID {
+		t.Fatal("unexpected payload", payload)
+	}
+	deliveries, err = table.GetWebhookDeliveries(ctx, webhookID, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deliveries[0].Status != table.DeliverySucceeded || deliveries[0].Attempts != 2 {
+		t.Fatal("delivery should have succeeded on the second attempt", deliveries[0])
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	if webhook.Backoff(1) != 30*time.Second || webhook.Backoff(3) != 2*time.Minute || webhook.Backoff(20) != time.Hour {
+		t.Fatal("unexpected backoff")
+	}
+}
+
+func TestMarkOverdueTasks(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	overdue := table.AddTask(ctx, table.Task{Name: "Late Task", Deadline: now.Add(-time.Hour), ParentTask: -1})
+	onTime := table.AddTask(ctx, table.Task{Name: "Future Task", Deadline: now.Add(time.Hour), ParentTask: -1})
+	defer func() {
+		_ = table.EliminateTask(ctx, overdue)
+		_ = table.EliminateTask(ctx, onTime)
+	}()
+	contains := func(ids []int, id int) bool {
+		for _, i := range ids {
+			if i == id {
+				return true
+			}
+		}
+		return false
+	}
+
+	ids, err := table.MarkOverdueTasks(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(ids, overdue) || contains(ids, onTime) {
+		t.Fatal("unexpected overdue tasks", ids)
+	}
+	ids, err = table.MarkOverdueTasks(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contains(ids, overdue) {
+		t.Fatal("overdue tasks should only be reported once")
+	}
+	err = table.UpdateTaskDeadline(ctx, overdue, now.Add(-time.Minute).UnixMilli())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err = table.MarkOverdueTasks(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(ids, overdue) {
+		t.Fatal("moving the deadline should report the task again")
+	}
+}
@@ -0,0 +1,160 @@
+package test
+
+import (
+	"atodo_go/search"
+	"atodo_go/table"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func asUser(userID int) context.Context {
+	return table.WithActor(context.Background(), table.Actor{Source: "test", UserID: userID})
+}
+
+func TestWorkspacePermissions(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	suffix := time.Now().UnixNano()
+	owner, err := table.CreateUser(ctx, fmt.Sprintf("owner-%d", suffix), "owner password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	viewer, err := table.CreateUser(ctx, fmt.Sprintf("viewer-%d", suffix), "viewer password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, err := table.CreateUser(ctx, fmt.Sprintf("stranger-%d", suffix), "stranger password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ownerCtx := asUser(owner)
+	workspace := table.AddTask(ownerCtx, table.Task{Name: "Shared Workspace", Deadline: time.Now(), ParentTask: -1})
+	child := table.AddTask(ownerCtx, table.Task{Name: "Shared Child", Deadline: time.Now(), ParentTask: workspace})
+	err = table.ShareWorkspace(ownerCtx, workspace, viewer, table.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = table.EliminateTask(ctx, workspace)
+	}()
+
+	check := func(userID int, role string, expected bool) {
+		ok, err := table.HasWorkspaceRole(ctx, userID, child, role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != expected {
+			t.Fatalf("user %d with role %s: expected %v", userID, role, expected)
+		}
+	}
+	check(owner, table.RoleOwner, true)
+	check(viewer, table.RoleViewer, true)
+	check(viewer, table.RoleEditor, false)
+	check(stranger, table.RoleViewer, false)
+
+	err = table.UnshareWorkspace(ctx, workspace, owner)
+	if err == nil {
+		t.Fatal("the last owner should not be removable")
+	}
+
+	err = table.AssignTask(ctx, child, stranger)
+	if err == nil {
+		t.Fatal("tasks should only be assigned to users who can view them")
+	}
+	err = table.AssignTask(ctx, child, viewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assigned, err := table.GetTasksAssignedTo(ctx, viewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assigned[child] {
+		t.Fatal("task should be assigned to the viewer")
+	}
+
+	workspaces, err := table.GetAccessibleWorkspaces(ctx, stranger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, accessible := range workspaces {
+		if accessible.ID == workspace {
+			t.Fatal("stranger should not see the shared workspace")
+		}
+	}
+
+	for _, userID := range []int{viewer, stranger} {
+		results, err := search.Search(asUser(userID), search.SearchRequest{Query: "Shared Child"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := len(results) != 0 && results[0].Id == child
+		if found != (userID == viewer) {
+			t.Fatalf("user %d: search found the shared task: %v", userID, found)
+		}
+		page, err := table.QueryAuditLog(asUser(userID), table.AuditFilter{Entity: table.EntityTask, EntityID: &child})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (page.Total != 0) != (userID == viewer) {
+			t.Fatalf("user %d: audit log shows %d changes of the shared task", userID, page.Total)
+		}
+	}
+}
+
+func TestMoveToTopLevelClaimsWorkspace(t *testing.T) {
+	ctx := context.Background()
+	err := table.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	suffix := time.Now().UnixNano()
+	owner, err := table.CreateUser(ctx, fmt.Sprintf("mover-%d", suffix), "mover password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, err := table.CreateUser(ctx, fmt.Sprintf("bystander-%d", suffix), "bystander password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerCtx := asUser(owner)
+	workspace := table.AddTask(ownerCtx, table.Task{Name: "Moving Workspace", Deadline: time.Now(), ParentTask: -1})
+	child := table.AddTask(ownerCtx, table.Task{Name: "Moving Child", Deadline: time.Now(), ParentTask: workspace})
+	defer func() {
+		_ = table.EliminateTask(ctx, workspace)
+		_ = table.EliminateTask(ctx, child)
+	}()
+
+	err = table.SetNowViewingTask(ownerCtx, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	detail, err := table.GetDetailedTask(ownerCtx, child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.SetDetailedTask(ownerCtx, detail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	role, err := table.GetWorkspaceRole(ctx, owner, child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role != table.RoleOwner {
+		t.Fatal("the mover should own the new workspace, got", role)
+	}
+	ok, err := table.HasWorkspaceRole(ctx, stranger, child, table.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("a task moved to the top level should not be open to everyone")
+	}
+}
@@ -0,0 +1,253 @@
+package time_tracking
+
+import (
+	"atodo_go/table"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	GroupByDay  = "day"
+	GroupByWeek = "week"
+	GroupByTask = "task"
+)
+
+type TimeEntryShow struct {
+	Id        int   `json:"id"`
+	TaskId    int   `json:"task_id"`
+	StartTime int64 `json:"start_time"`
+	EndTime   int64 `json:"end_time"`
+	Duration  int64 `json:"duration"`
+	Manual    bool  `json:"manual"`
+}
+
+type ReportRow struct {
+	Key      string `json:"key"`
+	TaskId   int    `json:"task_id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Duration int64  `json:"duration"`
+	// Total includes the time of every task below this one.
+	Total int64 `json:"total,omitempty"`
+}
+
+type Report struct {
+	GroupBy string      `json:"group_by"`
+	Rows    []ReportRow `json:"rows"`
+	Total   int64       `json:"total"`
+}
+
+func toShow(entry table.TimeEntry, now time.Time) TimeEntryShow {
+	show := TimeEntryShow{
+		Id:        entry.ID,
+		TaskId:    entry.TaskID,
+		StartTime: entry.StartTime.UnixMilli(),
+		Duration:  entry.Duration(now).Milliseconds(),
+		Manual:    entry.Manual,
+	}
+	if entry.EndTime != nil {
+		show.EndTime = entry.EndTime.UnixMilli()
+	}
+	return show
+}
+
+func millisToTime(millis int64) time.Time {
+	if millis == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(millis)
+}
+
+// GetSubtreeIDs returns id followed by every task below it.
+func GetSubtreeIDs(ctx context.Context, id int) ([]int, error) {
+	ids := []int{id}
+	for i := 0; i < len(ids); i++ {
+		subTasks, err := table.GetSubTasksID(ctx, ids[i])
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, subTasks...)
+	}
+	return ids, nil
+}
+
+func GetEntries(ctx context.Context, taskId int, from, to int64) ([]TimeEntryShow, error) {
+	var taskIds []int
+	if taskId > 0 {
+		taskIds = []int{taskId}
+	}
+	entries, err := table.GetTimeEntries(ctx, taskIds, millisToTime(from), millisToTime(to))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	shows := make([]TimeEntryShow, 0, len(entries))
+	for _, entry := range entries {
+		shows = append(shows, toShow(entry, now))
+	}
+	return shows, nil
+}
+
+// clip returns the part of the entry that lies inside [from, to).
+func clip(entry table.TimeEntry, from, to, now time.Time) (time.Time, time.Time) {
+	start := entry.StartTime
+	end := now
+	if entry.EndTime != nil {
+		end = *entry.EndTime
+	}
+	if !from.IsZero() && start.Before(from) {
+		start = from
+	}
+	if !to.IsZero() && end.After(to) {
+		end = to
+	}
+	return start, end
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func startOfWeek(t time.Time) time.Time {
+	day := startOfDay(t)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// splitByPeriod spreads [start, end) over the periods it touches.
+func splitByPeriod(start, end time.Time, groupBy string, durations map[string]int64) {
+	for start.Before(end) {
+		var periodStart, periodEnd time.Time
+		var key string
+		if groupBy == GroupByWeek {
+			periodStart = startOfWeek(start)
+			periodEnd = periodStart.AddDate(0, 0, 7)
+			year, week := periodStart.ISOWeek()
+			key = fmt.Sprintf("%d-W%02d", year, week)
+		} else {
+			periodStart = startOfDay(start)
+			periodEnd = periodStart.AddDate(0, 0, 1)
+			key = periodStart.Format("2006-01-02")
+		}
+		stop := end
+		if periodEnd.Before(stop) {
+			stop = periodEnd
+		}
+		durations[key] += stop.Sub(start).Milliseconds()
+		start = stop
+	}
+}
+
+// GetReport sums the time the context's user tracked in [from, to) per day,
+// week or task. If taskId is set, only that task and its subtasks are counted.
+func GetReport(ctx context.Context, groupBy string, taskId int, from, to int64) (*Report, error) {
+	if groupBy != GroupByDay && groupBy != GroupByWeek && groupBy != GroupByTask {
+		return nil, errors.New("unknown report grouping: " + groupBy)
+	}
+	var taskIds []int
+	if taskId > 0 {
+		ids, err := GetSubtreeIDs(ctx, taskId)
+		if err != nil {
+			return nil, err
+		}
+		taskIds = ids
+	}
+	fromTime := millisToTime(from)
+	toTime := millisToTime(to)
+	entries, err := table.GetTimeEntries(ctx, taskIds, fromTime, toTime)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := Report{GroupBy: groupBy, Rows: []ReportRow{}}
+	durations := make(map[string]int64)
+	taskDurations := make(map[int]int64)
+	for _, entry := range entries {
+		start, end := clip(entry, fromTime, toTime, now)
+		if !start.Before(end) {
+			continue
+		}
+		report.Total += end.Sub(start).Milliseconds()
+		if groupBy == GroupByTask {
+			taskDurations[entry.TaskID] += end.Sub(start).Milliseconds()
+		} else {
+			splitByPeriod(start, end, groupBy, durations)
+		}
+	}
+
+	if groupBy != GroupByTask {
+		for key, duration := range durations {
+			report.Rows = append(report.Rows, ReportRow{Key: key, Duration: duration})
+		}
+		sort.Slice(report.Rows, func(i, j int) bool { return report.Rows[i].Key < report.Rows[j].Key })
+		return &report, nil
+	}
+
+	totals, err := rollUp(ctx, taskDurations)
+	if err != nil {
+		return nil, err
+	}
+	for id, total := range totals {
+		task, err := table.GetTaskByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		report.Rows = append(report.Rows, ReportRow{
+			Key:      fmt.Sprintf("%d", id),
+			TaskId:   id,
+			Name:     task.Name,
+			Duration: taskDurations[id],
+			Total:    total,
+		})
+	}
+	sort.Slice(report.Rows, func(i, j int) bool { return report.Rows[i].Total > report.Rows[j].Total })
+	return &report, nil
+}
+
+// rollUp adds every task's own time to all of its ancestors.
+func rollUp(ctx context.Context, durations map[int]int64) (map[int]int64, error) {
+	totals := make(map[int]int64)
+	parents := make(map[int]int)
+	for id, duration := range durations {
+		current := id
+		visited := make(map[int]bool)
+		for current > 0 && !visited[current] {
+			visited[current] = true
+			totals[current] += duration
+			parent, ok := parents[current]
+			if !ok {
+				task, err := table.GetTaskByID(ctx, current)
+				if err != nil {
+					return nil, err
+				}
+				parent = task.ParentTask
+				parents[current] = parent
+			}
+			current = parent
+		}
+	}
+	return totals, nil
+}
+
+// GetTotal returns the time the context's user spent on a task including all
+// of its subtasks.
+func GetTotal(ctx context.Context, taskId int) (int64, error) {
+	ids, err := GetSubtreeIDs(ctx, taskId)
+	if err != nil {
+		return 0, err
+	}
+	entries, err := table.GetTimeEntries(ctx, ids, time.Time{}, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	var total int64
+	for _, entry := range entries {
+		total += entry.Duration(now).Milliseconds()
+	}
+	return total, nil
+}
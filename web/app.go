@@ -9,6 +9,9 @@ import (
 
 func InitAppWebInterface(router *gin.Engine) {
 	router.POST("/close", func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
 		// set a setTimeOut callback, and close this app in 3s
 		time.AfterFunc(3*time.Second, func() {
 			os.Exit(0)
@@ -15,7 +15,7 @@ type NowIsWorkTimeRequest struct {
 
 func InitAppStateWebInterface(engine *gin.Engine) {
 	engine.POST("/app_state/get_now_viewing_task", func(c *gin.Context) {
-		task, err := table.GetNowViewingTask()
+		task, err := table.GetNowViewingTask(c)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -30,7 +30,10 @@ func InitAppStateWebInterface(engine *gin.Engine) {
 			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
 			return
 		}
-		err = table.SetNowViewingTask(request.ID)
+		if request.ID > 0 && !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		err = table.SetNowViewingTask(c, request.ID)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -39,7 +42,7 @@ func InitAppStateWebInterface(engine *gin.Engine) {
 	})
 
 	engine.POST("/app_state/back_to_parent_task", func(c *gin.Context) {
-		err := table.BackToParentTask()
+		err := table.BackToParentTask(c)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -54,7 +57,7 @@ func InitAppStateWebInterface(engine *gin.Engine) {
 			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
 			return
 		}
-		err = table.SetWorkTime(request.WorkTime)
+		err = table.SetWorkTime(c, request.WorkTime)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -63,7 +66,7 @@ func InitAppStateWebInterface(engine *gin.Engine) {
 	})
 
 	engine.POST("/app_state/get_work_time", func(c *gin.Context) {
-		workTime, err := table.GetWorkTime()
+		workTime, err := table.GetWorkTime(c)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -78,7 +81,10 @@ func InitAppStateWebInterface(engine *gin.Engine) {
 			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
 			return
 		}
-		err = table.SetNowDoingTask(request.ID)
+		if request.ID > 0 && !requireRole(c, table.RoleEditor, request.ID) {
+			return
+		}
+		err = table.SetNowDoingTask(c, request.ID)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -87,7 +93,7 @@ func InitAppStateWebInterface(engine *gin.Engine) {
 	})
 
 	engine.POST("/app_state/get_now_doing_task", func(c *gin.Context) {
-		task, err := table.GetNowDoingTask()
+		task, err := table.GetNowDoingTask(c)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -102,7 +108,7 @@ func InitAppStateWebInterface(engine *gin.Engine) {
 			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
 			return
 		}
-		err = table.SetNowIsWorkTime(request.NowIsWorkTime)
+		err = table.SetNowIsWorkTime(c, request.NowIsWorkTime)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -111,7 +117,7 @@ func InitAppStateWebInterface(engine *gin.Engine) {
 	})
 
 	engine.POST("/app_state/get_now_is_work_time", func(c *gin.Context) {
-		nowIsWorkTime, err := table.GetNowIsWorkTime()
+		nowIsWorkTime, err := table.GetNowIsWorkTime(c)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
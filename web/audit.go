@@ -0,0 +1,23 @@
+package web
+
+import (
+	"atodo_go/table"
+	"github.com/gin-gonic/gin"
+)
+
+func InitAuditWebInterface(engine *gin.Engine) {
+	engine.POST("/audit/query", func(c *gin.Context) {
+		var filter table.AuditFilter
+		err := c.BindJSON(&filter)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		page, err := table.QueryAuditLog(c, filter)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, page)
+	})
+}
@@ -0,0 +1,238 @@
+package web
+
+import (
+	"atodo_go/table"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"strings"
+)
+
+const userIDKey = "user_id"
+
+// publicRoutes can be reached without a token. Registering requires an admin
+// once the first user exists, and inbound hooks check their own secrets.
+var publicRoutes = map[string]bool{
+	"/auth/login":    true,
+	"/auth/register": true,
+	inboundHookPath:  true,
+	wellKnownCaldav:  true,
+}
+
+// queryTokenRoutes also accept the token as ?access_token=, for clients such
+// as EventSource, calendar apps and download links that cannot send headers.
+var queryTokenRoutes = map[string]bool{
+	eventStreamPath: true,
+	calendarPath:    true,
+	exportPath:      true,
+}
+
+// basicAuthRoutes also accept HTTP basic auth with the account password or
+// an API key, for CalDAV clients.
+var basicAuthRoutes = map[string]bool{
+	caldavPath: true,
+}
+
+type RegisterRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+type APIKeyRequest struct {
+	Name string `json:"name"`
+}
+
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+	if queryTokenRoutes[c.FullPath()] {
+		return c.Query("access_token")
+	}
+	return ""
+}
+
+// authMiddleware resolves the session token or API key sent as a bearer
+// token, or basic auth where allowed, and rejects the request if it is
+// missing or invalid.
+func authMiddleware(c *gin.Context) {
+	token := bearerToken(c)
+	if token != "" {
+		user, err := table.Authenticate(c, token)
+		if err == nil {
+			c.Set(userIDKey, user.ID)
+			c.Next()
+			return
+		}
+		if !errors.Is(err, table.ErrInvalidToken) {
+			c.AbortWithStatusJSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+	}
+	if basicAuthRoutes[c.FullPath()] {
+		name, password, ok := c.Request.BasicAuth()
+		if ok {
+			user, err := table.AuthenticatePassword(c, name, password)
+			if err == nil {
+				c.Set(userIDKey, user.ID)
+				c.Next()
+				return
+			}
+		}
+		c.Header("WWW-Authenticate", `Basic realm="atodo"`)
+	}
+	if publicRoutes[c.FullPath()] {
+		c.Next()
+		return
+	}
+	c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized: " + table.ErrInvalidToken.Error()})
+}
+
+// requireAdmin responds with 403 and returns false unless the signed in user
+// administers the server.
+func requireAdmin(c *gin.Context) bool {
+	ok, err := table.IsAdmin(c, c.GetInt(userIDKey))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+		return false
+	}
+	if !ok {
+		c.JSON(403, gin.H{"error": "Forbidden: " + table.ErrForbidden.Error()})
+		return false
+	}
+	return true
+}
+
+func InitAuthWebInterface(engine *gin.Engine) {
+	engine.POST("/auth/register", func(c *gin.Context) {
+		var request RegisterRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		registrarID := -1
+		if _, ok := c.Get(userIDKey); ok {
+			registrarID = c.GetInt(userIDKey)
+		}
+		id, err := table.RegisterUser(c, registrarID, request.Name, request.Password)
+		if errors.Is(err, table.ErrForbidden) && registrarID == -1 {
+			c.JSON(401, gin.H{"error": "Unauthorized: " + table.ErrInvalidToken.Error()})
+			return
+		}
+		if errors.Is(err, table.ErrForbidden) {
+			c.JSON(403, gin.H{"error": "Forbidden: " + err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"id": id})
+	})
+
+	engine.POST("/auth/login", func(c *gin.Context) {
+		var request RegisterRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		token, expiresAt, err := table.Login(c, request.Name, request.Password)
+		if errors.Is(err, table.ErrInvalidCredentials) {
+			c.JSON(401, gin.H{"error": "Unauthorized: " + err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"token": token, "expires_at": expiresAt.UnixMilli()})
+	})
+
+	engine.POST("/auth/logout", func(c *gin.Context) {
+		err := table.Logout(c, bearerToken(c))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/auth/me", func(c *gin.Context) {
+		user, err := table.GetUserByID(c, c.GetInt(userIDKey))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"user": user.Show()})
+	})
+
+	engine.POST("/auth/get_users", func(c *gin.Context) {
+		users, err := table.GetAllUsers(c)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"users": users})
+	})
+
+	engine.POST("/auth/change_password", func(c *gin.Context) {
+		var request ChangePasswordRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		err = table.ChangePassword(c, c.GetInt(userIDKey), request.OldPassword, request.NewPassword)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/auth/create_api_key", func(c *gin.Context) {
+		var request APIKeyRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		id, key, err := table.CreateAPIKey(c, c.GetInt(userIDKey), request.Name)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"id": id, "key": key})
+	})
+
+	engine.POST("/auth/get_api_keys", func(c *gin.Context) {
+		keys, err := table.GetAPIKeys(c, c.GetInt(userIDKey))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"api_keys": keys})
+	})
+
+	engine.POST("/auth/delete_api_key", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		err = table.DeleteAPIKey(c, c.GetInt(userIDKey), request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+}
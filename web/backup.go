@@ -0,0 +1,62 @@
+package web
+
+import (
+	"atodo_go/backup"
+	"atodo_go/table"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+type BackupImportRequest struct {
+	Parent  int             `json:"parent"`
+	DryRun  bool            `json:"dry_run"`
+	Archive *backup.Archive `json:"archive"`
+}
+
+func InitBackupWebInterface(engine *gin.Engine) {
+	engine.POST("/backup/export", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		archive, err := backup.Export(c, request.ID, time.Now())
+		if errors.Is(err, backup.ErrTaskNotFound) {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"archive": archive})
+	})
+
+	// parent -1 imports the archive as a new workspace
+	engine.POST("/backup/import", func(c *gin.Context) {
+		var request BackupImportRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if request.Archive == nil {
+			c.JSON(400, gin.H{"error": "Invalid request: archive is missing"})
+			return
+		}
+		if request.Parent != -1 && !requireRole(c, table.RoleEditor, request.Parent) {
+			return
+		}
+		result, err := backup.Import(c, request.Archive, request.Parent, request.DryRun)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"result": result})
+	})
+}
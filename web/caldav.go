@@ -0,0 +1,151 @@
+package web
+
+import (
+	"atodo_go/caldav"
+	"atodo_go/table"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"strings"
+)
+
+const (
+	caldavPath      = "/caldav/*path"
+	wellKnownCaldav = "/.well-known/caldav"
+)
+
+const multistatusType = "application/xml; charset=utf-8"
+
+// caldavTarget returns the requested path, with the trailing slash of the
+// collections added if a client left it out.
+func caldavTarget(c *gin.Context) string {
+	target := strings.TrimSuffix(caldav.Prefix, "/") + c.Param("path")
+	switch target + "/" {
+	case caldav.Prefix, caldav.PrincipalPath, caldav.CollectionPath:
+		return target + "/"
+	}
+	return target
+}
+
+// caldavResourceName returns the resource name of a path inside the task
+// collection, or "" for any other path.
+func caldavResourceName(c *gin.Context) string {
+	target := caldavTarget(c)
+	if !strings.HasPrefix(target, caldav.CollectionPath) {
+		return ""
+	}
+	name := strings.TrimPrefix(target, caldav.CollectionPath)
+	if strings.Contains(name, "/") {
+		return ""
+	}
+	return name
+}
+
+func caldavError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, caldav.ErrNotFound):
+		c.String(404, err.Error())
+	case errors.Is(err, caldav.ErrPreconditionFailed):
+		c.String(412, err.Error())
+	case errors.Is(err, caldav.ErrUIDConflict):
+		c.String(409, err.Error())
+	case errors.Is(err, caldav.ErrUnsupported):
+		c.String(415, err.Error())
+	case errors.Is(err, caldav.ErrInvalidSyncToken):
+		c.Data(403, multistatusType, []byte(`<?xml version="1.0" encoding="utf-8"?>`+"\n"+
+			`<D:error xmlns:D="DAV:"><D:valid-sync-token/></D:error>`+"\n"))
+	case errors.Is(err, caldav.ErrUnsupportedReport), errors.Is(err, table.ErrForbidden):
+		c.String(403, err.Error())
+	default:
+		c.String(400, "Invalid request: "+err.Error())
+	}
+}
+
+func InitCaldavWebInterface(engine *gin.Engine) {
+	engine.GET(wellKnownCaldav, func(c *gin.Context) {
+		c.Redirect(301, caldav.Prefix)
+	})
+
+	engine.Handle("PROPFIND", wellKnownCaldav, func(c *gin.Context) {
+		c.Redirect(301, caldav.Prefix)
+	})
+
+	engine.OPTIONS(caldavPath, func(c *gin.Context) {
+		c.Header("DAV", "1, 3, calendar-access")
+		c.Header("Allow", "OPTIONS, PROPFIND, REPORT, GET, HEAD, PUT, DELETE")
+		c.Status(200)
+	})
+
+	engine.Handle("PROPFIND", caldavPath, func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.String(400, "Invalid request: "+err.Error())
+			return
+		}
+		data, err := caldav.Propfind(c, caldavTarget(c), c.GetHeader("Depth"), body)
+		if err != nil {
+			caldavError(c, err)
+			return
+		}
+		c.Data(207, multistatusType, data)
+	})
+
+	engine.Handle("REPORT", caldavPath, func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.String(400, "Invalid request: "+err.Error())
+			return
+		}
+		data, err := caldav.Report(c, caldavTarget(c), body)
+		if err != nil {
+			caldavError(c, err)
+			return
+		}
+		c.Data(207, multistatusType, data)
+	})
+
+	get := func(c *gin.Context) {
+		resource, err := caldav.Lookup(c, caldavResourceName(c))
+		if err != nil {
+			caldavError(c, err)
+			return
+		}
+		c.Header("ETag", resource.ETag())
+		c.Data(200, caldav.ContentType, []byte(resource.Data()))
+	}
+	engine.GET(caldavPath, get)
+	engine.HEAD(caldavPath, get)
+
+	engine.PUT(caldavPath, func(c *gin.Context) {
+		name := caldavResourceName(c)
+		if name == "" {
+			c.String(405, "Only task resources can be written")
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.String(400, "Invalid request: "+err.Error())
+			return
+		}
+		resource, created, err := caldav.Put(c, name, body, c.GetHeader("If-Match"), c.GetHeader("If-None-Match"))
+		if err != nil {
+			caldavError(c, err)
+			return
+		}
+		c.Header("ETag", resource.ETag())
+		if created {
+			c.Status(201)
+			return
+		}
+		c.Status(204)
+	})
+
+	engine.DELETE(caldavPath, func(c *gin.Context) {
+		err := caldav.Delete(c, caldavResourceName(c), c.GetHeader("If-Match"))
+		if err != nil {
+			caldavError(c, err)
+			return
+		}
+		c.Status(204)
+	})
+}
@@ -0,0 +1,45 @@
+package web
+
+import (
+	"atodo_go/calendar"
+	"atodo_go/table"
+	"atodo_go/tag_filter"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"time"
+)
+
+const calendarPath = "/calendar.ics"
+
+func InitCalendarWebInterface(engine *gin.Engine) {
+	// calendar apps subscribe with GET and pass the token as ?access_token=
+	engine.GET(calendarPath, func(c *gin.Context) {
+		filter := calendar.Filter{
+			WorkspaceID: -1,
+			Tags: tag_filter.TagFilter{
+				Include:    c.QueryArray("tag"),
+				Exclude:    c.QueryArray("exclude_tag"),
+				Expression: c.Query("expression"),
+			},
+		}
+		if workspace := c.Query("workspace"); workspace != "" {
+			id, err := strconv.Atoi(workspace)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+				return
+			}
+			if !requireRole(c, table.RoleViewer, id) {
+				return
+			}
+			filter.WorkspaceID = id
+		}
+		variant := c.DefaultQuery("variant", calendar.VariantEvent)
+		data, err := calendar.Export(c, filter, variant, time.Now())
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", `inline; filename="atodo.ics"`)
+		c.Data(200, "text/calendar; charset=utf-8", []byte(data))
+	})
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"atodo_go/digest"
+	"atodo_go/table"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+type DigestRequest struct {
+	Period string `json:"period"`
+	Format string `json:"format"`
+}
+
+type DigestSubscriptionRequest struct {
+	Period  string `json:"period"`
+	Hour    int    `json:"hour"`
+	Weekday int    `json:"weekday"`
+	Format  string `json:"format"`
+}
+
+func InitDigestWebInterface(engine *gin.Engine) {
+	engine.POST("/digest", func(c *gin.Context) {
+		request := DigestRequest{Period: table.DigestDaily, Format: digest.FormatMarkdown}
+		if c.Request.ContentLength > 0 {
+			err := c.BindJSON(&request)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+				return
+			}
+		}
+		if request.Format != "json" && !digest.IsValidFormat(request.Format) {
+			c.JSON(400, gin.H{"error": "Invalid request: unknown format " + request.Format})
+			return
+		}
+		data, err := digest.Generate(c, request.Period, time.Now())
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if request.Format == "json" {
+			c.JSON(200, data)
+			return
+		}
+		body, contentType, err := digest.Render(data, request.Format)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.Data(200, contentType, []byte(body))
+	})
+
+	engine.POST("/digest/get_subscription", func(c *gin.Context) {
+		subscription, err := table.GetDigestSubscription(c, c.GetInt(userIDKey))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		if subscription == nil {
+			c.JSON(200, gin.H{"subscription": nil})
+			return
+		}
+		c.JSON(200, gin.H{"subscription": subscription.Show()})
+	})
+
+	engine.POST("/digest/subscribe", func(c *gin.Context) {
+		var request DigestSubscriptionRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if request.Format == "" {
+			request.Format = digest.FormatText
+		}
+		if !digest.IsValidFormat(request.Format) {
+			c.JSON(400, gin.H{"error": "Invalid request: unknown format " + request.Format})
+			return
+		}
+		err = table.SetDigestSubscription(c, c.GetInt(userIDKey), request.Period, request.Hour, request.Weekday, request.Format, time.Now())
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/digest/unsubscribe", func(c *gin.Context) {
+		err := table.DeleteDigestSubscription(c, c.GetInt(userIDKey))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+}
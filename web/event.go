@@ -0,0 +1,63 @@
+package web
+
+import (
+	"atodo_go/event_bus"
+	"atodo_go/table"
+	"github.com/gin-gonic/gin"
+	"io"
+	"slices"
+	"time"
+)
+
+// eventStreamPath is a GET route because EventSource cannot POST. It cannot
+// send headers either, so the token may be passed as ?access_token=.
+const eventStreamPath = "/events/stream"
+
+const heartbeatInterval = 30 * time.Second
+
+// visibleTo reports whether the event may be sent to the user. Events of
+// tasks go to the users who could view the task's workspace when they were
+// published, so eliminations still reach them; those whose workspace could
+// not be resolved are not sent.
+func visibleTo(userID int, event event_bus.Event) bool {
+	switch event.Entity {
+	case table.EntityAppState:
+		return event.EntityID == userID
+	case table.EntityUser, table.EntityAPIKey:
+		return event.UserID == userID
+	case table.EntityTask, table.EntityTaskRelation:
+		return event.WorkspaceID != 0 && (event.Viewers == nil || slices.Contains(event.Viewers, userID))
+	}
+	return true
+}
+
+func InitEventWebInterface(engine *gin.Engine) {
+	engine.GET(eventStreamPath, func(c *gin.Context) {
+		userID := c.GetInt(userIDKey)
+		events, cancel := event_bus.Subscribe()
+		defer cancel()
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.SSEvent("ready", gin.H{"user_id": userID})
+		c.Writer.Flush()
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case event, ok := <-events:
+				if !ok {
+					return false
+				}
+				if visibleTo(userID, event) {
+					c.SSEvent(event.Type, event)
+				}
+				return true
+			case <-heartbeat.C:
+				c.SSEvent("ping", time.Now().UnixMilli())
+				return true
+			}
+		})
+	})
+}
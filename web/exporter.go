@@ -0,0 +1,57 @@
+package web
+
+import (
+	"atodo_go/backup"
+	"atodo_go/exporter"
+	"atodo_go/table"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"strings"
+)
+
+const exportPath = "/export"
+
+// exportFilename turns the task name into a file name for downloads.
+func exportFilename(name string, extension string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		name = "atodo"
+	}
+	return name + extension
+}
+
+func InitExporterWebInterface(engine *gin.Engine) {
+	// downloads with GET so links work, passing the token as ?access_token=
+	engine.GET(exportPath, func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Query("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		format, err := exporter.GetFormat(c.DefaultQuery("format", exporter.FormatMarkdown))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, id) {
+			return
+		}
+		data, name, err := exporter.Export(c, c.DefaultQuery("format", exporter.FormatMarkdown), id)
+		if errors.Is(err, backup.ErrTaskNotFound) {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+exportFilename(name, format.Extension)+`"`)
+		c.Data(200, format.ContentType, []byte(data))
+	})
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"atodo_go/focus"
+	"atodo_go/table"
+	"github.com/gin-gonic/gin"
+)
+
+func InitFocusWebInterface(engine *gin.Engine) {
+	engine.POST("/focus/start", func(c *gin.Context) {
+		session, err := focus.Start(c)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"session": session})
+	})
+
+	engine.POST("/focus/pause", func(c *gin.Context) {
+		err := focus.Pause(c)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/focus/resume", func(c *gin.Context) {
+		err := focus.Resume(c)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/focus/stop", func(c *gin.Context) {
+		err := focus.Stop(c)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/focus/get_state", func(c *gin.Context) {
+		session, err := focus.GetState(c)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"session": session})
+	})
+
+	engine.POST("/focus/get_sessions", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		sessions, err := focus.GetSessions(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"sessions": sessions})
+	})
+
+	engine.POST("/focus/get_config", func(c *gin.Context) {
+		config, err := focus.GetConfig(c)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, config)
+	})
+
+	engine.POST("/focus/set_config", func(c *gin.Context) {
+		var request focus.Config
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		err = focus.SetConfig(c, request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+}
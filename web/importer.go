@@ -0,0 +1,36 @@
+package web
+
+import (
+	"atodo_go/importer"
+	"atodo_go/table"
+	"github.com/gin-gonic/gin"
+)
+
+type ImportRequest struct {
+	Format string `json:"format"`
+	Data   string `json:"data"`
+	Name   string `json:"name"`
+	Parent int    `json:"parent"`
+	DryRun bool   `json:"dry_run"`
+}
+
+func InitImporterWebInterface(engine *gin.Engine) {
+	// format is todotxt, taskwarrior or org; parent -1 imports a new workspace
+	engine.POST("/import/external", func(c *gin.Context) {
+		var request ImportRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if request.Parent != -1 && !requireRole(c, table.RoleEditor, request.Parent) {
+			return
+		}
+		result, err := importer.Import(c, request.Format, request.Data, request.Name, request.Parent, request.DryRun)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"result": result})
+	})
+}
@@ -0,0 +1,103 @@
+package web
+
+import (
+	"atodo_go/table"
+	"atodo_go/webhook"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// inboundHookPath is public; the token in the URL and the request signature
+// authenticate the caller.
+const inboundHookPath = "/hooks/:token"
+
+const maxInboundBody = 1 << 20
+
+type InboundHookRequest struct {
+	EventName string         `json:"event_name"`
+	Match     map[string]any `json:"match"`
+}
+
+func InitInboundHookWebInterface(engine *gin.Engine) {
+	engine.POST(inboundHookPath, func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxInboundBody)
+		body, err := c.GetRawData()
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		result, err := webhook.Receive(c, c.Param("token"), c.GetHeader(webhook.TimestampHeader),
+			c.GetHeader(webhook.SignatureHeader), body, time.Now())
+		switch {
+		case errors.Is(err, webhook.ErrUnknownHook):
+			c.JSON(404, gin.H{"error": "Not found: " + err.Error()})
+		case errors.Is(err, webhook.ErrInvalidSignature), errors.Is(err, webhook.ErrStaleRequest):
+			c.JSON(401, gin.H{"error": "Unauthorized: " + err.Error()})
+		case errors.Is(err, webhook.ErrReplayedRequest):
+			c.JSON(409, gin.H{"error": "Conflict: " + err.Error()})
+		case err != nil:
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+		default:
+			c.JSON(200, result)
+		}
+	})
+
+	engine.POST("/inbound_hook/create_hook", func(c *gin.Context) {
+		var request InboundHookRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		id, token, secret, err := table.CreateInboundHook(c, c.GetInt(userIDKey), request.EventName, request.Match)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"id": id, "path": "/hooks/" + token, "secret": secret})
+	})
+
+	engine.POST("/inbound_hook/delete_hook", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		err = table.DeleteInboundHook(c, c.GetInt(userIDKey), request.ID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/inbound_hook/get_hooks", func(c *gin.Context) {
+		hooks, err := table.GetInboundHooksByUser(c, c.GetInt(userIDKey))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"hooks": hooks})
+	})
+
+	engine.POST("/inbound_hook/get_task_payloads", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		payloads, err := table.GetTaskEventPayloads(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"payloads": payloads})
+	})
+}
@@ -0,0 +1,84 @@
+package web
+
+import (
+	"atodo_go/notify"
+	"github.com/gin-gonic/gin"
+)
+
+type TestNotifierRequest struct {
+	Name string `json:"name"`
+}
+
+func InitNotifyWebInterface(engine *gin.Engine) {
+	engine.POST("/notify/get_config", func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+		c.JSON(200, notify.GetConfig().Redacted())
+	})
+
+	engine.POST("/notify/set_config", func(c *gin.Context) {
+		var request notify.Config
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireAdmin(c) {
+			return
+		}
+		err = notify.SetConfig(c, request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	// every user sets up the notifier their own digests are sent through
+	engine.POST("/notify/get_user_notifier", func(c *gin.Context) {
+		notifier, err := notify.GetUserNotifier(c, c.GetInt(userIDKey))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		if notifier == nil {
+			c.JSON(200, gin.H{"notifier": nil})
+			return
+		}
+		c.JSON(200, gin.H{"notifier": notifier.Redacted()})
+	})
+
+	engine.POST("/notify/set_user_notifier", func(c *gin.Context) {
+		var request notify.NotifierConfig
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		err = notify.SetUserNotifier(c, c.GetInt(userIDKey), request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/notify/test_notifier", func(c *gin.Context) {
+		var request TestNotifierRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireAdmin(c) {
+			return
+		}
+		err = notify.Test(request.Name)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"atodo_go/table"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+type TaskRemindersRequest struct {
+	ID      int     `json:"id"`
+	Offsets []int64 `json:"offsets"`
+}
+
+type WorkspaceRemindersRequest struct {
+	ID      int     `json:"id"`
+	Before  []int64 `json:"before"`
+	Overdue []int64 `json:"overdue"`
+}
+
+type SnoozeRequest struct {
+	ID       int   `json:"id"`
+	Duration int64 `json:"duration"`
+}
+
+func InitReminderWebInterface(engine *gin.Engine) {
+	engine.POST("/reminder/get_task_reminders", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		show, err := table.GetTaskRemindersShow(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, show)
+	})
+
+	engine.POST("/reminder/set_task_reminders", func(c *gin.Context) {
+		var request TaskRemindersRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, request.ID) {
+			return
+		}
+		err = table.SetTaskReminders(c, request.ID, request.Offsets)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/reminder/get_workspace_reminders", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		show, err := table.GetWorkspaceReminders(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, show)
+	})
+
+	engine.POST("/reminder/set_workspace_reminders", func(c *gin.Context) {
+		var request WorkspaceRemindersRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleOwner, request.ID) {
+			return
+		}
+		err = table.SetWorkspaceReminders(c, request.ID, request.Before, request.Overdue)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/reminder/snooze", func(c *gin.Context) {
+		var request SnoozeRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		until := time.Now().Add(time.Duration(request.Duration) * time.Millisecond)
+		err = table.SnoozeTaskReminders(c, request.ID, until)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok", "snoozed_until": until.UnixMilli()})
+	})
+}
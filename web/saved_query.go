@@ -0,0 +1,101 @@
+package web
+
+import (
+	"atodo_go/query"
+	"atodo_go/table"
+	"errors"
+	"github.com/gin-gonic/gin"
+)
+
+type SavedQueryRequest struct {
+	Name        string `json:"name"`
+	Query       string `json:"query"`
+	Description string `json:"description"`
+}
+
+type SavedQueryShow struct {
+	Name        string `json:"name"`
+	Query       string `json:"query"`
+	Description string `json:"description"`
+}
+
+func InitSavedQueryWebInterface(engine *gin.Engine) {
+	engine.POST("/lists/:name", func(c *gin.Context) {
+		tasks, err := query.EvaluateList(c, c.Param("name"))
+		if errors.Is(err, table.ErrSavedQueryNotFound) {
+			c.JSON(404, gin.H{"error": "Not found: " + err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"tasks": tasks})
+	})
+
+	engine.POST("/saved_query/save_query", func(c *gin.Context) {
+		var request SavedQueryRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		err = query.SaveList(c, request.Name, request.Query, request.Description)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/saved_query/delete_query", func(c *gin.Context) {
+		var request SavedQueryRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		err = table.DeleteSavedQuery(c, request.Name)
+		if errors.Is(err, table.ErrSavedQueryNotFound) {
+			c.JSON(404, gin.H{"error": "Not found: " + err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/saved_query/get_all_queries", func(c *gin.Context) {
+		savedQueries, err := table.GetAllSavedQueries(c)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		shows := make([]SavedQueryShow, 0, len(savedQueries))
+		for _, savedQuery := range savedQueries {
+			shows = append(shows, SavedQueryShow{
+				Name:        savedQuery.Name,
+				Query:       savedQuery.Query,
+				Description: savedQuery.Description,
+			})
+		}
+		c.JSON(200, gin.H{"queries": shows})
+	})
+
+	engine.POST("/saved_query/preview_query", func(c *gin.Context) {
+		var request SavedQueryRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		tasks, err := query.Evaluate(c, request.Query)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"tasks": tasks})
+	})
+}
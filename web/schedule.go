@@ -2,12 +2,38 @@ package web
 
 import (
 	"atodo_go/schedule"
+	"atodo_go/tag_filter"
 	"github.com/gin-gonic/gin"
 )
 
 func InitScheduleWebInterface(engine *gin.Engine) {
 	engine.POST("/schedule", func(c *gin.Context) {
-		data, err := schedule.Schedule()
+		var filter tag_filter.TagFilter
+		if c.Request.ContentLength > 0 {
+			err := c.BindJSON(&filter)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+				return
+			}
+		}
+		data, err := schedule.ScheduleFiltered(c, filter)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, data)
+	})
+
+	engine.POST("/schedule/assigned_to_me", func(c *gin.Context) {
+		var filter tag_filter.TagFilter
+		if c.Request.ContentLength > 0 {
+			err := c.BindJSON(&filter)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+				return
+			}
+		}
+		data, err := schedule.ScheduleAssignedTo(c, c.GetInt(userIDKey), filter)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
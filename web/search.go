@@ -0,0 +1,23 @@
+package web
+
+import (
+	"atodo_go/search"
+	"github.com/gin-gonic/gin"
+)
+
+func InitSearchWebInterface(engine *gin.Engine) {
+	engine.POST("/search", func(c *gin.Context) {
+		var request search.SearchRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		results, err := search.Search(c, request)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"results": results})
+	})
+}
@@ -0,0 +1,167 @@
+package web
+
+import (
+	"atodo_go/table"
+	"github.com/gin-gonic/gin"
+)
+
+type TagRequest struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type TaskTagRequest struct {
+	TaskID int `json:"task_id"`
+	TagID  int `json:"tag_id"`
+}
+
+type TagShow struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+func toTagShows(tags []table.Tag) []TagShow {
+	shows := make([]TagShow, 0, len(tags))
+	for _, tag := range tags {
+		shows = append(shows, TagShow{ID: tag.ID, Name: tag.Name, Color: tag.Color})
+	}
+	return shows
+}
+
+// requireTagEditor responds with 403 and returns false unless the signed in
+// user administers the server or can edit every task carrying the tag. Tags
+// are shared by all workspaces, so changing one changes those tasks too.
+func requireTagEditor(c *gin.Context, tagID int) bool {
+	ok, err := table.IsAdmin(c, c.GetInt(userIDKey))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+		return false
+	}
+	if ok {
+		return true
+	}
+	taskIDs, err := table.GetTaskIDsByTagID(c, tagID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+		return false
+	}
+	return requireRole(c, table.RoleEditor, taskIDs...)
+}
+
+func InitTagWebInterface(engine *gin.Engine) {
+	engine.POST("/tag/create_tag", func(c *gin.Context) {
+		var request TagRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		id, err := table.CreateTag(c, request.Name, request.Color)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"id": id})
+	})
+
+	engine.POST("/tag/update_tag", func(c *gin.Context) {
+		var request TagRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireTagEditor(c, request.ID) {
+			return
+		}
+		err = table.UpdateTag(c, request.ID, request.Name, request.Color)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/tag/delete_tag", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireTagEditor(c, request.ID) {
+			return
+		}
+		err = table.DeleteTag(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/tag/get_all_tags", func(c *gin.Context) {
+		tags, err := table.GetAllTags(c)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"tags": toTagShows(tags)})
+	})
+
+	engine.POST("/tag/add_tag_to_task", func(c *gin.Context) {
+		var request TaskTagRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, request.TaskID) {
+			return
+		}
+		err = table.AddTagToTask(c, request.TaskID, request.TagID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/tag/remove_tag_from_task", func(c *gin.Context) {
+		var request TaskTagRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, request.TaskID) {
+			return
+		}
+		err = table.RemoveTagFromTask(c, request.TaskID, request.TagID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/tag/get_task_tags", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		tags, err := table.GetTagsByTaskID(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"tags": toTagShows(tags)})
+	})
+}
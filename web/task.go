@@ -2,13 +2,25 @@ package web
 
 import (
 	"atodo_go/table"
+	"atodo_go/task_show"
 	"github.com/gin-gonic/gin"
+	"strconv"
 )
 
 type IDRequest struct {
 	ID int `json:"id"`
 }
 
+type CopyTaskRequest struct {
+	ID           int  `json:"id"`
+	CopyComments bool `json:"copy_comments"`
+}
+
+type AvailableFromRequest struct {
+	ID            int   `json:"id"`
+	AvailableFrom int64 `json:"available_from"`
+}
+
 type TaskDefaultRequest struct {
 	Name       string `json:"name"`
 	Goal       string `json:"goal"`
@@ -24,7 +36,10 @@ func InitTaskWebInterface(engine *gin.Engine) {
 			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
 			return
 		}
-		err = table.EliminateTask(request.ID)
+		if !requireRole(c, table.RoleEditor, request.ID) {
+			return
+		}
+		err = table.EliminateTask(c, request.ID)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -39,7 +54,64 @@ func InitTaskWebInterface(engine *gin.Engine) {
 			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
 			return
 		}
-		err = table.CompleteTask(request.ID)
+		if !requireRole(c, table.RoleEditor, request.ID) {
+			return
+		}
+		err = table.CompleteTask(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/task/history", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		history, err := table.GetTaskHistory(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, history)
+	})
+
+	engine.POST("/task/fire_event_trigger", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, request.ID) {
+			return
+		}
+		err = table.FireEventTrigger(c, request.ID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/task/set_available_from", func(c *gin.Context) {
+		var request AvailableFromRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, request.ID) {
+			return
+		}
+		err = table.UpdateTaskAvailableFrom(c, request.ID, request.AvailableFrom)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -53,8 +125,16 @@ func InitTaskWebInterface(engine *gin.Engine) {
 			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
+		if !requireViewingRole(c, table.RoleEditor) {
+			return
+		}
 
-		id, err := table.CreateTask(request.Name, request.Goal, request.Deadline, request.InWorkTime) // replace with your actual function
+		id, err := table.CreateTask(c, request.Name, request.Goal, request.Deadline, request.InWorkTime) // replace with your actual function
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		err = task_show.PlaceTask(c, id)
 		if err != nil {
 			c.JSON(500, gin.H{"error": err.Error()})
 			return
@@ -69,7 +149,10 @@ func InitTaskWebInterface(engine *gin.Engine) {
 			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
 			return
 		}
-		task, err := table.GetDetailedTask(request.ID)
+		if !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		task, err := table.GetDetailedTask(c, request.ID)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -84,7 +167,11 @@ func InitTaskWebInterface(engine *gin.Engine) {
 			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
 			return
 		}
-		err = table.SetDetailedTask(taskDetail)
+		// the task is moved below the task being viewed
+		if !requireRole(c, table.RoleEditor, taskDetail.Task.ID) || !requireViewingRole(c, table.RoleEditor) {
+			return
+		}
+		err = table.SetDetailedTask(c, taskDetail)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -99,7 +186,19 @@ func InitTaskWebInterface(engine *gin.Engine) {
 			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
 			return
 		}
-		err = table.UpdatePositions(request)
+		ids := make([]int, 0, len(request.TaskUIs))
+		for _, taskUI := range request.TaskUIs {
+			id, err := strconv.Atoi(taskUI.ID)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+				return
+			}
+			ids = append(ids, id)
+		}
+		if !requireRole(c, table.RoleEditor, ids...) {
+			return
+		}
+		err = table.UpdatePositions(c, request)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -108,13 +207,21 @@ func InitTaskWebInterface(engine *gin.Engine) {
 	})
 
 	engine.POST("/task/copy_task", func(c *gin.Context) {
-		var request IDRequest
+		var request CopyTaskRequest
 		err := c.BindJSON(&request)
 		if err != nil {
 			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
 			return
 		}
-		_, err = table.CopyTask(request.ID)
+		if !requireRole(c, table.RoleViewer, request.ID) || !requireViewingRole(c, table.RoleEditor) {
+			return
+		}
+		id, err := table.CopyTaskWithOptions(c, request.ID, table.CopyOptions{Comments: request.CopyComments})
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		err = task_show.PlaceTask(c, id)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -0,0 +1,105 @@
+package web
+
+import (
+	"atodo_go/table"
+	"errors"
+	"github.com/gin-gonic/gin"
+)
+
+type TaskCommentRequest struct {
+	ID      int    `json:"id"`
+	TaskID  int    `json:"task_id"`
+	Content string `json:"content"`
+}
+
+func InitTaskCommentWebInterface(engine *gin.Engine) {
+	engine.POST("/task_comment/add_comment", func(c *gin.Context) {
+		var request TaskCommentRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, request.TaskID) {
+			return
+		}
+		id, err := table.AddTaskComment(c, request.TaskID, request.Content)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"id": id})
+	})
+
+	engine.POST("/task_comment/update_comment", func(c *gin.Context) {
+		var request TaskCommentRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		comment, err := table.GetTaskComment(c, request.ID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, comment.TaskID) {
+			return
+		}
+		err = table.UpdateTaskComment(c, request.ID, request.Content)
+		if errors.Is(err, table.ErrForbidden) {
+			c.JSON(403, gin.H{"error": "Forbidden: " + err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/task_comment/delete_comment", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		comment, err := table.GetTaskComment(c, request.ID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, comment.TaskID) {
+			return
+		}
+		err = table.DeleteTaskComment(c, request.ID)
+		if errors.Is(err, table.ErrForbidden) {
+			c.JSON(403, gin.H{"error": "Forbidden: " + err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/task_comment/get_comments", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		comments, err := table.GetTaskComments(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"comments": comments})
+	})
+}
@@ -0,0 +1,191 @@
+package web
+
+import (
+	"atodo_go/table"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+type TaskNoteRequest struct {
+	ID      int    `json:"id"`
+	Content string `json:"content"`
+}
+
+type ChecklistItemRequest struct {
+	ID      int    `json:"id"`
+	TaskID  int    `json:"task_id"`
+	Text    string `json:"text"`
+	Checked bool   `json:"checked"`
+}
+
+func InitTaskNoteWebInterface(engine *gin.Engine) {
+	engine.POST("/task_note/set_note", func(c *gin.Context) {
+		var request TaskNoteRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, request.ID) {
+			return
+		}
+		err = table.SetTaskNote(c, request.ID, request.Content)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/task_note/get_note_revisions", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		revisions, err := table.GetTaskNoteRevisions(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"revisions": revisions})
+	})
+
+	engine.POST("/task_note/add_checklist_item", func(c *gin.Context) {
+		var request ChecklistItemRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, request.TaskID) {
+			return
+		}
+		id, err := table.AddChecklistItem(c, request.TaskID, request.Text)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"id": id})
+	})
+
+	engine.POST("/task_note/update_checklist_item", func(c *gin.Context) {
+		var request ChecklistItemRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		item, err := table.GetChecklistItem(c, request.ID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, item.TaskID) {
+			return
+		}
+		err = table.UpdateChecklistItem(c, request.ID, request.Text, request.Checked)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/task_note/delete_checklist_item", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		item, err := table.GetChecklistItem(c, request.ID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, item.TaskID) {
+			return
+		}
+		err = table.DeleteChecklistItem(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	// upload_attachment takes a multipart form with task_id and file fields.
+	engine.POST("/task_note/upload_attachment", func(c *gin.Context) {
+		taskID, err := strconv.Atoi(c.PostForm("task_id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, taskID) {
+			return
+		}
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		defer file.Close()
+		id, err := table.AddAttachment(c, taskID, fileHeader.Filename, fileHeader.Header.Get("Content-Type"), file)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"id": id})
+	})
+
+	engine.POST("/task_note/download_attachment", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		attachment, err := table.GetAttachment(c, request.ID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, attachment.TaskID) {
+			return
+		}
+		c.FileAttachment(attachment.Path(), attachment.FileName)
+	})
+
+	engine.POST("/task_note/delete_attachment", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		attachment, err := table.GetAttachment(c, request.ID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, attachment.TaskID) {
+			return
+		}
+		err = table.DeleteAttachment(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+}
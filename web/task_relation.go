@@ -20,8 +20,11 @@ func InitTaskRelationWebInterface(engine *gin.Engine) {
 			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
+		if !requireRole(c, table.RoleEditor, request.TaskRelation.Source, request.TaskRelation.Target) {
+			return
+		}
 
-		err := table.AddRelationDefault(request.TaskRelation.Source, request.TaskRelation.Target)
+		err := table.AddRelationDefault(c, request.TaskRelation.Source, request.TaskRelation.Target)
 		if err != nil {
 			c.JSON(500, gin.H{"error": err.Error()})
 			return
@@ -37,7 +40,10 @@ func InitTaskRelationWebInterface(engine *gin.Engine) {
 			return
 		}
 		fmt.Println(request)
-		err = table.DeleteRelation(request.TaskRelation.Source, request.TaskRelation.Target)
+		if !requireRole(c, table.RoleEditor, request.TaskRelation.Source, request.TaskRelation.Target) {
+			return
+		}
+		err = table.DeleteRelation(c, request.TaskRelation.Source, request.TaskRelation.Target)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -1,13 +1,26 @@
 package web
 
 import (
+	"atodo_go/table"
+	"atodo_go/tag_filter"
 	"atodo_go/task_show"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"time"
 )
 
+type ExportGraphRequest struct {
+	ID        int    `json:"id"`
+	Format    string `json:"format"`
+	Recursive bool   `json:"recursive"`
+}
+
 func InitTaskShowWebInterface(engine *gin.Engine) {
 	engine.POST("/task_show/get_show_stack", func(c *gin.Context) {
-		stack, err := task_show.GetShowStack()
+		if !requireViewingRole(c, table.RoleViewer) {
+			return
+		}
+		stack, err := task_show.GetShowStack(c)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
@@ -16,11 +29,63 @@ func InitTaskShowWebInterface(engine *gin.Engine) {
 	})
 
 	engine.POST("/task_show/get_show_data", func(c *gin.Context) {
-		data, err := task_show.GetShowData()
+		var filter tag_filter.TagFilter
+		if c.Request.ContentLength > 0 {
+			err := c.BindJSON(&filter)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+				return
+			}
+		}
+		data, err := task_show.GetShowDataFiltered(c, filter)
+		if errors.Is(err, table.ErrForbidden) {
+			c.JSON(403, gin.H{"error": "Forbidden: " + err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
 			return
 		}
 		c.JSON(200, data)
 	})
+
+	engine.POST("/task_show/export_graph", func(c *gin.Context) {
+		var request ExportGraphRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !task_show.IsGraphFormat(request.Format) {
+			c.JSON(400, gin.H{"error": "Invalid request: " + task_show.ErrUnknownGraphFormat.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		graph, err := task_show.ExportGraph(c, request.Format, request.ID, request.Recursive, time.Now())
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"format": request.Format, "graph": graph})
+	})
+
+	engine.POST("/task_show/auto_layout", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, request.ID) {
+			return
+		}
+		positions, err := task_show.AutoLayout(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"positions": positions})
+	})
 }
@@ -0,0 +1,156 @@
+package web
+
+import (
+	"atodo_go/table"
+	"atodo_go/time_tracking"
+	"errors"
+	"github.com/gin-gonic/gin"
+)
+
+type TimeEntryRequest struct {
+	ID        int   `json:"id"`
+	TaskID    int   `json:"task_id"`
+	StartTime int64 `json:"start_time"`
+	EndTime   int64 `json:"end_time"`
+}
+
+type TimeRangeRequest struct {
+	TaskID int   `json:"task_id"`
+	From   int64 `json:"from"`
+	To     int64 `json:"to"`
+}
+
+type TimeReportRequest struct {
+	GroupBy string `json:"group_by"`
+	TaskID  int    `json:"task_id"`
+	From    int64  `json:"from"`
+	To      int64  `json:"to"`
+}
+
+func InitTimeTrackingWebInterface(engine *gin.Engine) {
+	engine.POST("/time_tracking/add_entry", func(c *gin.Context) {
+		var request TimeEntryRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, request.TaskID) {
+			return
+		}
+		id, err := table.AddTimeEntry(c, request.TaskID, request.StartTime, request.EndTime)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"id": id})
+	})
+
+	engine.POST("/time_tracking/update_entry", func(c *gin.Context) {
+		var request TimeEntryRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		entry, err := table.GetTimeEntry(c, request.ID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, entry.TaskID) {
+			return
+		}
+		err = table.UpdateTimeEntry(c, request.ID, request.StartTime, request.EndTime)
+		if errors.Is(err, table.ErrForbidden) {
+			c.JSON(403, gin.H{"error": "Forbidden: " + err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/time_tracking/delete_entry", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		entry, err := table.GetTimeEntry(c, request.ID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, entry.TaskID) {
+			return
+		}
+		err = table.DeleteTimeEntry(c, request.ID)
+		if errors.Is(err, table.ErrForbidden) {
+			c.JSON(403, gin.H{"error": "Forbidden: " + err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/time_tracking/get_entries", func(c *gin.Context) {
+		var request TimeRangeRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if request.TaskID > 0 && !requireRole(c, table.RoleViewer, request.TaskID) {
+			return
+		}
+		entries, err := time_tracking.GetEntries(c, request.TaskID, request.From, request.To)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"entries": entries})
+	})
+
+	engine.POST("/time_tracking/get_total", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		total, err := time_tracking.GetTotal(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"total": total})
+	})
+
+	engine.POST("/time_tracking/report", func(c *gin.Context) {
+		var request TimeReportRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if request.TaskID > 0 && !requireRole(c, table.RoleViewer, request.TaskID) {
+			return
+		}
+		report, err := time_tracking.GetReport(c, request.GroupBy, request.TaskID, request.From, request.To)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, report)
+	})
+}
@@ -1,23 +1,102 @@
 package web
 
 import (
+	"atodo_go/table"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"log"
+	"strings"
+	"time"
 )
 
+// auditSourceMiddleware attributes every mutation made while handling a
+// request to the route that handled it and to the signed in user, whose
+// AppState is used for the request.
+func auditSourceMiddleware(c *gin.Context) {
+	actor := table.Actor{Source: "http:" + c.FullPath(), UserID: c.GetInt(userIDKey)}
+	c.Request = c.Request.WithContext(table.WithActor(c.Request.Context(), actor))
+	c.Next()
+}
+
+// redactQuery drops access_token from the query of a logged path, so tokens
+// passed in the URL do not end up in the request log.
+func redactQuery(path string) string {
+	base, query, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+	kept := make([]string, 0)
+	for _, parameter := range strings.Split(query, "&") {
+		if parameter != "access_token" && !strings.HasPrefix(parameter, "access_token=") {
+			kept = append(kept, parameter)
+		}
+	}
+	if len(kept) == 0 {
+		return base
+	}
+	return base + "?" + strings.Join(kept, "&")
+}
+
+// logFormatter is gin's default request log line with the path redacted.
+func logFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		redactQuery(param.Path),
+		param.ErrorMessage,
+	)
+}
+
 func InitWebInterface() *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.LoggerWithFormatter(logFormatter), gin.Recovery())
+	// handlers pass the gin context on, which resolves the actor from the request
+	router.ContextWithFallback = true
 	err := router.SetTrustedProxies([]string{"127.0.0.1"})
 	if err != nil {
 		return nil
 	}
+	router.Use(authMiddleware, auditSourceMiddleware)
+	InitAuthWebInterface(router)
 	InitAppStateWebInterface(router)
 	InitTaskWebInterface(router)
 	InitTaskRelationWebInterface(router)
 	InitTaskShowWebInterface(router)
 	InitScheduleWebInterface(router)
 	InitAppWebInterface(router)
+	InitAuditWebInterface(router)
+	InitTimeTrackingWebInterface(router)
+	InitFocusWebInterface(router)
+	InitTagWebInterface(router)
+	InitSearchWebInterface(router)
+	InitSavedQueryWebInterface(router)
+	InitTaskNoteWebInterface(router)
+	InitTaskCommentWebInterface(router)
+	InitWorkspaceWebInterface(router)
+	InitEventWebInterface(router)
+	InitWebhookWebInterface(router)
+	InitInboundHookWebInterface(router)
+	InitNotifyWebInterface(router)
+	InitReminderWebInterface(router)
+	InitDigestWebInterface(router)
+	InitCalendarWebInterface(router)
+	InitCaldavWebInterface(router)
+	InitBackupWebInterface(router)
+	InitImporterWebInterface(router)
+	InitExporterWebInterface(router)
 	return router
 }
 
@@ -0,0 +1,153 @@
+package web
+
+import (
+	"atodo_go/table"
+	"atodo_go/webhook"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+type WebhookRequest struct {
+	ID     int      `json:"id"`
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	Active bool     `json:"active"`
+}
+
+type WebhookDeliveriesRequest struct {
+	ID       int `json:"id"`
+	Page     int `json:"page"`
+	PageSize int `json:"page_size"`
+}
+
+func validWebhookEvents(events []string) bool {
+	for _, event := range events {
+		known := false
+		for _, e := range webhook.Events {
+			known = known || e == event
+		}
+		if !known {
+			return false
+		}
+	}
+	return true
+}
+
+func InitWebhookWebInterface(engine *gin.Engine) {
+	engine.POST("/webhook/get_events", func(c *gin.Context) {
+		c.JSON(200, gin.H{"events": webhook.Events})
+	})
+
+	engine.POST("/webhook/create_webhook", func(c *gin.Context) {
+		var request WebhookRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !validWebhookEvents(request.Events) {
+			c.JSON(400, gin.H{"error": "Invalid request: unknown event"})
+			return
+		}
+		id, secret, err := table.CreateWebhook(c, c.GetInt(userIDKey), request.URL, request.Secret, request.Events)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"id": id, "secret": secret})
+	})
+
+	engine.POST("/webhook/update_webhook", func(c *gin.Context) {
+		var request WebhookRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !validWebhookEvents(request.Events) {
+			c.JSON(400, gin.H{"error": "Invalid request: unknown event"})
+			return
+		}
+		err = table.UpdateWebhook(c, c.GetInt(userIDKey), request.ID, request.URL, request.Events, request.Active)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/webhook/delete_webhook", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		err = table.DeleteWebhook(c, c.GetInt(userIDKey), request.ID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/webhook/get_webhooks", func(c *gin.Context) {
+		webhooks, err := table.GetWebhooksByUser(c, c.GetInt(userIDKey))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"webhooks": webhooks})
+	})
+
+	engine.POST("/webhook/test_webhook", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		id, err := webhook.SendTest(c, c.GetInt(userIDKey), request.ID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"delivery_id": id})
+	})
+
+	engine.POST("/webhook/get_deliveries", func(c *gin.Context) {
+		var request WebhookDeliveriesRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		_, err = table.GetWebhook(c, c.GetInt(userIDKey), request.ID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		deliveries, err := table.GetWebhookDeliveries(c, request.ID, request.Page, request.PageSize)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"deliveries": deliveries})
+	})
+
+	engine.POST("/webhook/retry_delivery", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		err = table.RetryWebhookDelivery(c, c.GetInt(userIDKey), request.ID, time.Now())
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+}
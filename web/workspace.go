@@ -0,0 +1,169 @@
+package web
+
+import (
+	"atodo_go/table"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+type ShareWorkspaceRequest struct {
+	WorkspaceID int    `json:"workspace_id"`
+	UserID      int    `json:"user_id"`
+	Role        string `json:"role"`
+}
+
+type AssignTaskRequest struct {
+	TaskID int `json:"task_id"`
+	UserID int `json:"user_id"`
+}
+
+// requireRole responds with 403 and returns false unless the signed in user
+// has at least the role on the workspaces of all the tasks.
+func requireRole(c *gin.Context, role string, taskIDs ...int) bool {
+	for _, id := range taskIDs {
+		ok, err := table.HasWorkspaceRole(c, c.GetInt(userIDKey), id, role)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: task " + strconv.Itoa(id) + ": " + err.Error()})
+			return false
+		}
+		if !ok {
+			c.JSON(403, gin.H{"error": "Forbidden: " + table.ErrForbidden.Error()})
+			return false
+		}
+	}
+	return true
+}
+
+// requireViewingRole is requireRole for the task being viewed, which new and
+// copied tasks are added to. Nothing is required at the top level.
+func requireViewingRole(c *gin.Context, role string) bool {
+	nowViewingTask, err := table.GetNowViewingTask(c)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+		return false
+	}
+	if nowViewingTask == -1 {
+		return true
+	}
+	return requireRole(c, role, nowViewingTask)
+}
+
+func InitWorkspaceWebInterface(engine *gin.Engine) {
+	engine.POST("/workspace/get_workspaces", func(c *gin.Context) {
+		workspaces, err := table.GetAccessibleWorkspaces(c, c.GetInt(userIDKey))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"workspaces": workspaces})
+	})
+
+	engine.POST("/workspace/get_members", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		members, err := table.GetWorkspaceMembers(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"members": members})
+	})
+
+	engine.POST("/workspace/share", func(c *gin.Context) {
+		var request ShareWorkspaceRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleOwner, request.WorkspaceID) {
+			return
+		}
+		err = table.ShareWorkspace(c, request.WorkspaceID, request.UserID, request.Role)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/workspace/unshare", func(c *gin.Context) {
+		var request ShareWorkspaceRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		// members may always leave a workspace themselves
+		if request.UserID != c.GetInt(userIDKey) && !requireRole(c, table.RoleOwner, request.WorkspaceID) {
+			return
+		}
+		err = table.UnshareWorkspace(c, request.WorkspaceID, request.UserID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/workspace/assign_task", func(c *gin.Context) {
+		var request AssignTaskRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, request.TaskID) {
+			return
+		}
+		err = table.AssignTask(c, request.TaskID, request.UserID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/workspace/unassign_task", func(c *gin.Context) {
+		var request AssignTaskRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleEditor, request.TaskID) {
+			return
+		}
+		err = table.UnassignTask(c, request.TaskID, request.UserID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
+	engine.POST("/workspace/get_assignees", func(c *gin.Context) {
+		var request IDRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if !requireRole(c, table.RoleViewer, request.ID) {
+			return
+		}
+		assignees, err := table.GetTaskAssignees(c, request.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal error: " + err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"assignees": assignees})
+	})
+}
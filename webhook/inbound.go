@@ -0,0 +1,129 @@
+package webhook
+
+import (
+	"atodo_go/table"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimestampHeader carries the Unix time in seconds at which an inbound
+// request was signed. The signature covers "<timestamp>.<body>".
+const TimestampHeader = "X-Atodo-Timestamp"
+
+// replayWindow is how far the timestamp of an inbound request may be from now.
+const replayWindow = 5 * time.Minute
+
+var (
+	ErrUnknownHook      = errors.New("unknown hook")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrStaleRequest     = errors.New("timestamp outside the accepted window")
+	ErrReplayedRequest  = errors.New("request was already received")
+)
+
+type InboundResult struct {
+	Matched bool  `json:"matched"`
+	Fired   []int `json:"fired"`
+}
+
+// SignInbound returns the signature header value an external system must send
+// with an inbound request.
+func SignInbound(secret string, timestamp string, body []byte) string {
+	return Sign(secret, append([]byte(timestamp+"."), body...))
+}
+
+// lookup follows a dotted path such as "build.status" into a JSON document.
+func lookup(document any, path string) (any, bool) {
+	current := document
+	for _, key := range strings.Split(path, ".") {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = object[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Matches reports whether every path in match has the expected value in the
+// JSON body. An empty match accepts every body, including non-JSON ones.
+func Matches(match map[string]any, body []byte) bool {
+	if len(match) == 0 {
+		return true
+	}
+	var document any
+	err := json.Unmarshal(body, &document)
+	if err != nil {
+		return false
+	}
+	for path, expected := range match {
+		actual, ok := lookup(document, path)
+		if !ok || !reflect.DeepEqual(actual, expected) {
+			return false
+		}
+	}
+	return true
+}
+
+func verifyInbound(ctx context.Context, hook *table.InboundHook, timestamp string, signature string, body []byte, now time.Time) error {
+	expected := SignInbound(hook.Secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrStaleRequest
+	}
+	signedAt := time.Unix(seconds, 0)
+	if signedAt.Before(now.Add(-replayWindow)) || signedAt.After(now.Add(replayWindow)) {
+		return ErrStaleRequest
+	}
+	sum := sha256.Sum256([]byte(signature))
+	fresh, err := table.RecordInboundHookReceipt(ctx, hook.ID, hex.EncodeToString(sum[:]), now, now.Add(-2*replayWindow))
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrReplayedRequest
+	}
+	return nil
+}
+
+// Receive handles a call to the secret URL of an inbound hook: it checks the
+// signature and timestamp, rejects replays and fires the hook's Event
+// triggers if the body matches, on behalf of the hook's user.
+func Receive(ctx context.Context, token string, timestamp string, signature string, body []byte, now time.Time) (InboundResult, error) {
+	result := InboundResult{Fired: []int{}}
+	hook, err := table.GetInboundHookByToken(ctx, token)
+	if err != nil {
+		return result, ErrUnknownHook
+	}
+	err = verifyInbound(ctx, hook, timestamp, signature, body, now)
+	if err != nil {
+		return result, err
+	}
+	if !Matches(hook.GetMatch(), body) {
+		return result, nil
+	}
+	result.Matched = true
+	ctx = table.WithActor(ctx, table.Actor{Source: "inbound_hook:" + strconv.Itoa(hook.ID), UserID: hook.UserID})
+	result.Fired, err = table.FireEventTriggersByName(ctx, hook.UserID, hook.EventName, string(body), now)
+	if err != nil {
+		return result, err
+	}
+	err = table.MarkInboundHookFired(ctx, hook.ID, now)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
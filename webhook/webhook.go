@@ -0,0 +1,162 @@
+package webhook
+
+import (
+	"atodo_go/table"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Atodo-Signature"
+	EventHeader     = "X-Atodo-Event"
+	DeliveryHeader  = "X-Atodo-Delivery"
+)
+
+// PingEvent is sent by SendTest to check that a webhook is reachable.
+const PingEvent = "ping"
+
+// Events lists the events webhooks can subscribe to.
+var Events = table.WebhookEvents
+
+const (
+	maxAttempts     = 8
+	baseBackoff     = 30 * time.Second
+	maxBackoff      = time.Hour
+	pollInterval    = 5 * time.Second
+	overdueInterval = time.Minute
+	batchSize       = 20
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+type (
+	TaskPayload = table.WebhookTaskPayload
+	Payload     = table.WebhookPayload
+)
+
+// Sign returns the value of the signature header for the body: the hex
+// encoded HMAC-SHA256 of the body keyed with the webhook secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns how long to wait before the next attempt after the given
+// number of failed attempts.
+func Backoff(attempts int) time.Duration {
+	backoff := baseBackoff
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
+
+// SendTest queues a ping for one of the user's webhooks.
+func SendTest(ctx context.Context, userID int, webhookID int) (int, error) {
+	_, err := table.GetWebhook(ctx, userID, webhookID)
+	if err != nil {
+		return -1, err
+	}
+	now := time.Now()
+	body, err := json.Marshal(Payload{Event: PingEvent, Time: now.UnixMilli()})
+	if err != nil {
+		return -1, err
+	}
+	return table.EnqueueWebhookDelivery(ctx, webhookID, PingEvent, string(body), now)
+}
+
+func deliver(webhook *table.Webhook, delivery table.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(SignatureHeader, Sign(webhook.Secret, body))
+	request.Header.Set(EventHeader, delivery.Event)
+	request.Header.Set(DeliveryHeader, strconv.Itoa(delivery.ID))
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// ProcessDue attempts every pending delivery that is due and returns how
+// many were attempted. Failed attempts are retried with exponential backoff
+// until maxAttempts is reached.
+func ProcessDue(ctx context.Context, now time.Time) (int, error) {
+	deliveries, err := table.GetDueWebhookDeliveries(ctx, now, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, delivery := range deliveries {
+		webhook, err := table.GetWebhookByID(ctx, delivery.WebhookID)
+		if err != nil {
+			return 0, err
+		}
+		statusCode, err := deliver(webhook, delivery)
+		delivery.Attempts++
+		delivery.LastStatusCode = statusCode
+		if err == nil {
+			delivery.Status = table.DeliverySucceeded
+			delivery.LastError = ""
+			delivery.DeliveredAt = &now
+		} else {
+			delivery.LastError = err.Error()
+			if delivery.Attempts >= maxAttempts {
+				delivery.Status = table.DeliveryFailed
+			} else {
+				delivery.NextAttemptAt = now.Add(Backoff(delivery.Attempts))
+			}
+		}
+		err = table.SaveWebhookDelivery(ctx, delivery)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// Start reports overdue tasks and delivers the queue in the background.
+// Deliveries are queued by the table package along with the changes they
+// report.
+func Start(ctx context.Context) {
+	go func() {
+		poll := time.NewTicker(pollInterval)
+		overdue := time.NewTicker(overdueInterval)
+		for {
+			select {
+			case now := <-poll.C:
+				_, err := ProcessDue(ctx, now)
+				if err != nil {
+					log.Println("Failed to deliver webhooks: ", err)
+				}
+			case now := <-overdue.C:
+				_, err := table.MarkOverdueTasks(table.WithSource(ctx, table.SourceScheduler), now)
+				if err != nil {
+					log.Println("Failed to check overdue tasks: ", err)
+				}
+			}
+		}
+	}()
+}